@@ -7,21 +7,31 @@ import (
 	"agent/internal/handler"
 	"agent/internal/library/httpclient"
 	"agent/internal/middleware"
+	"agent/internal/model"
 	"agent/internal/repository"
 	"agent/internal/service"
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mrheza/distributed-config-management/shared/health"
+	"github.com/mrheza/distributed-config-management/shared/signing"
+	"github.com/mrheza/distributed-config-management/shared/tracing"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// version is reported to the controller on registration. Override at build
+// time with -ldflags "-X main.version=<version>".
+var version = "dev"
+
 // @title Agent API
 // @version 1.0
 // @description Agent service for controller polling and worker sync
@@ -32,32 +42,68 @@ func main() {
 		log.Fatal(err)
 	}
 	log.Printf(
-		"event=agent_config_loaded port=%s gin_mode=%s controller_base_url=%s worker_base_url=%s poll_url=%s poll_interval_secs=%d max_backoff_secs=%d jitter_pct=%d timeout_secs=%d",
+		"event=agent_config_loaded port=%s gin_mode=%s controller_base_url=%s worker_base_url=%s namespace=%s labels=%q version=%s sync_mode=%s poll_url=%s poll_interval_secs=%d long_poll_wait_secs=%d max_backoff_secs=%d jitter_pct=%d timeout_secs=%d trace_exporter=%s",
 		cfg.Port,
 		cfg.GinMode,
 		cfg.ControllerBaseURL,
 		cfg.WorkerBaseURL,
+		cfg.Namespace,
+		cfg.Labels,
+		version,
+		cfg.SyncMode,
 		cfg.PollURL,
 		cfg.PollIntervalSeconds,
+		cfg.LongPollWaitSeconds,
 		cfg.MaxBackoffSeconds,
 		cfg.BackoffJitterPercent,
 		cfg.RequestTimeoutSeconds,
+		cfg.TraceExporter,
 	)
 	gin.SetMode(cfg.GinMode)
 
+	shutdownTracing, err := tracing.Init(context.Background(), "agent", cfg.TraceExporter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("event=agent_hostname_unavailable err=%v", err)
+	}
+
 	httpClient := httpclient.New(cfg.RequestTimeoutSeconds)
-	controllerClient := client.NewControllerClient(cfg.ControllerBaseURL, cfg.ControllerAPIKey, httpClient)
+	longPollClient := httpclient.New(cfg.RequestTimeoutSeconds + cfg.LongPollWaitSeconds)
+	controllerClient := client.NewControllerClient(cfg.ControllerBaseURL, cfg.ControllerAPIKey, httpClient, longPollClient)
 	workerClient := client.NewWorkerClient(cfg.WorkerBaseURL, cfg.WorkerAPIKey, httpClient)
 	stateRepo := repository.NewFileStateRepository(cfg.StatePath)
 
+	var verifyKey ed25519.PublicKey
+	if cfg.PublicKeyFile != "" {
+		verifyKey, err = signing.LoadPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	agentSvc := service.NewAgentService(
 		controllerClient,
 		workerClient,
 		stateRepo,
+		model.RegisterRequest{
+			Namespace:       cfg.Namespace,
+			Hostname:        hostname,
+			Version:         version,
+			Labels:          cfg.LabelMap(),
+			EnrollmentToken: cfg.EnrollmentToken,
+		},
 		cfg.PollURL,
 		cfg.PollIntervalSeconds,
 		cfg.MaxBackoffSeconds,
 		cfg.BackoffJitterPercent,
+		cfg.LongPollWaitSeconds,
+		cfg.SyncMode == config.SyncModeStream,
+		verifyKey,
+		cfg.ReadyPollIntervals,
 	)
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -68,10 +114,18 @@ func main() {
 	h := handler.New(agentSvc)
 	r := gin.New()
 	r.Use(middleware.RequestLogger())
+	r.Use(middleware.Metrics())
+	r.Use(middleware.Tracing("agent"))
 	r.Use(gin.Recovery())
 	r.Use(middleware.CORSMiddleware())
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.GET("/metrics", middleware.MetricsHandler())
 	r.GET("/state", h.GetState)
+	r.GET("/healthz", health.Liveness())
+	r.GET("/readyz", health.Readiness(
+		health.Check{Name: "bootstrap", Run: agentSvc.CheckBootstrap},
+		health.Check{Name: "poll", Run: agentSvc.CheckPoll},
+	))
 
 	addr := ":" + cfg.Port
 	srv := &http.Server{
@@ -91,4 +145,10 @@ func main() {
 	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("tracing shutdown error: %v", err)
+	}
 }
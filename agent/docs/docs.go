@@ -42,15 +42,33 @@ const docTemplate = `{
                 "agent_id": {
                     "type": "string"
                 },
+                "config_data": {
+                    "type": "object"
+                },
+                "config_secrets": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "config_signature": {
+                    "type": "string"
+                },
                 "config_url": {
                     "type": "string"
                 },
+                "credential": {
+                    "type": "string"
+                },
                 "etag": {
                     "type": "string"
                 },
                 "last_config_version": {
                     "type": "integer"
                 },
+                "namespace": {
+                    "type": "string"
+                },
                 "poll_interval_seconds": {
                     "type": "integer"
                 },
@@ -3,36 +3,67 @@ package client
 import (
 	"agent/internal/library/httpclient"
 	"agent/internal/model"
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type ControllerClient interface {
-	Register(ctx context.Context, existingAgentID string) (*model.RegisterResponse, error)
-	GetConfig(ctx context.Context, agentID, etag, pollURL string) (*model.Config, string, int, error)
+	Register(ctx context.Context, existingAgentID, credential string, req *model.RegisterRequest) (*model.RegisterResponse, error)
+	GetConfig(ctx context.Context, agentID, credential, etag, pollURL string, wait time.Duration) (*model.Config, string, int, error)
+	ReportStatus(ctx context.Context, agentID, credential string, report *model.StatusReport) error
+	StreamConfig(ctx context.Context, agentID, credential string, lastVersion int, onConfig func(cfg *model.Config, etag string) error, onActivity func()) error
 }
 
+// StreamIdleTimeout ends a config stream that received neither an event nor a
+// keep-alive for three of the controller's 25 second keep-alive intervals, so
+// a half-open connection falls back to polling instead of blocking forever.
+const StreamIdleTimeout = 75 * time.Second
+
 type controllerClient struct {
-	baseURL string
-	apiKey  string
-	http    *httpclient.Client
+	baseURL  string
+	apiKey   string
+	http     *httpclient.Client
+	longPoll *httpclient.Client
+	stream   *httpclient.Client
+	// streamIdleTimeout is StreamIdleTimeout, shortened in tests.
+	streamIdleTimeout time.Duration
 }
 
-func NewControllerClient(baseURL, apiKey string, httpClient *httpclient.Client) ControllerClient {
+// NewControllerClient uses longPollClient for GetConfig calls that ask the
+// controller to wait, so its timeout must exceed the longest wait.
+func NewControllerClient(baseURL, apiKey string, httpClient, longPollClient *httpclient.Client) ControllerClient {
 	return &controllerClient{
-		baseURL: baseURL,
-		apiKey:  apiKey,
-		http:    httpClient,
+		baseURL:  baseURL,
+		apiKey:   apiKey,
+		http:     httpClient,
+		longPoll: longPollClient,
+		// Streams stay open indefinitely; the request context or the idle
+		// timeout ends them.
+		stream:            httpclient.New(0),
+		streamIdleTimeout: StreamIdleTimeout,
 	}
 }
 
-func (c *controllerClient) Register(ctx context.Context, existingAgentID string) (*model.RegisterResponse, error) {
+// Register re-registers existingAgentID when credential is set and otherwise
+// enrolls with req.EnrollmentToken; the controller picks whichever is valid.
+func (c *controllerClient) Register(ctx context.Context, existingAgentID, credential string, req *model.RegisterRequest) (*model.RegisterResponse, error) {
 	var out model.RegisterResponse
 	resp, err := c.http.DoJSON(ctx, http.MethodPost, c.baseURL+"/register", map[string]string{
-		"X-API-Key":  c.apiKey,
-		"X-Agent-ID": existingAgentID,
-	}, nil, &out)
+		"X-API-Key":          c.apiKey,
+		"X-Agent-ID":         existingAgentID,
+		"X-Agent-Credential": credential,
+		"X-Enrollment-Token": req.EnrollmentToken,
+	}, req, &out)
 	if err != nil {
 		return nil, err
 	}
@@ -42,12 +73,29 @@ func (c *controllerClient) Register(ctx context.Context, existingAgentID string)
 	return &out, nil
 }
 
-func (c *controllerClient) GetConfig(ctx context.Context, agentID, etag, pollURL string) (*model.Config, string, int, error) {
+// GetConfig fetches the latest config. A positive wait asks the controller to
+// hold the request until a version newer than etag exists or wait elapses.
+func (c *controllerClient) GetConfig(ctx context.Context, agentID, credential, etag, pollURL string, wait time.Duration) (*model.Config, string, int, error) {
+	target := c.baseURL + pollURL
+	httpClient := c.http
+	if wait > 0 {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		q := u.Query()
+		q.Set("wait", wait.String())
+		u.RawQuery = q.Encode()
+		target = u.String()
+		httpClient = c.longPoll
+	}
+
 	var out model.Config
-	resp, err := c.http.DoJSON(ctx, http.MethodGet, c.baseURL+pollURL, map[string]string{
-		"X-API-Key":     c.apiKey,
-		"X-Agent-ID":    agentID,
-		"If-None-Match": etag,
+	resp, err := httpClient.DoJSON(ctx, http.MethodGet, target, map[string]string{
+		"X-API-Key":          c.apiKey,
+		"X-Agent-ID":         agentID,
+		"X-Agent-Credential": credential,
+		"If-None-Match":      etag,
 	}, nil, &out)
 	if err != nil {
 		return nil, "", 0, err
@@ -63,3 +111,143 @@ func (c *controllerClient) GetConfig(ctx context.Context, agentID, etag, pollURL
 		return nil, newETag, resp.StatusCode, fmt.Errorf("get config failed with status %d", resp.StatusCode)
 	}
 }
+
+func (c *controllerClient) ReportStatus(ctx context.Context, agentID, credential string, report *model.StatusReport) error {
+	resp, err := c.http.DoJSON(ctx, http.MethodPost, c.baseURL+"/agents/"+url.PathEscape(agentID)+"/status", map[string]string{
+		"X-API-Key":          c.apiKey,
+		"X-Agent-ID":         agentID,
+		"X-Agent-Credential": credential,
+	}, report, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("report status failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// StreamConfig subscribes to the controller's config event stream and calls
+// onConfig for every version received, passing the ETag GET /config would
+// return for it. onActivity is called when the stream opens and for every
+// line received, keep-alives included. It blocks until the stream ends, stays
+// idle for longer than StreamIdleTimeout, the context is cancelled or onConfig
+// fails, and always returns a non-nil error.
+func (c *controllerClient) StreamConfig(
+	ctx context.Context,
+	agentID, credential string,
+	lastVersion int,
+	onConfig func(cfg *model.Config, etag string) error,
+	onActivity func(),
+) error {
+	lastEventID := ""
+	if lastVersion > 0 {
+		lastEventID = strconv.Itoa(lastVersion)
+	}
+
+	// Cancelling the request unblocks both the connect and body reads.
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var idleExpired atomic.Bool
+	idle := time.AfterFunc(c.streamIdleTimeout, func() {
+		idleExpired.Store(true)
+		cancel()
+	})
+	defer idle.Stop()
+	idleErr := func(err error) error {
+		if idleExpired.Load() {
+			return fmt.Errorf("config stream idle for more than %s", c.streamIdleTimeout)
+		}
+		return err
+	}
+
+	resp, err := c.stream.Stream(streamCtx, http.MethodGet, c.baseURL+"/config/stream", map[string]string{
+		"X-API-Key":          c.apiKey,
+		"X-Agent-ID":         agentID,
+		"X-Agent-Credential": credential,
+		"Accept":             "text/event-stream",
+		"Last-Event-ID":      lastEventID,
+	})
+	if err != nil {
+		return idleErr(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("config stream failed with status %d", resp.StatusCode)
+	}
+
+	activity := func() {
+		idle.Reset(c.streamIdleTimeout)
+		if onActivity != nil {
+			onActivity()
+		}
+	}
+	activity()
+	// Applying a version may take longer than the idle timeout, e.g. while the
+	// worker is retried, so the timer is paused meanwhile.
+	apply := func(cfg *model.Config, etag string) error {
+		idle.Stop()
+		defer idle.Reset(c.streamIdleTimeout)
+		return onConfig(cfg, etag)
+	}
+
+	if err := readConfigEvents(resp.Body, activity, apply); err != nil {
+		return idleErr(err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := idleErr(nil); err != nil {
+		return err
+	}
+	return errors.New("config stream closed by controller")
+}
+
+// readConfigEvents parses a text/event-stream body and dispatches "config"
+// events. Comments and other event types are ignored. onLine is called for
+// every line read.
+func readConfigEvents(r io.Reader, onLine func(), onConfig func(cfg *model.Config, etag string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var id, event string
+	var data strings.Builder
+	for scanner.Scan() {
+		onLine()
+		line := scanner.Text()
+		if line == "" {
+			if event == "config" && data.Len() > 0 {
+				var cfg model.Config
+				if err := json.Unmarshal([]byte(data.String()), &cfg); err != nil {
+					return fmt.Errorf("decode config event: %w", err)
+				}
+				if err := onConfig(&cfg, `"`+id+`"`); err != nil {
+					return err
+				}
+			}
+			id, event = "", ""
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "event":
+			event = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -2,10 +2,15 @@ package client
 
 import (
 	"agent/internal/library/httpclient"
+	"agent/internal/model"
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -16,20 +21,38 @@ func TestControllerClient_Register_Success(t *testing.T) {
 		assert.Equal(t, "/register", r.URL.Path)
 		assert.Equal(t, "agent-key", r.Header.Get("X-API-Key"))
 		assert.Equal(t, "existing-agent", r.Header.Get("X-Agent-ID"))
+		assert.Equal(t, "cred", r.Header.Get("X-Agent-Credential"))
+		assert.Equal(t, "enroll-token", r.Header.Get("X-Enrollment-Token"))
+
+		var body model.RegisterRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "prod", body.Namespace)
+		assert.Equal(t, "host-a", body.Hostname)
+		assert.Equal(t, "1.2.0", body.Version)
+		assert.Equal(t, map[string]string{"region": "eu"}, body.Labels)
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"agent_id":"agent-1","poll_url":"/config","poll_interval_seconds":30}`))
+		_, _ = w.Write([]byte(`{"agent_id":"agent-1","namespace":"prod","poll_url":"/config","poll_interval_seconds":30,"credential":"new-cred"}`))
 	}))
 	defer srv.Close()
 
-	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3))
-	out, err := c.Register(context.Background(), "existing-agent")
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	out, err := c.Register(context.Background(), "existing-agent", "cred", &model.RegisterRequest{
+		Namespace:       "prod",
+		Hostname:        "host-a",
+		Version:         "1.2.0",
+		Labels:          map[string]string{"region": "eu"},
+		EnrollmentToken: "enroll-token",
+	})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, out)
 	assert.Equal(t, "agent-1", out.AgentID)
+	assert.Equal(t, "prod", out.Namespace)
 	assert.Equal(t, "/config", out.PollURL)
 	assert.Equal(t, 30, out.PollIntervalSeconds)
+	assert.Equal(t, "new-cred", out.Credential)
 }
 
 func TestControllerClient_Register_StatusError(t *testing.T) {
@@ -38,8 +61,8 @@ func TestControllerClient_Register_StatusError(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3))
-	out, err := c.Register(context.Background(), "existing-agent")
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	out, err := c.Register(context.Background(), "existing-agent", "cred", &model.RegisterRequest{})
 
 	assert.Nil(t, out)
 	assert.Error(t, err)
@@ -52,6 +75,7 @@ func TestControllerClient_GetConfig_Success(t *testing.T) {
 		assert.Equal(t, "/config", r.URL.Path)
 		assert.Equal(t, "agent-key", r.Header.Get("X-API-Key"))
 		assert.Equal(t, "agent-1", r.Header.Get("X-Agent-ID"))
+		assert.Equal(t, "cred", r.Header.Get("X-Agent-Credential"))
 		assert.Equal(t, `"1"`, r.Header.Get("If-None-Match"))
 		w.Header().Set("ETag", `"2"`)
 		w.Header().Set("Content-Type", "application/json")
@@ -60,8 +84,8 @@ func TestControllerClient_GetConfig_Success(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3))
-	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", `"1"`, "/config")
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", "cred", `"1"`, "/config", 0)
 
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, status)
@@ -72,6 +96,43 @@ func TestControllerClient_GetConfig_Success(t *testing.T) {
 	assert.Equal(t, 45, cfg.PollIntervalSeconds)
 }
 
+func TestControllerClient_GetConfig_LongPoll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/config", r.URL.Path)
+		assert.Equal(t, "1m0s", r.URL.Query().Get("wait"))
+		assert.Equal(t, `"1"`, r.Header.Get("If-None-Match"))
+		w.Header().Set("ETag", `"1"`)
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(65))
+	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", "cred", `"1"`, "/config", time.Minute)
+
+	assert.NoError(t, err)
+	assert.Nil(t, cfg)
+	assert.Equal(t, `"1"`, etag)
+	assert.Equal(t, http.StatusNotModified, status)
+}
+
+func TestControllerClient_GetConfig_WithData(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"3"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"version":3,"url":"https://example.com","poll_interval_seconds":45,"data":{"limits":{"rps":10}}}`))
+	}))
+	defer srv.Close()
+
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	cfg, _, status, err := c.GetConfig(context.Background(), "agent-1", "cred", "", "/config", 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotNil(t, cfg)
+	assert.JSONEq(t, `{"limits":{"rps":10}}`, string(cfg.Data))
+}
+
 func TestControllerClient_GetConfig_NotModified(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("ETag", `"9"`)
@@ -79,8 +140,8 @@ func TestControllerClient_GetConfig_NotModified(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3))
-	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", `"9"`, "/config")
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", "cred", `"9"`, "/config", 0)
 
 	assert.NoError(t, err)
 	assert.Nil(t, cfg)
@@ -94,8 +155,8 @@ func TestControllerClient_GetConfig_StatusError(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3))
-	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", "", "/config")
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", "cred", "", "/config", 0)
 
 	assert.Nil(t, cfg)
 	assert.Equal(t, "", etag)
@@ -105,11 +166,164 @@ func TestControllerClient_GetConfig_StatusError(t *testing.T) {
 }
 
 func TestControllerClient_GetConfig_HTTPError(t *testing.T) {
-	c := NewControllerClient("://bad", "agent-key", httpclient.New(1))
-	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", "", "/config")
+	c := NewControllerClient("://bad", "agent-key", httpclient.New(1), httpclient.New(1))
+	cfg, etag, status, err := c.GetConfig(context.Background(), "agent-1", "cred", "", "/config", 0)
 
 	assert.Nil(t, cfg)
 	assert.Equal(t, "", etag)
 	assert.Equal(t, 0, status)
 	assert.Error(t, err)
 }
+
+func TestControllerClient_ReportStatus_Success(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/agents/agent-1/status", r.URL.Path)
+		assert.Equal(t, "agent-key", r.Header.Get("X-API-Key"))
+		assert.Equal(t, "agent-1", r.Header.Get("X-Agent-ID"))
+		assert.Equal(t, "cred", r.Header.Get("X-Agent-Credential"))
+
+		var body model.StatusReport
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, model.StatusReport{Version: 4, Status: model.StatusFailed, Error: "boom"}, body)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	err := c.ReportStatus(context.Background(), "agent-1", "cred", &model.StatusReport{Version: 4, Status: model.StatusFailed, Error: "boom"})
+
+	assert.NoError(t, err)
+}
+
+func TestControllerClient_ReportStatus_StatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	err := c.ReportStatus(context.Background(), "agent-1", "cred", &model.StatusReport{Version: 4, Status: model.StatusApplied})
+
+	assert.EqualError(t, err, "report status failed with status 404")
+}
+
+func TestControllerClient_StreamConfig_DeliversEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/config/stream", r.URL.Path)
+		assert.Equal(t, "agent-key", r.Header.Get("X-API-Key"))
+		assert.Equal(t, "agent-1", r.Header.Get("X-Agent-ID"))
+		assert.Equal(t, "cred", r.Header.Get("X-Agent-Credential"))
+		assert.Equal(t, "3", r.Header.Get("Last-Event-ID"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(": keep-alive\n\n"))
+		_, _ = w.Write([]byte("id: 4\nevent: config\ndata: {\"version\":4,\"url\":\"https://example.com/4\"}\n\n"))
+		_, _ = w.Write([]byte("id: 5\nevent: config\ndata: {\"version\":5,\"data\":{\"a\":1}}\n\n"))
+	}))
+	defer srv.Close()
+
+	var versions []int
+	var etags []string
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	err := c.StreamConfig(context.Background(), "agent-1", "cred", 3, func(cfg *model.Config, etag string) error {
+		versions = append(versions, cfg.Version)
+		etags = append(etags, etag)
+		return nil
+	}, nil)
+
+	assert.EqualError(t, err, "config stream closed by controller")
+	assert.Equal(t, []int{4, 5}, versions)
+	assert.Equal(t, []string{`"4"`, `"5"`}, etags)
+}
+
+func TestControllerClient_StreamConfig_CallbackErrorStopsStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Last-Event-ID"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("id: 1\nevent: config\ndata: {\"version\":1}\n\n"))
+		_, _ = w.Write([]byte("id: 2\nevent: config\ndata: {\"version\":2}\n\n"))
+	}))
+	defer srv.Close()
+
+	calls := 0
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	err := c.StreamConfig(context.Background(), "agent-1", "cred", 0, func(cfg *model.Config, etag string) error {
+		calls++
+		return errors.New("worker fail")
+	}, nil)
+
+	assert.EqualError(t, err, "worker fail")
+	assert.Equal(t, 1, calls)
+}
+
+func TestControllerClient_StreamConfig_StatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3))
+	err := c.StreamConfig(context.Background(), "agent-1", "cred", 0, func(cfg *model.Config, etag string) error {
+		t.Fatal("unexpected event")
+		return nil
+	}, nil)
+
+	assert.EqualError(t, err, "config stream failed with status 404")
+}
+
+func TestControllerClient_StreamConfig_IdleTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(": keep-alive\n\n"))
+		w.(http.Flusher).Flush()
+		// Then nothing, like a half-open connection.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := NewControllerClient(srv.URL, "agent-key", httpclient.New(3), httpclient.New(3)).(*controllerClient)
+	c.streamIdleTimeout = 50 * time.Millisecond
+
+	activity := 0
+	start := time.Now()
+	err := c.StreamConfig(context.Background(), "agent-1", "cred", 0, func(cfg *model.Config, etag string) error {
+		t.Fatal("unexpected event")
+		return nil
+	}, func() { activity++ })
+
+	assert.EqualError(t, err, "config stream idle for more than 50ms")
+	assert.Less(t, time.Since(start), 2*time.Second)
+	// Connect, the keep-alive comment and its blank line.
+	assert.Equal(t, 3, activity)
+}
+
+func TestReadConfigEvents_IgnoresOtherEventsAndJoinsData(t *testing.T) {
+	body := strings.NewReader("event: other\ndata: {}\n\n" +
+		"id: 7\nevent: config\ndata: {\"version\":7,\ndata: \"url\":\"https://example.com\"}\n\n")
+
+	var got *model.Config
+	err := readConfigEvents(body, func() {}, func(cfg *model.Config, etag string) error {
+		got = cfg
+		assert.Equal(t, `"7"`, etag)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Config{Version: 7, URL: "https://example.com"}, got)
+}
+
+func TestReadConfigEvents_InvalidData(t *testing.T) {
+	err := readConfigEvents(strings.NewReader("id: 1\nevent: config\ndata: nope\n\n"), func() {}, func(cfg *model.Config, etag string) error {
+		return nil
+	})
+
+	assert.ErrorContains(t, err, "decode config event")
+}
@@ -4,12 +4,24 @@ import (
 	"agent/internal/library/httpclient"
 	"agent/internal/model"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 )
 
+// ErrWorkerHasNewerVersion is returned by ApplyConfig when the worker rejects
+// the config because it already applied a newer version. Retrying without
+// force cannot succeed, and the worker is not behind.
+var ErrWorkerHasNewerVersion = errors.New("worker already has a newer config version")
+
+type staleVersionResponse struct {
+	CurrentVersion int `json:"current_version"`
+}
+
 type WorkerClient interface {
-	ApplyConfig(ctx context.Context, cfg *model.Config) error
+	// ApplyConfig pushes cfg to the worker. force applies it even when the
+	// worker has a newer version, for downgrades served by the controller.
+	ApplyConfig(ctx context.Context, cfg *model.Config, force bool) error
 }
 
 type workerClient struct {
@@ -26,15 +38,25 @@ func NewWorkerClient(baseURL, apiKey string, httpClient *httpclient.Client) Work
 	}
 }
 
-func (w *workerClient) ApplyConfig(ctx context.Context, cfg *model.Config) error {
-	resp, err := w.http.DoJSON(ctx, http.MethodPost, w.baseURL+"/config", map[string]string{
+func (w *workerClient) ApplyConfig(ctx context.Context, cfg *model.Config, force bool) error {
+	url := w.baseURL + "/config"
+	if force {
+		url += "?force=true"
+	}
+
+	var stale staleVersionResponse
+	resp, err := w.http.DoJSON(ctx, http.MethodPost, url, map[string]string{
 		"X-API-Key": w.apiKey,
-	}, cfg, nil)
-	if err != nil {
+	}, cfg, &stale)
+	// The body is only of interest on 409; a status from a response whose
+	// body failed to decode still takes precedence over the decode error.
+	switch {
+	case resp == nil:
 		return err
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+	case resp.StatusCode == http.StatusConflict:
+		return fmt.Errorf("%w: worker has version %d", ErrWorkerHasNewerVersion, stale.CurrentVersion)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
 		return fmt.Errorf("worker apply failed with status %d", resp.StatusCode)
 	}
-	return nil
+	return err
 }
@@ -13,6 +13,29 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestWorkerClient_ApplyConfig_ForwardsData(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		var payload map[string]interface{}
+		err = json.Unmarshal(body, &payload)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"mode": "fast"}, payload["data"])
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewWorkerClient(srv.URL, "worker-secret", httpclient.New(3))
+	err := c.ApplyConfig(context.Background(), &model.Config{
+		URL:     "https://example.com",
+		Version: 2,
+		Data:    json.RawMessage(`{"mode":"fast"}`),
+	}, false)
+	assert.NoError(t, err)
+}
+
 func TestWorkerClient_ApplyConfig_Success(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, http.MethodPost, r.Method)
@@ -35,7 +58,7 @@ func TestWorkerClient_ApplyConfig_Success(t *testing.T) {
 	defer srv.Close()
 
 	c := NewWorkerClient(srv.URL, "worker-secret", httpclient.New(3))
-	err := c.ApplyConfig(context.Background(), &model.Config{URL: "https://example.com", Version: 1, PollIntervalSeconds: 30})
+	err := c.ApplyConfig(context.Background(), &model.Config{URL: "https://example.com", Version: 1, PollIntervalSeconds: 30}, false)
 	assert.NoError(t, err)
 }
 
@@ -46,13 +69,40 @@ func TestWorkerClient_ApplyConfig_StatusError(t *testing.T) {
 	defer srv.Close()
 
 	c := NewWorkerClient(srv.URL, "worker-secret", httpclient.New(3))
-	err := c.ApplyConfig(context.Background(), &model.Config{URL: "https://example.com"})
+	err := c.ApplyConfig(context.Background(), &model.Config{URL: "https://example.com"}, false)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "worker apply failed with status 400")
 }
 
 func TestWorkerClient_ApplyConfig_HTTPError(t *testing.T) {
 	c := NewWorkerClient("://bad", "worker-secret", httpclient.New(1))
-	err := c.ApplyConfig(context.Background(), &model.Config{URL: "https://example.com"})
+	err := c.ApplyConfig(context.Background(), &model.Config{URL: "https://example.com"}, false)
 	assert.Error(t, err)
 }
+
+func TestWorkerClient_ApplyConfig_NewerVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"error":{"code":"STALE_VERSION","message":"stale"},"current_version":5}`))
+	}))
+	defer srv.Close()
+
+	c := NewWorkerClient(srv.URL, "worker-secret", httpclient.New(3))
+	err := c.ApplyConfig(context.Background(), &model.Config{URL: "https://example.com", Version: 2}, false)
+	assert.ErrorIs(t, err, ErrWorkerHasNewerVersion)
+	assert.Contains(t, err.Error(), "worker has version 5")
+}
+
+func TestWorkerClient_ApplyConfig_Force(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/config", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("force"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewWorkerClient(srv.URL, "worker-secret", httpclient.New(3))
+	err := c.ApplyConfig(context.Background(), &model.Config{URL: "https://example.com", Version: 1}, true)
+	assert.NoError(t, err)
+}
@@ -7,21 +7,35 @@ import (
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/mrheza/distributed-config-management/shared/tracing"
+)
+
+const (
+	SyncModePoll   = "poll"
+	SyncModeStream = "stream"
 )
 
 type Config struct {
 	ControllerBaseURL     string
 	ControllerAPIKey      string
+	EnrollmentToken       string
+	PublicKeyFile         string
 	WorkerBaseURL         string
 	WorkerAPIKey          string
+	Namespace             string
+	Labels                string
 	PollURL               string
 	PollIntervalSeconds   int
+	LongPollWaitSeconds   int
+	SyncMode              string
 	StatePath             string
 	MaxBackoffSeconds     int
 	BackoffJitterPercent  int
 	RequestTimeoutSeconds int
 	GinMode               string
 	Port                  string
+	TraceExporter         string
+	ReadyPollIntervals    int
 }
 
 func Load() *Config {
@@ -30,16 +44,24 @@ func Load() *Config {
 	return &Config{
 		ControllerBaseURL:     os.Getenv("CONTROLLER_BASE_URL"),
 		ControllerAPIKey:      os.Getenv("CONTROLLER_API_KEY"),
+		EnrollmentToken:       os.Getenv("ENROLLMENT_TOKEN"),
+		PublicKeyFile:         os.Getenv("CONFIG_PUBLIC_KEY_FILE"),
 		WorkerBaseURL:         os.Getenv("WORKER_BASE_URL"),
 		WorkerAPIKey:          os.Getenv("WORKER_API_KEY"),
+		Namespace:             os.Getenv("NAMESPACE"),
+		Labels:                os.Getenv("AGENT_LABELS"),
 		PollURL:               os.Getenv("POLL_URL"),
 		PollIntervalSeconds:   getEnvInt("POLL_INTERVAL_SECONDS"),
+		LongPollWaitSeconds:   getEnvInt("LONG_POLL_WAIT_SECONDS"),
+		SyncMode:              getEnvDefault("SYNC_MODE", SyncModePoll),
 		StatePath:             os.Getenv("STATE_PATH"),
 		MaxBackoffSeconds:     getEnvInt("MAX_BACKOFF_SECONDS"),
 		BackoffJitterPercent:  getEnvInt("BACKOFF_JITTER_PERCENT"),
 		RequestTimeoutSeconds: getEnvInt("REQUEST_TIMEOUT_SECONDS"),
 		GinMode:               os.Getenv("GIN_MODE"),
 		Port:                  os.Getenv("PORT"),
+		TraceExporter:         getEnvDefault("OTEL_TRACES_EXPORTER", tracing.ExporterNone),
+		ReadyPollIntervals:    getEnvIntDefault("READY_POLL_INTERVALS", 3),
 	}
 }
 
@@ -76,6 +98,12 @@ func (c *Config) Validate() error {
 	if c.PollIntervalSeconds <= 0 {
 		return fmt.Errorf("invalid POLL_INTERVAL_SECONDS: must be > 0")
 	}
+	if c.LongPollWaitSeconds < 0 || c.LongPollWaitSeconds > 120 {
+		return fmt.Errorf("invalid LONG_POLL_WAIT_SECONDS: must be between 0 and 120")
+	}
+	if c.SyncMode != SyncModePoll && c.SyncMode != SyncModeStream {
+		return fmt.Errorf("invalid SYNC_MODE: must be %s or %s", SyncModePoll, SyncModeStream)
+	}
 	if c.MaxBackoffSeconds <= 0 {
 		return fmt.Errorf("invalid MAX_BACKOFF_SECONDS: must be > 0")
 	}
@@ -85,10 +113,52 @@ func (c *Config) Validate() error {
 	if c.RequestTimeoutSeconds <= 0 {
 		return fmt.Errorf("invalid REQUEST_TIMEOUT_SECONDS: must be > 0")
 	}
+	if _, err := ParseLabels(c.Labels); err != nil {
+		return fmt.Errorf("invalid AGENT_LABELS: %w", err)
+	}
+	if c.ReadyPollIntervals <= 0 {
+		return fmt.Errorf("invalid READY_POLL_INTERVALS: must be > 0")
+	}
+	if !tracing.ValidExporter(c.TraceExporter) {
+		return fmt.Errorf("invalid OTEL_TRACES_EXPORTER: must be none, stdout or otlp")
+	}
 
 	return nil
 }
 
+// LabelMap returns the parsed AGENT_LABELS. Call Validate first.
+func (c *Config) LabelMap() map[string]string {
+	labels, _ := ParseLabels(c.Labels)
+	return labels
+}
+
+// ParseLabels parses a comma separated list of key=value pairs, e.g.
+// "region=eu-west,tier=edge". An empty string yields no labels.
+func ParseLabels(raw string) (map[string]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", strings.TrimSpace(pair))
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+
+	return labels, nil
+}
+
+func getEnvDefault(k, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+		return v
+	}
+	return fallback
+}
+
 func getEnvInt(k string) int {
 	raw := os.Getenv(k)
 	if raw == "" {
@@ -100,3 +170,10 @@ func getEnvInt(k string) int {
 	}
 	return v
 }
+
+func getEnvIntDefault(k string, fallback int) int {
+	if strings.TrimSpace(os.Getenv(k)) == "" {
+		return fallback
+	}
+	return getEnvInt(k)
+}
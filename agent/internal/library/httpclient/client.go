@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/mrheza/distributed-config-management/shared/tracing"
 )
 
 type Client struct {
@@ -21,7 +23,8 @@ type ResponseMeta struct {
 func New(timeoutSeconds int) *Client {
 	return &Client{
 		http: &http.Client{
-			Timeout: time.Duration(timeoutSeconds) * time.Second,
+			Timeout:   time.Duration(timeoutSeconds) * time.Second,
+			Transport: tracing.Transport(nil),
 		},
 	}
 }
@@ -72,6 +75,24 @@ func (c *Client) DoJSON(ctx context.Context, method, url string, headers map[str
 	}, nil
 }
 
+// Stream sends a request and returns the response with its body still open;
+// the caller must close it. The client timeout also bounds reading the body,
+// so long-lived streams need a client created with New(0).
+func (c *Client) Stream(ctx context.Context, method, url string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range headers {
+		if v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	return c.http.Do(req)
+}
+
 func cloneHeader(h http.Header) http.Header {
 	out := make(http.Header, len(h))
 	for k, v := range h {
@@ -3,11 +3,15 @@ package httpclient
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/mrheza/distributed-config-management/shared/tracing"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type badBody struct{}
@@ -36,6 +40,32 @@ func TestDoJSON_GetAndDecode(t *testing.T) {
 	assert.Equal(t, true, out["ok"])
 }
 
+func TestDoJSON_PropagatesTraceContext(t *testing.T) {
+	_, err := tracing.Init(context.Background(), "agent-test", tracing.ExporterNone)
+	require.NoError(t, err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := New(5)
+	_, err = c.DoJSON(ctx, http.MethodGet, srv.URL, nil, nil, nil)
+
+	assert.NoError(t, err)
+	assert.Contains(t, traceparent, "4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
 func TestDoJSON_PostBodyAndContentType(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
@@ -82,3 +112,33 @@ func TestDoJSON_MarshalError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "marshal failed")
 }
+
+func TestStream_ReturnsOpenBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		assert.Empty(t, r.Header.Values("X-Empty"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("data: hello\n\n"))
+	}))
+	defer srv.Close()
+
+	c := New(0)
+	resp, err := c.Stream(context.Background(), http.MethodGet, srv.URL, map[string]string{
+		"Accept":  "text/event-stream",
+		"X-Empty": "",
+	})
+
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "data: hello\n\n", string(raw))
+}
+
+func TestStream_InvalidURL(t *testing.T) {
+	c := New(0)
+	resp, err := c.Stream(context.Background(), http.MethodGet, "://bad", nil)
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,39 @@
+// Package metrics holds the agent's Prometheus metrics. They are registered
+// with the default registry served on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Poll outcomes.
+const (
+	PollApplied     = "applied"
+	PollNotModified = "not_modified"
+	PollEmpty       = "empty"
+	PollError       = "error"
+	PollApplyFailed = "apply_failed"
+)
+
+var (
+	Polls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_polls_total",
+		Help: "Config polls of the controller by outcome.",
+	}, []string{"outcome"})
+
+	BackoffRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_backoff_retries_total",
+		Help: "Retries scheduled with backoff after a failed request, by target (controller or worker).",
+	}, []string{"target"})
+
+	LastApplySuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_last_apply_success_timestamp_seconds",
+		Help: "Unix time of the last config applied to the worker.",
+	})
+
+	ConfigVersion = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_config_version",
+		Help: "Config version last applied to the worker.",
+	})
+)
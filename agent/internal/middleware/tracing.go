@@ -0,0 +1,11 @@
+package middleware
+
+import (
+	sharedmiddleware "github.com/mrheza/distributed-config-management/shared/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Tracing(service string) gin.HandlerFunc {
+	return sharedmiddleware.Tracing(service)
+}
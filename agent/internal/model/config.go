@@ -1,7 +1,13 @@
 package model
 
+import "encoding/json"
+
 type Config struct {
-	Version             int    `json:"version"`
-	URL                 string `json:"url"`
-	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+	Version             int               `json:"version"`
+	Namespace           string            `json:"namespace,omitempty"`
+	URL                 string            `json:"url"`
+	PollIntervalSeconds int               `json:"poll_interval_seconds"`
+	Data                json.RawMessage   `json:"data,omitempty" swaggertype:"object"`
+	Secrets             map[string]string `json:"secrets,omitempty"`
+	Signature           string            `json:"signature,omitempty"`
 }
@@ -1,7 +1,19 @@
 package model
 
+type RegisterRequest struct {
+	Namespace string            `json:"namespace,omitempty"`
+	Hostname  string            `json:"hostname,omitempty"`
+	Version   string            `json:"version,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// EnrollmentToken is sent as X-Enrollment-Token and only needed until the
+	// agent holds a credential.
+	EnrollmentToken string `json:"-"`
+}
+
 type RegisterResponse struct {
 	AgentID             string `json:"agent_id"`
+	Namespace           string `json:"namespace"`
 	PollURL             string `json:"poll_url"`
 	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+	Credential          string `json:"credential,omitempty"`
 }
@@ -1,10 +1,22 @@
 package model
 
+import "encoding/json"
+
 type State struct {
-	AgentID             string `json:"agent_id"`
-	ETag                string `json:"etag"`
-	ConfigURL           string `json:"config_url"`
-	PollURL             string `json:"poll_url"`
-	PollIntervalSeconds int    `json:"poll_interval_seconds"`
-	LastConfigVersion   int    `json:"last_config_version"`
+	AgentID             string            `json:"agent_id"`
+	Credential          string            `json:"credential,omitempty"`
+	Namespace           string            `json:"namespace"`
+	ETag                string            `json:"etag"`
+	ConfigURL           string            `json:"config_url"`
+	ConfigData          json.RawMessage   `json:"config_data,omitempty" swaggertype:"object"`
+	ConfigSecrets       map[string]string `json:"config_secrets,omitempty"`
+	PollURL             string            `json:"poll_url"`
+	PollIntervalSeconds int               `json:"poll_interval_seconds"`
+	LastConfigVersion   int               `json:"last_config_version"`
+	ConfigSignature     string            `json:"config_signature,omitempty"`
+	// ConfigNamespace and ConfigPollIntervalSeconds are those of the applied
+	// config, as signed. PollIntervalSeconds and Namespace follow registration
+	// and may differ from them.
+	ConfigNamespace           string `json:"config_namespace,omitempty"`
+	ConfigPollIntervalSeconds int    `json:"config_poll_interval_seconds"`
 }
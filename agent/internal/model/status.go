@@ -0,0 +1,13 @@
+package model
+
+const (
+	StatusApplied = "applied"
+	StatusFailed  = "failed"
+)
+
+// StatusReport tells the controller whether a config version reached the worker.
+type StatusReport struct {
+	Version int    `json:"version"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
@@ -5,7 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
-	"path/filepath"
+
+	"github.com/mrheza/distributed-config-management/shared/fileutil"
 )
 
 type FileStateRepository struct {
@@ -32,14 +33,13 @@ func (r *FileStateRepository) Load() (*model.State, error) {
 	return &s, nil
 }
 
+// Save replaces the state file atomically. It holds the agent credential and
+// config secrets, so it is only readable by its owner, and a crash must not
+// leave a partial file that forces re-enrollment.
 func (r *FileStateRepository) Save(state *model.State) error {
-	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
-		return err
-	}
-
 	raw, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(r.path, raw, 0o644)
+	return fileutil.WriteAtomic(r.path, raw)
 }
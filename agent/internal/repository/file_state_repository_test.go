@@ -2,6 +2,7 @@ package repository
 
 import (
 	"agent/internal/model"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -43,6 +44,43 @@ func TestFileStateRepository_Save_AndLoad_Success(t *testing.T) {
 	assert.Equal(t, expected, loaded)
 }
 
+func TestFileStateRepository_Save_PrivateAndAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	// State files written by older agents were world-readable.
+	require.NoError(t, os.WriteFile(path, []byte(`{"agent_id":"agent-old"}`), 0o644))
+	repo := NewFileStateRepository(path)
+
+	require.NoError(t, repo.Save(&model.State{AgentID: "agent-1", Credential: "cred-1"}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must not be left behind")
+
+	loaded, err := repo.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "cred-1", loaded.Credential)
+}
+
+func TestFileStateRepository_Save_AndLoad_ConfigData(t *testing.T) {
+	tmp := t.TempDir()
+	repo := NewFileStateRepository(filepath.Join(tmp, "state.json"))
+
+	err := repo.Save(&model.State{
+		AgentID:    "agent-1",
+		ConfigURL:  "https://example.com/config",
+		ConfigData: json.RawMessage(`{"mode":"fast","limits":{"rps":10}}`),
+	})
+	require.NoError(t, err)
+
+	loaded, err := repo.Load()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mode":"fast","limits":{"rps":10}}`, string(loaded.ConfigData))
+}
+
 func TestFileStateRepository_Load_InvalidJSON_ReturnsError(t *testing.T) {
 	tmp := t.TempDir()
 	path := filepath.Join(tmp, "state.json")
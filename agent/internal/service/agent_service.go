@@ -2,30 +2,62 @@ package service
 
 import (
 	"agent/internal/client"
+	"agent/internal/metrics"
 	"agent/internal/model"
 	"agent/internal/repository"
 	"context"
+	"crypto/ed25519"
 	"errors"
+	"fmt"
 	"log"
 	"math/rand"
+	"sync/atomic"
 	"time"
+
+	"github.com/mrheza/distributed-config-management/shared/redact"
+	"github.com/mrheza/distributed-config-management/shared/signing"
+	"github.com/mrheza/distributed-config-management/shared/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = otel.Tracer("agent/internal/service")
+
 type AgentService interface {
 	Run(ctx context.Context)
 	GetState() *model.State
+	// CheckBootstrap fails until the agent has registered with the controller.
+	CheckBootstrap(ctx context.Context) error
+	// CheckPoll fails when the controller has not been reached for more than
+	// the configured number of poll intervals.
+	CheckPoll(ctx context.Context) error
 }
 
+// ErrNotBootstrapped is returned by the readiness checks before the agent has
+// registered with the controller.
+var ErrNotBootstrapped = errors.New("agent has not registered with the controller yet")
+
 type agentService struct {
 	controller       client.ControllerClient
 	worker           client.WorkerClient
 	stateRepo        repository.StateRepository
+	registration     model.RegisterRequest
 	defaultPollURL   string
 	defaultPollSecs  int
 	maxBackoffSecs   int
 	backoffJitterPct int
+	longPollWait     time.Duration
+	stream           bool
+	verifyKey        ed25519.PublicKey
 	rng              *rand.Rand
 	currentState     *model.State
+
+	// Readiness is read by HTTP handlers while Run updates it.
+	readyPollIntervals int
+	bootstrapped       atomic.Bool
+	lastPollAt         atomic.Int64
+	pollDeadline       atomic.Int64
+	now                func() time.Time
 }
 
 type reqError struct {
@@ -40,38 +72,60 @@ func NewAgentService(
 	controller client.ControllerClient,
 	worker client.WorkerClient,
 	stateRepo repository.StateRepository,
+	registration model.RegisterRequest,
 	defaultPollURL string,
 	defaultPollSecs int,
 	maxBackoffSecs int,
 	backoffJitterPct int,
+	longPollWaitSecs int,
+	stream bool,
+	verifyKey ed25519.PublicKey,
+	readyPollIntervals int,
 ) AgentService {
 	return &agentService{
-		controller:       controller,
-		worker:           worker,
-		stateRepo:        stateRepo,
-		defaultPollURL:   defaultPollURL,
-		defaultPollSecs:  defaultPollSecs,
-		maxBackoffSecs:   maxBackoffSecs,
-		backoffJitterPct: backoffJitterPct,
-		rng:              rand.New(rand.NewSource(time.Now().UnixNano())),
+		controller:         controller,
+		worker:             worker,
+		stateRepo:          stateRepo,
+		registration:       registration,
+		defaultPollURL:     defaultPollURL,
+		defaultPollSecs:    defaultPollSecs,
+		maxBackoffSecs:     maxBackoffSecs,
+		backoffJitterPct:   backoffJitterPct,
+		longPollWait:       time.Duration(longPollWaitSecs) * time.Second,
+		stream:             stream,
+		verifyKey:          verifyKey,
+		rng:                rand.New(rand.NewSource(time.Now().UnixNano())),
+		readyPollIntervals: readyPollIntervals,
+		now:                time.Now,
 		currentState: &model.State{
+			Namespace:           registration.Namespace,
 			PollURL:             defaultPollURL,
 			PollIntervalSeconds: defaultPollSecs,
 		},
 	}
 }
 
+// GetState returns a copy of the current state without the credential and
+// secret values, which must not leave the state file.
 func (s *agentService) GetState() *model.State {
 	clone := *s.currentState
+	clone.Credential = ""
+	if s.currentState.ConfigSecrets != nil {
+		clone.ConfigSecrets = redact.Secrets(s.currentState.ConfigSecrets)
+		clone.ConfigSignature = ""
+	}
 	return &clone
 }
 
 func (s *agentService) Run(ctx context.Context) {
 	log.Printf(
-		"event=agent_run_started default_poll_secs=%d max_backoff_secs=%d backoff_jitter_pct=%d",
+		"event=agent_run_started default_poll_secs=%d max_backoff_secs=%d backoff_jitter_pct=%d long_poll_wait_secs=%.0f stream=%t verify_signatures=%t",
 		s.defaultPollSecs,
 		s.maxBackoffSecs,
 		s.backoffJitterPct,
+		s.longPollWait.Seconds(),
+		s.stream,
+		s.verifyKey != nil,
 	)
 
 	if !s.runBootstrapLoop(ctx) {
@@ -88,6 +142,10 @@ func (s *agentService) Run(ctx context.Context) {
 		)
 	}
 
+	if s.stream {
+		s.runStreamLoop(ctx)
+		return
+	}
 	s.runPollLoop(ctx)
 }
 
@@ -101,6 +159,8 @@ func (s *agentService) runBootstrapLoop(ctx context.Context) bool {
 		err := s.bootstrap(ctx)
 		if err == nil {
 			retry.reset()
+			s.bootstrapped.Store(true)
+			s.recordPoll()
 			return true
 		}
 
@@ -111,6 +171,7 @@ func (s *agentService) runBootstrapLoop(ctx context.Context) bool {
 			target := reqErr.target
 			retryCount := retry.next(target)
 			sleep := s.applyJitter(calculateBackoff(retryCount, s.maxBackoffSecs), s.backoffJitterPct)
+			metrics.BackoffRetries.WithLabelValues(target).Inc()
 			log.Printf(
 				"event=bootstrap_retry_scheduled target=%s retry_count=%d sleep_secs=%.3f",
 				target,
@@ -141,41 +202,85 @@ func (s *agentService) runPollLoop(ctx context.Context) {
 		if err := ctx.Err(); err != nil {
 			return
 		}
+		if !s.pollAndWait(ctx, retry) {
+			return
+		}
+	}
+}
 
-		err := s.pollOnce(ctx)
-		if err != nil {
-			var reqErr *reqError
-			if errors.As(err, &reqErr) {
-				target := reqErr.target
-				retryCount := retry.next(target)
-				sleep := s.applyJitter(calculateBackoff(retryCount, s.maxBackoffSecs), s.backoffJitterPct)
-				log.Printf(
-					"event=poll_retry_scheduled target=%s retry_count=%d sleep_secs=%.3f err=%q",
-					target,
-					retryCount,
-					sleep.Seconds(),
-					err,
-				)
-				if !sleepWithContext(ctx, sleep) {
-					return
-				}
-				continue
-			}
+// runStreamLoop applies versions pushed over the controller's event stream.
+// Whenever the stream drops, one round of the ETag poll loop catches up on
+// missed versions and paces the reconnect.
+func (s *agentService) runStreamLoop(ctx context.Context) {
+	retry := &retryState{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return
 		}
 
-		retry.reset()
-		interval := s.currentState.PollIntervalSeconds
-		if interval <= 0 {
-			interval = s.defaultPollSecs
+		log.Printf(
+			"event=config_stream_connecting agent_id=%s last_config_version=%d",
+			s.currentState.AgentID,
+			s.currentState.LastConfigVersion,
+		)
+		err := s.controller.StreamConfig(
+			ctx,
+			s.currentState.AgentID,
+			s.currentState.Credential,
+			s.currentState.LastConfigVersion,
+			func(cfg *model.Config, etag string) error {
+				return s.applyConfig(ctx, cfg, etag)
+			},
+			s.recordStreamActivity,
+		)
+		if ctx.Err() != nil {
+			return
 		}
-		log.Printf("event=next_poll_scheduled sleep_secs=%d", interval)
+		log.Printf("event=config_stream_dropped agent_id=%s err=%q", s.currentState.AgentID, err)
 
-		if !sleepWithContext(ctx, time.Duration(interval)*time.Second) {
+		if !s.pollAndWait(ctx, retry) {
 			return
 		}
 	}
 }
 
+// pollAndWait runs one poll and sleeps until the next one is due. It returns
+// false once ctx is done.
+func (s *agentService) pollAndWait(ctx context.Context, retry *retryState) bool {
+	err := s.pollOnce(ctx)
+	if err != nil {
+		var reqErr *reqError
+		if errors.As(err, &reqErr) {
+			target := reqErr.target
+			retryCount := retry.next(target)
+			sleep := s.applyJitter(calculateBackoff(retryCount, s.maxBackoffSecs), s.backoffJitterPct)
+			metrics.BackoffRetries.WithLabelValues(target).Inc()
+			log.Printf(
+				"event=poll_retry_scheduled target=%s retry_count=%d sleep_secs=%.3f err=%q",
+				target,
+				retryCount,
+				sleep.Seconds(),
+				err,
+			)
+			return sleepWithContext(ctx, sleep)
+		}
+	}
+
+	retry.reset()
+	// In long-poll mode the controller paces the loop by holding requests.
+	if err == nil && s.longPollWait > 0 {
+		return true
+	}
+
+	interval := s.currentState.PollIntervalSeconds
+	if interval <= 0 {
+		interval = s.defaultPollSecs
+	}
+	log.Printf("event=next_poll_scheduled sleep_secs=%d", interval)
+
+	return sleepWithContext(ctx, time.Duration(interval)*time.Second)
+}
+
 func (s *agentService) bootstrap(ctx context.Context) error {
 	log.Printf("event=bootstrap_started")
 
@@ -208,36 +313,56 @@ func (s *agentService) bootstrap(ctx context.Context) error {
 		state.PollIntervalSeconds = s.defaultPollSecs
 	}
 
-	// Rehydrate worker from local state so worker still has config even if controller returns 304.
+	// A cached config that no longer verifies, e.g. one saved before signing
+	// was enabled, is refetched instead of rehydrated.
 	if state.ConfigURL != "" {
-		cached := &model.Config{
-			Version:             state.LastConfigVersion,
-			URL:                 state.ConfigURL,
-			PollIntervalSeconds: state.PollIntervalSeconds,
+		if err := s.verifyConfig(cachedConfig(state), state.Namespace); err != nil {
+			log.Printf("event=state_config_signature_invalid version=%d err=%q", state.LastConfigVersion, err)
+			resetCachedConfig(state)
 		}
-		if err := s.worker.ApplyConfig(ctx, cached); err != nil {
+	}
+
+	// Rehydrate worker from local state so worker still has config even if controller returns 304.
+	if state.ConfigURL != "" {
+		cached := cachedConfig(state)
+		// Rehydration must not roll back a worker that kept a newer config,
+		// e.g. in its file store, so it is never forced.
+		err := s.worker.ApplyConfig(ctx, cached, false)
+		switch {
+		case errors.Is(err, client.ErrWorkerHasNewerVersion):
+			log.Printf("event=worker_has_newer_version version=%d err=%q", cached.Version, err)
+		case err != nil:
 			return &reqError{err: err, target: "worker"}
+		default:
+			log.Printf(
+				"event=worker_rehydrated_from_state version=%d url=%s poll_interval_secs=%d",
+				cached.Version,
+				cached.URL,
+				cached.PollIntervalSeconds,
+			)
 		}
-		log.Printf(
-			"event=worker_rehydrated_from_state version=%d url=%s poll_interval_secs=%d",
-			cached.Version,
-			cached.URL,
-			cached.PollIntervalSeconds,
-		)
 	}
 
-	reg, err := s.controller.Register(ctx, state.AgentID)
+	registration := s.registration
+	reg, err := s.controller.Register(ctx, state.AgentID, state.Credential, &registration)
 	if err != nil {
 		return &reqError{err: err, target: "controller"}
 	}
 	log.Printf(
-		"event=register_success agent_id=%s poll_url=%s poll_interval_secs=%d",
+		"event=register_success agent_id=%s enrolled=%t namespace=%s poll_url=%s poll_interval_secs=%d",
 		reg.AgentID,
+		reg.Credential != "",
+		reg.Namespace,
 		reg.PollURL,
 		reg.PollIntervalSeconds,
 	)
 
 	state.AgentID = reg.AgentID
+	// Only enrollment issues a credential; re-registration keeps the stored one.
+	if reg.Credential != "" {
+		state.Credential = reg.Credential
+	}
+	state.Namespace = reg.Namespace
 	if reg.PollURL != "" {
 		state.PollURL = reg.PollURL
 	}
@@ -262,7 +387,13 @@ func (s *agentService) bootstrap(ctx context.Context) error {
 	return nil
 }
 
-func (s *agentService) pollOnce(ctx context.Context) error {
+func (s *agentService) pollOnce(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "agent.poll")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	log.Printf(
 		"event=poll_started agent_id=%s poll_url=%s etag=%q",
 		s.currentState.AgentID,
@@ -273,12 +404,16 @@ func (s *agentService) pollOnce(ctx context.Context) error {
 	cfg, newETag, status, err := s.controller.GetConfig(
 		ctx,
 		s.currentState.AgentID,
+		s.currentState.Credential,
 		s.currentState.ETag,
 		s.currentState.PollURL,
+		s.longPollWait,
 	)
 	if err != nil {
+		metrics.Polls.WithLabelValues(metrics.PollError).Inc()
 		return &reqError{err: err, target: "controller"}
 	}
+	s.recordPoll()
 	log.Printf(
 		"event=poll_response status=%d etag=%q",
 		status,
@@ -291,28 +426,132 @@ func (s *agentService) pollOnce(ctx context.Context) error {
 			s.currentState.AgentID,
 			newETag,
 		)
+		metrics.Polls.WithLabelValues(metrics.PollNotModified).Inc()
 		return nil
 	}
 
 	if cfg == nil {
 		log.Printf("event=config_empty")
+		metrics.Polls.WithLabelValues(metrics.PollEmpty).Inc()
 		return nil
 	}
+
+	if err := s.applyConfig(ctx, cfg, newETag); err != nil {
+		metrics.Polls.WithLabelValues(metrics.PollApplyFailed).Inc()
+		return err
+	}
+	metrics.Polls.WithLabelValues(metrics.PollApplied).Inc()
+	return nil
+}
+
+// recordPoll notes that the controller answered. The agent stays ready for
+// readyPollIntervals poll intervals, each including the long-poll wait.
+func (s *agentService) recordPoll() {
+	s.markReachable(s.pollWindow())
+}
+
+// recordStreamActivity notes an event or keep-alive on the config stream. A
+// stream idle for client.StreamIdleTimeout is closed and replaced by a poll,
+// so the agent stays ready at least that long.
+func (s *agentService) recordStreamActivity() {
+	window := s.pollWindow()
+	if window < client.StreamIdleTimeout {
+		window = client.StreamIdleTimeout
+	}
+	s.markReachable(window)
+}
+
+func (s *agentService) pollWindow() time.Duration {
+	interval := s.currentState.PollIntervalSeconds
+	if interval <= 0 {
+		interval = s.defaultPollSecs
+	}
+	return time.Duration(s.readyPollIntervals) * (time.Duration(interval)*time.Second + s.longPollWait)
+}
+
+func (s *agentService) markReachable(window time.Duration) {
+	now := s.now()
+	s.lastPollAt.Store(now.UnixNano())
+	s.pollDeadline.Store(now.Add(window).UnixNano())
+}
+
+func (s *agentService) CheckBootstrap(ctx context.Context) error {
+	if !s.bootstrapped.Load() {
+		return ErrNotBootstrapped
+	}
+	return nil
+}
+
+// CheckPoll counts stream events and keep-alives as contact too, as streamed
+// versions arrive without polls.
+func (s *agentService) CheckPoll(ctx context.Context) error {
+	if !s.bootstrapped.Load() {
+		return ErrNotBootstrapped
+	}
+
+	now := s.now()
+	if deadline := time.Unix(0, s.pollDeadline.Load()); now.After(deadline) {
+		lastPoll := time.Unix(0, s.lastPollAt.Load())
+		return fmt.Errorf("last successful poll or stream activity was %s ago", now.Sub(lastPoll).Round(time.Second))
+	}
+	return nil
+}
+
+// applyConfig pushes a config received from the controller to the worker,
+// reports the result and persists it together with its ETag.
+func (s *agentService) applyConfig(ctx context.Context, cfg *model.Config, etag string) (err error) {
+	ctx, span := tracer.Start(ctx, "agent.apply")
+	span.SetAttributes(attribute.Int("config.version", cfg.Version))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	log.Printf(
-		"event=config_received version=%d poll_interval_secs=%d url=%s",
+		"event=config_received version=%d poll_interval_secs=%d url=%s data_bytes=%d",
 		cfg.Version,
 		cfg.PollIntervalSeconds,
 		cfg.URL,
+		len(cfg.Data),
 	)
 
-	if err := s.worker.ApplyConfig(ctx, cfg); err != nil {
+	if err := s.verifyConfig(cfg, s.currentState.Namespace); err != nil {
+		log.Printf("event=config_signature_invalid version=%d err=%q", cfg.Version, err)
+		s.reportStatus(ctx, &model.StatusReport{Version: cfg.Version, Status: model.StatusFailed, Error: err.Error()})
+		return err
+	}
+
+	// Versions come from one sequence across namespaces, so the controller
+	// serves a lower version on purpose, e.g. the base version after a rollout
+	// abort or the head of a new namespace. Only those downgrades are forced;
+	// the worker still rejects anything else older than what it has.
+	force := cfg.Version < s.currentState.LastConfigVersion
+	err = s.worker.ApplyConfig(ctx, cfg, force)
+	if errors.Is(err, client.ErrWorkerHasNewerVersion) && !force {
+		// The worker is ahead of what the controller serves, e.g. after the
+		// state file was lost. The controller is the source of truth, so its
+		// version is forced once instead of failing on every poll.
+		log.Printf("event=worker_has_newer_version version=%d err=%q action=force", cfg.Version, err)
+		force = true
+		err = s.worker.ApplyConfig(ctx, cfg, force)
+	}
+	if err != nil {
+		s.reportStatus(ctx, &model.StatusReport{Version: cfg.Version, Status: model.StatusFailed, Error: err.Error()})
 		return &reqError{err: err, target: "worker"}
 	}
-	log.Printf("event=worker_apply_success version=%d", cfg.Version)
+	log.Printf("event=worker_apply_success version=%d forced=%t", cfg.Version, force)
+	metrics.LastApplySuccess.SetToCurrentTime()
+	metrics.ConfigVersion.Set(float64(cfg.Version))
+	s.reportStatus(ctx, &model.StatusReport{Version: cfg.Version, Status: model.StatusApplied})
 
-	s.currentState.ETag = newETag
+	s.currentState.ETag = etag
 	s.currentState.ConfigURL = cfg.URL
+	s.currentState.ConfigData = cfg.Data
+	s.currentState.ConfigSecrets = cfg.Secrets
 	s.currentState.LastConfigVersion = cfg.Version
+	s.currentState.ConfigSignature = cfg.Signature
+	s.currentState.ConfigNamespace = cfg.Namespace
+	s.currentState.ConfigPollIntervalSeconds = cfg.PollIntervalSeconds
 	if cfg.PollIntervalSeconds > 0 {
 		s.currentState.PollIntervalSeconds = cfg.PollIntervalSeconds
 	}
@@ -331,3 +570,68 @@ func (s *agentService) pollOnce(ctx context.Context) error {
 
 	return nil
 }
+
+// verifyConfig checks the controller's signature on cfg and that it was issued
+// for namespace. Without a verify key every config is accepted.
+func (s *agentService) verifyConfig(cfg *model.Config, namespace string) error {
+	if s.verifyKey == nil {
+		return nil
+	}
+
+	err := signing.Verify(s.verifyKey, signing.Payload{
+		Version:             cfg.Version,
+		Namespace:           cfg.Namespace,
+		URL:                 cfg.URL,
+		PollIntervalSeconds: cfg.PollIntervalSeconds,
+		Data:                cfg.Data,
+		Secrets:             cfg.Secrets,
+	}, cfg.Signature)
+	if err != nil {
+		return err
+	}
+	if cfg.Namespace != namespace {
+		return fmt.Errorf("config signed for namespace %q, agent is in %q", cfg.Namespace, namespace)
+	}
+	return nil
+}
+
+// cachedConfig rebuilds the last applied config from the state file.
+func cachedConfig(state *model.State) *model.Config {
+	return &model.Config{
+		Version:             state.LastConfigVersion,
+		Namespace:           state.ConfigNamespace,
+		URL:                 state.ConfigURL,
+		PollIntervalSeconds: state.ConfigPollIntervalSeconds,
+		Data:                state.ConfigData,
+		Secrets:             state.ConfigSecrets,
+		Signature:           state.ConfigSignature,
+	}
+}
+
+// resetCachedConfig drops the cached config so the next poll fetches it again.
+func resetCachedConfig(state *model.State) {
+	state.ETag = ""
+	state.LastConfigVersion = 0
+	state.ConfigURL = ""
+	state.ConfigData = nil
+	state.ConfigSecrets = nil
+	state.ConfigSignature = ""
+	state.ConfigNamespace = ""
+	state.ConfigPollIntervalSeconds = 0
+}
+
+// reportStatus acknowledges an apply result to the controller. Failures are
+// only logged: the next delivery of the same version reports again.
+func (s *agentService) reportStatus(ctx context.Context, report *model.StatusReport) {
+	if err := s.controller.ReportStatus(ctx, s.currentState.AgentID, s.currentState.Credential, report); err != nil {
+		log.Printf(
+			"event=status_report_failed agent_id=%s version=%d status=%s err=%q",
+			s.currentState.AgentID,
+			report.Version,
+			report.Status,
+			err,
+		)
+		return
+	}
+	log.Printf("event=status_reported version=%d status=%s", report.Version, report.Status)
+}
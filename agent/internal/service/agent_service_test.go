@@ -1,16 +1,26 @@
 package service
 
 import (
+	"agent/internal/client"
+	"agent/internal/metrics"
 	clientMocks "agent/internal/mocks/client"
 	repositoryMocks "agent/internal/mocks/repository"
 	"agent/internal/model"
+	"agent/internal/repository"
 	"context"
+	"crypto/ed25519"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/mrheza/distributed-config-management/shared/signing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAgentService_GetState_Defaults(t *testing.T) {
@@ -18,13 +28,34 @@ func TestAgentService_GetState_Defaults(t *testing.T) {
 	worker := new(clientMocks.WorkerClient)
 	stateRepo := new(repositoryMocks.StateRepository)
 
-	svc := NewAgentService(controller, worker, stateRepo, "/config", 30, 60, 20)
+	svc := NewAgentService(controller, worker, stateRepo, model.RegisterRequest{Namespace: "prod"}, "/config", 30, 60, 20, 0, false, nil, 3)
 	state := svc.GetState()
 
+	assert.Equal(t, "prod", state.Namespace)
 	assert.Equal(t, "/config", state.PollURL)
 	assert.Equal(t, 30, state.PollIntervalSeconds)
 }
 
+func TestAgentService_GetState_HidesCredential(t *testing.T) {
+	svc := newService(new(clientMocks.ControllerClient), new(clientMocks.WorkerClient), new(repositoryMocks.StateRepository))
+	svc.currentState.Credential = "cred-1"
+
+	assert.Empty(t, svc.GetState().Credential)
+	assert.Equal(t, "cred-1", svc.currentState.Credential)
+}
+
+func TestAgentService_GetState_RedactsSecrets(t *testing.T) {
+	svc := newService(new(clientMocks.ControllerClient), new(clientMocks.WorkerClient), new(repositoryMocks.StateRepository))
+	svc.currentState.ConfigSecrets = map[string]string{"db_password": "hunter2"}
+	svc.currentState.ConfigSignature = "c2lnbmF0dXJl"
+
+	state := svc.GetState()
+	assert.Equal(t, map[string]string{"db_password": "[REDACTED]"}, state.ConfigSecrets)
+	assert.Empty(t, state.ConfigSignature)
+	assert.Equal(t, "hunter2", svc.currentState.ConfigSecrets["db_password"])
+	assert.Equal(t, "c2lnbmF0dXJl", svc.currentState.ConfigSignature)
+}
+
 func TestApplyJitter(t *testing.T) {
 	base := 10 * time.Second
 
@@ -67,13 +98,15 @@ func newService(
 	stateRepo *repositoryMocks.StateRepository,
 ) *agentService {
 	return &agentService{
-		controller:       controller,
-		worker:           worker,
-		stateRepo:        stateRepo,
-		defaultPollURL:   "/config",
-		defaultPollSecs:  1,
-		maxBackoffSecs:   2,
-		backoffJitterPct: 0,
+		controller:         controller,
+		worker:             worker,
+		stateRepo:          stateRepo,
+		defaultPollURL:     "/config",
+		defaultPollSecs:    1,
+		maxBackoffSecs:     2,
+		backoffJitterPct:   0,
+		readyPollIntervals: 3,
+		now:                time.Now,
 		currentState: &model.State{
 			AgentID:             "agent-1",
 			PollURL:             "/config",
@@ -89,7 +122,7 @@ func TestBootstrap_Success(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 
 	stateRepo.On("Load").Return(&model.State{}, nil).Once()
-	controller.On("Register", mock.Anything, "").Return(&model.RegisterResponse{
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
 		AgentID:             "agent-new",
 		PollURL:             "/config",
 		PollIntervalSeconds: 30,
@@ -106,6 +139,79 @@ func TestBootstrap_Success(t *testing.T) {
 	worker.AssertExpectations(t)
 }
 
+func TestBootstrap_SendsNamespace(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+	svc.registration = model.RegisterRequest{Namespace: "staging"}
+
+	stateRepo.On("Load").Return(&model.State{}, nil).Once()
+	controller.On("Register", mock.Anything, "", "", &model.RegisterRequest{Namespace: "staging"}).Return(&model.RegisterResponse{
+		AgentID:             "agent-new",
+		Namespace:           "staging",
+		PollURL:             "/config",
+		PollIntervalSeconds: 30,
+	}, nil).Once()
+	stateRepo.On("Save", mock.MatchedBy(func(state *model.State) bool {
+		return state.Namespace == "staging"
+	})).Return(nil).Once()
+
+	err := svc.bootstrap(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", svc.currentState.Namespace)
+
+	controller.AssertExpectations(t)
+	stateRepo.AssertExpectations(t)
+}
+
+func TestBootstrap_PersistsEnrollmentCredential(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+	svc.registration = model.RegisterRequest{EnrollmentToken: "enroll-token"}
+
+	stateRepo.On("Load").Return(&model.State{}, nil).Once()
+	controller.On("Register", mock.Anything, "", "", &model.RegisterRequest{EnrollmentToken: "enroll-token"}).Return(&model.RegisterResponse{
+		AgentID:    "agent-new",
+		PollURL:    "/config",
+		Credential: "cred-1",
+	}, nil).Once()
+	stateRepo.On("Save", mock.MatchedBy(func(state *model.State) bool {
+		return state.AgentID == "agent-new" && state.Credential == "cred-1"
+	})).Return(nil).Once()
+
+	err := svc.bootstrap(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "cred-1", svc.currentState.Credential)
+
+	controller.AssertExpectations(t)
+	stateRepo.AssertExpectations(t)
+}
+
+func TestBootstrap_ReRegisterKeepsCredential(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+
+	stateRepo.On("Load").Return(&model.State{AgentID: "agent-old", Credential: "cred-1"}, nil).Once()
+	controller.On("Register", mock.Anything, "agent-old", "cred-1", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
+		AgentID: "agent-old",
+		PollURL: "/config",
+	}, nil).Once()
+	stateRepo.On("Save", mock.MatchedBy(func(state *model.State) bool {
+		return state.Credential == "cred-1"
+	})).Return(nil).Once()
+
+	err := svc.bootstrap(context.Background())
+	assert.NoError(t, err)
+
+	controller.AssertExpectations(t)
+	stateRepo.AssertExpectations(t)
+}
+
 func TestBootstrap_RehydrateWorkerFromState(t *testing.T) {
 	controller := new(clientMocks.ControllerClient)
 	worker := new(clientMocks.WorkerClient)
@@ -115,10 +221,13 @@ func TestBootstrap_RehydrateWorkerFromState(t *testing.T) {
 	loaded := &model.State{
 		AgentID:             "agent-old",
 		ConfigURL:           "https://example.com/from-state",
+		ConfigData:          json.RawMessage(`{"mode":"cached"}`),
 		PollURL:             "/config",
 		PollIntervalSeconds: 20,
 		ETag:                "\"7\"",
 		LastConfigVersion:   7,
+
+		ConfigPollIntervalSeconds: 20,
 	}
 
 	stateRepo.On("Load").Return(loaded, nil).Once()
@@ -126,9 +235,10 @@ func TestBootstrap_RehydrateWorkerFromState(t *testing.T) {
 		return cfg != nil &&
 			cfg.URL == "https://example.com/from-state" &&
 			cfg.Version == 7 &&
-			cfg.PollIntervalSeconds == 20
-	})).Return(nil).Once()
-	controller.On("Register", mock.Anything, "agent-old").Return(&model.RegisterResponse{
+			cfg.PollIntervalSeconds == 20 &&
+			string(cfg.Data) == `{"mode":"cached"}`
+	}), false).Return(nil).Once()
+	controller.On("Register", mock.Anything, "agent-old", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
 		AgentID:             "agent-old",
 		PollURL:             "/config",
 		PollIntervalSeconds: 20,
@@ -139,6 +249,69 @@ func TestBootstrap_RehydrateWorkerFromState(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestBootstrap_RehydrateIgnoresNewerWorker(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+
+	stateRepo.On("Load").Return(&model.State{
+		AgentID:             "agent-old",
+		ConfigURL:           "https://example.com/from-state",
+		PollURL:             "/config",
+		PollIntervalSeconds: 20,
+		LastConfigVersion:   7,
+	}, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, mock.AnythingOfType("*model.Config"), false).
+		Return(fmt.Errorf("%w: worker has version 9", client.ErrWorkerHasNewerVersion)).Once()
+	controller.On("Register", mock.Anything, "agent-old", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
+		AgentID:             "agent-old",
+		PollURL:             "/config",
+		PollIntervalSeconds: 20,
+	}, nil).Once()
+	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
+
+	err := svc.bootstrap(context.Background())
+	assert.NoError(t, err)
+	controller.AssertExpectations(t)
+}
+
+func TestBootstrap_SkipsRehydrateForUnverifiedState(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+	svc.verifyKey = pub
+
+	loaded := &model.State{
+		AgentID:             "agent-old",
+		Namespace:           "prod",
+		ConfigURL:           "https://example.com/from-state",
+		ConfigData:          json.RawMessage(`{"mode":"cached"}`),
+		PollURL:             "/config",
+		PollIntervalSeconds: 20,
+		ETag:                "\"7\"",
+		LastConfigVersion:   7,
+	}
+
+	stateRepo.On("Load").Return(loaded, nil).Once()
+	controller.On("Register", mock.Anything, "agent-old", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
+		AgentID:   "agent-old",
+		Namespace: "prod",
+	}, nil).Once()
+	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
+
+	err = svc.bootstrap(context.Background())
+	assert.NoError(t, err)
+	worker.AssertNotCalled(t, "ApplyConfig", mock.Anything, mock.Anything, mock.Anything)
+	assert.Empty(t, svc.currentState.ETag)
+	assert.Empty(t, svc.currentState.ConfigURL)
+	assert.Equal(t, 0, svc.currentState.LastConfigVersion)
+}
+
 func TestBootstrap_ResetETagWhenConfigURLMissing(t *testing.T) {
 	controller := new(clientMocks.ControllerClient)
 	worker := new(clientMocks.WorkerClient)
@@ -153,7 +326,7 @@ func TestBootstrap_ResetETagWhenConfigURLMissing(t *testing.T) {
 		LastConfigVersion:   9,
 	}, nil).Once()
 
-	controller.On("Register", mock.Anything, "agent-old").Return(&model.RegisterResponse{
+	controller.On("Register", mock.Anything, "agent-old", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
 		AgentID:             "agent-old",
 		PollURL:             "/config",
 		PollIntervalSeconds: 30,
@@ -189,7 +362,7 @@ func TestBootstrap_RegisterError_Wrapped(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 
 	stateRepo.On("Load").Return(&model.State{}, nil).Once()
-	controller.On("Register", mock.Anything, "").Return((*model.RegisterResponse)(nil), errors.New("controller down")).Once()
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return((*model.RegisterResponse)(nil), errors.New("controller down")).Once()
 
 	err := svc.bootstrap(context.Background())
 	assert.Error(t, err)
@@ -203,7 +376,7 @@ func TestPollOnce_ControllerError_Wrapped(t *testing.T) {
 	stateRepo := new(repositoryMocks.StateRepository)
 	svc := newService(controller, worker, stateRepo)
 
-	controller.On("GetConfig", mock.Anything, "agent-1", "", "/config").Return((*model.Config)(nil), "", 0, errors.New("timeout")).Once()
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return((*model.Config)(nil), "", 0, errors.New("timeout")).Once()
 
 	err := svc.pollOnce(context.Background())
 	assert.Error(t, err)
@@ -218,10 +391,12 @@ func TestPollOnce_NotModified(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 	svc.currentState.ETag = "\"1\""
 
-	controller.On("GetConfig", mock.Anything, "agent-1", "\"1\"", "/config").Return((*model.Config)(nil), "\"1\"", 304, nil).Once()
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "\"1\"", "/config", time.Duration(0)).Return((*model.Config)(nil), "\"1\"", 304, nil).Once()
 
+	before := testutil.ToFloat64(metrics.Polls.WithLabelValues(metrics.PollNotModified))
 	err := svc.pollOnce(context.Background())
 	assert.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.Polls.WithLabelValues(metrics.PollNotModified)))
 }
 
 func TestPollOnce_ApplyError(t *testing.T) {
@@ -231,12 +406,102 @@ func TestPollOnce_ApplyError(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 
 	cfg := &model.Config{Version: 2, URL: "http://example.com", PollIntervalSeconds: 20}
-	controller.On("GetConfig", mock.Anything, "agent-1", "", "/config").Return(cfg, "\"2\"", 200, nil).Once()
-	worker.On("ApplyConfig", mock.Anything, cfg).Return(errors.New("worker fail")).Once()
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return(cfg, "\"2\"", 200, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(errors.New("worker fail")).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", &model.StatusReport{
+		Version: 2,
+		Status:  model.StatusFailed,
+		Error:   "worker fail",
+	}).Return(nil).Once()
 
 	err := svc.pollOnce(context.Background())
 	assert.Error(t, err)
 	assert.EqualError(t, err, "worker fail")
+	controller.AssertExpectations(t)
+}
+
+func TestPollOnce_WorkerHasNewerVersion_ForcesAfterLostState(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+
+	// The state file was lost, so the agent no longer knows that the worker
+	// applied version 5 before the controller rolled back to version 2.
+	cfg := &model.Config{Version: 2, URL: "http://example.com", PollIntervalSeconds: 20}
+	werr := fmt.Errorf("%w: worker has version 5", client.ErrWorkerHasNewerVersion)
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return(cfg, "\"2\"", 200, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(werr).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, true).Return(nil).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", &model.StatusReport{Version: 2, Status: model.StatusApplied}).Return(nil).Once()
+	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
+
+	err := svc.pollOnce(context.Background())
+	assert.NoError(t, err)
+	worker.AssertExpectations(t)
+	controller.AssertExpectations(t)
+	stateRepo.AssertExpectations(t)
+	assert.Equal(t, "\"2\"", svc.currentState.ETag)
+	assert.Equal(t, 2, svc.currentState.LastConfigVersion)
+	assert.Equal(t, "http://example.com", svc.currentState.ConfigURL)
+}
+
+func TestPollOnce_WorkerHasNewerVersion_ForceFails(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+
+	svc.currentState.ETag = "\"1\""
+	svc.currentState.ConfigURL = "http://example.com/v1"
+	svc.currentState.LastConfigVersion = 1
+
+	cfg := &model.Config{Version: 2, URL: "http://example.com", PollIntervalSeconds: 20}
+	werr := fmt.Errorf("%w: worker has version 5", client.ErrWorkerHasNewerVersion)
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "\"1\"", "/config", time.Duration(0)).Return(cfg, "\"2\"", 200, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(werr).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, true).Return(errors.New("worker fail")).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", &model.StatusReport{
+		Version: 2,
+		Status:  model.StatusFailed,
+		Error:   "worker fail",
+	}).Return(nil).Once()
+
+	err := svc.pollOnce(context.Background())
+	assert.EqualError(t, err, "worker fail")
+	worker.AssertExpectations(t)
+	controller.AssertExpectations(t)
+	stateRepo.AssertNotCalled(t, "Save", mock.Anything)
+	assert.Equal(t, "\"1\"", svc.currentState.ETag)
+	assert.Equal(t, 1, svc.currentState.LastConfigVersion)
+	assert.Equal(t, "http://example.com/v1", svc.currentState.ConfigURL)
+}
+
+func TestPollOnce_RolloutAbortForcesDowngrade(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+
+	// The canary version 8 was applied; aborting the rollout serves the base
+	// version 5 again.
+	svc.currentState.ETag = "\"8\""
+	svc.currentState.ConfigURL = "http://example.com/canary"
+	svc.currentState.LastConfigVersion = 8
+
+	base := &model.Config{Version: 5, URL: "http://example.com/base"}
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "\"8\"", "/config", time.Duration(0)).Return(base, "\"5\"", 200, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, base, true).Return(nil).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", &model.StatusReport{Version: 5, Status: model.StatusApplied}).Return(nil).Once()
+	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
+
+	err := svc.pollOnce(context.Background())
+	assert.NoError(t, err)
+	worker.AssertExpectations(t)
+	controller.AssertExpectations(t)
+	assert.Equal(t, 5, svc.currentState.LastConfigVersion)
+	assert.Equal(t, "http://example.com/base", svc.currentState.ConfigURL)
+	assert.Equal(t, "\"5\"", svc.currentState.ETag)
 }
 
 func TestPollOnce_Success_UpdatesStateAndSaves(t *testing.T) {
@@ -245,17 +510,281 @@ func TestPollOnce_Success_UpdatesStateAndSaves(t *testing.T) {
 	stateRepo := new(repositoryMocks.StateRepository)
 	svc := newService(controller, worker, stateRepo)
 
-	cfg := &model.Config{Version: 3, URL: "http://example.com", PollIntervalSeconds: 15}
-	controller.On("GetConfig", mock.Anything, "agent-1", "", "/config").Return(cfg, "\"3\"", 200, nil).Once()
-	worker.On("ApplyConfig", mock.Anything, cfg).Return(nil).Once()
+	cfg := &model.Config{
+		Version:             3,
+		URL:                 "http://example.com",
+		PollIntervalSeconds: 15,
+		Data:                json.RawMessage(`{"retries":3}`),
+	}
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return(cfg, "\"3\"", 200, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(nil).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", &model.StatusReport{Version: 3, Status: model.StatusApplied}).Return(nil).Once()
 	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
 
 	err := svc.pollOnce(context.Background())
 	assert.NoError(t, err)
+	controller.AssertExpectations(t)
 	assert.Equal(t, "\"3\"", svc.currentState.ETag)
 	assert.Equal(t, "http://example.com", svc.currentState.ConfigURL)
+	assert.JSONEq(t, `{"retries":3}`, string(svc.currentState.ConfigData))
 	assert.Equal(t, 3, svc.currentState.LastConfigVersion)
 	assert.Equal(t, 15, svc.currentState.PollIntervalSeconds)
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.ConfigVersion))
+	assert.NotZero(t, testutil.ToFloat64(metrics.LastApplySuccess))
+}
+
+func signConfig(t *testing.T, key ed25519.PrivateKey, cfg *model.Config) {
+	t.Helper()
+	sig, err := signing.Sign(key, signing.Payload{
+		Version:             cfg.Version,
+		Namespace:           cfg.Namespace,
+		URL:                 cfg.URL,
+		PollIntervalSeconds: cfg.PollIntervalSeconds,
+		Data:                cfg.Data,
+		Secrets:             cfg.Secrets,
+	})
+	require.NoError(t, err)
+	cfg.Signature = sig
+}
+
+func TestPollOnce_VerifiesSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+	svc.verifyKey = pub
+	svc.currentState.Namespace = "prod"
+
+	cfg := &model.Config{
+		Version:             4,
+		Namespace:           "prod",
+		URL:                 "http://example.com",
+		PollIntervalSeconds: 15,
+		Data:                json.RawMessage(`{"retries":3}`),
+		Secrets:             map[string]string{"db_password": "hunter2"},
+	}
+	signConfig(t, priv, cfg)
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return(cfg, "\"4\"", 200, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(nil).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", &model.StatusReport{Version: 4, Status: model.StatusApplied}).Return(nil).Once()
+	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
+
+	err = svc.pollOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, cfg.Signature, svc.currentState.ConfigSignature)
+	assert.Equal(t, cfg.Secrets, svc.currentState.ConfigSecrets)
+}
+
+func TestBootstrap_RestartRehydratesSignedConfig(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	stateRepo := repository.NewFileStateRepository(filepath.Join(t.TempDir(), "state.json"))
+
+	// Interval 0 falls back to the agent's poll interval, and registration
+	// sets another one; neither may break the cached signature.
+	cfg := &model.Config{Version: 4, Namespace: "prod", URL: "http://example.com", PollIntervalSeconds: 0}
+	signConfig(t, priv, cfg)
+
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	svc := newService(controller, worker, nil)
+	svc.stateRepo = stateRepo
+	svc.verifyKey = pub
+	svc.currentState.Namespace = "prod"
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return(cfg, "\"4\"", 200, nil).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", mock.Anything).Return(nil).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(nil).Once()
+	require.NoError(t, svc.pollOnce(context.Background()))
+
+	controller = new(clientMocks.ControllerClient)
+	worker = new(clientMocks.WorkerClient)
+	restarted := newService(controller, worker, nil)
+	restarted.stateRepo = stateRepo
+	restarted.verifyKey = pub
+	worker.On("ApplyConfig", mock.Anything, mock.MatchedBy(func(cached *model.Config) bool {
+		return cached.Version == 4 && cached.PollIntervalSeconds == 0 && cached.Signature == cfg.Signature
+	}), false).Return(nil).Once()
+	controller.On("Register", mock.Anything, "agent-1", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
+		AgentID:             "agent-1",
+		Namespace:           "prod",
+		PollIntervalSeconds: 30,
+	}, nil).Twice()
+
+	require.NoError(t, restarted.bootstrap(context.Background()))
+	worker.AssertExpectations(t)
+	assert.Equal(t, "\"4\"", restarted.currentState.ETag)
+
+	// Registration saved interval 30; the next restart still verifies.
+	worker.On("ApplyConfig", mock.Anything, mock.AnythingOfType("*model.Config"), false).Return(nil).Once()
+	require.NoError(t, restarted.bootstrap(context.Background()))
+	worker.AssertExpectations(t)
+	assert.Equal(t, 4, restarted.currentState.LastConfigVersion)
+}
+
+func TestPollOnce_RejectsInvalidSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		namespace string
+		tamper    func(cfg *model.Config)
+	}{
+		{name: "unsigned", namespace: "prod", tamper: func(cfg *model.Config) { cfg.Signature = "" }},
+		{name: "tampered data", namespace: "prod", tamper: func(cfg *model.Config) { cfg.Data = json.RawMessage(`{"retries":9}`) }},
+		{name: "tampered secrets", namespace: "prod", tamper: func(cfg *model.Config) { cfg.Secrets = map[string]string{"token": "x"} }},
+		{name: "other namespace", namespace: "staging", tamper: func(cfg *model.Config) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := new(clientMocks.ControllerClient)
+			worker := new(clientMocks.WorkerClient)
+			stateRepo := new(repositoryMocks.StateRepository)
+			svc := newService(controller, worker, stateRepo)
+			svc.verifyKey = pub
+			svc.currentState.Namespace = "prod"
+
+			cfg := &model.Config{Version: 5, Namespace: tt.namespace, URL: "http://example.com", Data: json.RawMessage(`{"retries":3}`)}
+			signConfig(t, priv, cfg)
+			tt.tamper(cfg)
+			controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return(cfg, "\"5\"", 200, nil).Once()
+			controller.On("ReportStatus", mock.Anything, "agent-1", "", mock.MatchedBy(func(r *model.StatusReport) bool {
+				return r.Version == 5 && r.Status == model.StatusFailed && r.Error != ""
+			})).Return(nil).Once()
+
+			err := svc.pollOnce(context.Background())
+			assert.Error(t, err)
+			worker.AssertNotCalled(t, "ApplyConfig", mock.Anything, mock.Anything, mock.Anything)
+			stateRepo.AssertNotCalled(t, "Save", mock.Anything)
+			controller.AssertExpectations(t)
+			assert.Empty(t, svc.currentState.ETag)
+		})
+	}
+}
+
+func TestPollOnce_ReportStatusError_DoesNotFailPoll(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+
+	cfg := &model.Config{Version: 3, URL: "http://example.com", PollIntervalSeconds: 15}
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return(cfg, "\"3\"", 200, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(nil).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", mock.Anything).Return(errors.New("controller down")).Once()
+	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
+
+	err := svc.pollOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, svc.currentState.LastConfigVersion)
+}
+
+func TestRun_LongPoll_PollsAgainWithoutSleeping(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+	svc.longPollWait = time.Minute
+
+	stateRepo.On("Load").Return(&model.State{PollURL: "/config", PollIntervalSeconds: 30}, nil).Once()
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
+		AgentID:             "agent-run",
+		PollURL:             "/config",
+		PollIntervalSeconds: 30,
+	}, nil).Once()
+	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
+	controller.On("GetConfig", mock.Anything, "agent-run", "", "", "/config", time.Minute).
+		Return((*model.Config)(nil), "", 304, nil).
+		Twice()
+	controller.On("GetConfig", mock.Anything, "agent-run", "", "", "/config", time.Minute).
+		Return((*model.Config)(nil), "", 304, nil).
+		WaitUntil(time.After(200 * time.Millisecond)).
+		Maybe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	svc.Run(ctx)
+
+	// A 30s poll interval would allow a single poll within the deadline.
+	controller.AssertNumberOfCalls(t, "GetConfig", 3)
+}
+
+func TestReadinessChecks(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+	svc.currentState.PollIntervalSeconds = 10
+
+	now := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	assert.ErrorIs(t, svc.CheckBootstrap(context.Background()), ErrNotBootstrapped)
+	assert.ErrorIs(t, svc.CheckPoll(context.Background()), ErrNotBootstrapped)
+
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return((*model.Config)(nil), "", 304, nil).Once()
+	svc.bootstrapped.Store(true)
+	assert.NoError(t, svc.pollOnce(context.Background()))
+	assert.NoError(t, svc.CheckBootstrap(context.Background()))
+
+	// Three intervals of ten seconds.
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, svc.CheckPoll(context.Background()))
+
+	now = now.Add(time.Second)
+	assert.EqualError(t, svc.CheckPoll(context.Background()), "last successful poll or stream activity was 31s ago")
+
+	// Keep-alives keep a stream ready for the stream idle timeout, longer than
+	// three poll intervals here.
+	svc.recordStreamActivity()
+	now = now.Add(client.StreamIdleTimeout)
+	assert.NoError(t, svc.CheckPoll(context.Background()))
+
+	// A half-open stream stops delivering keep-alives.
+	now = now.Add(time.Second)
+	assert.EqualError(t, svc.CheckPoll(context.Background()), "last successful poll or stream activity was 1m16s ago")
+}
+
+func TestRun_Stream_AppliesEventsAndFallsBackToPoll(t *testing.T) {
+	controller := new(clientMocks.ControllerClient)
+	worker := new(clientMocks.WorkerClient)
+	stateRepo := new(repositoryMocks.StateRepository)
+	svc := newService(controller, worker, stateRepo)
+	svc.stream = true
+
+	stateRepo.On("Load").Return(&model.State{PollURL: "/config", PollIntervalSeconds: 1}, nil).Once()
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
+		AgentID:             "agent-run",
+		PollURL:             "/config",
+		PollIntervalSeconds: 1,
+	}, nil).Once()
+	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Twice()
+
+	cfg := &model.Config{Version: 5, URL: "http://example.com", PollIntervalSeconds: 1}
+	controller.On("StreamConfig", mock.Anything, "agent-run", "", 0, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			onConfig := args.Get(4).(func(*model.Config, string) error)
+			assert.NoError(t, onConfig(cfg, "\"5\""))
+		}).
+		Return(errors.New("config stream closed by controller")).
+		Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(nil).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-run", "", &model.StatusReport{Version: 5, Status: model.StatusApplied}).Return(nil).Once()
+	controller.On("GetConfig", mock.Anything, "agent-run", "", "\"5\"", "/config", time.Duration(0)).
+		Return((*model.Config)(nil), "\"5\"", 304, nil).
+		Once()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	svc.Run(ctx)
+
+	assert.Equal(t, 5, svc.currentState.LastConfigVersion)
+	controller.AssertExpectations(t)
+	worker.AssertExpectations(t)
+	stateRepo.AssertExpectations(t)
 }
 
 func TestRun_StopsOnContextCancellation(t *testing.T) {
@@ -266,13 +795,13 @@ func TestRun_StopsOnContextCancellation(t *testing.T) {
 
 	loaded := &model.State{PollURL: "/config", PollIntervalSeconds: 1}
 	stateRepo.On("Load").Return(loaded, nil).Once()
-	controller.On("Register", mock.Anything, "").Return(&model.RegisterResponse{
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
 		AgentID:             "agent-run",
 		PollURL:             "/config",
 		PollIntervalSeconds: 1,
 	}, nil).Once()
 	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
-	controller.On("GetConfig", mock.Anything, "agent-run", "", "/config").Return((*model.Config)(nil), "", 304, nil).Maybe()
+	controller.On("GetConfig", mock.Anything, "agent-run", "", "", "/config", time.Duration(0)).Return((*model.Config)(nil), "", 304, nil).Maybe()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
 	defer cancel()
@@ -287,7 +816,7 @@ func TestBootstrap_SaveError(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 
 	stateRepo.On("Load").Return(&model.State{}, nil).Once()
-	controller.On("Register", mock.Anything, "").Return(&model.RegisterResponse{
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
 		AgentID:             "agent-new",
 		PollURL:             "/config",
 		PollIntervalSeconds: 30,
@@ -305,7 +834,7 @@ func TestPollOnce_ConfigNil_NoError(t *testing.T) {
 	stateRepo := new(repositoryMocks.StateRepository)
 	svc := newService(controller, worker, stateRepo)
 
-	controller.On("GetConfig", mock.Anything, "agent-1", "", "/config").Return((*model.Config)(nil), "", 200, nil).Once()
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return((*model.Config)(nil), "", 200, nil).Once()
 
 	err := svc.pollOnce(context.Background())
 	assert.NoError(t, err)
@@ -318,8 +847,9 @@ func TestPollOnce_SaveError(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 
 	cfg := &model.Config{Version: 3, URL: "http://example.com", PollIntervalSeconds: 15}
-	controller.On("GetConfig", mock.Anything, "agent-1", "", "/config").Return(cfg, "\"3\"", 200, nil).Once()
-	worker.On("ApplyConfig", mock.Anything, cfg).Return(nil).Once()
+	controller.On("GetConfig", mock.Anything, "agent-1", "", "", "/config", time.Duration(0)).Return(cfg, "\"3\"", 200, nil).Once()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(nil).Once()
+	controller.On("ReportStatus", mock.Anything, "agent-1", "", mock.Anything).Return(nil).Once()
 	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(errors.New("save fail")).Once()
 
 	err := svc.pollOnce(context.Background())
@@ -341,7 +871,7 @@ func TestRun_BootstrapControllerError_UsesBackoffPath(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 
 	stateRepo.On("Load").Return(&model.State{}, nil).Once()
-	controller.On("Register", mock.Anything, "").Return((*model.RegisterResponse)(nil), errors.New("controller down")).Once()
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return((*model.RegisterResponse)(nil), errors.New("controller down")).Once()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
 	defer cancel()
@@ -368,13 +898,13 @@ func TestRun_PollControllerError_UsesBackoffPath(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 
 	stateRepo.On("Load").Return(&model.State{PollURL: "/config", PollIntervalSeconds: 1}, nil).Once()
-	controller.On("Register", mock.Anything, "").Return(&model.RegisterResponse{
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
 		AgentID:             "agent-run",
 		PollURL:             "/config",
 		PollIntervalSeconds: 1,
 	}, nil).Once()
 	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
-	controller.On("GetConfig", mock.Anything, "agent-run", "", "/config").Return((*model.Config)(nil), "", 0, errors.New("controller timeout")).Maybe()
+	controller.On("GetConfig", mock.Anything, "agent-run", "", "", "/config", time.Duration(0)).Return((*model.Config)(nil), "", 0, errors.New("controller timeout")).Maybe()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
 	defer cancel()
@@ -388,15 +918,16 @@ func TestRun_PollNonControllerError_UsesNormalInterval(t *testing.T) {
 	svc := newService(controller, worker, stateRepo)
 
 	stateRepo.On("Load").Return(&model.State{PollURL: "/config", PollIntervalSeconds: 1}, nil).Once()
-	controller.On("Register", mock.Anything, "").Return(&model.RegisterResponse{
+	controller.On("Register", mock.Anything, "", "", mock.AnythingOfType("*model.RegisterRequest")).Return(&model.RegisterResponse{
 		AgentID:             "agent-run",
 		PollURL:             "/config",
 		PollIntervalSeconds: 1,
 	}, nil).Once()
 	stateRepo.On("Save", mock.AnythingOfType("*model.State")).Return(nil).Once()
 	cfg := &model.Config{Version: 2, URL: "http://example.com", PollIntervalSeconds: 1}
-	controller.On("GetConfig", mock.Anything, "agent-run", "", "/config").Return(cfg, "\"2\"", 200, nil).Maybe()
-	worker.On("ApplyConfig", mock.Anything, cfg).Return(errors.New("worker fail")).Maybe()
+	controller.On("GetConfig", mock.Anything, "agent-run", "", "", "/config", time.Duration(0)).Return(cfg, "\"2\"", 200, nil).Maybe()
+	worker.On("ApplyConfig", mock.Anything, cfg, false).Return(errors.New("worker fail")).Maybe()
+	controller.On("ReportStatus", mock.Anything, "agent-run", "", mock.Anything).Return(nil).Maybe()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
 	defer cancel()
@@ -1,16 +1,29 @@
 package main
 
 import (
+	"context"
 	_ "controller/docs"
 	"controller/internal/config"
 	"controller/internal/db"
 	"controller/internal/handler"
+	"controller/internal/metrics"
 	"controller/internal/middleware"
+	"controller/internal/model"
 	postgresRepo "controller/internal/repository/postgres"
 	"controller/internal/service"
+	"crypto/ed25519"
+	"errors"
 	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mrheza/distributed-config-management/shared/health"
+	"github.com/mrheza/distributed-config-management/shared/signing"
+	"github.com/mrheza/distributed-config-management/shared/tracing"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -30,14 +43,26 @@ func main() {
 		log.Fatal(err)
 	}
 	log.Printf(
-		"event=controller_config_loaded port=%s gin_mode=%s poll_url=%s database_url_set=%t",
+		"event=controller_config_loaded port=%s gin_mode=%s poll_url=%s database_url_set=%t agent_stale_after_seconds=%d agent_dead_after_seconds=%d config_resync_seconds=%d webhook_poll_seconds=%d signing_key_set=%t secrets_key_set=%t trace_exporter=%s",
 		cfg.Port,
 		cfg.GinMode,
 		cfg.PollURL,
 		cfg.DatabaseURL != "",
+		cfg.AgentStaleAfterSeconds,
+		cfg.AgentDeadAfterSeconds,
+		cfg.ConfigResyncSeconds,
+		cfg.WebhookPollSeconds,
+		cfg.SigningKeyFile != "",
+		cfg.SecretsKeyFile != "",
+		cfg.TraceExporter,
 	)
 	gin.SetMode(cfg.GinMode)
 
+	shutdownTracing, err := tracing.Init(context.Background(), "controller", cfg.TraceExporter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	database, err := db.New(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal(err)
@@ -45,11 +70,69 @@ func main() {
 
 	configRepo := postgresRepo.NewConfigRepository(database)
 	agentRepo := postgresRepo.NewAgentRepository(database)
+	agentStatusRepo := postgresRepo.NewAgentStatusRepository(database)
+	rolloutRepo := postgresRepo.NewRolloutRepository(database)
+	auditRepo := postgresRepo.NewAuditRepository(database)
+	apiKeyRepo := postgresRepo.NewAPIKeyRepository(database)
+	enrollmentRepo := postgresRepo.NewEnrollmentTokenRepository(database)
+	webhookRepo := postgresRepo.NewWebhookRepository(database)
 
-	configService := service.NewConfigService(configRepo)
-	agentService := service.NewAgentService(agentRepo)
+	var signingKey ed25519.PrivateKey
+	if cfg.SigningKeyFile != "" {
+		signingKey, err = signing.LoadPrivateKey(cfg.SigningKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var secretsKey []byte
+	if cfg.SecretsKeyFile != "" {
+		secretsKey, err = service.LoadSecretsKey(cfg.SecretsKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	h := handler.New(cfg, configService, agentService)
+	webhookService := service.NewWebhookService(webhookRepo, secretsKey, nil)
+	configService := service.NewConfigService(configRepo, signingKey, secretsKey, cfg.ApprovalNamespaces, webhookService)
+	enrollmentService := service.NewEnrollmentService(enrollmentRepo)
+	agentService := service.NewAgentService(
+		agentRepo,
+		agentStatusRepo,
+		enrollmentService,
+		time.Duration(cfg.AgentStaleAfterSeconds)*time.Second,
+		time.Duration(cfg.AgentDeadAfterSeconds)*time.Second,
+	)
+	metrics.RegisterAgentCollector(func(status string) (int, error) {
+		return agentService.Count("", status)
+	})
+	rolloutService := service.NewRolloutService(rolloutRepo, configService)
+	auditService := service.NewAuditService(auditRepo)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, bootstrapKeys(cfg))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Other controller instances create versions too; follow them so this
+	// instance's cache, long-polls and streams see every new version.
+	changes, err := postgresRepo.NewConfigNotifier(cfg.DatabaseURL).Listen(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go configService.Sync(ctx, changes, time.Duration(cfg.ConfigResyncSeconds)*time.Second)
+
+	go webhookService.Run(ctx, time.Duration(cfg.WebhookPollSeconds)*time.Second)
+
+	h := handler.New(
+		cfg,
+		configService,
+		agentService,
+		rolloutService,
+		auditService,
+		apiKeyService,
+		enrollmentService,
+		webhookService,
+	)
 
 	r := gin.New()
 	if err := r.SetTrustedProxies(nil); err != nil {
@@ -57,20 +140,102 @@ func main() {
 	}
 
 	r.Use(middleware.RequestLogger())
+	r.Use(middleware.Metrics())
+	r.Use(middleware.Tracing("controller"))
 	r.Use(gin.Recovery())
 	r.Use(middleware.CORSMiddleware())
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.GET("/metrics", middleware.MetricsHandler())
+	r.GET("/healthz", health.Liveness())
+	r.GET("/readyz", health.Readiness(
+		health.Check{Name: "database", Run: database.PingContext},
+	))
+
+	register := r.Group("/", middleware.RequireScope(apiKeyService, model.ScopeRegister))
+	register.POST("/register", h.RegisterAgent)
 
-	agent := r.Group("/", middleware.APIKeyAuth(cfg.AgentAPIKey))
-	agent.POST("/register", h.RegisterAgent)
+	agent := r.Group("/",
+		middleware.RequireScope(apiKeyService, model.ScopeReadConfig),
+		middleware.RequireAgentCredential(agentService),
+	)
 	agent.GET("/config", h.GetConfig)
+	agent.GET("/config/stream", h.StreamConfig)
+	agent.POST("/agents/:id/status", h.ReportAgentStatus)
+
+	readHistory := r.Group("/", middleware.RequireScope(apiKeyService, model.ScopeReadHistory))
+	readHistory.GET("/configs", h.ListConfigs)
+	readHistory.GET("/configs/scheduled", h.ListScheduledConfigs)
+	readHistory.GET("/configs/diff", h.GetConfigDiff)
+	readHistory.GET("/configs/:version", h.GetConfigVersion)
+	readHistory.GET("/configs/:version/status", h.GetConfigStatus)
+	readHistory.GET("/configs/:version/rollout", h.GetRollout)
 
-	admin := r.Group("/", middleware.APIKeyAuth(cfg.AdminAPIKey))
-	admin.POST("/config", h.CreateConfig)
+	writeConfig := r.Group("/", middleware.RequireScope(apiKeyService, model.ScopeWriteConfig))
+	writeConfig.POST("/config", h.CreateConfig)
+	writeConfig.POST("/configs/:version/rollback", h.RollbackConfig)
+	writeConfig.POST("/configs/:version/cancel", h.CancelConfig)
+	writeConfig.POST("/configs/:version/approve", h.ApproveConfig)
+	writeConfig.POST("/configs/:version/reject", h.RejectConfig)
+	writeConfig.POST("/configs/:version/rollout/advance", h.AdvanceRollout)
+	writeConfig.POST("/configs/:version/rollout/pause", h.PauseRollout)
+	writeConfig.POST("/configs/:version/rollout/abort", h.AbortRollout)
+
+	admin := r.Group("/", middleware.RequireScope(apiKeyService, model.ScopeAdmin))
+	admin.GET("/agents", h.ListAgents)
+	admin.GET("/agents/:id", h.GetAgent)
+	admin.GET("/audit", h.ListAudit)
+	admin.POST("/api-keys", h.CreateAPIKey)
+	admin.GET("/api-keys", h.ListAPIKeys)
+	admin.DELETE("/api-keys/:id", h.RevokeAPIKey)
+	admin.POST("/enrollment-tokens", h.CreateEnrollmentToken)
+	admin.GET("/enrollment-tokens", h.ListEnrollmentTokens)
+	admin.DELETE("/enrollment-tokens/:id", h.RevokeEnrollmentToken)
+	admin.POST("/webhooks", h.CreateWebhook)
+	admin.GET("/webhooks", h.ListWebhooks)
+	admin.DELETE("/webhooks/:id", h.DeleteWebhook)
+	admin.GET("/webhooks/:id/deliveries", h.ListWebhookDeliveries)
+	admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", h.RedeliverWebhookDelivery)
 
 	addr := ":" + cfg.Port
-	if err := r.Run(addr); err != nil {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: r,
+		// Cancels long-polls and config streams on shutdown.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("server shutdown error: %v", err)
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("tracing shutdown error: %v", err)
+	}
+}
+
+// bootstrapKeys maps the API keys from the environment to their identities, so
+// keys can be minted before any exist in the database.
+func bootstrapKeys(cfg *config.Config) map[string]*model.APIKey {
+	keys := map[string]*model.APIKey{
+		cfg.AdminAPIKey: {Name: model.BootstrapAdminKeyName, Scopes: []string{model.ScopeAdmin}},
+	}
+	if cfg.AgentAPIKey != "" {
+		keys[cfg.AgentAPIKey] = &model.APIKey{
+			Name:   model.BootstrapAgentKeyName,
+			Scopes: []string{model.ScopeRegister, model.ScopeReadConfig},
+		}
+	}
+	return keys
 }
@@ -3,30 +3,50 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/mrheza/distributed-config-management/shared/tracing"
 )
 
 type Config struct {
-	AdminAPIKey string
-	AgentAPIKey string
-	PollURL     string
-	GinMode     string
-	DatabaseURL string
-	Port        string
+	AdminAPIKey            string
+	AgentAPIKey            string
+	PollURL                string
+	GinMode                string
+	DatabaseURL            string
+	Port                   string
+	AgentStaleAfterSeconds int
+	AgentDeadAfterSeconds  int
+	ConfigResyncSeconds    int
+	SigningKeyFile         string
+	SecretsKeyFile         string
+	// ApprovalNamespaces require a second identity to approve every new
+	// version before it is served.
+	ApprovalNamespaces []string
+	WebhookPollSeconds int
+	TraceExporter      string
 }
 
 func Load() *Config {
 	_ = godotenv.Load()
 
 	return &Config{
-		AdminAPIKey: os.Getenv("ADMIN_API_KEY"),
-		AgentAPIKey: os.Getenv("AGENT_API_KEY"),
-		PollURL:     os.Getenv("POLL_URL"),
-		GinMode:     os.Getenv("GIN_MODE"),
-		DatabaseURL: os.Getenv("DATABASE_URL"),
-		Port:        os.Getenv("PORT"),
+		AdminAPIKey:            os.Getenv("ADMIN_API_KEY"),
+		AgentAPIKey:            os.Getenv("AGENT_API_KEY"),
+		PollURL:                os.Getenv("POLL_URL"),
+		GinMode:                os.Getenv("GIN_MODE"),
+		DatabaseURL:            os.Getenv("DATABASE_URL"),
+		Port:                   os.Getenv("PORT"),
+		AgentStaleAfterSeconds: getEnvInt("AGENT_STALE_AFTER_SECONDS", 90),
+		AgentDeadAfterSeconds:  getEnvInt("AGENT_DEAD_AFTER_SECONDS", 300),
+		ConfigResyncSeconds:    getEnvInt("CONFIG_RESYNC_SECONDS", 60),
+		SigningKeyFile:         os.Getenv("CONFIG_SIGNING_KEY_FILE"),
+		SecretsKeyFile:         os.Getenv("CONFIG_SECRETS_KEY_FILE"),
+		ApprovalNamespaces:     getEnvList("APPROVAL_REQUIRED_NAMESPACES"),
+		WebhookPollSeconds:     getEnvInt("WEBHOOK_POLL_SECONDS", 5),
+		TraceExporter:          getEnvDefault("OTEL_TRACES_EXPORTER", tracing.ExporterNone),
 	}
 }
 
@@ -35,9 +55,6 @@ func (c *Config) Validate() error {
 	if strings.TrimSpace(c.AdminAPIKey) == "" {
 		missing = append(missing, "ADMIN_API_KEY")
 	}
-	if strings.TrimSpace(c.AgentAPIKey) == "" {
-		missing = append(missing, "AGENT_API_KEY")
-	}
 	if strings.TrimSpace(c.PollURL) == "" {
 		missing = append(missing, "POLL_URL")
 	}
@@ -55,5 +72,56 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("missing required env: %s", strings.Join(missing, ", "))
 	}
 
+	if c.AgentAPIKey != "" && c.AgentAPIKey == c.AdminAPIKey {
+		return fmt.Errorf("invalid AGENT_API_KEY: must differ from ADMIN_API_KEY")
+	}
+
+	if c.AgentStaleAfterSeconds <= 0 {
+		return fmt.Errorf("invalid AGENT_STALE_AFTER_SECONDS: must be > 0")
+	}
+	if c.AgentDeadAfterSeconds <= c.AgentStaleAfterSeconds {
+		return fmt.Errorf("invalid AGENT_DEAD_AFTER_SECONDS: must be > AGENT_STALE_AFTER_SECONDS")
+	}
+	if c.ConfigResyncSeconds <= 0 {
+		return fmt.Errorf("invalid CONFIG_RESYNC_SECONDS: must be > 0")
+	}
+	if c.WebhookPollSeconds <= 0 {
+		return fmt.Errorf("invalid WEBHOOK_POLL_SECONDS: must be > 0")
+	}
+	if !tracing.ValidExporter(c.TraceExporter) {
+		return fmt.Errorf("invalid OTEL_TRACES_EXPORTER: must be none, stdout or otlp")
+	}
+
 	return nil
 }
+
+// getEnvInt returns fallback when the variable is unset or not a number.
+func getEnvInt(k string, fallback int) int {
+	raw := os.Getenv(k)
+	if raw == "" {
+		return fallback
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvDefault(k, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getEnvList splits a comma separated variable, dropping empty entries.
+func getEnvList(k string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(k), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
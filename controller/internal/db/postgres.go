@@ -20,6 +20,7 @@ func New(databaseURL string) (*sql.DB, error) {
 	if _, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS agents (
 			id TEXT PRIMARY KEY,
+			namespace TEXT NOT NULL DEFAULT 'default',
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)
 	`); err != nil {
@@ -29,13 +30,325 @@ func New(databaseURL string) (*sql.DB, error) {
 	if _, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS configurations (
 			version BIGSERIAL PRIMARY KEY,
+			namespace TEXT NOT NULL DEFAULT 'default',
 			url TEXT,
 			poll_interval_seconds INTEGER NOT NULL DEFAULT 30,
+			data JSONB,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)
 	`); err != nil {
 		return nil, fmt.Errorf("create configurations table: %w", err)
 	}
 
+	if _, err := db.Exec(`
+		ALTER TABLE configurations ADD COLUMN IF NOT EXISTS data JSONB
+	`); err != nil {
+		return nil, fmt.Errorf("migrate configurations data column: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE configurations ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT 'default'
+	`); err != nil {
+		return nil, fmt.Errorf("migrate configurations namespace column: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE configurations ADD COLUMN IF NOT EXISTS encrypted_secrets JSONB
+	`); err != nil {
+		return nil, fmt.Errorf("migrate configurations encrypted_secrets column: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE configurations ADD COLUMN IF NOT EXISTS activate_at TIMESTAMPTZ
+	`); err != nil {
+		return nil, fmt.Errorf("migrate configurations activate_at column: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE configurations ADD COLUMN IF NOT EXISTS canceled_at TIMESTAMPTZ
+	`); err != nil {
+		return nil, fmt.Errorf("migrate configurations canceled_at column: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE configurations
+			ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS approval_status TEXT,
+			ADD COLUMN IF NOT EXISTS reviewed_by TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ
+	`); err != nil {
+		return nil, fmt.Errorf("migrate configurations approval columns: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS configurations_namespace_version_idx
+		ON configurations (namespace, version DESC)
+	`); err != nil {
+		return nil, fmt.Errorf("create configurations namespace index: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE OR REPLACE FUNCTION notify_configuration_created() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_notify('configurations_changed', NEW.namespace);
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql
+	`); err != nil {
+		return nil, fmt.Errorf("create configurations notify function: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_trigger
+				WHERE tgname = 'configurations_notify'
+					AND tgrelid = 'configurations'::regclass
+			) THEN
+				CREATE TRIGGER configurations_notify
+				AFTER INSERT ON configurations
+				FOR EACH ROW EXECUTE PROCEDURE notify_configuration_created();
+			END IF;
+		END
+		$$
+	`); err != nil {
+		return nil, fmt.Errorf("create configurations notify trigger: %w", err)
+	}
+
+	// Approving a draft makes it the namespace's latest version, just like an
+	// insert does.
+	if _, err := db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_trigger
+				WHERE tgname = 'configurations_review_notify'
+					AND tgrelid = 'configurations'::regclass
+			) THEN
+				CREATE TRIGGER configurations_review_notify
+				AFTER UPDATE OF approval_status ON configurations
+				FOR EACH ROW EXECUTE PROCEDURE notify_configuration_created();
+			END IF;
+		END
+		$$
+	`); err != nil {
+		return nil, fmt.Errorf("create configurations review notify trigger: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE agents ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT 'default'
+	`); err != nil {
+		return nil, fmt.Errorf("migrate agents namespace column: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE agents
+			ADD COLUMN IF NOT EXISTS hostname TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS agent_version TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS labels JSONB,
+			ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP WITH TIME ZONE,
+			ADD COLUMN IF NOT EXISTS last_config_version BIGINT NOT NULL DEFAULT 0
+	`); err != nil {
+		return nil, fmt.Errorf("migrate agents registry columns: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS agent_config_status (
+			agent_id TEXT NOT NULL REFERENCES agents (id) ON DELETE CASCADE,
+			version BIGINT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			reported_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (agent_id, version)
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create agent_config_status table: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS agent_config_status_version_idx
+		ON agent_config_status (version, status)
+	`); err != nil {
+		return nil, fmt.Errorf("create agent_config_status version index: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS rollouts (
+			version BIGINT PRIMARY KEY REFERENCES configurations (version) ON DELETE CASCADE,
+			namespace TEXT NOT NULL,
+			base_version BIGINT NOT NULL DEFAULT 0,
+			percentage INTEGER NOT NULL DEFAULT 0,
+			agent_ids JSONB,
+			labels JSONB,
+			status TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create rollouts table: %w", err)
+	}
+
+	// Rollout changes alter which version agents are served, so they are
+	// announced on the same channel as new versions.
+	if _, err := db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_trigger
+				WHERE tgname = 'rollouts_notify'
+					AND tgrelid = 'rollouts'::regclass
+			) THEN
+				CREATE TRIGGER rollouts_notify
+				AFTER UPDATE ON rollouts
+				FOR EACH ROW EXECUTE PROCEDURE notify_configuration_created();
+			END IF;
+		END
+		$$
+	`); err != nil {
+		return nil, fmt.Errorf("create rollouts notify trigger: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			namespace TEXT NOT NULL DEFAULT '',
+			version_before BIGINT NOT NULL DEFAULT 0,
+			version_after BIGINT NOT NULL DEFAULT 0,
+			request_id TEXT NOT NULL DEFAULT '',
+			client_ip TEXT NOT NULL DEFAULT '',
+			details JSONB,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create audit_log table: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS audit_log_created_at_idx
+		ON audit_log (created_at DESC)
+	`); err != nil {
+		return nil, fmt.Errorf("create audit_log created_at index: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS audit_log_actor_idx
+		ON audit_log (actor, created_at DESC)
+	`); err != nil {
+		return nil, fmt.Errorf("create audit_log actor index: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_log is append-only';
+		END;
+		$$ LANGUAGE plpgsql
+	`); err != nil {
+		return nil, fmt.Errorf("create audit_log append-only function: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_trigger
+				WHERE tgname = 'audit_log_append_only'
+					AND tgrelid = 'audit_log'::regclass
+			) THEN
+				CREATE TRIGGER audit_log_append_only
+				BEFORE UPDATE OR DELETE ON audit_log
+				FOR EACH ROW EXECUTE PROCEDURE audit_log_append_only();
+			END IF;
+		END
+		$$
+	`); err != nil {
+		return nil, fmt.Errorf("create audit_log append-only trigger: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			key_hash TEXT NOT NULL,
+			scopes JSONB NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create api_keys table: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS enrollment_tokens (
+			id TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL,
+			max_uses INT NOT NULL,
+			uses INT NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create enrollment_tokens table: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE agents ADD COLUMN IF NOT EXISTS credential_hash TEXT
+	`); err != nil {
+		return nil, fmt.Errorf("migrate agents credential_hash column: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			namespace TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP WITH TIME ZONE
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create webhooks table: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id BIGSERIAL PRIMARY KEY,
+			webhook_id TEXT NOT NULL REFERENCES webhooks (id),
+			event TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP WITH TIME ZONE,
+			response_status INT NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			redelivery_of BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			delivered_at TIMESTAMP WITH TIME ZONE
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create webhook_deliveries table: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx
+		ON webhook_deliveries (next_attempt_at)
+		WHERE status = 'pending'
+	`); err != nil {
+		return nil, fmt.Errorf("create webhook_deliveries due index: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx
+		ON webhook_deliveries (webhook_id, id DESC)
+	`); err != nil {
+		return nil, fmt.Errorf("create webhook_deliveries webhook index: %w", err)
+	}
+
 	return db, nil
 }
@@ -1,63 +1,292 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"controller/internal/config"
 	"controller/internal/httpresponse"
+	"controller/internal/middleware"
+	"controller/internal/model"
+	"controller/internal/repository"
 	"controller/internal/service"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/mrheza/distributed-config-management/shared/redact"
 )
 
 type Handler struct {
-	config        *config.Config
-	configService service.ConfigService
-	agentService  service.AgentService
+	config         *config.Config
+	configService  service.ConfigService
+	agentService   service.AgentService
+	rolloutService service.RolloutService
+	auditService   service.AuditService
+	apiKeyService  service.APIKeyService
+	enrollService  service.EnrollmentService
+	webhookService service.WebhookService
+}
+
+type RegisterAgentRequest struct {
+	Namespace string            `json:"namespace" example:"prod"`
+	Hostname  string            `json:"hostname" binding:"max=255" example:"worker-host-1"`
+	Version   string            `json:"version" binding:"max=64" example:"1.4.0"`
+	Labels    map[string]string `json:"labels" binding:"max=32"`
 }
 
 type RegisterAgentResponse struct {
 	AgentID             string `json:"agent_id"`
+	Namespace           string `json:"namespace"`
 	PollURL             string `json:"poll_url"`
 	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+	// Credential is only returned when a new agent is enrolled. The agent
+	// sends it as X-Agent-Credential from then on.
+	Credential string `json:"credential,omitempty"`
 }
 
 type CreateConfigRequest struct {
-	URL                 string `json:"url" binding:"required,url"`
-	PollIntervalSeconds int    `json:"poll_interval_seconds" binding:"required,gte=1"`
+	Namespace           string            `json:"namespace" example:"prod"`
+	URL                 string            `json:"url" binding:"required,url"`
+	PollIntervalSeconds int               `json:"poll_interval_seconds" binding:"required,gte=1"`
+	Data                json.RawMessage   `json:"data" swaggertype:"object"`
+	Secrets             map[string]string `json:"secrets" binding:"max=64,dive,keys,required,endkeys"`
+	Rollout             *RolloutRequest   `json:"rollout"`
+	// ActivateAt schedules the version: agents keep the current one until then.
+	ActivateAt *time.Time `json:"activate_at" example:"2030-01-02T02:00:00Z"`
+}
+
+// RolloutRequest stages a new version to the selected agents only.
+type RolloutRequest struct {
+	Percentage int               `json:"percentage" binding:"gte=0,lte=100" example:"10"`
+	AgentIDs   []string          `json:"agent_ids" binding:"max=1000,dive,uuid"`
+	Labels     map[string]string `json:"labels" binding:"max=32"`
+}
+
+type AdvanceRolloutRequest struct {
+	Percentage int      `json:"percentage" binding:"required,gte=1,lte=100" example:"50"`
+	AgentIDs   []string `json:"agent_ids" binding:"max=1000,dive,uuid"`
+}
+
+type ListConfigsQuery struct {
+	Namespace string `form:"namespace"`
+	Limit     int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset    int    `form:"offset" binding:"omitempty,gte=0"`
+}
+
+type ListConfigsResponse struct {
+	Items  []model.Config `json:"items"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+type ConfigDiffQuery struct {
+	From   int    `form:"from" binding:"required,gte=1"`
+	To     int    `form:"to" binding:"required,gte=1"`
+	Format string `form:"format" binding:"omitempty,oneof=json text"`
+}
+
+type ListScheduledConfigsQuery struct {
+	Namespace string `form:"namespace"`
+}
+
+type ListScheduledConfigsResponse struct {
+	Items []model.Config `json:"items"`
+}
+
+type ListAgentsQuery struct {
+	Namespace string `form:"namespace"`
+	Status    string `form:"status" binding:"omitempty,oneof=healthy stale dead"`
+	Limit     int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset    int    `form:"offset" binding:"omitempty,gte=0"`
+}
+
+type ListAgentsResponse struct {
+	Items  []model.Agent `json:"items"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+type ListAuditQuery struct {
+	Actor  string    `form:"actor"`
+	Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit  int       `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset int       `form:"offset" binding:"omitempty,gte=0"`
+}
+
+type ListAuditResponse struct {
+	Items  []model.AuditEntry `json:"items"`
+	Total  int                `json:"total"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=64" example:"ci-deployer"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=read-config read-history write-config register admin" example:"write-config"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreateAPIKeyResponse struct {
+	model.APIKey
+	// Key is the plain API key. It is only returned once.
+	Key string `json:"key"`
+}
+
+type ListAPIKeysResponse struct {
+	Items []model.APIKey `json:"items"`
+}
+
+type CreateWebhookRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"deploy-tracker"`
+	URL  string `json:"url" binding:"required,url" example:"https://hooks.example.com/config"`
+	// Namespace limits the webhook to one namespace; empty subscribes to all.
+	Namespace string `json:"namespace" example:"prod"`
 }
 
-func New(cf *config.Config, cs service.ConfigService, as service.AgentService) *Handler {
+// CreateWebhookResponse carries the signing secret, which is not returned again.
+type CreateWebhookResponse struct {
+	model.Webhook
+	Secret string `json:"secret"`
+}
+
+type ListWebhooksResponse struct {
+	Items []model.Webhook `json:"items"`
+}
+
+type ListWebhookDeliveriesQuery struct {
+	Limit  int `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset int `form:"offset" binding:"omitempty,gte=0"`
+}
+
+type ListWebhookDeliveriesResponse struct {
+	Items  []model.WebhookDelivery `json:"items"`
+	Limit  int                     `json:"limit"`
+	Offset int                     `json:"offset"`
+}
+
+type CreateEnrollmentTokenRequest struct {
+	MaxUses   int        `json:"max_uses" binding:"omitempty,gte=1,lte=10000" example:"1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreateEnrollmentTokenResponse struct {
+	model.EnrollmentToken
+	// Token is the plain enrollment token. It is only returned once.
+	Token string `json:"token"`
+}
+
+type ListEnrollmentTokensResponse struct {
+	Items []model.EnrollmentToken `json:"items"`
+}
+
+type ReportAgentStatusRequest struct {
+	Version int    `json:"version" binding:"required,gte=1" example:"42"`
+	Status  string `json:"status" binding:"required,oneof=applied failed" example:"applied"`
+	Error   string `json:"error" binding:"max=1024"`
+}
+
+const defaultListLimit = 20
+
+// maxConfigWait caps how long GET /config may hold a long-poll request.
+const maxConfigWait = 2 * time.Minute
+
+// streamKeepAlive is how often an idle config stream sends a comment line and
+// records an agent heartbeat.
+var streamKeepAlive = 25 * time.Second
+
+// namespacePattern allows lowercase segments such as "prod" or "team-a/service-x".
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]*[a-z0-9])?(/[a-z0-9]([a-z0-9_-]*[a-z0-9])?)*$`)
+
+const maxNamespaceLength = 128
+
+func New(
+	cf *config.Config,
+	cs service.ConfigService,
+	as service.AgentService,
+	rs service.RolloutService,
+	aus service.AuditService,
+	ks service.APIKeyService,
+	es service.EnrollmentService,
+	ws service.WebhookService,
+) *Handler {
 	return &Handler{
-		config:        cf,
-		configService: cs,
-		agentService:  as,
+		config:         cf,
+		configService:  cs,
+		agentService:   as,
+		rolloutService: rs,
+		auditService:   aus,
+		apiKeyService:  ks,
+		enrollService:  es,
+		webhookService: ws,
 	}
 }
 
 // RegisterAgent godoc
 // @Summary Register agent
-// @Description Register a new agent and return polling info
+// @Description Enroll a new agent with an enrollment token, or re-register an existing one with its credential, and return polling info.
+// @Description A new agent gets a fresh ID and its credential, which is only returned in this response.
 // @Tags agent
+// @Accept json
 // @Produce json
 // @Param X-API-Key header string true "API key"
-// @Param X-Agent-ID header string false "existing agent ID for UUID reuse"
+// @Param X-Agent-ID header string false "existing agent ID, together with X-Agent-Credential"
+// @Param X-Agent-Credential header string false "credential issued to X-Agent-ID"
+// @Param X-Enrollment-Token header string false "enrollment token for a new agent"
+// @Param request body RegisterAgentRequest false "registration payload"
 // @Success 200 {object} RegisterAgentResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
 // @Failure 401 {object} httpresponse.ErrorResponse
 // @Failure 500 {object} httpresponse.ErrorResponse
 // @Security ApiKeyAuth
 // @Router /register [post]
 func (h *Handler) RegisterAgent(c *gin.Context) {
-	id, err := h.agentService.Register(c.GetHeader("X-Agent-ID"))
+	var req RegisterAgentRequest
+	// The body is optional; agents that send none join the default namespace.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		httpresponse.ValidationError(c, err, req)
+		return
+	}
+
+	namespace, ok := resolveNamespace(c, req.Namespace)
+	if !ok {
+		return
+	}
+
+	auth := model.AgentAuth{
+		AgentID:         c.GetHeader("X-Agent-ID"),
+		Credential:      c.GetHeader("X-Agent-Credential"),
+		EnrollmentToken: c.GetHeader("X-Enrollment-Token"),
+	}
+	id, credential, err := h.agentService.Register(auth, &model.Agent{
+		Namespace: namespace,
+		Hostname:  req.Hostname,
+		Version:   req.Version,
+		Labels:    req.Labels,
+	})
+	if errors.Is(err, service.ErrInvalidEnrollmentToken) {
+		httpresponse.Error(c, http.StatusUnauthorized, "INVALID_ENROLLMENT", "valid agent credential or enrollment token required")
+		return
+	}
 	if err != nil {
 		httpresponse.FromError(c, err)
 		return
 	}
 
-	// get global config
-	cfg, err := h.configService.GetLatest()
+	// get namespace config
+	cfg, err := h.configService.GetLatest(namespace)
 
 	// fallback if config not set
 	pollInterval := 30
@@ -67,18 +296,22 @@ func (h *Handler) RegisterAgent(c *gin.Context) {
 
 	c.JSON(http.StatusOK, RegisterAgentResponse{
 		AgentID:             id,
+		Namespace:           namespace,
 		PollURL:             h.config.PollURL,
 		PollIntervalSeconds: pollInterval,
+		Credential:          credential,
 	})
 }
 
 // GetConfig godoc
 // @Summary Get latest config
-// @Description Returns latest configuration with ETag support
+// @Description Returns latest configuration of the agent's namespace with ETag support and records an agent heartbeat.
+// @Description With wait, a request whose If-None-Match matches the latest version is held open until a newer version is created or the wait elapses.
 // @Tags config
 // @Produce json
 // @Param X-API-Key header string true "API key"
 // @Param X-Agent-ID header string true "agent ID"
+// @Param X-Agent-Credential header string true "credential issued at enrollment"
 // @Success 200 {object} model.Config
 // @Success 304 "Not Modified"
 // @Failure 400 {object} httpresponse.ErrorResponse
@@ -86,6 +319,7 @@ func (h *Handler) RegisterAgent(c *gin.Context) {
 // @Failure 404 {object} httpresponse.ErrorResponse
 // @Failure 500 {object} httpresponse.ErrorResponse
 // @Param If-None-Match header string false "ETag value"
+// @Param wait query string false "long-poll timeout, e.g. 60s (max 120s)"
 // @Security ApiKeyAuth
 // @Router /config [get]
 func (h *Handler) GetConfig(c *gin.Context) {
@@ -95,15 +329,51 @@ func (h *Handler) GetConfig(c *gin.Context) {
 		return
 	}
 
-	cfg, err := h.configService.GetLatest()
+	wait, ok := parseWaitQuery(c)
+	if !ok {
+		return
+	}
+
+	agent, err := h.agentService.Get(agentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpresponse.NotFound(c, "agent not registered")
+			return
+		}
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	// Subscribe before reading so a version created in between still wakes us.
+	var changed <-chan struct{}
+	if wait > 0 {
+		changed = h.configService.Changed(agent.Namespace)
+	}
+
+	cfg, err := h.configForAgent(agent)
 	if err != nil {
 		httpresponse.FromError(c, err)
 		return
 	}
 
-	etag := fmt.Sprintf(`"%d"`, cfg.Version)
+	h.recordPoll(agentID, cfg.Version)
+
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if wait > 0 && ifNoneMatchContains(ifNoneMatch, configETag(cfg)) {
+		servedVersion := cfg.Version
+		cfg, err = h.awaitNewerConfig(c.Request.Context(), agent, cfg, changed, ifNoneMatch, wait)
+		if err != nil {
+			httpresponse.FromError(c, err)
+			return
+		}
+		if cfg.Version != servedVersion {
+			h.recordPoll(agentID, cfg.Version)
+		}
+	}
+
+	etag := configETag(cfg)
 	c.Header("ETag", etag)
-	if ifNoneMatchContains(c.GetHeader("If-None-Match"), etag) {
+	if ifNoneMatchContains(ifNoneMatch, etag) {
 		c.Status(http.StatusNotModified)
 		return
 	}
@@ -111,17 +381,112 @@ func (h *Handler) GetConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, cfg)
 }
 
+// StreamConfig godoc
+// @Summary Stream config changes
+// @Description Server-Sent Events stream of the agent's namespace. Each new version is sent as a "config" event whose id is the version number.
+// @Description The latest version is sent on connect unless it matches Last-Event-ID. Idle streams receive a keep-alive comment every 25 seconds.
+// @Tags config
+// @Produce text/event-stream
+// @Param X-API-Key header string true "API key"
+// @Param X-Agent-ID header string true "agent ID"
+// @Param X-Agent-Credential header string true "credential issued at enrollment"
+// @Param Last-Event-ID header string false "last version received"
+// @Success 200 {object} model.Config "event stream of model.Config"
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /config/stream [get]
+func (h *Handler) StreamConfig(c *gin.Context) {
+	agentID := c.GetHeader("X-Agent-ID")
+	if _, err := uuid.Parse(agentID); err != nil {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid X-Agent-ID header")
+		return
+	}
+
+	lastVersion := 0
+	if raw := c.GetHeader("Last-Event-ID"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid Last-Event-ID header")
+			return
+		}
+		lastVersion = v
+	}
+
+	agent, err := h.agentService.Get(agentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpresponse.NotFound(c, "agent not registered")
+			return
+		}
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		// Subscribe before reading so a version created in between still wakes us.
+		changed := h.configService.Changed(agent.Namespace)
+
+		cfg, err := h.configForAgent(agent)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// Nothing to send until the namespace gets its first version.
+		case err != nil:
+			log.Printf("event=config_stream_read_failed agent_id=%s err=%v", agentID, err)
+			return
+		case cfg.Version != lastVersion:
+			if err := writeConfigEvent(c.Writer, cfg); err != nil {
+				return
+			}
+			lastVersion = cfg.Version
+			h.recordPoll(agentID, cfg.Version)
+		}
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			if _, err := io.WriteString(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+			h.recordPoll(agentID, lastVersion)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // CreateConfig godoc
 // @Summary Create config
-// @Description Create a new configuration version
+// @Description Create a new configuration version; data is an arbitrary JSON object delivered to agents and workers as-is.
+// @Description secrets are encrypted at rest, delivered to agents only and redacted in every admin response.
+// @Description With activate_at the version is scheduled: agents keep being served the current version until then.
+// @Description In namespaces requiring approval the version is a draft until another API key approves it.
 // @Tags config
 // @Accept json
 // @Produce json
 // @Param X-API-Key header string true "API key"
+// @Param If-Match header string false "only create if this is still the latest version, e.g. \"3\""
 // @Param request body CreateConfigRequest true "config payload"
 // @Success 201 {object} model.Config
+// @Header 201 {string} ETag "version of the created config"
 // @Failure 401 {object} httpresponse.ErrorResponse
 // @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 412 {object} httpresponse.ErrorResponse
 // @Failure 500 {object} httpresponse.ErrorResponse
 // @Security ApiKeyAuth
 // @Router /config [post]
@@ -133,19 +498,1427 @@ func (h *Handler) CreateConfig(c *gin.Context) {
 		return
 	}
 
-	err := h.configService.Create(req.URL, req.PollIntervalSeconds)
+	namespace, ok := resolveNamespace(c, req.Namespace)
+	if !ok {
+		return
+	}
+
+	data, ok := normalizeJSONObject(req.Data)
+	if !ok {
+		httpresponse.FieldValidationError(c, "data", "object", "must be a JSON object")
+		return
+	}
+
+	if req.ActivateAt != nil && !req.ActivateAt.After(time.Now()) {
+		httpresponse.FieldValidationError(c, "activate_at", "future", "must be in the future")
+		return
+	}
+
+	expectedVersion, ok := parseIfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	cfg := &model.Config{
+		Namespace:           namespace,
+		URL:                 req.URL,
+		PollIntervalSeconds: req.PollIntervalSeconds,
+		Data:                data,
+		Secrets:             req.Secrets,
+		ActivateAt:          req.ActivateAt,
+		CreatedBy:           middleware.Identity(c),
+	}
+	if req.Rollout != nil {
+		cfg.Rollout = &model.RolloutPolicy{
+			Percentage: req.Rollout.Percentage,
+			AgentIDs:   req.Rollout.AgentIDs,
+			Labels:     req.Rollout.Labels,
+		}
+	}
+
+	before := h.servedVersion(namespace)
+	err := h.configService.Create(cfg, expectedVersion)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		// Tell the client which version it lost to, when we can.
+		if latest, latestErr := h.configService.GetLatest(namespace); latestErr == nil {
+			c.Header("ETag", configETag(latest))
+		}
+		httpresponse.Error(c, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "config version has changed")
+		return
+	}
+	if errors.Is(err, repository.ErrRolloutInProgress) {
+		rolloutInProgress(c)
+		return
+	}
+	if errors.Is(err, repository.ErrActivationPending) {
+		activationPending(c)
+		return
+	}
+	if errors.Is(err, repository.ErrApprovalPending) {
+		approvalPending(c)
+		return
+	}
+	if errors.Is(err, service.ErrSecretsDisabled) {
+		httpresponse.Error(c, http.StatusBadRequest, "SECRETS_DISABLED", "controller has no secrets key configured")
+		return
+	}
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	var details interface{}
+	if cfg.Rollout != nil || cfg.ActivateAt != nil || cfg.Approval != nil {
+		fields := gin.H{}
+		if cfg.Rollout != nil {
+			fields["rollout"] = cfg.Rollout
+		}
+		if cfg.ActivateAt != nil {
+			fields["activate_at"] = cfg.ActivateAt
+		}
+		if cfg.Approval != nil {
+			fields["approval"] = cfg.Approval.Status
+		}
+		details = fields
+	}
+	h.audit(c, model.AuditActionConfigCreate, namespace, before, cfg.Version, details)
+
+	if cfg.ActivateAt != nil || cfg.Approval != nil {
+		// Not served yet, so the latest version is still the previous one.
+		cfg, err = h.configService.GetByVersion(cfg.Version)
+	} else {
+		cfg, err = h.configService.GetLatest(namespace)
+	}
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.Header("ETag", configETag(cfg))
+	c.JSON(http.StatusCreated, redactSecrets(cfg))
+}
+
+// ListConfigs godoc
+// @Summary List config history
+// @Description Returns configuration versions, newest first
+// @Tags config
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param namespace query string false "only list versions of this namespace"
+// @Param limit query int false "page size (1-100, default 20)"
+// @Param offset query int false "number of versions to skip"
+// @Success 200 {object} ListConfigsResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs [get]
+func (h *Handler) ListConfigs(c *gin.Context) {
+	var query ListConfigsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		httpresponse.ValidationError(c, err, query)
+		return
+	}
+	if query.Namespace != "" && !validNamespace(query.Namespace) {
+		httpresponse.FieldValidationError(c, "namespace", "namespace", "invalid namespace")
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultListLimit
+	}
+
+	configs, total, err := h.configService.List(query.Namespace, query.Limit, query.Offset)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+	for i := range configs {
+		configs[i] = *redactSecrets(&configs[i])
+	}
+
+	c.JSON(http.StatusOK, ListConfigsResponse{
+		Items:  configs,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
+}
+
+// GetConfigVersion godoc
+// @Summary Get config version
+// @Description Returns a single configuration version from history
+// @Tags config
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "config version"
+// @Success 200 {object} model.Config
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version} [get]
+func (h *Handler) GetConfigVersion(c *gin.Context) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	cfg, err := h.configService.GetByVersion(version)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, redactSecrets(cfg))
+}
+
+// GetConfigDiff godoc
+// @Summary Diff config versions
+// @Description Returns the field-level changes between two versions (added, removed and changed paths), or a text rendering with format=text.
+// @Description Secret values are redacted; a changed secret is listed without its values.
+// @Tags config
+// @Produce json
+// @Produce plain
+// @Param X-API-Key header string true "API key"
+// @Param from query int true "base version"
+// @Param to query int true "version to compare with"
+// @Param format query string false "json (default) or text"
+// @Success 200 {object} model.ConfigDiff
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/diff [get]
+func (h *Handler) GetConfigDiff(c *gin.Context) {
+	var query ConfigDiffQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		httpresponse.ValidationError(c, err, query)
+		return
+	}
+
+	diff, err := h.configService.Diff(query.From, query.To)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+	redactDiff(diff)
+
+	if query.Format == "text" {
+		c.String(http.StatusOK, diffText(diff))
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// RollbackConfig godoc
+// @Summary Roll back config
+// @Description Create a new configuration version copying an older one
+// @Tags config
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "config version to restore"
+// @Success 201 {object} model.Config
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/rollback [post]
+func (h *Handler) RollbackConfig(c *gin.Context) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	before := h.servedVersionOf(version)
+	cfg, err := h.configService.Rollback(version, middleware.Identity(c))
+	if errors.Is(err, repository.ErrRolloutInProgress) {
+		rolloutInProgress(c)
+		return
+	}
+	if errors.Is(err, repository.ErrActivationPending) {
+		activationPending(c)
+		return
+	}
+	if errors.Is(err, repository.ErrApprovalPending) {
+		approvalPending(c)
+		return
+	}
 	if err != nil {
 		httpresponse.FromError(c, err)
 		return
 	}
 
-	cfg, err := h.configService.GetLatest()
+	details := gin.H{"restored_version": version}
+	if cfg.Approval != nil {
+		details["approval"] = cfg.Approval.Status
+	}
+	h.audit(c, model.AuditActionConfigRollback, cfg.Namespace, before, cfg.Version, details)
+
+	c.JSON(http.StatusCreated, redactSecrets(cfg))
+}
+
+// ListScheduledConfigs godoc
+// @Summary List scheduled configs
+// @Description Returns the versions waiting for their activate_at time, soonest first
+// @Tags config
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param namespace query string false "only list versions of this namespace"
+// @Success 200 {object} ListScheduledConfigsResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/scheduled [get]
+func (h *Handler) ListScheduledConfigs(c *gin.Context) {
+	var query ListScheduledConfigsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		httpresponse.ValidationError(c, err, query)
+		return
+	}
+	if query.Namespace != "" && !validNamespace(query.Namespace) {
+		httpresponse.FieldValidationError(c, "namespace", "namespace", "invalid namespace")
+		return
+	}
+
+	configs, err := h.configService.ListScheduled(query.Namespace)
 	if err != nil {
 		httpresponse.FromError(c, err)
 		return
 	}
+	for i := range configs {
+		configs[i] = *redactSecrets(&configs[i])
+	}
 
-	c.JSON(http.StatusCreated, cfg)
+	c.JSON(http.StatusOK, ListScheduledConfigsResponse{Items: configs})
+}
+
+// CancelConfig godoc
+// @Summary Cancel scheduled config
+// @Description Cancel a version that is waiting for its activate_at time; it will never be served
+// @Tags config
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "scheduled config version"
+// @Success 200 {object} model.Config
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/cancel [post]
+func (h *Handler) CancelConfig(c *gin.Context) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	before := h.servedVersionOf(version)
+	cfg, err := h.configService.Cancel(version)
+	if errors.Is(err, service.ErrNotScheduled) {
+		httpresponse.Error(c, http.StatusConflict, "NOT_SCHEDULED", "only versions waiting for activation can be canceled")
+		return
+	}
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, model.AuditActionConfigCancel, cfg.Namespace, before, cfg.Version, gin.H{"activate_at": cfg.ActivateAt})
+
+	c.JSON(http.StatusOK, redactSecrets(cfg))
+}
+
+// ApproveConfig godoc
+// @Summary Approve draft config
+// @Description Approve a draft version so it is served; the approver must differ from its creator
+// @Tags config
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "draft config version"
+// @Success 200 {object} model.Config
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/approve [post]
+func (h *Handler) ApproveConfig(c *gin.Context) {
+	h.reviewConfig(c, h.configService.Approve, model.AuditActionConfigApprove)
+}
+
+// RejectConfig godoc
+// @Summary Reject draft config
+// @Description Reject a draft version so it is never served; the reviewer must differ from its creator
+// @Tags config
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "draft config version"
+// @Success 200 {object} model.Config
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/reject [post]
+func (h *Handler) RejectConfig(c *gin.Context) {
+	h.reviewConfig(c, h.configService.Reject, model.AuditActionConfigReject)
+}
+
+func (h *Handler) reviewConfig(c *gin.Context, review func(int, string) (*model.Config, error), action string) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	before := h.servedVersionOf(version)
+	cfg, err := review(version, middleware.Identity(c))
+	if errors.Is(err, service.ErrNotPendingApproval) {
+		httpresponse.Error(c, http.StatusConflict, "NOT_PENDING_APPROVAL", "only draft versions waiting for approval can be reviewed")
+		return
+	}
+	if errors.Is(err, service.ErrSelfApproval) {
+		httpresponse.Error(c, http.StatusForbidden, "SELF_APPROVAL", "a draft must be reviewed by another API key than the one that created it")
+		return
+	}
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, action, cfg.Namespace, before, cfg.Version, gin.H{"created_by": cfg.CreatedBy})
+
+	c.JSON(http.StatusOK, redactSecrets(cfg))
+}
+
+// GetRollout godoc
+// @Summary Get rollout
+// @Description Returns the rollout policy and status of a staged config version
+// @Tags rollout
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "config version"
+// @Success 200 {object} model.Rollout
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/rollout [get]
+func (h *Handler) GetRollout(c *gin.Context) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	rollout, err := h.rolloutService.Get(version)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rollout)
+}
+
+// AdvanceRollout godoc
+// @Summary Advance rollout
+// @Description Widen a rollout to a new percentage and extra agents, resuming it when paused. 100 percent completes the rollout. The percentage cannot decrease.
+// @Tags rollout
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "config version"
+// @Param request body AdvanceRolloutRequest true "new selection"
+// @Success 200 {object} model.Rollout
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/rollout/advance [post]
+func (h *Handler) AdvanceRollout(c *gin.Context) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	var req AdvanceRolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresponse.ValidationError(c, err, req)
+		return
+	}
+
+	rollout, err := h.rolloutService.Advance(version, req.Percentage, req.AgentIDs)
+	h.respondRollout(c, model.AuditActionRolloutAdvance, rollout, err)
+}
+
+// PauseRollout godoc
+// @Summary Pause rollout
+// @Description Hold a rollout; selected agents keep the new version and nobody else gets it until it is advanced
+// @Tags rollout
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "config version"
+// @Success 200 {object} model.Rollout
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/rollout/pause [post]
+func (h *Handler) PauseRollout(c *gin.Context) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	rollout, err := h.rolloutService.Pause(version)
+	h.respondRollout(c, model.AuditActionRolloutPause, rollout, err)
+}
+
+// AbortRollout godoc
+// @Summary Abort rollout
+// @Description Stop a rollout and send every agent back to the base version
+// @Tags rollout
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "config version"
+// @Success 200 {object} model.Rollout
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/rollout/abort [post]
+func (h *Handler) AbortRollout(c *gin.Context) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	rollout, err := h.rolloutService.Abort(version)
+	h.respondRollout(c, model.AuditActionRolloutAbort, rollout, err)
+}
+
+// ListAudit godoc
+// @Summary List audit log
+// @Description Returns admin actions newest first
+// @Tags audit
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param actor query string false "only list actions of this actor"
+// @Param since query string false "only list actions at or after this RFC 3339 time"
+// @Param until query string false "only list actions before this RFC 3339 time"
+// @Param limit query int false "page size (1-100, default 20)"
+// @Param offset query int false "number of entries to skip"
+// @Success 200 {object} ListAuditResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /audit [get]
+func (h *Handler) ListAudit(c *gin.Context) {
+	var query ListAuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		httpresponse.ValidationError(c, err, query)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultListLimit
+	}
+
+	filter := model.AuditFilter{
+		Actor:  query.Actor,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}
+	if !query.Since.IsZero() {
+		filter.Since = &query.Since
+	}
+	if !query.Until.IsZero() {
+		filter.Until = &query.Until
+	}
+
+	entries, total, err := h.auditService.List(filter)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListAuditResponse{
+		Items:  entries,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
+}
+
+// CreateAPIKey godoc
+// @Summary Create API key
+// @Description Mint a named API key with the given scopes. The plain key is only returned in this response.
+// @Tags api-key
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param request body CreateAPIKeyRequest true "key name, scopes and optional expiry"
+// @Success 201 {object} CreateAPIKeyResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api-keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresponse.ValidationError(c, err, req)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		httpresponse.FieldValidationError(c, "expires_at", "future", "must be in the future")
+		return
+	}
+
+	key, plain, err := h.apiKeyService.Create(req.Name, req.Scopes, req.ExpiresAt)
+	if errors.Is(err, service.ErrReservedAPIKeyName) {
+		httpresponse.FieldValidationError(c, "name", "reserved", "is reserved for a bootstrap key")
+		return
+	}
+	if errors.Is(err, repository.ErrAPIKeyNameTaken) {
+		httpresponse.Error(c, http.StatusConflict, "NAME_TAKEN", "api key name already in use")
+		return
+	}
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, model.AuditActionAPIKeyCreate, "", 0, 0, gin.H{
+		"id":     key.ID,
+		"name":   key.Name,
+		"scopes": key.Scopes,
+	})
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: *key, Key: plain})
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description Returns all API keys, including revoked and expired ones, newest first. Secrets are never returned.
+// @Tags api-key
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Success 200 {object} ListAPIKeysResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api-keys [get]
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.List()
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListAPIKeysResponse{Items: keys})
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke API key
+// @Description Revoke an API key; requests using it are rejected from now on
+// @Tags api-key
+// @Param X-API-Key header string true "API key"
+// @Param id path string true "API key ID"
+// @Success 204
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid api key id")
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(id); err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, model.AuditActionAPIKeyRevoke, "", 0, 0, gin.H{"id": id})
+	c.Status(http.StatusNoContent)
+}
+
+// CreateWebhook godoc
+// @Summary Create webhook
+// @Description Register an endpoint that receives a signed JSON event for every new config version. The signing secret is only returned in this response.
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param request body CreateWebhookRequest true "webhook name, URL and optional namespace"
+// @Success 201 {object} CreateWebhookResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks [post]
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresponse.ValidationError(c, err, req)
+		return
+	}
+	if req.Namespace != "" && !validNamespace(req.Namespace) {
+		httpresponse.FieldValidationError(c, "namespace", "namespace", "invalid namespace")
+		return
+	}
+
+	webhook, secret, err := h.webhookService.Create(req.Name, req.URL, req.Namespace)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, model.AuditActionWebhookCreate, webhook.Namespace, 0, 0, gin.H{
+		"id":   webhook.ID,
+		"name": webhook.Name,
+		"url":  webhook.URL,
+	})
+	c.JSON(http.StatusCreated, CreateWebhookResponse{Webhook: *webhook, Secret: secret})
+}
+
+// ListWebhooks godoc
+// @Summary List webhooks
+// @Description Returns all webhooks, including deleted ones, newest first. Secrets are never returned.
+// @Tags webhook
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Success 200 {object} ListWebhooksResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks [get]
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.webhookService.List()
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListWebhooksResponse{Items: webhooks})
+}
+
+// DeleteWebhook godoc
+// @Summary Delete webhook
+// @Description Stop sending events to a webhook; its pending deliveries fail and its delivery log is kept
+// @Tags webhook
+// @Param X-API-Key header string true "API key"
+// @Param id path string true "webhook ID"
+// @Success 204
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.Delete(id); err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, model.AuditActionWebhookDelete, "", 0, 0, gin.H{"id": id})
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries godoc
+// @Summary List webhook deliveries
+// @Description Returns the delivery log of a webhook, newest first, with the outcome of the latest attempt of each delivery
+// @Tags webhook
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param id path string true "webhook ID"
+// @Param limit query int false "page size (1-100, default 20)"
+// @Param offset query int false "number of deliveries to skip"
+// @Success 200 {object} ListWebhookDeliveriesResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks/{id}/deliveries [get]
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	var query ListWebhookDeliveriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		httpresponse.ValidationError(c, err, query)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultListLimit
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(id, query.Limit, query.Offset)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListWebhookDeliveriesResponse{
+		Items:  deliveries,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
+}
+
+// RedeliverWebhookDelivery godoc
+// @Summary Redeliver webhook delivery
+// @Description Queue the payload of an earlier delivery once more, as a new delivery with its own retries
+// @Tags webhook
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param id path string true "webhook ID"
+// @Param delivery_id path int true "delivery ID"
+// @Success 202 {object} model.WebhookDelivery
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) RedeliverWebhookDelivery(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil || deliveryID < 1 {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid delivery id")
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(id, deliveryID)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, model.AuditActionWebhookRedeliver, "", 0, 0, gin.H{
+		"id":            id,
+		"delivery_id":   delivery.ID,
+		"redelivery_of": deliveryID,
+	})
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// CreateEnrollmentToken godoc
+// @Summary Create enrollment token
+// @Description Mint a token that lets up to max_uses new agents register. The plain token is only returned in this response.
+// @Tags agent
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param request body CreateEnrollmentTokenRequest false "allowed uses (default 1) and optional expiry"
+// @Success 201 {object} CreateEnrollmentTokenResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /enrollment-tokens [post]
+func (h *Handler) CreateEnrollmentToken(c *gin.Context) {
+	var req CreateEnrollmentTokenRequest
+	// The body is optional; without one the token is single-use and never expires.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		httpresponse.ValidationError(c, err, req)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		httpresponse.FieldValidationError(c, "expires_at", "future", "must be in the future")
+		return
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+
+	token, plain, err := h.enrollService.Create(req.MaxUses, req.ExpiresAt)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, model.AuditActionEnrollmentCreate, "", 0, 0, gin.H{
+		"id":       token.ID,
+		"max_uses": token.MaxUses,
+	})
+	c.JSON(http.StatusCreated, CreateEnrollmentTokenResponse{EnrollmentToken: *token, Token: plain})
+}
+
+// ListEnrollmentTokens godoc
+// @Summary List enrollment tokens
+// @Description Returns all enrollment tokens with their use counts, newest first. Secrets are never returned.
+// @Tags agent
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Success 200 {object} ListEnrollmentTokensResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /enrollment-tokens [get]
+func (h *Handler) ListEnrollmentTokens(c *gin.Context) {
+	tokens, err := h.enrollService.List()
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListEnrollmentTokensResponse{Items: tokens})
+}
+
+// RevokeEnrollmentToken godoc
+// @Summary Revoke enrollment token
+// @Description Revoke an enrollment token; agents already enrolled with it keep their credentials
+// @Tags agent
+// @Param X-API-Key header string true "API key"
+// @Param id path string true "enrollment token ID"
+// @Success 204
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /enrollment-tokens/{id} [delete]
+func (h *Handler) RevokeEnrollmentToken(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid enrollment token id")
+		return
+	}
+
+	if err := h.enrollService.Revoke(id); err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	h.audit(c, model.AuditActionEnrollmentRevoke, "", 0, 0, gin.H{"id": id})
+	c.Status(http.StatusNoContent)
+}
+
+// ListAgents godoc
+// @Summary List agents
+// @Description Returns registered agents with their last heartbeat and health status, most recently seen first
+// @Tags agent
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param namespace query string false "only list agents of this namespace"
+// @Param status query string false "only list agents with this status" Enums(healthy, stale, dead)
+// @Param limit query int false "page size (1-100, default 20)"
+// @Param offset query int false "number of agents to skip"
+// @Success 200 {object} ListAgentsResponse
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /agents [get]
+func (h *Handler) ListAgents(c *gin.Context) {
+	var query ListAgentsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		httpresponse.ValidationError(c, err, query)
+		return
+	}
+	if query.Namespace != "" && !validNamespace(query.Namespace) {
+		httpresponse.FieldValidationError(c, "namespace", "namespace", "invalid namespace")
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultListLimit
+	}
+
+	agents, total, err := h.agentService.List(query.Namespace, query.Status, query.Limit, query.Offset)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListAgentsResponse{
+		Items:  agents,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
+}
+
+// GetAgent godoc
+// @Summary Get agent
+// @Description Returns a registered agent with its last heartbeat and health status
+// @Tags agent
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param id path string true "agent ID"
+// @Success 200 {object} model.Agent
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /agents/{id} [get]
+func (h *Handler) GetAgent(c *gin.Context) {
+	agentID := c.Param("id")
+	if _, err := uuid.Parse(agentID); err != nil {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid agent id")
+		return
+	}
+
+	agent, err := h.agentService.Get(agentID)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, agent)
+}
+
+// ReportAgentStatus godoc
+// @Summary Report apply status
+// @Description Records whether the agent applied a config version to its worker; later reports for the same version replace earlier ones
+// @Tags agent
+// @Accept json
+// @Param X-API-Key header string true "API key"
+// @Param X-Agent-ID header string true "agent ID, must match id"
+// @Param X-Agent-Credential header string true "credential issued at enrollment"
+// @Param id path string true "agent ID"
+// @Param request body ReportAgentStatusRequest true "apply result"
+// @Success 204 "No Content"
+// @Failure 400 {object} httpresponse.ValidationErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 403 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /agents/{id}/status [post]
+func (h *Handler) ReportAgentStatus(c *gin.Context) {
+	agentID := c.Param("id")
+	if _, err := uuid.Parse(agentID); err != nil {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid agent id")
+		return
+	}
+
+	var req ReportAgentStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresponse.ValidationError(c, err, req)
+		return
+	}
+
+	if _, err := h.configService.GetByVersion(req.Version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpresponse.NotFound(c, "config version not found")
+			return
+		}
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	err := h.agentService.ReportStatus(&model.AgentConfigStatus{
+		AgentID: agentID,
+		Version: req.Version,
+		Status:  req.Status,
+		Error:   req.Error,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpresponse.NotFound(c, "agent not registered")
+			return
+		}
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetConfigStatus godoc
+// @Summary Get config rollout status
+// @Description Summarizes how many agents of the version's namespace applied it, failed to apply it, or have not reported yet
+// @Tags config
+// @Produce json
+// @Param X-API-Key header string true "API key"
+// @Param version path int true "config version"
+// @Success 200 {object} model.ConfigStatusSummary
+// @Failure 400 {object} httpresponse.ErrorResponse
+// @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 404 {object} httpresponse.ErrorResponse
+// @Failure 500 {object} httpresponse.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /configs/{version}/status [get]
+func (h *Handler) GetConfigStatus(c *gin.Context) {
+	version, ok := parseVersionParam(c)
+	if !ok {
+		return
+	}
+
+	cfg, err := h.configService.GetByVersion(version)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	summary, err := h.agentService.StatusSummary(cfg.Namespace, cfg.Version)
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// awaitNewerConfig blocks until the agent is served a version not listed in
+// ifNoneMatch, the wait elapses or the client goes away, and returns the
+// agent's config at that point.
+func (h *Handler) awaitNewerConfig(
+	ctx context.Context,
+	agent *model.Agent,
+	cfg *model.Config,
+	changed <-chan struct{},
+	ifNoneMatch string,
+	wait time.Duration,
+) (*model.Config, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for ifNoneMatchContains(ifNoneMatch, configETag(cfg)) {
+		select {
+		case <-changed:
+		case <-timer.C:
+			return cfg, nil
+		case <-ctx.Done():
+			return cfg, nil
+		}
+
+		changed = h.configService.Changed(agent.Namespace)
+		latest, err := h.configForAgent(agent)
+		if err != nil {
+			return nil, err
+		}
+		cfg = latest
+	}
+
+	return cfg, nil
+}
+
+// configForAgent returns the version the agent should run: the latest of its
+// namespace unless a rollout holds the agent on an older one.
+func (h *Handler) configForAgent(agent *model.Agent) (*model.Config, error) {
+	latest, err := h.configService.GetLatest(agent.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.rolloutService.Resolve(agent, latest)
+}
+
+// audit records an admin action on version taken by the caller; before is
+// the version the namespace served before the action, 0 for none or for
+// actions outside configs. The action has already happened, so a failed write
+// is logged instead of failing the request.
+func (h *Handler) audit(c *gin.Context, action, namespace string, before, version int, details interface{}) {
+	entry := &model.AuditEntry{
+		Actor:         middleware.Identity(c),
+		Action:        action,
+		Namespace:     namespace,
+		VersionBefore: before,
+		VersionAfter:  version,
+		RequestID:     middleware.RequestID(c),
+		ClientIP:      c.ClientIP(),
+	}
+
+	if details != nil {
+		raw, err := json.Marshal(details)
+		if err != nil {
+			log.Printf("event=audit_details_marshal_failed action=%s err=%v", action, err)
+		} else {
+			entry.Details = raw
+		}
+	}
+
+	if err := h.auditService.Record(entry); err != nil {
+		log.Printf(
+			"event=audit_write_failed action=%s actor=%s namespace=%s version=%d request_id=%s err=%v",
+			action, entry.Actor, namespace, version, entry.RequestID, err,
+		)
+	}
+}
+
+// servedVersion returns the version namespace serves, for the audit log. It
+// is 0 when the namespace has none or it cannot be read; the action itself is
+// audited either way.
+func (h *Handler) servedVersion(namespace string) int {
+	latest, err := h.configService.GetLatest(namespace)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("event=audit_served_version_failed namespace=%s err=%v", namespace, err)
+		}
+		return 0
+	}
+	return latest.Version
+}
+
+// servedVersionOf is servedVersion for the namespace of version.
+func (h *Handler) servedVersionOf(version int) int {
+	cfg, err := h.configService.GetByVersion(version)
+	if err != nil {
+		// The action reports a missing version itself.
+		return 0
+	}
+	return h.servedVersion(cfg.Namespace)
+}
+
+// recordPoll stores the agent heartbeat. A failed heartbeat must not keep the
+// agent from receiving its config.
+func (h *Handler) recordPoll(agentID string, version int) {
+	if err := h.agentService.RecordPoll(agentID, version); err != nil {
+		log.Printf("event=agent_heartbeat_failed agent_id=%s err=%v", agentID, err)
+	}
+}
+
+// parseWaitQuery reads the long-poll timeout as a duration ("60s") or a plain
+// number of seconds ("60"). Zero means no waiting.
+func parseWaitQuery(c *gin.Context) (time.Duration, bool) {
+	raw := c.Query("wait")
+	if raw == "" {
+		return 0, true
+	}
+
+	wait, err := time.ParseDuration(raw)
+	if err != nil {
+		secs, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			httpresponse.FieldValidationError(c, "wait", "duration", "must be a duration such as 60s")
+			return 0, false
+		}
+		wait = time.Duration(secs) * time.Second
+	}
+
+	if wait < 0 || wait > maxConfigWait {
+		httpresponse.FieldValidationError(c, "wait", "max", fmt.Sprintf("must be between 0s and %s", maxConfigWait))
+		return 0, false
+	}
+
+	return wait, true
+}
+
+func writeConfigEvent(w gin.ResponseWriter, cfg *model.Config) error {
+	payload, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: config\ndata: %s\n\n", cfg.Version, payload); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
+func (h *Handler) respondRollout(c *gin.Context, action string, rollout *model.Rollout, err error) {
+	if errors.Is(err, service.ErrRolloutNarrowed) {
+		httpresponse.Error(c, http.StatusConflict, "INVALID_ROLLOUT_STATE", "rollout percentage cannot decrease")
+		return
+	}
+	if errors.Is(err, service.ErrInvalidRolloutState) {
+		httpresponse.Error(c, http.StatusConflict, "INVALID_ROLLOUT_STATE", "rollout is no longer in progress")
+		return
+	}
+	if err != nil {
+		httpresponse.FromError(c, err)
+		return
+	}
+
+	// Outside the rollout the namespace keeps serving its base version.
+	h.audit(c, action, rollout.Namespace, rollout.BaseVersion, rollout.Version, gin.H{
+		"status":     rollout.Status,
+		"percentage": rollout.Percentage,
+		"agent_ids":  rollout.AgentIDs,
+	})
+	c.JSON(http.StatusOK, rollout)
+}
+
+func rolloutInProgress(c *gin.Context) {
+	httpresponse.Error(c, http.StatusConflict, "ROLLOUT_IN_PROGRESS", "complete or abort the current rollout first")
+}
+
+func activationPending(c *gin.Context) {
+	httpresponse.Error(c, http.StatusConflict, "SCHEDULED_VERSION_PENDING", "wait for or cancel the scheduled version first")
+}
+
+func approvalPending(c *gin.Context) {
+	httpresponse.Error(c, http.StatusConflict, "APPROVAL_PENDING", "approve or reject the pending draft first")
+}
+
+// redactSecrets returns a copy of cfg for admin responses: secret names stay
+// visible, their values do not. Admin responses never carry the signature;
+// only agents verify it.
+func redactSecrets(cfg *model.Config) *model.Config {
+	out := *cfg
+	out.Secrets = redact.Secrets(cfg.Secrets)
+	out.Signature = ""
+	return &out
+}
+
+// redactDiff hides the values of changed secrets; their names stay visible.
+func redactDiff(diff *model.ConfigDiff) {
+	for i := range diff.Changes {
+		change := &diff.Changes[i]
+		if change.Path != "secrets" && !strings.HasPrefix(change.Path, "secrets.") {
+			continue
+		}
+		change.From = redactedValue(change.From)
+		change.To = redactedValue(change.To)
+	}
+}
+
+func redactedValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for name := range v {
+			out[name] = redact.Value
+		}
+		return out
+	default:
+		return redact.Value
+	}
+}
+
+// diffText renders diff one change per line: "+" added, "-" removed and "~"
+// changed, with values as JSON.
+func diffText(diff *model.ConfigDiff) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- version %d\n+++ version %d\n", diff.From, diff.To)
+
+	for _, change := range diff.Changes {
+		switch change.Op {
+		case model.DiffAdded:
+			fmt.Fprintf(&b, "+ %s: %s\n", change.Path, diffValueText(change.To))
+		case model.DiffRemoved:
+			fmt.Fprintf(&b, "- %s: %s\n", change.Path, diffValueText(change.From))
+		default:
+			fmt.Fprintf(&b, "~ %s: %s -> %s\n", change.Path, diffValueText(change.From), diffValueText(change.To))
+		}
+	}
+	return b.String()
+}
+
+func diffValueText(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(raw)
+}
+
+func parseWebhookID(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid webhook id")
+		return "", false
+	}
+	return id, true
+}
+
+func configETag(cfg *model.Config) string {
+	return fmt.Sprintf(`"%d"`, cfg.Version)
+}
+
+// parseIfMatchVersion reads the config version from an optional If-Match
+// header. It returns 0 when the header is absent.
+func parseIfMatchVersion(c *gin.Context) (int, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return 0, true
+	}
+
+	version, err := strconv.Atoi(normalizeETag(header))
+	if err != nil || version < 1 {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid If-Match header")
+		return 0, false
+	}
+
+	return version, true
+}
+
+func parseVersionParam(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		httpresponse.Error(c, http.StatusBadRequest, "VALIDATION_ERROR", "invalid version")
+		return 0, false
+	}
+
+	return version, true
+}
+
+// resolveNamespace applies the default namespace and writes a validation error
+// response when the namespace is malformed.
+func resolveNamespace(c *gin.Context, namespace string) (string, bool) {
+	if namespace == "" {
+		return model.DefaultNamespace, true
+	}
+	if !validNamespace(namespace) {
+		httpresponse.FieldValidationError(c, "namespace", "namespace", "invalid namespace")
+		return "", false
+	}
+	return namespace, true
+}
+
+func validNamespace(namespace string) bool {
+	return len(namespace) <= maxNamespaceLength && namespacePattern.MatchString(namespace)
+}
+
+// normalizeJSONObject accepts an absent or null document, or a JSON object.
+func normalizeJSONObject(raw json.RawMessage) (json.RawMessage, bool) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, true
+	}
+	if trimmed[0] != '{' {
+		return nil, false
+	}
+	return trimmed, true
 }
 
 func ifNoneMatchContains(headerValue, currentETag string) bool {
@@ -2,19 +2,26 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"controller/internal/config"
+	"controller/internal/middleware"
 	serviceMocks "controller/internal/mocks/service"
 	"controller/internal/model"
+	"controller/internal/repository"
+	"controller/internal/service"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func setupRouter(handler *Handler) *gin.Engine {
@@ -23,11 +30,81 @@ func setupRouter(handler *Handler) *gin.Engine {
 
 	r.POST("/register", handler.RegisterAgent)
 	r.GET("/config", handler.GetConfig)
+	r.GET("/config/stream", handler.StreamConfig)
 	r.POST("/config", handler.CreateConfig)
+	r.GET("/configs", handler.ListConfigs)
+	r.GET("/configs/scheduled", handler.ListScheduledConfigs)
+	r.GET("/configs/diff", handler.GetConfigDiff)
+	r.GET("/configs/:version", handler.GetConfigVersion)
+	r.GET("/agents", handler.ListAgents)
+	r.GET("/agents/:id", handler.GetAgent)
+	r.POST("/agents/:id/status", handler.ReportAgentStatus)
+	r.GET("/configs/:version/status", handler.GetConfigStatus)
+	r.POST("/configs/:version/rollback", handler.RollbackConfig)
+	r.POST("/configs/:version/cancel", handler.CancelConfig)
+	r.POST("/configs/:version/approve", handler.ApproveConfig)
+	r.POST("/configs/:version/reject", handler.RejectConfig)
+	r.GET("/configs/:version/rollout", handler.GetRollout)
+	r.POST("/configs/:version/rollout/advance", handler.AdvanceRollout)
+	r.POST("/configs/:version/rollout/pause", handler.PauseRollout)
+	r.POST("/configs/:version/rollout/abort", handler.AbortRollout)
+	r.GET("/audit", handler.ListAudit)
+	r.POST("/api-keys", handler.CreateAPIKey)
+	r.GET("/api-keys", handler.ListAPIKeys)
+	r.DELETE("/api-keys/:id", handler.RevokeAPIKey)
+	r.POST("/enrollment-tokens", handler.CreateEnrollmentToken)
+	r.GET("/enrollment-tokens", handler.ListEnrollmentTokens)
+	r.DELETE("/enrollment-tokens/:id", handler.RevokeEnrollmentToken)
+	r.POST("/webhooks", handler.CreateWebhook)
+	r.GET("/webhooks", handler.ListWebhooks)
+	r.DELETE("/webhooks/:id", handler.DeleteWebhook)
+	r.GET("/webhooks/:id/deliveries", handler.ListWebhookDeliveries)
+	r.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", handler.RedeliverWebhookDelivery)
 
 	return r
 }
 
+func newRegisteredAgentService(namespace string) *serviceMocks.AgentService {
+	m := new(serviceMocks.AgentService)
+	m.On("Get", mock.Anything).Return(&model.Agent{Namespace: namespace}, nil).Maybe()
+	m.On("RecordPoll", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return m
+}
+
+// passThroughRollouts serves every agent the latest version, as if no rollout
+// was in progress.
+func passThroughRollouts() *serviceMocks.RolloutService {
+	m := new(serviceMocks.RolloutService)
+	m.On("Resolve", mock.Anything, mock.Anything).
+		Return(func(_ *model.Agent, latest *model.Config) (*model.Config, error) { return latest, nil }).
+		Maybe()
+	return m
+}
+
+// expectServedVersion stubs the lookup of the version namespace serves before
+// a config change, which the audit log records; 0 means none yet.
+func expectServedVersion(m *serviceMocks.ConfigService, namespace string, version int) {
+	if version == 0 {
+		m.On("GetLatest", namespace).Return(nil, sql.ErrNoRows).Once()
+		return
+	}
+	m.On("GetLatest", namespace).Return(&model.Config{Version: version, Namespace: namespace}, nil).Once()
+}
+
+// expectServedVersionOf is expectServedVersion for a change of version in
+// namespace.
+func expectServedVersionOf(m *serviceMocks.ConfigService, changed int, namespace string, version int) {
+	m.On("GetByVersion", changed).Return(&model.Config{Version: changed, Namespace: namespace}, nil).Once()
+	expectServedVersion(m, namespace, version)
+}
+
+// acceptAudits records nothing and never fails.
+func acceptAudits() *serviceMocks.AuditService {
+	m := new(serviceMocks.AuditService)
+	m.On("Record", mock.Anything).Return(nil).Maybe()
+	return m
+}
+
 // Success - config exists
 func TestRegisterAgent_Success_WithConfig(t *testing.T) {
 
@@ -41,12 +118,12 @@ func TestRegisterAgent_Success_WithConfig(t *testing.T) {
 	expectedAgentID := "agent-123"
 
 	mockAgent.
-		On("Register", "").
-		Return(expectedAgentID, nil).
+		On("Register", model.AgentAuth{}, &model.Agent{Namespace: "default"}).
+		Return(expectedAgentID, "", nil).
 		Once()
 
 	mockConfig.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(&model.Config{
 			Version:             1,
 			URL:                 "https://example.com",
@@ -54,7 +131,7 @@ func TestRegisterAgent_Success_WithConfig(t *testing.T) {
 		}, nil).
 		Once()
 
-	handler := New(cfg, mockConfig, mockAgent)
+	handler := New(cfg, mockConfig, mockAgent, nil, nil, nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -89,16 +166,16 @@ func TestRegisterAgent_Fallback_DefaultInterval(t *testing.T) {
 	}
 
 	mockAgent.
-		On("Register", "").
-		Return("agent-123", nil).
+		On("Register", model.AgentAuth{}, &model.Agent{Namespace: "default"}).
+		Return("agent-123", "", nil).
 		Once()
 
 	mockConfig.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(nil, errors.New("not found")).
 		Once()
 
-	handler := New(cfg, mockConfig, mockAgent)
+	handler := New(cfg, mockConfig, mockAgent, nil, nil, nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -130,12 +207,12 @@ func TestRegisterAgent_ConfigIntervalZero_Fallback(t *testing.T) {
 	}
 
 	mockAgent.
-		On("Register", "").
-		Return("agent-123", nil).
+		On("Register", model.AgentAuth{}, &model.Agent{Namespace: "default"}).
+		Return("agent-123", "", nil).
 		Once()
 
 	mockConfig.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(&model.Config{
 			Version:             1,
 			URL:                 "https://example.com",
@@ -143,7 +220,7 @@ func TestRegisterAgent_ConfigIntervalZero_Fallback(t *testing.T) {
 		}, nil).
 		Once()
 
-	handler := New(cfg, mockConfig, mockAgent)
+	handler := New(cfg, mockConfig, mockAgent, nil, nil, nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -171,16 +248,16 @@ func TestRegisterAgent_ConfigNil_Fallback(t *testing.T) {
 	cfg := &config.Config{PollURL: "/config"}
 
 	mockAgent.
-		On("Register", "").
-		Return("agent-123", nil).
+		On("Register", model.AgentAuth{}, &model.Agent{Namespace: "default"}).
+		Return("agent-123", "", nil).
 		Once()
 
 	mockConfig.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return((*model.Config)(nil), nil).
 		Once()
 
-	handler := New(cfg, mockConfig, mockAgent)
+	handler := New(cfg, mockConfig, mockAgent, nil, nil, nil, nil, nil)
 	router := setupRouter(handler)
 
 	req := httptest.NewRequest(http.MethodPost, "/register", nil)
@@ -208,11 +285,11 @@ func TestRegisterAgent_AgentServiceError(t *testing.T) {
 	}
 
 	mockAgent.
-		On("Register", "").
-		Return("", errors.New("register failed")).
+		On("Register", model.AgentAuth{}, &model.Agent{Namespace: "default"}).
+		Return("", "", errors.New("register failed")).
 		Once()
 
-	handler := New(cfg, mockConfig, mockAgent)
+	handler := New(cfg, mockConfig, mockAgent, nil, nil, nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -226,7 +303,7 @@ func TestRegisterAgent_AgentServiceError(t *testing.T) {
 	mockAgent.AssertExpectations(t)
 }
 
-func TestRegisterAgent_Success_WithExistingAgentIDHeader(t *testing.T) {
+func TestRegisterAgent_Success_WithAgentCredential(t *testing.T) {
 
 	mockAgent := new(serviceMocks.AgentService)
 	mockConfig := new(serviceMocks.ConfigService)
@@ -238,20 +315,21 @@ func TestRegisterAgent_Success_WithExistingAgentIDHeader(t *testing.T) {
 	existingID := "f73f1430-ad82-44a5-8cd2-b2c8ffbf2f57"
 
 	mockAgent.
-		On("Register", existingID).
-		Return(existingID, nil).
+		On("Register", model.AgentAuth{AgentID: existingID, Credential: "cred"}, &model.Agent{Namespace: "default"}).
+		Return(existingID, "", nil).
 		Once()
 
 	mockConfig.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(nil, errors.New("not found")).
 		Once()
 
-	handler := New(cfg, mockConfig, mockAgent)
+	handler := New(cfg, mockConfig, mockAgent, nil, nil, nil, nil, nil)
 	router := setupRouter(handler)
 
 	req := httptest.NewRequest(http.MethodPost, "/register", nil)
 	req.Header.Set("X-Agent-ID", existingID)
+	req.Header.Set("X-Agent-Credential", "cred")
 	resp := httptest.NewRecorder()
 	router.ServeHTTP(resp, req)
 
@@ -261,6 +339,126 @@ func TestRegisterAgent_Success_WithExistingAgentIDHeader(t *testing.T) {
 	err := json.Unmarshal(resp.Body.Bytes(), &body)
 	assert.NoError(t, err)
 	assert.Equal(t, existingID, body["agent_id"])
+	assert.NotContains(t, body, "credential")
+
+	mockAgent.AssertExpectations(t)
+	mockConfig.AssertExpectations(t)
+}
+
+func TestRegisterAgent_Enrollment(t *testing.T) {
+
+	mockAgent := new(serviceMocks.AgentService)
+	mockConfig := new(serviceMocks.ConfigService)
+
+	mockAgent.
+		On("Register", model.AgentAuth{AgentID: "ignored", EnrollmentToken: "tok"}, &model.Agent{Namespace: "default"}).
+		Return("agent-123", "secret", nil).
+		Once()
+	mockConfig.
+		On("GetLatest", "default").
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(&config.Config{PollURL: "/config"}, mockConfig, mockAgent, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/register", nil)
+	req.Header.Set("X-Agent-ID", "ignored")
+	req.Header.Set("X-Enrollment-Token", "tok")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body RegisterAgentResponse
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, "agent-123", body.AgentID)
+	assert.Equal(t, "secret", body.Credential)
+
+	mockAgent.AssertExpectations(t)
+}
+
+func TestRegisterAgent_InvalidEnrollmentToken(t *testing.T) {
+
+	mockAgent := new(serviceMocks.AgentService)
+	mockConfig := new(serviceMocks.ConfigService)
+
+	mockAgent.
+		On("Register", model.AgentAuth{EnrollmentToken: "used"}, &model.Agent{Namespace: "default"}).
+		Return("", "", service.ErrInvalidEnrollmentToken).
+		Once()
+
+	handler := New(&config.Config{PollURL: "/config"}, mockConfig, mockAgent, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/register", nil)
+	req.Header.Set("X-Enrollment-Token", "used")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Contains(t, resp.Body.String(), "INVALID_ENROLLMENT")
+	mockConfig.AssertNotCalled(t, "GetLatest", mock.Anything)
+}
+
+func TestRegisterAgent_Success_WithNamespace(t *testing.T) {
+
+	mockAgent := new(serviceMocks.AgentService)
+	mockConfig := new(serviceMocks.ConfigService)
+
+	cfg := &config.Config{PollURL: "/config"}
+
+	mockAgent.
+		On("Register", model.AgentAuth{}, &model.Agent{Namespace: "team-a/service-x"}).
+		Return("agent-123", "", nil).
+		Once()
+
+	mockConfig.
+		On("GetLatest", "team-a/service-x").
+		Return(&model.Config{Version: 5, Namespace: "team-a/service-x", PollIntervalSeconds: 15}, nil).
+		Once()
+
+	handler := New(cfg, mockConfig, mockAgent, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBufferString(`{"namespace":"team-a/service-x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body RegisterAgentResponse
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a/service-x", body.Namespace)
+	assert.Equal(t, 15, body.PollIntervalSeconds)
+
+	mockAgent.AssertExpectations(t)
+	mockConfig.AssertExpectations(t)
+}
+
+func TestRegisterAgent_InvalidNamespace(t *testing.T) {
+
+	mockAgent := new(serviceMocks.AgentService)
+	mockConfig := new(serviceMocks.ConfigService)
+
+	handler := New(&config.Config{PollURL: "/config"}, mockConfig, mockAgent, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBufferString(`{"namespace":"Prod Env"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var body map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	errorObj := body["error"].(map[string]interface{})
+	fields := errorObj["fields"].([]interface{})
+	assert.Equal(t, "namespace", fields[0].(map[string]interface{})["field"])
 
 	mockAgent.AssertExpectations(t)
 	mockConfig.AssertExpectations(t)
@@ -270,6 +468,70 @@ func TestRegisterAgent_Success_WithExistingAgentIDHeader(t *testing.T) {
 // GetConfig Tests
 //
 
+func TestGetConfig_UsesAgentNamespace(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockAgentService := new(serviceMocks.AgentService)
+
+	agentID := uuid.NewString()
+	mockAgentService.
+		On("Get", agentID).
+		Return(&model.Agent{ID: agentID, Namespace: "staging"}, nil).
+		Once()
+	mockAgentService.
+		On("RecordPoll", agentID, 8).
+		Return(nil).
+		Once()
+	mockConfigService.
+		On("GetLatest", "staging").
+		Return(&model.Config{Version: 8, Namespace: "staging", URL: "https://staging.example.com"}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, mockAgentService, passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	req.Header.Set("X-Agent-ID", agentID)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"8"`, resp.Header().Get("ETag"))
+
+	mockAgentService.AssertExpectations(t)
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestGetConfig_AgentNotRegistered(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockAgentService := new(serviceMocks.AgentService)
+
+	mockAgentService.
+		On("Get", mock.Anything).
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(nil, mockConfigService, mockAgentService, passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	req.Header.Set("X-Agent-ID", uuid.NewString())
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	var body map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	errorObj := body["error"].(map[string]interface{})
+	assert.Equal(t, "agent not registered", errorObj["message"])
+
+	mockAgentService.AssertExpectations(t)
+	mockConfigService.AssertExpectations(t)
+}
+
 func TestGetConfig_Success(t *testing.T) {
 
 	mockConfigService := new(serviceMocks.ConfigService)
@@ -277,14 +539,15 @@ func TestGetConfig_Success(t *testing.T) {
 	expected := &model.Config{
 		Version: 1,
 		URL:     "https://example.com",
+		Secrets: map[string]string{"db_password": "hunter2"},
 	}
 
 	mockConfigService.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(expected, nil).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -304,6 +567,8 @@ func TestGetConfig_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expected.Version, body.Version)
 	assert.Equal(t, expected.URL, body.URL)
+	// Agents receive secrets in clear.
+	assert.Equal(t, expected.Secrets, body.Secrets)
 
 	mockConfigService.AssertExpectations(t)
 }
@@ -318,11 +583,11 @@ func TestGetConfig_NotModified_ETag(t *testing.T) {
 	}
 
 	mockConfigService.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(expected, nil).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -348,11 +613,11 @@ func TestGetConfig_NotModified_ETag_Unquoted(t *testing.T) {
 	}
 
 	mockConfigService.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(expected, nil).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
 	router := setupRouter(handler)
 
 	req := httptest.NewRequest(http.MethodGet, "/config", nil)
@@ -373,11 +638,11 @@ func TestGetConfig_NotModified_ETag_Weak(t *testing.T) {
 	}
 
 	mockConfigService.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(expected, nil).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
 	router := setupRouter(handler)
 
 	req := httptest.NewRequest(http.MethodGet, "/config", nil)
@@ -398,11 +663,11 @@ func TestGetConfig_NotModified_ETag_List(t *testing.T) {
 	}
 
 	mockConfigService.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(expected, nil).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
 	router := setupRouter(handler)
 
 	req := httptest.NewRequest(http.MethodGet, "/config", nil)
@@ -419,11 +684,11 @@ func TestGetConfig_Error(t *testing.T) {
 	mockConfigService := new(serviceMocks.ConfigService)
 
 	mockConfigService.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(nil, errors.New("database error")).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -443,11 +708,11 @@ func TestGetConfig_NotFound(t *testing.T) {
 	mockConfigService := new(serviceMocks.ConfigService)
 
 	mockConfigService.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(nil, sql.ErrNoRows).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -473,7 +738,7 @@ func TestGetConfig_NotFound(t *testing.T) {
 func TestGetConfig_InvalidAgentIDHeader(t *testing.T) {
 
 	mockConfigService := new(serviceMocks.ConfigService)
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
 	router := setupRouter(handler)
 
 	req := httptest.NewRequest(http.MethodGet, "/config", nil)
@@ -502,6 +767,7 @@ func TestGetConfig_InvalidAgentIDHeader(t *testing.T) {
 func TestCreateConfig_Success(t *testing.T) {
 
 	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
 
 	reqBody := `{
 		"url": "https://example.com",
@@ -515,16 +781,16 @@ func TestCreateConfig_Success(t *testing.T) {
 	}
 
 	mockConfigService.
-		On("Create", "https://example.com", 60).
+		On("Create", &model.Config{Namespace: "default", URL: "https://example.com", PollIntervalSeconds: 60}, 0).
 		Return(nil).
 		Once()
 
 	mockConfigService.
-		On("GetLatest").
+		On("GetLatest", "default").
 		Return(expectedConfig, nil).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -554,159 +820,374 @@ func TestCreateConfig_Success(t *testing.T) {
 	mockConfigService.AssertExpectations(t)
 }
 
-func TestCreateConfig_ValidationError_MissingFields(t *testing.T) {
+func TestCreateConfig_Success_WithData(t *testing.T) {
 
 	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
 
-	reqBody := `{}`
+	reqBody := `{
+		"url": "https://example.com",
+		"poll_interval_seconds": 60,
+		"data": {"feature_flags": {"beta": true}, "retries": 3}
+	}`
 
-	handler := New(nil, mockConfigService, nil)
+	expectedConfig := &model.Config{
+		Version:             4,
+		URL:                 "https://example.com",
+		PollIntervalSeconds: 60,
+		Data:                json.RawMessage(`{"feature_flags": {"beta": true}, "retries": 3}`),
+	}
 
-	router := setupRouter(handler)
+	mockConfigService.
+		On("Create", mock.MatchedBy(func(cfg *model.Config) bool {
+			return cfg.Namespace == "default" &&
+				cfg.URL == "https://example.com" &&
+				cfg.PollIntervalSeconds == 60 &&
+				string(cfg.Data) == `{"feature_flags": {"beta": true}, "retries": 3}`
+		}), 0).
+		Return(nil).
+		Once()
 
-	req := httptest.NewRequest(
-		http.MethodPost,
-		"/config",
-		bytes.NewBufferString(reqBody),
-	)
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(expectedConfig, nil).
+		Once()
 
-	req.Header.Set("Content-Type", "application/json")
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
 
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
 	resp := httptest.NewRecorder()
-
 	router.ServeHTTP(resp, req)
 
-	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, http.StatusCreated, resp.Code)
 
 	var body map[string]interface{}
-
 	err := json.Unmarshal(resp.Body.Bytes(), &body)
-
 	assert.NoError(t, err)
+	assert.Equal(t, float64(3), body["data"].(map[string]interface{})["retries"])
 
-	errorObj := body["error"].(map[string]interface{})
-
-	assert.Equal(t, "VALIDATION_ERROR", errorObj["code"])
-	assert.Equal(t, "validation failed", errorObj["message"])
-
-	fields := errorObj["fields"].([]interface{})
-	assert.Len(t, fields, 2)
-
-	found := map[string]map[string]string{}
-	for _, f := range fields {
-		fieldObj := f.(map[string]interface{})
-		found[fieldObj["field"].(string)] = map[string]string{
-			"code":    fieldObj["code"].(string),
-			"message": fieldObj["message"].(string),
-		}
-	}
-
-	assert.Equal(t, "required", found["url"]["code"])
-	assert.Equal(t, "failed \"required\" validation", found["url"]["message"])
-	assert.Equal(t, "required", found["poll_interval_seconds"]["code"])
-	assert.Equal(t, "failed \"required\" validation", found["poll_interval_seconds"]["message"])
+	mockConfigService.AssertExpectations(t)
 }
 
-func TestCreateConfig_ValidationError_InvalidURL(t *testing.T) {
+func TestCreateConfig_Success_WithSecrets(t *testing.T) {
 
 	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
 
 	reqBody := `{
-		"url": "invalid-url",
-		"poll_interval_seconds": 60
+		"url": "https://example.com",
+		"poll_interval_seconds": 60,
+		"secrets": {"db_password": "hunter2"}
 	}`
 
-	handler := New(nil, mockConfigService, nil)
+	mockConfigService.
+		On("Create", mock.MatchedBy(func(cfg *model.Config) bool {
+			return cfg.Secrets["db_password"] == "hunter2"
+		}), 0).
+		Return(nil).
+		Once()
 
-	router := setupRouter(handler)
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{
+			Version:   5,
+			URL:       "https://example.com",
+			Secrets:   map[string]string{"db_password": "hunter2"},
+			Signature: "sig",
+		}, nil).
+		Once()
 
-	req := httptest.NewRequest(
-		http.MethodPost,
-		"/config",
-		bytes.NewBufferString(reqBody),
-	)
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
 
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(reqBody))
 	req.Header.Set("Content-Type", "application/json")
-
 	resp := httptest.NewRecorder()
-
 	router.ServeHTTP(resp, req)
 
-	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.NotContains(t, resp.Body.String(), "hunter2")
 
-	var body map[string]interface{}
+	var body model.Config
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, map[string]string{"db_password": "[REDACTED]"}, body.Secrets)
+	assert.Empty(t, body.Signature)
 
-	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	mockConfigService.AssertExpectations(t)
+}
 
-	assert.NoError(t, err)
+func TestCreateConfig_SecretsDisabled(t *testing.T) {
 
-	errorObj := body["error"].(map[string]interface{})
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
+	mockConfigService.
+		On("Create", mock.AnythingOfType("*model.Config"), 0).
+		Return(service.ErrSecretsDisabled).
+		Once()
 
-	assert.Equal(t, "VALIDATION_ERROR", errorObj["code"])
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
 
-	fields := errorObj["fields"].([]interface{})
-	assert.Len(t, fields, 1)
-	fieldObj := fields[0].(map[string]interface{})
-	assert.Equal(t, "url", fieldObj["field"])
-	assert.Equal(t, "url", fieldObj["code"])
-	assert.Equal(t, "failed \"url\" validation", fieldObj["message"])
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{
+		"url": "https://example.com",
+		"poll_interval_seconds": 60,
+		"secrets": {"db_password": "hunter2"}
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "SECRETS_DISABLED")
 }
 
-func TestCreateConfig_ValidationError_InvalidType(t *testing.T) {
+func TestCreateConfig_Scheduled(t *testing.T) {
 
 	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
 
-	reqBody := `{
-		"url": 123,
-		"poll_interval_seconds": 60
-	}`
+	activateAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	mockConfigService.
+		On("Create", mock.MatchedBy(func(cfg *model.Config) bool {
+			return cfg.ActivateAt != nil && cfg.ActivateAt.Equal(activateAt)
+		}), 0).
+		Run(func(args mock.Arguments) { args.Get(0).(*model.Config).Version = 6 }).
+		Return(nil).
+		Once()
+	mockConfigService.
+		On("GetByVersion", 6).
+		Return(&model.Config{Version: 6, Namespace: "default", URL: "https://example.com", ActivateAt: &activateAt}, nil).
+		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
 	router := setupRouter(handler)
 
-	req := httptest.NewRequest(
-		http.MethodPost,
-		"/config",
-		bytes.NewBufferString(reqBody),
-	)
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{
+		"url": "https://example.com",
+		"poll_interval_seconds": 60,
+		"activate_at": "`+activateAt.Format(time.RFC3339)+`"
+	}`))
 	req.Header.Set("Content-Type", "application/json")
-
 	resp := httptest.NewRecorder()
 	router.ServeHTTP(resp, req)
 
-	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, http.StatusCreated, resp.Code)
 
-	var body map[string]interface{}
-	err := json.Unmarshal(resp.Body.Bytes(), &body)
-	assert.NoError(t, err)
+	var body model.Config
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, 6, body.Version)
+	if assert.NotNil(t, body.ActivateAt) {
+		assert.True(t, body.ActivateAt.Equal(activateAt))
+	}
 
-	errorObj := body["error"].(map[string]interface{})
-	assert.Equal(t, "VALIDATION_ERROR", errorObj["code"])
+	mockConfigService.AssertExpectations(t)
+	// Only the audit looks up the served version; the response is the
+	// scheduled version itself.
+	mockConfigService.AssertNumberOfCalls(t, "GetLatest", 1)
+}
 
-	fields := errorObj["fields"].([]interface{})
-	assert.Len(t, fields, 1)
-	fieldObj := fields[0].(map[string]interface{})
-	assert.Equal(t, "url", fieldObj["field"])
-	assert.Equal(t, "type_mismatch", fieldObj["code"])
-	assert.Equal(t, "string", fieldObj["param"])
-	assert.Equal(t, "must be string", fieldObj["message"])
+func TestCreateConfig_ActivateAtInPast(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{
+		"url": "https://example.com",
+		"poll_interval_seconds": 60,
+		"activate_at": "2020-01-01T00:00:00Z"
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "activate_at")
+	mockConfigService.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
 }
 
-func TestCreateConfig_CreateServiceError(t *testing.T) {
+func TestCreateConfig_ActivationPending(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
+	mockConfigService.
+		On("Create", mock.AnythingOfType("*model.Config"), 0).
+		Return(repository.ErrActivationPending).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"url":"https://example.com","poll_interval_seconds":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "SCHEDULED_VERSION_PENDING")
+}
+
+func TestCreateConfig_Success_WithNamespace(t *testing.T) {
 
 	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "prod", 0)
 
 	reqBody := `{
+		"namespace": "prod",
 		"url": "https://example.com",
 		"poll_interval_seconds": 60
 	}`
 
 	mockConfigService.
-		On("Create", "https://example.com", 60).
-		Return(errors.New("db error")).
+		On("Create", &model.Config{Namespace: "prod", URL: "https://example.com", PollIntervalSeconds: 60}, 0).
+		Return(nil).
+		Once()
+	mockConfigService.
+		On("GetLatest", "prod").
+		Return(&model.Config{Version: 9, Namespace: "prod", URL: "https://example.com", PollIntervalSeconds: 60}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var body model.Config
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", body.Namespace)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestCreateConfig_IfMatch_Success(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
+
+	mockConfigService.
+		On("Create", &model.Config{Namespace: "default", URL: "https://example.com", PollIntervalSeconds: 60}, 3).
+		Return(nil).
+		Once()
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{Version: 4, Namespace: "default", URL: "https://example.com", PollIntervalSeconds: 60}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"url":"https://example.com","poll_interval_seconds":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"3"`)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.Equal(t, `"4"`, resp.Header().Get("ETag"))
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestCreateConfig_IfMatch_VersionConflict(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
+
+	mockConfigService.
+		On("Create", &model.Config{Namespace: "default", URL: "https://example.com", PollIntervalSeconds: 60}, 3).
+		Return(repository.ErrVersionConflict).
+		Once()
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{Version: 5, Namespace: "default"}, nil).
 		Once()
 
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"url":"https://example.com","poll_interval_seconds":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"3"`)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	assert.Equal(t, `"5"`, resp.Header().Get("ETag"))
+	assert.Contains(t, resp.Body.String(), "PRECONDITION_FAILED")
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestCreateConfig_IfMatch_Invalid(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"url":"https://example.com","poll_interval_seconds":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "invalid If-Match header")
+
+	mockConfigService.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateConfig_ValidationError_DataNotObject(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	reqBody := `{
+		"url": "https://example.com",
+		"poll_interval_seconds": 60,
+		"data": [1, 2, 3]
+	}`
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var body map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+
+	errorObj := body["error"].(map[string]interface{})
+	assert.Equal(t, "VALIDATION_ERROR", errorObj["code"])
+	fields := errorObj["fields"].([]interface{})
+	assert.Len(t, fields, 1)
+	fieldObj := fields[0].(map[string]interface{})
+	assert.Equal(t, "data", fieldObj["field"])
+	assert.Equal(t, "object", fieldObj["code"])
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestCreateConfig_ValidationError_MissingFields(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	reqBody := `{}`
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -722,31 +1203,47 @@ func TestCreateConfig_CreateServiceError(t *testing.T) {
 
 	router.ServeHTTP(resp, req)
 
-	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
 
-	mockConfigService.AssertExpectations(t)
+	var body map[string]interface{}
+
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+
+	assert.NoError(t, err)
+
+	errorObj := body["error"].(map[string]interface{})
+
+	assert.Equal(t, "VALIDATION_ERROR", errorObj["code"])
+	assert.Equal(t, "validation failed", errorObj["message"])
+
+	fields := errorObj["fields"].([]interface{})
+	assert.Len(t, fields, 2)
+
+	found := map[string]map[string]string{}
+	for _, f := range fields {
+		fieldObj := f.(map[string]interface{})
+		found[fieldObj["field"].(string)] = map[string]string{
+			"code":    fieldObj["code"].(string),
+			"message": fieldObj["message"].(string),
+		}
+	}
+
+	assert.Equal(t, "required", found["url"]["code"])
+	assert.Equal(t, "failed \"required\" validation", found["url"]["message"])
+	assert.Equal(t, "required", found["poll_interval_seconds"]["code"])
+	assert.Equal(t, "failed \"required\" validation", found["poll_interval_seconds"]["message"])
 }
 
-func TestCreateConfig_GetLatestError(t *testing.T) {
+func TestCreateConfig_ValidationError_InvalidURL(t *testing.T) {
 
 	mockConfigService := new(serviceMocks.ConfigService)
 
 	reqBody := `{
-		"url": "https://example.com",
+		"url": "invalid-url",
 		"poll_interval_seconds": 60
 	}`
 
-	mockConfigService.
-		On("Create", "https://example.com", 60).
-		Return(nil).
-		Once()
-
-	mockConfigService.
-		On("GetLatest").
-		Return(nil, errors.New("db error")).
-		Once()
-
-	handler := New(nil, mockConfigService, nil)
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
 
 	router := setupRouter(handler)
 
@@ -762,22 +1259,2300 @@ func TestCreateConfig_GetLatestError(t *testing.T) {
 
 	router.ServeHTTP(resp, req)
 
-	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
 
-	mockConfigService.AssertExpectations(t)
+	var body map[string]interface{}
+
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+
+	assert.NoError(t, err)
+
+	errorObj := body["error"].(map[string]interface{})
+
+	assert.Equal(t, "VALIDATION_ERROR", errorObj["code"])
+
+	fields := errorObj["fields"].([]interface{})
+	assert.Len(t, fields, 1)
+	fieldObj := fields[0].(map[string]interface{})
+	assert.Equal(t, "url", fieldObj["field"])
+	assert.Equal(t, "url", fieldObj["code"])
+	assert.Equal(t, "failed \"url\" validation", fieldObj["message"])
 }
 
-func TestIfNoneMatchContains(t *testing.T) {
-	assert.False(t, ifNoneMatchContains("", `"1"`))
-	assert.False(t, ifNoneMatchContains(`"1"`, ""))
-	assert.False(t, ifNoneMatchContains(`"2"`, `"1"`))
-	assert.True(t, ifNoneMatchContains(`W/"1"`, `"1"`))
-	assert.True(t, ifNoneMatchContains(`"2", "1"`, `"1"`))
+func TestCreateConfig_ValidationError_InvalidType(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	reqBody := `{
+		"url": 123,
+		"poll_interval_seconds": 60
+	}`
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/config",
+		bytes.NewBufferString(reqBody),
+	)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var body map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+
+	errorObj := body["error"].(map[string]interface{})
+	assert.Equal(t, "VALIDATION_ERROR", errorObj["code"])
+
+	fields := errorObj["fields"].([]interface{})
+	assert.Len(t, fields, 1)
+	fieldObj := fields[0].(map[string]interface{})
+	assert.Equal(t, "url", fieldObj["field"])
+	assert.Equal(t, "type_mismatch", fieldObj["code"])
+	assert.Equal(t, "string", fieldObj["param"])
+	assert.Equal(t, "must be string", fieldObj["message"])
 }
 
-func TestNormalizeETag(t *testing.T) {
-	assert.Equal(t, "1", normalizeETag(`"1"`))
-	assert.Equal(t, "1", normalizeETag(`W/"1"`))
-	assert.Equal(t, "1", normalizeETag(`w/"1"`))
-	assert.Equal(t, "1", normalizeETag(`  "1"  `))
+func TestCreateConfig_CreateServiceError(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
+
+	reqBody := `{
+		"url": "https://example.com",
+		"poll_interval_seconds": 60
+	}`
+
+	mockConfigService.
+		On("Create", &model.Config{Namespace: "default", URL: "https://example.com", PollIntervalSeconds: 60}, 0).
+		Return(errors.New("db error")).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/config",
+		bytes.NewBufferString(reqBody),
+	)
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestCreateConfig_GetLatestError(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
+
+	reqBody := `{
+		"url": "https://example.com",
+		"poll_interval_seconds": 60
+	}`
+
+	mockConfigService.
+		On("Create", &model.Config{Namespace: "default", URL: "https://example.com", PollIntervalSeconds: 60}, 0).
+		Return(nil).
+		Once()
+
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(nil, errors.New("db error")).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/config",
+		bytes.NewBufferString(reqBody),
+	)
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+//
+// Config History Tests
+//
+
+func TestListConfigs_Success_DefaultPagination(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	configs := []model.Config{
+		{Version: 2, URL: "https://example.com/v2", PollIntervalSeconds: 60},
+		{Version: 1, URL: "https://example.com/v1", PollIntervalSeconds: 30},
+	}
+
+	mockConfigService.
+		On("List", "", 20, 0).
+		Return(configs, 2, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body ListConfigsResponse
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Len(t, body.Items, 2)
+	assert.Equal(t, 2, body.Total)
+	assert.Equal(t, 20, body.Limit)
+	assert.Equal(t, 0, body.Offset)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestListConfigs_CustomPagination(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("List", "", 5, 10).
+		Return([]model.Config{}, 12, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs?limit=5&offset=10", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body ListConfigsResponse
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, body.Total)
+	assert.Equal(t, 5, body.Limit)
+	assert.Equal(t, 10, body.Offset)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestListConfigs_FilterByNamespace(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("List", "prod", 20, 0).
+		Return([]model.Config{{Version: 3, Namespace: "prod"}}, 1, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs?namespace=prod", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestListConfigs_ValidationError_LimitTooLarge(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs?limit=500", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var body map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+
+	errorObj := body["error"].(map[string]interface{})
+	fields := errorObj["fields"].([]interface{})
+	assert.Len(t, fields, 1)
+	fieldObj := fields[0].(map[string]interface{})
+	assert.Equal(t, "limit", fieldObj["field"])
+	assert.Equal(t, "lte", fieldObj["code"])
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestGetConfigVersion_Success(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("GetByVersion", 1).
+		Return(&model.Config{Version: 1, URL: "https://example.com/v1", PollIntervalSeconds: 30, Signature: "sig"}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/1", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body model.Config
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, body.Version)
+	assert.Equal(t, "https://example.com/v1", body.URL)
+	assert.Empty(t, body.Signature, "admin responses never carry the signature")
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestGetConfigVersion_RedactsSecrets(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("GetByVersion", 2).
+		Return(&model.Config{Version: 2, URL: "https://example.com/v2", Secrets: map[string]string{"token": "abc"}}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/2", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body model.Config
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, map[string]string{"token": "[REDACTED]"}, body.Secrets)
+}
+
+func TestGetConfigVersion_NotFound(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("GetByVersion", 7).
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/7", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestGetConfigVersion_InvalidVersion(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/abc", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var body map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+
+	errorObj := body["error"].(map[string]interface{})
+	assert.Equal(t, "VALIDATION_ERROR", errorObj["code"])
+	assert.Equal(t, "invalid version", errorObj["message"])
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestRollbackConfig_Success(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersionOf(mockConfigService, 1, "prod", 0)
+
+	mockConfigService.
+		On("Rollback", 1, "").
+		Return(&model.Config{Version: 4, URL: "https://example.com/v1", PollIntervalSeconds: 30}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/1/rollback", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var body model.Config
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, body.Version)
+	assert.Equal(t, "https://example.com/v1", body.URL)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestRollbackConfig_NotFound(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersionOf(mockConfigService, 9, "prod", 0)
+
+	mockConfigService.
+		On("Rollback", 9, "").
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/9/rollback", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+//
+// Long-poll Tests
+//
+
+func TestGetConfig_Wait_ReturnsNewVersionWhenCreated(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	created := make(chan struct{})
+	close(created)
+	mockConfigService.
+		On("Changed", "default").
+		Return((<-chan struct{})(created)).
+		Once()
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{Version: 1, URL: "https://example.com/v1"}, nil).
+		Once()
+	mockConfigService.
+		On("Changed", "default").
+		Return((<-chan struct{})(make(chan struct{}))).
+		Once()
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{Version: 2, URL: "https://example.com/v2"}, nil).
+		Once()
+
+	mockAgentService := newRegisteredAgentService("default")
+	handler := New(nil, mockConfigService, mockAgentService, passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/config?wait=60s", nil)
+	req.Header.Set("X-Agent-ID", uuid.NewString())
+	req.Header.Set("If-None-Match", `"1"`)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+	mockAgentService.AssertCalled(t, "RecordPoll", mock.Anything, 2)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestGetConfig_Wait_TimesOutWithNotModified(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("Changed", "default").
+		Return((<-chan struct{})(make(chan struct{}))).
+		Once()
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{Version: 1, URL: "https://example.com"}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/config?wait=20ms", nil)
+	req.Header.Set("X-Agent-ID", uuid.NewString())
+	req.Header.Set("If-None-Match", `"1"`)
+	resp := httptest.NewRecorder()
+
+	start := time.Now()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotModified, resp.Code)
+	assert.Equal(t, `"1"`, resp.Header().Get("ETag"))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestGetConfig_Wait_ReturnsImmediatelyWhenStale(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("Changed", "default").
+		Return((<-chan struct{})(make(chan struct{}))).
+		Once()
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{Version: 3, URL: "https://example.com"}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/config?wait=60", nil)
+	req.Header.Set("X-Agent-ID", uuid.NewString())
+	req.Header.Set("If-None-Match", `"2"`)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"3"`, resp.Header().Get("ETag"))
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestGetConfig_Wait_Invalid(t *testing.T) {
+
+	for _, wait := range []string{"soon", "-1s", "10m"} {
+		t.Run(wait, func(t *testing.T) {
+			handler := New(nil, new(serviceMocks.ConfigService), new(serviceMocks.AgentService), passThroughRollouts(), nil, nil, nil, nil)
+			router := setupRouter(handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/config?wait="+wait, nil)
+			req.Header.Set("X-Agent-ID", uuid.NewString())
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	}
+}
+
+//
+// Config stream Tests
+//
+
+// serveStream runs the stream handler until the client goes away after d.
+func serveStream(router *gin.Engine, lastEventID string, d time.Duration) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/config/stream", nil).WithContext(ctx)
+	req.Header.Set("X-Agent-ID", uuid.NewString())
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestStreamConfig_SendsLatestAndChanges(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	created := make(chan struct{})
+	close(created)
+	mockConfigService.
+		On("Changed", "prod").
+		Return((<-chan struct{})(created)).
+		Once()
+	mockConfigService.
+		On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod", URL: "https://example.com/v1"}, nil).
+		Once()
+	mockConfigService.
+		On("Changed", "prod").
+		Return((<-chan struct{})(make(chan struct{}))).
+		Once()
+	mockConfigService.
+		On("GetLatest", "prod").
+		Return(&model.Config{Version: 2, Namespace: "prod", URL: "https://example.com/v2"}, nil).
+		Once()
+
+	mockAgentService := newRegisteredAgentService("prod")
+	handler := New(nil, mockConfigService, mockAgentService, passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	resp := serveStream(router, "", 50*time.Millisecond)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/event-stream", resp.Header().Get("Content-Type"))
+	body := resp.Body.String()
+	assert.Contains(t, body, "id: 1\nevent: config\ndata: {\"version\":1,")
+	assert.Contains(t, body, "id: 2\nevent: config\ndata: {\"version\":2,")
+	mockAgentService.AssertCalled(t, "RecordPoll", mock.Anything, 2)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestStreamConfig_SkipsVersionFromLastEventID(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("Changed", "default").
+		Return((<-chan struct{})(make(chan struct{}))).
+		Once()
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{Version: 4}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	resp := serveStream(router, "4", 20*time.Millisecond)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotContains(t, resp.Body.String(), "event: config")
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestStreamConfig_KeepAlive(t *testing.T) {
+
+	previous := streamKeepAlive
+	streamKeepAlive = 5 * time.Millisecond
+	defer func() { streamKeepAlive = previous }()
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("Changed", "default").
+		Return((<-chan struct{})(make(chan struct{})))
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(nil, sql.ErrNoRows)
+
+	handler := New(nil, mockConfigService, newRegisteredAgentService("default"), passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	resp := serveStream(router, "", 30*time.Millisecond)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), ": keep-alive\n\n")
+	assert.NotContains(t, resp.Body.String(), "event: config")
+}
+
+func TestStreamConfig_InvalidLastEventID(t *testing.T) {
+
+	handler := New(nil, new(serviceMocks.ConfigService), new(serviceMocks.AgentService), passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	resp := serveStream(router, "abc", time.Second)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestStreamConfig_AgentNotRegistered(t *testing.T) {
+
+	mockAgentService := new(serviceMocks.AgentService)
+	mockAgentService.
+		On("Get", mock.Anything).
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(nil, new(serviceMocks.ConfigService), mockAgentService, passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	resp := serveStream(router, "", time.Second)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+//
+// Agent registry Tests
+//
+
+func TestRegisterAgent_WithReportedDetails(t *testing.T) {
+
+	mockAgent := new(serviceMocks.AgentService)
+	mockConfig := new(serviceMocks.ConfigService)
+
+	mockAgent.
+		On("Register", model.AgentAuth{}, &model.Agent{
+			Namespace: "prod",
+			Hostname:  "host-a",
+			Version:   "1.2.0",
+			Labels:    map[string]string{"region": "eu"},
+		}).
+		Return("agent-123", "", nil).
+		Once()
+	mockConfig.
+		On("GetLatest", "prod").
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(&config.Config{PollURL: "/config"}, mockConfig, mockAgent, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	body := `{"namespace":"prod","hostname":"host-a","version":"1.2.0","labels":{"region":"eu"}}`
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	mockAgent.AssertExpectations(t)
+	mockConfig.AssertExpectations(t)
+}
+
+func TestGetConfig_HeartbeatErrorStillServesConfig(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockAgentService := new(serviceMocks.AgentService)
+
+	agentID := uuid.NewString()
+	mockAgentService.
+		On("Get", agentID).
+		Return(&model.Agent{ID: agentID, Namespace: "default"}, nil).
+		Once()
+	mockAgentService.
+		On("RecordPoll", agentID, 2).
+		Return(errors.New("db down")).
+		Once()
+	mockConfigService.
+		On("GetLatest", "default").
+		Return(&model.Config{Version: 2, URL: "https://example.com"}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, mockAgentService, passThroughRollouts(), nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	req.Header.Set("X-Agent-ID", agentID)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	mockAgentService.AssertExpectations(t)
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestListAgents_Success(t *testing.T) {
+
+	mockAgentService := new(serviceMocks.AgentService)
+
+	agents := []model.Agent{
+		{ID: "agent-1", Namespace: "prod", Status: model.AgentStatusStale},
+	}
+	mockAgentService.
+		On("List", "prod", "stale", 10, 5).
+		Return(agents, 6, nil).
+		Once()
+
+	handler := New(nil, nil, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/agents?namespace=prod&status=stale&limit=10&offset=5", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body ListAgentsResponse
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, agents, body.Items)
+	assert.Equal(t, 6, body.Total)
+	assert.Equal(t, 10, body.Limit)
+	assert.Equal(t, 5, body.Offset)
+
+	mockAgentService.AssertExpectations(t)
+}
+
+func TestListAgents_DefaultLimit(t *testing.T) {
+
+	mockAgentService := new(serviceMocks.AgentService)
+
+	mockAgentService.
+		On("List", "", "", defaultListLimit, 0).
+		Return([]model.Agent{}, 0, nil).
+		Once()
+
+	handler := New(nil, nil, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/agents", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockAgentService.AssertExpectations(t)
+}
+
+func TestListAgents_InvalidStatus(t *testing.T) {
+
+	mockAgentService := new(serviceMocks.AgentService)
+
+	handler := New(nil, nil, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/agents?status=zombie", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockAgentService.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetAgent_Success(t *testing.T) {
+
+	mockAgentService := new(serviceMocks.AgentService)
+
+	agentID := uuid.NewString()
+	expected := &model.Agent{ID: agentID, Namespace: "prod", Hostname: "host-a", Status: model.AgentStatusHealthy}
+	mockAgentService.
+		On("Get", agentID).
+		Return(expected, nil).
+		Once()
+
+	handler := New(nil, nil, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/agents/"+agentID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body model.Agent
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, *expected, body)
+
+	mockAgentService.AssertExpectations(t)
+}
+
+func TestGetAgent_InvalidID(t *testing.T) {
+
+	handler := New(nil, nil, new(serviceMocks.AgentService), nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/agents/not-a-uuid", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestGetAgent_NotFound(t *testing.T) {
+
+	mockAgentService := new(serviceMocks.AgentService)
+
+	mockAgentService.
+		On("Get", mock.Anything).
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(nil, nil, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/agents/"+uuid.NewString(), nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	mockAgentService.AssertExpectations(t)
+}
+
+//
+// Apply status Tests
+//
+
+func TestReportAgentStatus_Success(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockAgentService := new(serviceMocks.AgentService)
+
+	agentID := uuid.NewString()
+	mockConfigService.
+		On("GetByVersion", 42).
+		Return(&model.Config{Version: 42}, nil).
+		Once()
+	mockAgentService.
+		On("ReportStatus", &model.AgentConfigStatus{
+			AgentID: agentID,
+			Version: 42,
+			Status:  model.ApplyStatusFailed,
+			Error:   "worker unreachable",
+		}).
+		Return(nil).
+		Once()
+
+	handler := New(nil, mockConfigService, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	body := `{"version":42,"status":"failed","error":"worker unreachable"}`
+	req := httptest.NewRequest(http.MethodPost, "/agents/"+agentID+"/status", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	mockConfigService.AssertExpectations(t)
+	mockAgentService.AssertExpectations(t)
+}
+
+func TestReportAgentStatus_InvalidStatus(t *testing.T) {
+
+	handler := New(nil, new(serviceMocks.ConfigService), new(serviceMocks.AgentService), nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	body := `{"version":42,"status":"done"}`
+	req := httptest.NewRequest(http.MethodPost, "/agents/"+uuid.NewString()+"/status", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestReportAgentStatus_UnknownVersion(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockAgentService := new(serviceMocks.AgentService)
+
+	mockConfigService.
+		On("GetByVersion", 42).
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(nil, mockConfigService, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	body := `{"version":42,"status":"applied"}`
+	req := httptest.NewRequest(http.MethodPost, "/agents/"+uuid.NewString()+"/status", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	mockAgentService.AssertNotCalled(t, "ReportStatus", mock.Anything)
+}
+
+func TestReportAgentStatus_AgentNotRegistered(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockAgentService := new(serviceMocks.AgentService)
+
+	mockConfigService.
+		On("GetByVersion", 42).
+		Return(&model.Config{Version: 42}, nil).
+		Once()
+	mockAgentService.
+		On("ReportStatus", mock.Anything).
+		Return(sql.ErrNoRows).
+		Once()
+
+	handler := New(nil, mockConfigService, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	body := `{"version":42,"status":"applied"}`
+	req := httptest.NewRequest(http.MethodPost, "/agents/"+uuid.NewString()+"/status", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	var respBody map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &respBody)
+	assert.NoError(t, err)
+	errorObj := respBody["error"].(map[string]interface{})
+	assert.Equal(t, "agent not registered", errorObj["message"])
+}
+
+func TestGetConfigStatus_Success(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockAgentService := new(serviceMocks.AgentService)
+
+	summary := &model.ConfigStatusSummary{
+		Version:   42,
+		Namespace: "prod",
+		Agents:    120,
+		Applied:   118,
+		Failed:    2,
+		Failures:  []model.AgentConfigStatus{},
+	}
+	mockConfigService.
+		On("GetByVersion", 42).
+		Return(&model.Config{Version: 42, Namespace: "prod"}, nil).
+		Once()
+	mockAgentService.
+		On("StatusSummary", "prod", 42).
+		Return(summary, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, mockAgentService, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/42/status", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body model.ConfigStatusSummary
+	err := json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, *summary, body)
+
+	mockConfigService.AssertExpectations(t)
+	mockAgentService.AssertExpectations(t)
+}
+
+func TestGetConfigStatus_VersionNotFound(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	mockConfigService.
+		On("GetByVersion", 42).
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	handler := New(nil, mockConfigService, new(serviceMocks.AgentService), nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/42/status", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestGetConfig_RolloutServesBaseVersion(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockAgentService := new(serviceMocks.AgentService)
+	mockRolloutService := new(serviceMocks.RolloutService)
+
+	agentID := uuid.NewString()
+	agent := &model.Agent{ID: agentID, Namespace: "prod"}
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockAgentService.On("Get", agentID).Return(agent, nil).Once()
+	mockAgentService.On("RecordPoll", agentID, 4).Return(nil).Once()
+	mockConfigService.On("GetLatest", "prod").Return(latest, nil).Once()
+	mockRolloutService.
+		On("Resolve", agent, latest).
+		Return(&model.Config{Version: 4, Namespace: "prod"}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, mockAgentService, mockRolloutService, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	req.Header.Set("X-Agent-ID", agentID)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"4"`, resp.Header().Get("ETag"))
+
+	mockAgentService.AssertExpectations(t)
+	mockRolloutService.AssertExpectations(t)
+}
+
+func TestCreateConfig_WithRollout(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "prod", 4)
+
+	agentID := uuid.NewString()
+	mockConfigService.
+		On("Create", &model.Config{
+			Namespace:           "prod",
+			URL:                 "https://example.com",
+			PollIntervalSeconds: 60,
+			Rollout: &model.RolloutPolicy{
+				Percentage: 10,
+				AgentIDs:   []string{agentID},
+				Labels:     map[string]string{"zone": "eu"},
+			},
+		}, 0).
+		Return(nil).
+		Once()
+	mockConfigService.
+		On("GetLatest", "prod").
+		Return(&model.Config{Version: 5, Namespace: "prod"}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	reqBody := `{
+		"namespace": "prod",
+		"url": "https://example.com",
+		"poll_interval_seconds": 60,
+		"rollout": {"percentage": 10, "agent_ids": ["` + agentID + `"], "labels": {"zone": "eu"}}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestCreateConfig_RolloutValidationError(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	reqBody := `{
+		"url": "https://example.com",
+		"poll_interval_seconds": 60,
+		"rollout": {"percentage": 150}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	mockConfigService.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateConfig_RolloutInProgress(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
+
+	mockConfigService.
+		On("Create", &model.Config{Namespace: "default", URL: "https://example.com", PollIntervalSeconds: 60}, 0).
+		Return(repository.ErrRolloutInProgress).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"url":"https://example.com","poll_interval_seconds":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "ROLLOUT_IN_PROGRESS")
+}
+
+func TestGetRollout_NotFound(t *testing.T) {
+
+	mockRolloutService := new(serviceMocks.RolloutService)
+	mockRolloutService.On("Get", 5).Return(nil, sql.ErrNoRows).Once()
+
+	handler := New(nil, nil, nil, mockRolloutService, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/5/rollout", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestAdvanceRollout_Success(t *testing.T) {
+
+	mockRolloutService := new(serviceMocks.RolloutService)
+
+	agentID := uuid.NewString()
+	mockRolloutService.
+		On("Advance", 5, 50, []string{agentID}).
+		Return(&model.Rollout{
+			Version:       5,
+			RolloutPolicy: model.RolloutPolicy{Percentage: 50, AgentIDs: []string{agentID}},
+			Status:        model.RolloutStatusInProgress,
+		}, nil).
+		Once()
+
+	handler := New(nil, nil, nil, mockRolloutService, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/5/rollout/advance", bytes.NewBufferString(`{"percentage":50,"agent_ids":["`+agentID+`"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body model.Rollout
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, 50, body.Percentage)
+	assert.Equal(t, model.RolloutStatusInProgress, body.Status)
+
+	mockRolloutService.AssertExpectations(t)
+}
+
+func TestAdvanceRollout_LowerPercentage(t *testing.T) {
+
+	mockRolloutService := new(serviceMocks.RolloutService)
+	mockRolloutService.
+		On("Advance", 5, 10, []string(nil)).
+		Return(nil, service.ErrRolloutNarrowed).
+		Once()
+
+	handler := New(nil, nil, nil, mockRolloutService, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/5/rollout/advance", bytes.NewBufferString(`{"percentage":10}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "INVALID_ROLLOUT_STATE")
+	mockRolloutService.AssertExpectations(t)
+}
+
+func TestAdvanceRollout_InvalidAgentID(t *testing.T) {
+
+	mockRolloutService := new(serviceMocks.RolloutService)
+
+	handler := New(nil, nil, nil, mockRolloutService, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/5/rollout/advance", bytes.NewBufferString(`{"percentage":50,"agent_ids":["nope"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockRolloutService.AssertNotCalled(t, "Advance", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPauseRollout_Success(t *testing.T) {
+
+	mockRolloutService := new(serviceMocks.RolloutService)
+	mockRolloutService.
+		On("Pause", 5).
+		Return(&model.Rollout{Version: 5, Status: model.RolloutStatusPaused}, nil).
+		Once()
+
+	handler := New(nil, nil, nil, mockRolloutService, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/5/rollout/pause", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"status":"paused"`)
+}
+
+func TestAbortRollout_InvalidState(t *testing.T) {
+
+	mockRolloutService := new(serviceMocks.RolloutService)
+	mockRolloutService.
+		On("Abort", 5).
+		Return(nil, service.ErrInvalidRolloutState).
+		Once()
+
+	handler := New(nil, nil, nil, mockRolloutService, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/5/rollout/abort", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "INVALID_ROLLOUT_STATE")
+}
+
+func TestCreateConfig_RecordsAudit(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "prod", 6)
+	mockAuditService := new(serviceMocks.AuditService)
+
+	mockConfigService.
+		On("Create", mock.Anything, 0).
+		Run(func(args mock.Arguments) { args.Get(0).(*model.Config).Version = 7 }).
+		Return(nil).
+		Once()
+	mockConfigService.
+		On("GetLatest", "prod").
+		Return(&model.Config{Version: 7, Namespace: "prod"}, nil).
+		Once()
+	mockAuditService.
+		On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+			return e.Actor == "admin" &&
+				e.Action == model.AuditActionConfigCreate &&
+				e.Namespace == "prod" &&
+				e.VersionBefore == 6 &&
+				e.VersionAfter == 7 &&
+				e.RequestID == "req-1" &&
+				e.ClientIP != "" &&
+				e.Details == nil
+		})).
+		Return(errors.New("db down")).
+		Once()
+
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+	mockAPIKeyService.
+		On("Authenticate", "secret").
+		Return(&model.APIKey{Name: "admin", Scopes: []string{model.ScopeAdmin}}, nil).
+		Once()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestLogger())
+	r.POST("/config", middleware.RequireScope(mockAPIKeyService, model.ScopeWriteConfig), New(nil, mockConfigService, nil, nil, mockAuditService, nil, nil, nil).CreateConfig)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"namespace":"prod","url":"https://example.com","poll_interval_seconds":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "secret")
+	req.Header.Set("X-Request-ID", "req-1")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	// A failed audit write does not undo the change.
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	mockAuditService.AssertExpectations(t)
+}
+
+func TestAbortRollout_RecordsAudit(t *testing.T) {
+
+	mockRolloutService := new(serviceMocks.RolloutService)
+	mockAuditService := new(serviceMocks.AuditService)
+
+	mockRolloutService.
+		On("Abort", 5).
+		Return(&model.Rollout{Version: 5, BaseVersion: 4, Namespace: "prod", Status: model.RolloutStatusAborted}, nil).
+		Once()
+	mockAuditService.
+		On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+			return e.Action == model.AuditActionRolloutAbort &&
+				e.Namespace == "prod" &&
+				e.VersionBefore == 4 &&
+				e.VersionAfter == 5 &&
+				strings.Contains(string(e.Details), `"status":"aborted"`)
+		})).
+		Return(nil).
+		Once()
+
+	handler := New(nil, nil, nil, mockRolloutService, mockAuditService, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/5/rollout/abort", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockAuditService.AssertExpectations(t)
+}
+
+func TestListAudit_Success(t *testing.T) {
+
+	mockAuditService := new(serviceMocks.AuditService)
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockAuditService.
+		On("List", mock.MatchedBy(func(f model.AuditFilter) bool {
+			return f.Actor == "admin" &&
+				f.Since != nil && f.Since.Equal(since) &&
+				f.Until == nil &&
+				f.Limit == 20 && f.Offset == 0
+		})).
+		Return([]model.AuditEntry{{ID: 1, Actor: "admin", Action: model.AuditActionConfigCreate}}, 1, nil).
+		Once()
+
+	handler := New(nil, nil, nil, nil, mockAuditService, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/audit?actor=admin&since=2024-01-01T00:00:00Z", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body ListAuditResponse
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.Total)
+	assert.Len(t, body.Items, 1)
+
+	mockAuditService.AssertExpectations(t)
+}
+
+func TestListAudit_InvalidSince(t *testing.T) {
+
+	mockAuditService := new(serviceMocks.AuditService)
+
+	handler := New(nil, nil, nil, nil, mockAuditService, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/audit?since=yesterday", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockAuditService.AssertNotCalled(t, "List", mock.Anything)
+}
+
+func TestCreateAPIKey_Success(t *testing.T) {
+
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+	mockAuditService := new(serviceMocks.AuditService)
+
+	keyID := uuid.NewString()
+	mockAPIKeyService.
+		On("Create", "ci", []string{model.ScopeWriteConfig}, (*time.Time)(nil)).
+		Return(&model.APIKey{ID: keyID, Name: "ci", Scopes: []string{model.ScopeWriteConfig}, Hash: "hash"}, keyID+".secret", nil).
+		Once()
+	mockAuditService.
+		On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+			return e.Action == model.AuditActionAPIKeyCreate &&
+				strings.Contains(string(e.Details), keyID) &&
+				!strings.Contains(string(e.Details), "secret")
+		})).
+		Return(nil).
+		Once()
+
+	handler := New(nil, nil, nil, nil, mockAuditService, mockAPIKeyService, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewBufferString(`{"name":"ci","scopes":["write-config"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, keyID+".secret", body["key"])
+	assert.Equal(t, keyID, body["id"])
+	assert.NotContains(t, body, "hash")
+
+	mockAPIKeyService.AssertExpectations(t)
+	mockAuditService.AssertExpectations(t)
+}
+
+func TestCreateAPIKey_ValidationError(t *testing.T) {
+
+	tests := map[string]string{
+		"unknown scope":  `{"name":"ci","scopes":["root"]}`,
+		"no scopes":      `{"name":"ci","scopes":[]}`,
+		"missing name":   `{"scopes":["admin"]}`,
+		"expired":        `{"name":"ci","scopes":["admin"],"expires_at":"2000-01-01T00:00:00Z"}`,
+		"malformed time": `{"name":"ci","scopes":["admin"],"expires_at":"tomorrow"}`,
+	}
+
+	for name, reqBody := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockAPIKeyService := new(serviceMocks.APIKeyService)
+
+			handler := New(nil, nil, nil, nil, nil, mockAPIKeyService, nil, nil)
+			router := setupRouter(handler)
+
+			req := httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewBufferString(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			mockAPIKeyService.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateAPIKey_ReservedName(t *testing.T) {
+
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+	mockAPIKeyService.
+		On("Create", "agent", []string{model.ScopeRegister}, (*time.Time)(nil)).
+		Return(nil, "", service.ErrReservedAPIKeyName).
+		Once()
+
+	handler := New(nil, nil, nil, nil, nil, mockAPIKeyService, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewBufferString(`{"name":"agent","scopes":["register"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "reserved")
+}
+
+func TestCreateAPIKey_NameTaken(t *testing.T) {
+
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+	mockAPIKeyService.
+		On("Create", "ci", []string{model.ScopeAdmin}, (*time.Time)(nil)).
+		Return(nil, "", repository.ErrAPIKeyNameTaken).
+		Once()
+
+	handler := New(nil, nil, nil, nil, nil, mockAPIKeyService, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewBufferString(`{"name":"ci","scopes":["admin"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "NAME_TAKEN")
+}
+
+func TestListAPIKeys_Success(t *testing.T) {
+
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+	mockAPIKeyService.
+		On("List").
+		Return([]model.APIKey{{ID: "k1", Name: "ci", Scopes: []string{model.ScopeReadConfig}, Hash: "hash"}}, nil).
+		Once()
+
+	handler := New(nil, nil, nil, nil, nil, mockAPIKeyService, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api-keys", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"name":"ci"`)
+	assert.NotContains(t, resp.Body.String(), "hash")
+}
+
+func TestRevokeAPIKey_Success(t *testing.T) {
+
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+	keyID := uuid.NewString()
+	mockAPIKeyService.On("Revoke", keyID).Return(nil).Once()
+
+	handler := New(nil, nil, nil, nil, acceptAudits(), mockAPIKeyService, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api-keys/"+keyID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	mockAPIKeyService.AssertExpectations(t)
+}
+
+func TestRevokeAPIKey_NotFound(t *testing.T) {
+
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+	keyID := uuid.NewString()
+	mockAPIKeyService.On("Revoke", keyID).Return(sql.ErrNoRows).Once()
+
+	handler := New(nil, nil, nil, nil, nil, mockAPIKeyService, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api-keys/"+keyID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestRevokeAPIKey_InvalidID(t *testing.T) {
+
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+
+	handler := New(nil, nil, nil, nil, nil, mockAPIKeyService, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api-keys/nope", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockAPIKeyService.AssertNotCalled(t, "Revoke", mock.Anything)
+}
+
+func TestCreateEnrollmentToken_DefaultsToSingleUse(t *testing.T) {
+
+	mockEnrollService := new(serviceMocks.EnrollmentService)
+	mockAuditService := new(serviceMocks.AuditService)
+
+	tokenID := uuid.NewString()
+	mockEnrollService.
+		On("Create", 1, (*time.Time)(nil)).
+		Return(&model.EnrollmentToken{ID: tokenID, MaxUses: 1, Hash: "hash"}, tokenID+".secret", nil).
+		Once()
+	mockAuditService.
+		On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+			return e.Action == model.AuditActionEnrollmentCreate && !strings.Contains(string(e.Details), "secret")
+		})).
+		Return(nil).
+		Once()
+
+	handler := New(nil, nil, nil, nil, mockAuditService, nil, mockEnrollService, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/enrollment-tokens", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, tokenID+".secret", body["token"])
+	assert.Equal(t, float64(1), body["max_uses"])
+	assert.NotContains(t, body, "hash")
+
+	mockEnrollService.AssertExpectations(t)
+	mockAuditService.AssertExpectations(t)
+}
+
+func TestCreateEnrollmentToken_ValidationError(t *testing.T) {
+
+	tests := map[string]string{
+		"negative uses": `{"max_uses":-1}`,
+		"too many uses": `{"max_uses":10001}`,
+		"expired":       `{"expires_at":"2000-01-01T00:00:00Z"}`,
+	}
+
+	for name, reqBody := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockEnrollService := new(serviceMocks.EnrollmentService)
+
+			handler := New(nil, nil, nil, nil, nil, nil, mockEnrollService, nil)
+			router := setupRouter(handler)
+
+			req := httptest.NewRequest(http.MethodPost, "/enrollment-tokens", bytes.NewBufferString(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			mockEnrollService.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestListEnrollmentTokens_Success(t *testing.T) {
+
+	mockEnrollService := new(serviceMocks.EnrollmentService)
+	mockEnrollService.
+		On("List").
+		Return([]model.EnrollmentToken{{ID: "t1", MaxUses: 5, Uses: 2, Hash: "hash"}}, nil).
+		Once()
+
+	handler := New(nil, nil, nil, nil, nil, nil, mockEnrollService, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/enrollment-tokens", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"uses":2`)
+	assert.NotContains(t, resp.Body.String(), "hash")
+}
+
+func TestRevokeEnrollmentToken_Success(t *testing.T) {
+
+	mockEnrollService := new(serviceMocks.EnrollmentService)
+	tokenID := uuid.NewString()
+	mockEnrollService.On("Revoke", tokenID).Return(nil).Once()
+
+	handler := New(nil, nil, nil, nil, acceptAudits(), nil, mockEnrollService, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodDelete, "/enrollment-tokens/"+tokenID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	mockEnrollService.AssertExpectations(t)
+}
+
+func TestCreateWebhook_Success(t *testing.T) {
+
+	mockWebhookService := new(serviceMocks.WebhookService)
+	mockAuditService := new(serviceMocks.AuditService)
+
+	webhookID := uuid.NewString()
+	mockWebhookService.
+		On("Create", "deploys", "https://hooks.example.com/config", "prod").
+		Return(&model.Webhook{
+			ID:        webhookID,
+			Name:      "deploys",
+			URL:       "https://hooks.example.com/config",
+			Namespace: "prod",
+			Secret:    "whsec",
+		}, "whsec", nil).
+		Once()
+	mockAuditService.
+		On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+			return e.Action == model.AuditActionWebhookCreate &&
+				e.Namespace == "prod" &&
+				!strings.Contains(string(e.Details), "whsec")
+		})).
+		Return(nil).
+		Once()
+
+	handler := New(nil, nil, nil, nil, mockAuditService, nil, nil, mockWebhookService)
+	router := setupRouter(handler)
+
+	reqBody := `{"name":"deploys","url":"https://hooks.example.com/config","namespace":"prod"}`
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, webhookID, body["id"])
+	assert.Equal(t, "whsec", body["secret"])
+
+	mockWebhookService.AssertExpectations(t)
+	mockAuditService.AssertExpectations(t)
+}
+
+func TestCreateWebhook_ValidationError(t *testing.T) {
+
+	tests := map[string]string{
+		"missing name":      `{"url":"https://hooks.example.com"}`,
+		"missing url":       `{"name":"deploys"}`,
+		"invalid url":       `{"name":"deploys","url":"not a url"}`,
+		"invalid namespace": `{"name":"deploys","url":"https://hooks.example.com","namespace":"Prod"}`,
+	}
+
+	for name, reqBody := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockWebhookService := new(serviceMocks.WebhookService)
+
+			handler := New(nil, nil, nil, nil, nil, nil, nil, mockWebhookService)
+			router := setupRouter(handler)
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			mockWebhookService.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestListWebhooks_HidesSecrets(t *testing.T) {
+
+	mockWebhookService := new(serviceMocks.WebhookService)
+	mockWebhookService.
+		On("List").
+		Return([]model.Webhook{{ID: "w1", Name: "deploys", URL: "https://hooks.example.com", Secret: "whsec"}}, nil).
+		Once()
+
+	handler := New(nil, nil, nil, nil, nil, nil, nil, mockWebhookService)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"name":"deploys"`)
+	assert.NotContains(t, resp.Body.String(), "whsec")
+}
+
+func TestDeleteWebhook(t *testing.T) {
+
+	t.Run("success", func(t *testing.T) {
+		mockWebhookService := new(serviceMocks.WebhookService)
+		webhookID := uuid.NewString()
+		mockWebhookService.On("Delete", webhookID).Return(nil).Once()
+
+		handler := New(nil, nil, nil, nil, acceptAudits(), nil, nil, mockWebhookService)
+		router := setupRouter(handler)
+
+		req := httptest.NewRequest(http.MethodDelete, "/webhooks/"+webhookID, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		mockWebhookService.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockWebhookService := new(serviceMocks.WebhookService)
+		webhookID := uuid.NewString()
+		mockWebhookService.On("Delete", webhookID).Return(sql.ErrNoRows).Once()
+
+		handler := New(nil, nil, nil, nil, nil, nil, nil, mockWebhookService)
+		router := setupRouter(handler)
+
+		req := httptest.NewRequest(http.MethodDelete, "/webhooks/"+webhookID, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		mockWebhookService := new(serviceMocks.WebhookService)
+
+		handler := New(nil, nil, nil, nil, nil, nil, nil, mockWebhookService)
+		router := setupRouter(handler)
+
+		req := httptest.NewRequest(http.MethodDelete, "/webhooks/nope", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockWebhookService.AssertNotCalled(t, "Delete", mock.Anything)
+	})
+}
+
+func TestListWebhookDeliveries_DefaultsLimit(t *testing.T) {
+
+	mockWebhookService := new(serviceMocks.WebhookService)
+	webhookID := uuid.NewString()
+	mockWebhookService.
+		On("ListDeliveries", webhookID, defaultListLimit, 0).
+		Return([]model.WebhookDelivery{{
+			ID:             7,
+			WebhookID:      webhookID,
+			Event:          model.WebhookEventConfigCreated,
+			Payload:        json.RawMessage(`{"event":"config.created"}`),
+			Status:         model.DeliveryStatusFailed,
+			Attempts:       8,
+			ResponseStatus: 500,
+			Secret:         "whsec",
+		}}, nil).
+		Once()
+
+	handler := New(nil, nil, nil, nil, nil, nil, nil, mockWebhookService)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/webhooks/"+webhookID+"/deliveries", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"status":"failed"`)
+	assert.Contains(t, resp.Body.String(), `"attempts":8`)
+	assert.NotContains(t, resp.Body.String(), "whsec")
+	mockWebhookService.AssertExpectations(t)
+}
+
+func TestRedeliverWebhookDelivery(t *testing.T) {
+
+	t.Run("success", func(t *testing.T) {
+		mockWebhookService := new(serviceMocks.WebhookService)
+		mockAuditService := new(serviceMocks.AuditService)
+		webhookID := uuid.NewString()
+		mockWebhookService.
+			On("Redeliver", webhookID, int64(7)).
+			Return(&model.WebhookDelivery{
+				ID:           9,
+				WebhookID:    webhookID,
+				Status:       model.DeliveryStatusPending,
+				RedeliveryOf: 7,
+			}, nil).
+			Once()
+		mockAuditService.
+			On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+				return e.Action == model.AuditActionWebhookRedeliver &&
+					strings.Contains(string(e.Details), `"redelivery_of":7`)
+			})).
+			Return(nil).
+			Once()
+
+		handler := New(nil, nil, nil, nil, mockAuditService, nil, nil, mockWebhookService)
+		router := setupRouter(handler)
+
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/"+webhookID+"/deliveries/7/redeliver", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"id":9`)
+		mockWebhookService.AssertExpectations(t)
+		mockAuditService.AssertExpectations(t)
+	})
+
+	t.Run("invalid delivery id", func(t *testing.T) {
+		mockWebhookService := new(serviceMocks.WebhookService)
+
+		handler := New(nil, nil, nil, nil, nil, nil, nil, mockWebhookService)
+		router := setupRouter(handler)
+
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/"+uuid.NewString()+"/deliveries/abc/redeliver", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockWebhookService.AssertNotCalled(t, "Redeliver", mock.Anything, mock.Anything)
+	})
+}
+
+func TestIfNoneMatchContains(t *testing.T) {
+	assert.False(t, ifNoneMatchContains("", `"1"`))
+	assert.False(t, ifNoneMatchContains(`"1"`, ""))
+	assert.False(t, ifNoneMatchContains(`"2"`, `"1"`))
+	assert.True(t, ifNoneMatchContains(`W/"1"`, `"1"`))
+	assert.True(t, ifNoneMatchContains(`"2", "1"`, `"1"`))
+}
+
+func TestNormalizeJSONObject(t *testing.T) {
+	data, ok := normalizeJSONObject(nil)
+	assert.True(t, ok)
+	assert.Nil(t, data)
+
+	data, ok = normalizeJSONObject(json.RawMessage(` null `))
+	assert.True(t, ok)
+	assert.Nil(t, data)
+
+	data, ok = normalizeJSONObject(json.RawMessage(` {"a":1} `))
+	assert.True(t, ok)
+	assert.Equal(t, `{"a":1}`, string(data))
+
+	_, ok = normalizeJSONObject(json.RawMessage(`"text"`))
+	assert.False(t, ok)
+}
+
+func TestValidNamespace(t *testing.T) {
+	assert.True(t, validNamespace("prod"))
+	assert.True(t, validNamespace("team-a/service-x"))
+	assert.True(t, validNamespace("a_b/c1"))
+	assert.False(t, validNamespace(""))
+	assert.False(t, validNamespace("Prod"))
+	assert.False(t, validNamespace("/prod"))
+	assert.False(t, validNamespace("prod/"))
+	assert.False(t, validNamespace("team a"))
+	assert.False(t, validNamespace(strings.Repeat("a", maxNamespaceLength+1)))
+}
+
+func TestNormalizeETag(t *testing.T) {
+	assert.Equal(t, "1", normalizeETag(`"1"`))
+	assert.Equal(t, "1", normalizeETag(`W/"1"`))
+	assert.Equal(t, "1", normalizeETag(`w/"1"`))
+	assert.Equal(t, "1", normalizeETag(`  "1"  `))
+}
+
+func TestListScheduledConfigs(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+
+	activateAt := time.Now().Add(time.Hour)
+	mockConfigService.
+		On("ListScheduled", "prod").
+		Return([]model.Config{{
+			Version:    8,
+			Namespace:  "prod",
+			Secrets:    map[string]string{"token": "s3cret"},
+			ActivateAt: &activateAt,
+		}}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/scheduled?namespace=prod", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotContains(t, resp.Body.String(), "s3cret")
+
+	var body ListScheduledConfigsResponse
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	if assert.Len(t, body.Items, 1) {
+		assert.Equal(t, 8, body.Items[0].Version)
+		assert.Equal(t, map[string]string{"token": "[REDACTED]"}, body.Items[0].Secrets)
+	}
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestCancelConfig_Success(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersionOf(mockConfigService, 8, "prod", 7)
+	mockAudit := new(serviceMocks.AuditService)
+
+	activateAt := time.Now().Add(time.Hour)
+	canceledAt := time.Now()
+	mockConfigService.
+		On("Cancel", 8).
+		Return(&model.Config{Version: 8, Namespace: "prod", ActivateAt: &activateAt, CanceledAt: &canceledAt}, nil).
+		Once()
+	mockAudit.
+		On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+			return e.Action == model.AuditActionConfigCancel && e.Namespace == "prod" && e.VersionBefore == 7 && e.VersionAfter == 8
+		})).
+		Return(nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, mockAudit, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/8/cancel", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body model.Config
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.NotNil(t, body.CanceledAt)
+
+	mockConfigService.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestCancelConfig_NotScheduled(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersionOf(mockConfigService, 8, "prod", 0)
+	mockConfigService.
+		On("Cancel", 8).
+		Return(nil, service.ErrNotScheduled).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/8/cancel", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "NOT_SCHEDULED")
+}
+
+func TestCreateConfig_Draft(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "prod", 0)
+
+	mockConfigService.
+		On("Create", mock.AnythingOfType("*model.Config"), 0).
+		Run(func(args mock.Arguments) {
+			cfg := args.Get(0).(*model.Config)
+			cfg.Version = 6
+			cfg.Approval = &model.Approval{Status: model.ApprovalPending}
+		}).
+		Return(nil).
+		Once()
+	mockConfigService.
+		On("GetByVersion", 6).
+		Return(&model.Config{Version: 6, Namespace: "prod", Approval: &model.Approval{Status: model.ApprovalPending}}, nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, acceptAudits(), nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"namespace":"prod","url":"https://example.com","poll_interval_seconds":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var body model.Config
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, 6, body.Version)
+	if assert.NotNil(t, body.Approval) {
+		assert.Equal(t, model.ApprovalPending, body.Approval.Status)
+	}
+
+	mockConfigService.AssertExpectations(t)
+	// Only the audit looks up the served version; the response is the
+	// draft itself.
+	mockConfigService.AssertNumberOfCalls(t, "GetLatest", 1)
+}
+
+func TestCreateConfig_ApprovalPending(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersion(mockConfigService, "default", 0)
+	mockConfigService.
+		On("Create", mock.AnythingOfType("*model.Config"), 0).
+		Return(repository.ErrApprovalPending).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"url":"https://example.com","poll_interval_seconds":60}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "APPROVAL_PENDING")
+}
+
+func TestApproveConfig_UsesReviewerIdentity(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersionOf(mockConfigService, 6, "prod", 5)
+	mockAuditService := new(serviceMocks.AuditService)
+	mockAPIKeyService := new(serviceMocks.APIKeyService)
+
+	mockAPIKeyService.
+		On("Authenticate", "secret").
+		Return(&model.APIKey{Name: "bob", Scopes: []string{model.ScopeWriteConfig}}, nil).
+		Once()
+	mockConfigService.
+		On("Approve", 6, "bob").
+		Return(&model.Config{
+			Version:   6,
+			Namespace: "prod",
+			CreatedBy: "alice",
+			Approval:  &model.Approval{Status: model.ApprovalApproved, ReviewedBy: "bob"},
+		}, nil).
+		Once()
+	mockAuditService.
+		On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+			return e.Actor == "bob" &&
+				e.Action == model.AuditActionConfigApprove &&
+				e.Namespace == "prod" &&
+				e.VersionBefore == 5 &&
+				e.VersionAfter == 6 &&
+				strings.Contains(string(e.Details), `"created_by":"alice"`)
+		})).
+		Return(nil).
+		Once()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST(
+		"/configs/:version/approve",
+		middleware.RequireScope(mockAPIKeyService, model.ScopeWriteConfig),
+		New(nil, mockConfigService, nil, nil, mockAuditService, nil, nil, nil).ApproveConfig,
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/6/approve", nil)
+	req.Header.Set("X-API-Key", "secret")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body model.Config
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	if assert.NotNil(t, body.Approval) {
+		assert.Equal(t, "bob", body.Approval.ReviewedBy)
+	}
+
+	mockConfigService.AssertExpectations(t)
+	mockAuditService.AssertExpectations(t)
+}
+
+func TestApproveConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "own draft", err: service.ErrSelfApproval, wantStatus: http.StatusForbidden, wantCode: "SELF_APPROVAL"},
+		{name: "not a draft", err: service.ErrNotPendingApproval, wantStatus: http.StatusConflict, wantCode: "NOT_PENDING_APPROVAL"},
+		{name: "unknown version", err: sql.ErrNoRows, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockConfigService := new(serviceMocks.ConfigService)
+			expectServedVersionOf(mockConfigService, 6, "prod", 0)
+			mockConfigService.On("Approve", 6, "").Return(nil, tt.err).Once()
+
+			handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+			router := setupRouter(handler)
+
+			req := httptest.NewRequest(http.MethodPost, "/configs/6/approve", nil)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.wantStatus, resp.Code)
+			assert.Contains(t, resp.Body.String(), tt.wantCode)
+		})
+	}
+}
+
+func TestRejectConfig_Success(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	expectServedVersionOf(mockConfigService, 6, "prod", 0)
+	mockAudit := new(serviceMocks.AuditService)
+
+	mockConfigService.
+		On("Reject", 6, "").
+		Return(&model.Config{Version: 6, Namespace: "prod", Approval: &model.Approval{Status: model.ApprovalRejected}}, nil).
+		Once()
+	mockAudit.
+		On("Record", mock.MatchedBy(func(e *model.AuditEntry) bool {
+			return e.Action == model.AuditActionConfigReject && e.VersionAfter == 6
+		})).
+		Return(nil).
+		Once()
+
+	handler := New(nil, mockConfigService, nil, nil, mockAudit, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/configs/6/reject", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"status":"rejected"`)
+
+	mockConfigService.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+func configDiffFixture() *model.ConfigDiff {
+	return &model.ConfigDiff{
+		From: 41,
+		To:   42,
+		Changes: []model.ConfigChange{
+			{Op: model.DiffChanged, Path: "data.feature", From: "off", To: "on"},
+			{Op: model.DiffAdded, Path: "data.retries", To: json.Number("3")},
+			{Op: model.DiffChanged, Path: "secrets.token", From: "a", To: "b"},
+			{Op: model.DiffAdded, Path: "secrets", To: map[string]interface{}{"db_password": "hunter2"}},
+			{Op: model.DiffRemoved, Path: "data.old", From: true},
+		},
+	}
+}
+
+func TestGetConfigDiff_JSON(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockConfigService.On("Diff", 41, 42).Return(configDiffFixture(), nil).Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/diff?from=41&to=42", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotContains(t, resp.Body.String(), "hunter2")
+	assert.JSONEq(t, `{
+		"from": 41,
+		"to": 42,
+		"changes": [
+			{"op": "changed", "path": "data.feature", "from": "off", "to": "on"},
+			{"op": "added", "path": "data.retries", "to": 3},
+			{"op": "changed", "path": "secrets.token", "from": "[REDACTED]", "to": "[REDACTED]"},
+			{"op": "added", "path": "secrets", "to": {"db_password": "[REDACTED]"}},
+			{"op": "removed", "path": "data.old", "from": true}
+		]
+	}`, resp.Body.String())
+
+	mockConfigService.AssertExpectations(t)
+}
+
+func TestGetConfigDiff_Text(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockConfigService.On("Diff", 41, 42).Return(configDiffFixture(), nil).Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/diff?from=41&to=42&format=text", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Header().Get("Content-Type"), "text/plain")
+	assert.Equal(t, `--- version 41
++++ version 42
+~ data.feature: "off" -> "on"
++ data.retries: 3
+~ secrets.token: "[REDACTED]" -> "[REDACTED]"
++ secrets: {"db_password":"[REDACTED]"}
+- data.old: true
+`, resp.Body.String())
+}
+
+func TestGetConfigDiff_InvalidQuery(t *testing.T) {
+	for _, query := range []string{"", "?from=41", "?from=0&to=42", "?from=41&to=42&format=yaml"} {
+		t.Run(query, func(t *testing.T) {
+			mockConfigService := new(serviceMocks.ConfigService)
+
+			handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+			router := setupRouter(handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/configs/diff"+query, nil)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			mockConfigService.AssertNotCalled(t, "Diff", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetConfigDiff_NotFound(t *testing.T) {
+
+	mockConfigService := new(serviceMocks.ConfigService)
+	mockConfigService.On("Diff", 41, 99).Return(nil, sql.ErrNoRows).Once()
+
+	handler := New(nil, mockConfigService, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/diff?from=41&to=99", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
 }
@@ -46,6 +46,10 @@ func Unauthorized(c *gin.Context) {
 	sharedhttpresponse.Unauthorized(c)
 }
 
+func Forbidden(c *gin.Context, message string) {
+	sharedhttpresponse.Forbidden(c, message)
+}
+
 func NotFound(c *gin.Context, message string) {
 	sharedhttpresponse.NotFound(c, message)
 }
@@ -58,6 +62,10 @@ func ValidationError(c *gin.Context, err error, requestStruct interface{}) {
 	sharedhttpresponse.ValidationError(c, err, requestStruct)
 }
 
+func FieldValidationError(c *gin.Context, field, code, message string) {
+	sharedhttpresponse.FieldValidationError(c, field, code, message)
+}
+
 func FromError(c *gin.Context, err error) {
 	sharedhttpresponse.FromError(c, err)
 }
\ No newline at end of file
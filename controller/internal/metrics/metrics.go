@@ -0,0 +1,55 @@
+// Package metrics holds the controller's Prometheus metrics. They are
+// registered with the default registry served on /metrics.
+package metrics
+
+import (
+	"controller/internal/model"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ConfigVersion is the version this instance serves per namespace. Only
+// namespaces the instance has served since it started are reported.
+var ConfigVersion = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "controller_config_version",
+	Help: "Config version currently served per namespace.",
+}, []string{"namespace"})
+
+var registeredAgentsDesc = prometheus.NewDesc(
+	"controller_registered_agents",
+	"Registered agents by health status.",
+	[]string{"status"},
+	nil,
+)
+
+// AgentCounter returns the number of agents with the given health status.
+type AgentCounter func(status string) (int, error)
+
+// agentCollector counts agents on every scrape, so the numbers match the
+// database no matter which instance registered or heard from the agents.
+type agentCollector struct {
+	count AgentCounter
+}
+
+// RegisterAgentCollector reports controller_registered_agents using count.
+func RegisterAgentCollector(count AgentCounter) {
+	prometheus.MustRegister(&agentCollector{count: count})
+}
+
+func (c *agentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- registeredAgentsDesc
+}
+
+func (c *agentCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range []string{model.AgentStatusHealthy, model.AgentStatusStale, model.AgentStatusDead} {
+		n, err := c.count(status)
+		if err != nil {
+			log.Printf("event=metrics_agent_count_failed status=%s err=%v", status, err)
+			ch <- prometheus.NewInvalidMetric(registeredAgentsDesc, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(registeredAgentsDesc, prometheus.GaugeValue, float64(n), status)
+	}
+}
@@ -1,11 +1,71 @@
 package middleware
 
 import (
+	"controller/internal/httpresponse"
+	"controller/internal/service"
+	"errors"
+
 	sharedmiddleware "github.com/mrheza/distributed-config-management/shared/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func APIKeyAuth(key string) gin.HandlerFunc {
-	return sharedmiddleware.APIKeyAuth(key)
+// RequireScope authenticates the X-API-Key header against keys, rejects keys
+// that lack scope and records the key name as the caller's identity.
+func RequireScope(keys service.APIKeyService, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := keys.Authenticate(c.GetHeader("X-API-Key"))
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			httpresponse.Unauthorized(c)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			httpresponse.InternalServerError(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Set(sharedmiddleware.IdentityKey, key.Name)
+
+		if !key.HasScope(scope) {
+			httpresponse.Forbidden(c, "api key lacks scope "+scope)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAgentCredential rejects requests whose X-Agent-Credential header is
+// not the credential issued to the agent in X-Agent-ID. On routes with an :id
+// parameter, it must name the same agent.
+func RequireAgentCredential(agents service.AgentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		agentID := c.GetHeader("X-Agent-ID")
+		if id := c.Param("id"); id != "" && id != agentID {
+			httpresponse.Forbidden(c, "X-Agent-ID does not match the agent in the path")
+			c.Abort()
+			return
+		}
+
+		err := agents.Authenticate(agentID, c.GetHeader("X-Agent-Credential"))
+		if errors.Is(err, service.ErrInvalidAgentCredential) {
+			httpresponse.Unauthorized(c)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			httpresponse.InternalServerError(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func Identity(c *gin.Context) string {
+	return sharedmiddleware.Identity(c)
 }
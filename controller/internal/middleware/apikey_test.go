@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	serviceMocks "controller/internal/mocks/service"
+	"controller/internal/model"
+	"controller/internal/service"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newScopedRouter(keys service.APIKeyService, scope string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", RequireScope(keys, scope), func(c *gin.Context) {
+		c.String(http.StatusOK, Identity(c))
+	})
+	return r
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        *model.APIKey
+		err        error
+		scope      string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "granted", key: &model.APIKey{Name: "ci", Scopes: []string{model.ScopeWriteConfig}}, scope: model.ScopeWriteConfig, wantStatus: http.StatusOK, wantBody: "ci"},
+		{name: "admin grants all", key: &model.APIKey{Name: "root", Scopes: []string{model.ScopeAdmin}}, scope: model.ScopeRegister, wantStatus: http.StatusOK, wantBody: "root"},
+		{name: "missing scope", key: &model.APIKey{Name: "agent", Scopes: []string{model.ScopeReadConfig}}, scope: model.ScopeWriteConfig, wantStatus: http.StatusForbidden},
+		{name: "agent key reads no history", key: &model.APIKey{Name: "agent", Scopes: []string{model.ScopeRegister, model.ScopeReadConfig}}, scope: model.ScopeReadHistory, wantStatus: http.StatusForbidden},
+		{name: "invalid key", err: service.ErrInvalidAPIKey, scope: model.ScopeReadConfig, wantStatus: http.StatusUnauthorized},
+		{name: "lookup error", err: errors.New("db down"), scope: model.ScopeReadConfig, wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := new(serviceMocks.APIKeyService)
+			keys.On("Authenticate", "token").Return(tt.key, tt.err).Once()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-API-Key", "token")
+			resp := httptest.NewRecorder()
+			newScopedRouter(keys, tt.scope).ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.wantStatus, resp.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, resp.Body.String())
+			}
+			keys.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequireAgentCredential(t *testing.T) {
+	agentID := "4f6ad0a2-2b4c-4bf5-9a4e-7a4a3fb1c111"
+
+	tests := []struct {
+		name       string
+		path       string
+		err        error
+		wantStatus int
+	}{
+		{name: "valid", path: "/agents/" + agentID, wantStatus: http.StatusOK},
+		{name: "invalid credential", path: "/agents/" + agentID, err: service.ErrInvalidAgentCredential, wantStatus: http.StatusUnauthorized},
+		{name: "lookup error", path: "/agents/" + agentID, err: errors.New("db down"), wantStatus: http.StatusInternalServerError},
+		{name: "other agent", path: "/agents/9d1c1f0e-0000-4000-8000-000000000000", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agents := new(serviceMocks.AgentService)
+			agents.On("Authenticate", agentID, "cred").Return(tt.err).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.GET("/agents/:id", RequireAgentCredential(agents), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-Agent-ID", agentID)
+			req.Header.Set("X-Agent-Credential", "cred")
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.wantStatus, resp.Code)
+		})
+	}
+}
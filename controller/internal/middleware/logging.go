@@ -9,3 +9,7 @@ import (
 func RequestLogger() gin.HandlerFunc {
 	return sharedmiddleware.RequestLogger()
 }
+
+func RequestID(c *gin.Context) string {
+	return sharedmiddleware.RequestID(c)
+}
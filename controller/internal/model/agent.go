@@ -1,5 +1,30 @@
 package model
 
+import "time"
+
+const (
+	AgentStatusHealthy = "healthy"
+	AgentStatusStale   = "stale"
+	AgentStatusDead    = "dead"
+)
+
 type Agent struct {
-    ID string `json:"agent_id"`
+	ID                string            `json:"agent_id"`
+	Namespace         string            `json:"namespace"`
+	Hostname          string            `json:"hostname"`
+	Version           string            `json:"version"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	LastSeenAt        *time.Time        `json:"last_seen_at"`
+	LastConfigVersion int               `json:"last_config_version"`
+	CreatedAt         time.Time         `json:"created_at"`
+	Status            string            `json:"status,omitempty"`
+}
+
+// AgentFilter narrows agent listings. Nil time bounds are ignored.
+type AgentFilter struct {
+	Namespace  string
+	SeenSince  *time.Time
+	SeenBefore *time.Time
+	Limit      int
+	Offset     int
 }
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+const (
+	ApplyStatusApplied = "applied"
+	ApplyStatusFailed  = "failed"
+)
+
+// AgentConfigStatus is an agent's latest apply result for one config version.
+type AgentConfigStatus struct {
+	AgentID    string    `json:"agent_id"`
+	Version    int       `json:"version"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	ReportedAt time.Time `json:"reported_at"`
+}
+
+// ConfigStatusSummary aggregates apply results for one config version across
+// the agents registered in its namespace.
+type ConfigStatusSummary struct {
+	Version   int                 `json:"version"`
+	Namespace string              `json:"namespace"`
+	Agents    int                 `json:"agents"`
+	Applied   int                 `json:"applied"`
+	Failed    int                 `json:"failed"`
+	Pending   int                 `json:"pending"`
+	Failures  []AgentConfigStatus `json:"failures"`
+}
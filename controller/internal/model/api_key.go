@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+const (
+	ScopeReadConfig  = "read-config"
+	ScopeReadHistory = "read-history"
+	ScopeWriteConfig = "write-config"
+	ScopeRegister    = "register"
+	ScopeAdmin       = "admin"
+)
+
+// Names of the bootstrap keys from ADMIN_API_KEY and AGENT_API_KEY. Stored keys
+// cannot use them, so an actor name always identifies a single key.
+const (
+	BootstrapAdminKeyName = "admin"
+	BootstrapAgentKeyName = "agent"
+)
+
+// APIKey is a named credential. Only a hash of its secret is stored; the
+// plain key is shown once when the key is created.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Hash      string     `json:"-"`
+}
+
+// HasScope reports whether the key grants scope. The admin scope grants all.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable reports whether the key is neither revoked nor expired at now.
+func (k *APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionConfigCreate     = "config.create"
+	AuditActionConfigRollback   = "config.rollback"
+	AuditActionConfigCancel     = "config.cancel"
+	AuditActionConfigApprove    = "config.approve"
+	AuditActionConfigReject     = "config.reject"
+	AuditActionRolloutAdvance   = "rollout.advance"
+	AuditActionRolloutPause     = "rollout.pause"
+	AuditActionRolloutAbort     = "rollout.abort"
+	AuditActionAPIKeyCreate     = "api_key.create"
+	AuditActionAPIKeyRevoke     = "api_key.revoke"
+	AuditActionEnrollmentCreate = "enrollment_token.create"
+	AuditActionEnrollmentRevoke = "enrollment_token.revoke"
+	AuditActionWebhookCreate    = "webhook.create"
+	AuditActionWebhookDelete    = "webhook.delete"
+	AuditActionWebhookRedeliver = "webhook.redeliver"
+)
+
+// AuditEntry records one admin action. VersionAfter is the version acted on
+// and VersionBefore the version its namespace served before the action; for
+// rollout actions, the rollout's base version.
+type AuditEntry struct {
+	ID            int64           `json:"id"`
+	Actor         string          `json:"actor"`
+	Action        string          `json:"action"`
+	Namespace     string          `json:"namespace"`
+	VersionBefore int             `json:"version_before"`
+	VersionAfter  int             `json:"version_after"`
+	RequestID     string          `json:"request_id"`
+	ClientIP      string          `json:"client_ip"`
+	Details       json.RawMessage `json:"details,omitempty" swaggertype:"object"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows audit listings. Nil time bounds and an empty actor are
+// ignored.
+type AuditFilter struct {
+	Actor  string
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int
+	Offset int
+}
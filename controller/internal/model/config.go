@@ -1,7 +1,64 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// DefaultNamespace is used when a config or agent does not declare one.
+const DefaultNamespace = "default"
+
+// Approval states of versions created in a namespace that requires review.
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
 type Config struct {
-	Version             int    `json:"version"`
-	URL                 string `json:"url"`
-	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+	Version             int             `json:"version"`
+	Namespace           string          `json:"namespace"`
+	URL                 string          `json:"url"`
+	PollIntervalSeconds int             `json:"poll_interval_seconds"`
+	Data                json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+	CreatedAt           time.Time       `json:"created_at"`
+	// ActivateAt delays serving the version until then. CanceledAt is set
+	// when a scheduled version is canceled before activation; it is never
+	// served afterwards.
+	ActivateAt *time.Time `json:"activate_at,omitempty"`
+	CanceledAt *time.Time `json:"canceled_at,omitempty"`
+	// CreatedBy is the identity of the API key that created the version.
+	CreatedBy string `json:"created_by,omitempty"`
+	// Approval is set on versions of namespaces that require review; only
+	// approved ones are served.
+	Approval *Approval `json:"approval,omitempty"`
+	// Secrets are stored encrypted and only delivered to agents; admin reads
+	// return them with redacted values.
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// Signature is the base64 Ed25519 signature of the version, set on reads
+	// when the controller has a signing key.
+	Signature string `json:"signature,omitempty"`
+
+	// Rollout, when set on create, stages the new version to the selected
+	// agents only. It is not loaded on reads.
+	Rollout *RolloutPolicy `json:"-"`
+	// EncryptedSecrets is the at-rest envelope of Secrets.
+	EncryptedSecrets json.RawMessage `json:"-"`
+}
+
+// Approval records the review of a draft version.
+type Approval struct {
+	Status     string     `json:"status" example:"pending"`
+	ReviewedBy string     `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// Scheduled reports whether the version is waiting for its activation time.
+func (c *Config) Scheduled(now time.Time) bool {
+	return c.CanceledAt == nil && c.ActivateAt != nil && c.ActivateAt.After(now)
+}
+
+// PendingApproval reports whether the version is a draft waiting for review.
+func (c *Config) PendingApproval() bool {
+	return c.CanceledAt == nil && c.Approval != nil && c.Approval.Status == ApprovalPending
 }
@@ -0,0 +1,24 @@
+package model
+
+// Operations of a ConfigChange.
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// ConfigDiff lists the field-level changes between two stored versions.
+type ConfigDiff struct {
+	From    int            `json:"from" example:"41"`
+	To      int            `json:"to" example:"42"`
+	Changes []ConfigChange `json:"changes"`
+}
+
+// ConfigChange is one added, removed or changed value. Path addresses it in
+// the config document, e.g. "data.feature_flags.beta" or "data.hosts[2]".
+type ConfigChange struct {
+	Op   string      `json:"op" example:"changed"`
+	Path string      `json:"path" example:"url"`
+	From interface{} `json:"from,omitempty" swaggertype:"object"`
+	To   interface{} `json:"to,omitempty" swaggertype:"object"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// EnrollmentToken lets up to MaxUses new agents register. Only a hash of its
+// secret is stored; the plain token is shown once when it is created.
+type EnrollmentToken struct {
+	ID        string     `json:"id"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Hash      string     `json:"-"`
+}
+
+// AgentAuth is what an agent presents to POST /register: the credential issued
+// to AgentID at enrollment, or an enrollment token to enroll as a new agent.
+type AgentAuth struct {
+	AgentID         string
+	Credential      string
+	EnrollmentToken string
+}
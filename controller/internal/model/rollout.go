@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+const (
+	RolloutStatusInProgress = "in_progress"
+	RolloutStatusPaused     = "paused"
+	RolloutStatusCompleted  = "completed"
+	RolloutStatusAborted    = "aborted"
+)
+
+// RolloutPolicy selects the agents that receive a staged version. An agent is
+// selected when its ID is listed, when it carries all of Labels, or when the
+// stable hash of its ID falls below Percentage.
+type RolloutPolicy struct {
+	Percentage int               `json:"percentage" example:"10"`
+	AgentIDs   []string          `json:"agent_ids,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// Rollout gates a config version. Agents that are not selected keep receiving
+// BaseVersion until the rollout completes.
+type Rollout struct {
+	Version     int    `json:"version"`
+	Namespace   string `json:"namespace"`
+	BaseVersion int    `json:"base_version"`
+	RolloutPolicy
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Active reports whether the rollout still splits the namespace.
+func (r *Rollout) Active() bool {
+	return r.Status == RolloutStatusInProgress || r.Status == RolloutStatusPaused
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Events sent to webhooks. A version is served once it is created or rolled
+// back, unless it is a draft, which is served once approved, or scheduled,
+// which is served once activated.
+const (
+	WebhookEventConfigCreated    = "config.created"
+	WebhookEventConfigRolledBack = "config.rolled_back"
+	WebhookEventConfigApproved   = "config.approved"
+	WebhookEventConfigActivated  = "config.activated"
+)
+
+// Delivery states. A pending delivery is retried until it succeeds or runs out
+// of attempts.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+// Webhook is an endpoint notified of config changes. Its secret signs every
+// delivery and is shown once when the webhook is created.
+type Webhook struct {
+	ID   string `json:"id"`
+	Name string `json:"name" example:"deploy-tracker"`
+	URL  string `json:"url" example:"https://hooks.example.com/config"`
+	// Namespace limits the webhook to events of one namespace; empty means
+	// all namespaces.
+	Namespace string     `json:"namespace,omitempty" example:"prod"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Secret    string     `json:"-"`
+}
+
+// WebhookEvent is the JSON body delivered to webhooks.
+type WebhookEvent struct {
+	Event     string `json:"event" example:"config.created"`
+	Namespace string `json:"namespace" example:"prod"`
+	Version   int    `json:"version" example:"42"`
+	// RestoredVersion is the version a rollback copied.
+	RestoredVersion int        `json:"restored_version,omitempty"`
+	CreatedBy       string     `json:"created_by,omitempty"`
+	ActivateAt      *time.Time `json:"activate_at,omitempty"`
+	Approval        string     `json:"approval,omitempty"`
+	ReviewedBy      string     `json:"reviewed_by,omitempty"`
+	OccurredAt      time.Time  `json:"occurred_at"`
+}
+
+// WebhookDelivery is one event queued for one webhook, together with the
+// outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" example:"pending"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	// RedeliveryOf is the delivery this one repeats.
+	RedeliveryOf int64      `json:"redelivery_of,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+
+	// URL and Secret of the webhook, loaded when the delivery is claimed.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
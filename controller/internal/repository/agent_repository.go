@@ -1,5 +1,18 @@
 package repository
 
+import "controller/internal/model"
+
 type AgentRepository interface {
-	Save(id string) error
+	Save(agent *model.Agent) error
+	GetByID(id string) (*model.Agent, error)
+	RecordPoll(id string, configVersion int) error
+	List(filter model.AgentFilter) ([]model.Agent, error)
+	Count(filter model.AgentFilter) (int, error)
+	// Enroll stores a new agent with the hash of its credential and uses the
+	// enrollment token tokenID for it, atomically. It returns sql.ErrNoRows
+	// when the token is unknown, revoked, expired or used up.
+	Enroll(agent *model.Agent, credentialHash, tokenID string) error
+	// GetCredentialHash returns an empty hash for agents enrolled before
+	// credentials were issued.
+	GetCredentialHash(id string) (string, error)
 }
@@ -0,0 +1,9 @@
+package repository
+
+import "controller/internal/model"
+
+type AgentStatusRepository interface {
+	Save(status *model.AgentConfigStatus) error
+	CountByStatus(version int) (map[string]int, error)
+	ListByStatus(version int, status string, limit int) ([]model.AgentConfigStatus, error)
+}
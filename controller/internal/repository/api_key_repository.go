@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"controller/internal/model"
+	"errors"
+)
+
+// ErrAPIKeyNameTaken is returned by APIKeyRepository.Create when another key
+// already uses the name.
+var ErrAPIKeyNameTaken = errors.New("api key name taken")
+
+type APIKeyRepository interface {
+	Create(key *model.APIKey) error
+	GetByID(id string) (*model.APIKey, error)
+	List() ([]model.APIKey, error)
+	// Revoke marks the key revoked. Revoking it again keeps the first time.
+	Revoke(id string) error
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "controller/internal/model"
+
+// AuditRepository is append-only.
+type AuditRepository interface {
+	Save(entry *model.AuditEntry) error
+	List(filter model.AuditFilter) ([]model.AuditEntry, error)
+	Count(filter model.AuditFilter) (int, error)
+}
@@ -0,0 +1,11 @@
+package repository
+
+import "context"
+
+// ConfigNotifier reports namespaces that received a new config version,
+// including versions created by other controller instances. An empty
+// namespace means notifications may have been missed and every namespace
+// should be re-read.
+type ConfigNotifier interface {
+	Listen(ctx context.Context) (<-chan string, error)
+}
@@ -2,9 +2,47 @@ package repository
 
 import (
 	"controller/internal/model"
+	"errors"
 )
 
+// ErrVersionConflict is returned by ConfigRepository.Create when the latest
+// version of the namespace no longer matches the expected one.
+var ErrVersionConflict = errors.New("config version conflict")
+
+// ErrRolloutInProgress is returned by ConfigRepository.Create while the latest
+// version of the namespace is still being rolled out.
+var ErrRolloutInProgress = errors.New("rollout in progress")
+
+// ErrActivationPending is returned by ConfigRepository.Create while the
+// namespace has a scheduled version that is not active yet.
+var ErrActivationPending = errors.New("scheduled version pending")
+
+// ErrApprovalPending is returned by ConfigRepository.Create while the namespace
+// has a draft version waiting for approval.
+var ErrApprovalPending = errors.New("draft version pending approval")
+
 type ConfigRepository interface {
-	GetLatest() (*model.Config, error)
-	Create(url string, pollIntervalSeconds int) error
+	// GetLatest returns the newest version of namespace that is active:
+	// neither canceled, nor waiting for its activation time, nor an unapproved
+	// draft.
+	GetLatest(namespace string) (*model.Config, error)
+	GetByVersion(version int) (*model.Config, error)
+	List(namespace string, limit, offset int) ([]model.Config, error)
+	Count(namespace string) (int, error)
+	// Create inserts a new version and sets cfg.Version. A positive
+	// expectedVersion makes the insert conditional on it still being the latest
+	// version of the namespace. cfg.Rollout stages the version and events are
+	// queued for webhooks, with their Version set, in the same transaction.
+	Create(cfg *model.Config, expectedVersion int, events []model.WebhookEvent) error
+	// ListScheduled returns the versions waiting for activation, soonest
+	// first. An empty namespace lists all namespaces.
+	ListScheduled(namespace string) ([]model.Config, error)
+	// Cancel marks a scheduled version canceled and fails the pending
+	// deliveries of its activation event. It returns sql.ErrNoRows unless the
+	// version is still waiting for activation.
+	Cancel(version int) error
+	// Review sets the approval status of a draft version and queues events
+	// for webhooks in the same transaction. It returns sql.ErrNoRows unless
+	// the version is a pending draft created by someone other than reviewer.
+	Review(version int, status, reviewer string, events []model.WebhookEvent) error
 }
@@ -0,0 +1,10 @@
+package repository
+
+import "controller/internal/model"
+
+type EnrollmentTokenRepository interface {
+	Create(token *model.EnrollmentToken) error
+	GetByID(id string) (*model.EnrollmentToken, error)
+	List() ([]model.EnrollmentToken, error)
+	Revoke(id string) error
+}
@@ -1,6 +1,11 @@
 package postgres
 
-import "database/sql"
+import (
+	"controller/internal/model"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
 
 type AgentRepository struct{ db *sql.DB }
 
@@ -8,11 +13,195 @@ func NewAgentRepository(db *sql.DB) *AgentRepository {
 	return &AgentRepository{db}
 }
 
-func (r *AgentRepository) Save(id string) error {
-	_, err := r.db.Exec(`
-		INSERT INTO agents (id)
-		VALUES ($1) ON CONFLICT (id) DO NOTHING
-	`, id)
+// Save upserts the agent's registration details and marks it as seen.
+func (r *AgentRepository) Save(agent *model.Agent) error {
+	labels, err := marshalLabels(agent.Labels)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO agents (id, namespace, hostname, agent_version, labels, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			namespace = EXCLUDED.namespace,
+			hostname = EXCLUDED.hostname,
+			agent_version = EXCLUDED.agent_version,
+			labels = EXCLUDED.labels,
+			last_seen_at = EXCLUDED.last_seen_at
+	`, agent.ID, agent.Namespace, agent.Hostname, agent.Version, labels)
 
 	return err
 }
+
+func (r *AgentRepository) GetByID(id string) (*model.Agent, error) {
+
+	row := r.db.QueryRow(`
+		SELECT id, namespace, hostname, agent_version, labels, last_seen_at, last_config_version, created_at
+		FROM agents
+		WHERE id = $1
+	`, id)
+
+	return scanAgent(row)
+}
+
+// RecordPoll stores a heartbeat together with the config version served.
+func (r *AgentRepository) RecordPoll(id string, configVersion int) error {
+	res, err := r.db.Exec(`
+		UPDATE agents
+		SET last_seen_at = NOW(), last_config_version = $2
+		WHERE id = $1
+	`, id, configVersion)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// List returns agents most recently seen first; never-seen agents come last.
+func (r *AgentRepository) List(filter model.AgentFilter) ([]model.Agent, error) {
+
+	rows, err := r.db.Query(`
+		SELECT id, namespace, hostname, agent_version, labels, last_seen_at, last_config_version, created_at
+		FROM agents
+		WHERE ($1 = '' OR namespace = $1)
+			AND ($2::timestamptz IS NULL OR last_seen_at >= $2)
+			AND ($3::timestamptz IS NULL OR last_seen_at IS NULL OR last_seen_at < $3)
+		ORDER BY last_seen_at DESC NULLS LAST, id
+		LIMIT $4 OFFSET $5
+	`, filter.Namespace, filter.SeenSince, filter.SeenBefore, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agents := make([]model.Agent, 0, filter.Limit)
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return agents, nil
+}
+
+func (r *AgentRepository) Count(filter model.AgentFilter) (int, error) {
+
+	var total int
+	if err := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM agents
+		WHERE ($1 = '' OR namespace = $1)
+			AND ($2::timestamptz IS NULL OR last_seen_at >= $2)
+			AND ($3::timestamptz IS NULL OR last_seen_at IS NULL OR last_seen_at < $3)
+	`, filter.Namespace, filter.SeenSince, filter.SeenBefore).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// Enroll uses the enrollment token and inserts the agent with the hash of its
+// credential in one transaction, so a failed registration leaves the token
+// unused.
+func (r *AgentRepository) Enroll(agent *model.Agent, credentialHash, tokenID string) error {
+	labels, err := marshalLabels(agent.Labels)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := consumeEnrollmentToken(tx, tokenID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO agents (id, namespace, hostname, agent_version, labels, credential_hash, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, agent.ID, agent.Namespace, agent.Hostname, agent.Version, labels, credentialHash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AgentRepository) GetCredentialHash(id string) (string, error) {
+	var hash sql.NullString
+	err := r.db.QueryRow(`
+		SELECT credential_hash
+		FROM agents
+		WHERE id = $1
+	`, id).Scan(&hash)
+	if err != nil {
+		return "", err
+	}
+
+	return hash.String, nil
+}
+
+func scanAgent(row rowScanner) (*model.Agent, error) {
+	var a model.Agent
+	var labels []byte
+	var lastSeen sql.NullTime
+
+	err := row.Scan(
+		&a.ID,
+		&a.Namespace,
+		&a.Hostname,
+		&a.Version,
+		&labels,
+		&lastSeen,
+		&a.LastConfigVersion,
+		&a.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	if len(labels) > 0 {
+		if err := json.Unmarshal(labels, &a.Labels); err != nil {
+			return nil, err
+		}
+	}
+	if lastSeen.Valid {
+		t := lastSeen.Time
+		a.LastSeenAt = &t
+	}
+
+	return &a, nil
+}
+
+func marshalLabels(labels map[string]string) (interface{}, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(labels)
+	if err != nil {
+		return nil, err
+	}
+	return nullableJSON(raw), nil
+}
@@ -1,27 +1,62 @@
 package postgres
 
 import (
+	"controller/internal/model"
+	"database/sql"
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+const saveAgentQuery = `
+		INSERT INTO agents (id, namespace, hostname, agent_version, labels, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			namespace = EXCLUDED.namespace,
+			hostname = EXCLUDED.hostname,
+			agent_version = EXCLUDED.agent_version,
+			labels = EXCLUDED.labels,
+			last_seen_at = EXCLUDED.last_seen_at
+	`
+
+const getAgentQuery = `
+		SELECT id, namespace, hostname, agent_version, labels, last_seen_at, last_config_version, created_at
+		FROM agents
+		WHERE id = $1
+	`
+
+const listAgentsQuery = `
+		SELECT id, namespace, hostname, agent_version, labels, last_seen_at, last_config_version, created_at
+		FROM agents
+		WHERE ($1 = '' OR namespace = $1)
+			AND ($2::timestamptz IS NULL OR last_seen_at >= $2)
+			AND ($3::timestamptz IS NULL OR last_seen_at IS NULL OR last_seen_at < $3)
+		ORDER BY last_seen_at DESC NULLS LAST, id
+		LIMIT $4 OFFSET $5
+	`
+
+var agentColumns = []string{"id", "namespace", "hostname", "agent_version", "labels", "last_seen_at", "last_config_version", "created_at"}
+
 func TestAgentRepository_Save_Success(t *testing.T) {
 	database, mock := newMockDB(t)
 	repo := NewAgentRepository(database)
 
-	mock.ExpectExec(regexp.QuoteMeta(`
-		INSERT INTO agents (id)
-		VALUES ($1) ON CONFLICT (id) DO NOTHING
-	`)).
-		WithArgs("agent-1").
+	mock.ExpectExec(regexp.QuoteMeta(saveAgentQuery)).
+		WithArgs("agent-1", "prod", "host-a", "1.2.0", `{"region":"eu"}`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := repo.Save("agent-1")
+	err := repo.Save(&model.Agent{
+		ID:        "agent-1",
+		Namespace: "prod",
+		Hostname:  "host-a",
+		Version:   "1.2.0",
+		Labels:    map[string]string{"region": "eu"},
+	})
 	require.NoError(t, err)
 }
 
@@ -30,14 +65,207 @@ func TestAgentRepository_Save_Error(t *testing.T) {
 	repo := NewAgentRepository(database)
 
 	expectedErr := errors.New("insert failed")
+	mock.ExpectExec(regexp.QuoteMeta(saveAgentQuery)).
+		WithArgs("agent-1", "default", "", "", nil).
+		WillReturnError(expectedErr)
+
+	err := repo.Save(&model.Agent{ID: "agent-1", Namespace: "default"})
+	assert.Error(t, err)
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestAgentRepository_GetByID_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentRepository(database)
+
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	created := seen.Add(-time.Hour)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getAgentQuery)).
+		WithArgs("agent-1").
+		WillReturnRows(sqlmock.NewRows(agentColumns).
+			AddRow("agent-1", "prod", "host-a", "1.2.0", []byte(`{"region":"eu"}`), seen, 7, created))
+
+	agent, err := repo.GetByID("agent-1")
+	require.NoError(t, err)
+	assert.Equal(t, &model.Agent{
+		ID:                "agent-1",
+		Namespace:         "prod",
+		Hostname:          "host-a",
+		Version:           "1.2.0",
+		Labels:            map[string]string{"region": "eu"},
+		LastSeenAt:        &seen,
+		LastConfigVersion: 7,
+		CreatedAt:         created,
+	}, agent)
+}
+
+func TestAgentRepository_GetByID_NeverSeen(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getAgentQuery)).
+		WithArgs("agent-1").
+		WillReturnRows(sqlmock.NewRows(agentColumns).
+			AddRow("agent-1", "default", "", "", nil, nil, 0, time.Now()))
+
+	agent, err := repo.GetByID("agent-1")
+	require.NoError(t, err)
+	assert.Nil(t, agent.LastSeenAt)
+	assert.Nil(t, agent.Labels)
+}
+
+func TestAgentRepository_GetByID_NotFound(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getAgentQuery)).
+		WithArgs("agent-1").
+		WillReturnError(sql.ErrNoRows)
+
+	agent, err := repo.GetByID("agent-1")
+	assert.Nil(t, agent)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestAgentRepository_RecordPoll_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentRepository(database)
+
 	mock.ExpectExec(regexp.QuoteMeta(`
-		INSERT INTO agents (id)
-		VALUES ($1) ON CONFLICT (id) DO NOTHING
+		UPDATE agents
+		SET last_seen_at = NOW(), last_config_version = $2
+		WHERE id = $1
 	`)).
-		WithArgs("agent-1").
+		WithArgs("agent-1", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.RecordPoll("agent-1", 4))
+}
+
+func TestAgentRepository_RecordPoll_UnknownAgent(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentRepository(database)
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE agents
+		SET last_seen_at = NOW(), last_config_version = $2
+		WHERE id = $1
+	`)).
+		WithArgs("agent-1", 4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.RecordPoll("agent-1", 4)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestAgentRepository_List_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentRepository(database)
+
+	since := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	seen := since.Add(time.Minute)
+
+	mock.ExpectQuery(regexp.QuoteMeta(listAgentsQuery)).
+		WithArgs("prod", since, nil, 10, 0).
+		WillReturnRows(sqlmock.NewRows(agentColumns).
+			AddRow("agent-1", "prod", "host-a", "1.2.0", nil, seen, 3, since).
+			AddRow("agent-2", "prod", "host-b", "1.2.0", nil, seen, 3, since))
+
+	agents, err := repo.List(model.AgentFilter{Namespace: "prod", SeenSince: &since, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, agents, 2)
+	assert.Equal(t, "agent-1", agents[0].ID)
+	assert.Equal(t, "host-b", agents[1].Hostname)
+}
+
+func TestAgentRepository_List_QueryError(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentRepository(database)
+
+	expectedErr := errors.New("query failed")
+	mock.ExpectQuery(regexp.QuoteMeta(listAgentsQuery)).
+		WithArgs("", nil, nil, 20, 0).
 		WillReturnError(expectedErr)
 
-	err := repo.Save("agent-1")
-	assert.Error(t, err)
+	agents, err := repo.List(model.AgentFilter{Limit: 20})
+	assert.Nil(t, agents)
 	assert.Equal(t, expectedErr, err)
 }
+
+func TestAgentRepository_Count_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentRepository(database)
+
+	before := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT COUNT(*)
+		FROM agents
+		WHERE ($1 = '' OR namespace = $1)
+			AND ($2::timestamptz IS NULL OR last_seen_at >= $2)
+			AND ($3::timestamptz IS NULL OR last_seen_at IS NULL OR last_seen_at < $3)
+	`)).
+		WithArgs("", nil, before).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+
+	total, err := repo.Count(model.AgentFilter{SeenBefore: &before})
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+}
+
+func TestAgentRepository_Enroll(t *testing.T) {
+	consumeQuery := regexp.QuoteMeta(`
+		UPDATE enrollment_tokens
+		SET uses = uses + 1
+		WHERE id = $1
+			AND uses < max_uses
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+	`)
+	insertQuery := regexp.QuoteMeta(`
+		INSERT INTO agents (id, namespace, hostname, agent_version, labels, credential_hash, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`)
+	agent := &model.Agent{ID: "agent-1", Namespace: "prod", Hostname: "host-a", Version: "1.2.0"}
+
+	t.Run("success", func(t *testing.T) {
+		database, mock := newMockDB(t)
+		repo := NewAgentRepository(database)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(consumeQuery).WithArgs("t1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(insertQuery).
+			WithArgs("agent-1", "prod", "host-a", "1.2.0", nil, "hash").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.Enroll(agent, "hash", "t1"))
+	})
+
+	t.Run("token used up", func(t *testing.T) {
+		database, mock := newMockDB(t)
+		repo := NewAgentRepository(database)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(consumeQuery).WithArgs("t1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.Enroll(agent, "hash", "t1"), sql.ErrNoRows)
+	})
+
+	t.Run("insert error keeps the token", func(t *testing.T) {
+		database, mock := newMockDB(t)
+		repo := NewAgentRepository(database)
+
+		expectedErr := errors.New("insert failed")
+		mock.ExpectBegin()
+		mock.ExpectExec(consumeQuery).WithArgs("t1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(insertQuery).
+			WithArgs("agent-1", "prod", "host-a", "1.2.0", nil, "hash").
+			WillReturnError(expectedErr)
+		mock.ExpectRollback()
+
+		assert.Equal(t, expectedErr, repo.Enroll(agent, "hash", "t1"))
+	})
+}
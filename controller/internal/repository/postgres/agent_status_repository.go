@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"database/sql"
+)
+
+type AgentStatusRepository struct{ db *sql.DB }
+
+func NewAgentStatusRepository(db *sql.DB) *AgentStatusRepository {
+	return &AgentStatusRepository{db}
+}
+
+// Save upserts the agent's result for a version. It returns sql.ErrNoRows when
+// the agent is not registered.
+func (r *AgentStatusRepository) Save(status *model.AgentConfigStatus) error {
+	res, err := r.db.Exec(`
+		INSERT INTO agent_config_status (agent_id, version, status, error, reported_at)
+		SELECT id, $2, $3, $4, NOW()
+		FROM agents
+		WHERE id = $1
+		ON CONFLICT (agent_id, version) DO UPDATE SET
+			status = EXCLUDED.status,
+			error = EXCLUDED.error,
+			reported_at = EXCLUDED.reported_at
+	`, status.AgentID, status.Version, status.Status, status.Error)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *AgentStatusRepository) CountByStatus(version int) (map[string]int, error) {
+
+	rows, err := r.db.Query(`
+		SELECT status, COUNT(*)
+		FROM agent_config_status
+		WHERE version = $1
+		GROUP BY status
+	`, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// ListByStatus returns the most recent reports first.
+func (r *AgentStatusRepository) ListByStatus(version int, status string, limit int) ([]model.AgentConfigStatus, error) {
+
+	rows, err := r.db.Query(`
+		SELECT agent_id, version, status, error, reported_at
+		FROM agent_config_status
+		WHERE version = $1 AND status = $2
+		ORDER BY reported_at DESC
+		LIMIT $3
+	`, version, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make([]model.AgentConfigStatus, 0)
+	for rows.Next() {
+		var s model.AgentConfigStatus
+		if err := rows.Scan(&s.AgentID, &s.Version, &s.Status, &s.Error, &s.ReportedAt); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
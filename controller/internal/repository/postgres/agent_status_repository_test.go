@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const saveAgentStatusQuery = `
+		INSERT INTO agent_config_status (agent_id, version, status, error, reported_at)
+		SELECT id, $2, $3, $4, NOW()
+		FROM agents
+		WHERE id = $1
+		ON CONFLICT (agent_id, version) DO UPDATE SET
+			status = EXCLUDED.status,
+			error = EXCLUDED.error,
+			reported_at = EXCLUDED.reported_at
+	`
+
+func TestAgentStatusRepository_Save_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentStatusRepository(database)
+
+	mock.ExpectExec(regexp.QuoteMeta(saveAgentStatusQuery)).
+		WithArgs("agent-1", 42, "failed", "worker unreachable").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.Save(&model.AgentConfigStatus{
+		AgentID: "agent-1",
+		Version: 42,
+		Status:  model.ApplyStatusFailed,
+		Error:   "worker unreachable",
+	})
+	require.NoError(t, err)
+}
+
+func TestAgentStatusRepository_Save_UnknownAgent(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentStatusRepository(database)
+
+	mock.ExpectExec(regexp.QuoteMeta(saveAgentStatusQuery)).
+		WithArgs("agent-1", 42, "applied", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.Save(&model.AgentConfigStatus{AgentID: "agent-1", Version: 42, Status: model.ApplyStatusApplied})
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestAgentStatusRepository_CountByStatus_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentStatusRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT status, COUNT(*)
+		FROM agent_config_status
+		WHERE version = $1
+		GROUP BY status
+	`)).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).
+			AddRow("applied", 118).
+			AddRow("failed", 2))
+
+	counts, err := repo.CountByStatus(42)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"applied": 118, "failed": 2}, counts)
+}
+
+func TestAgentStatusRepository_ListByStatus_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentStatusRepository(database)
+
+	reported := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT agent_id, version, status, error, reported_at
+		FROM agent_config_status
+		WHERE version = $1 AND status = $2
+		ORDER BY reported_at DESC
+		LIMIT $3
+	`)).
+		WithArgs(42, "failed", 100).
+		WillReturnRows(sqlmock.NewRows([]string{"agent_id", "version", "status", "error", "reported_at"}).
+			AddRow("agent-9", 42, "failed", "worker unreachable", reported))
+
+	statuses, err := repo.ListByStatus(42, model.ApplyStatusFailed, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []model.AgentConfigStatus{{
+		AgentID:    "agent-9",
+		Version:    42,
+		Status:     "failed",
+		Error:      "worker unreachable",
+		ReportedAt: reported,
+	}}, statuses)
+}
+
+func TestAgentStatusRepository_ListByStatus_QueryError(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAgentStatusRepository(database)
+
+	expectedErr := errors.New("query failed")
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT agent_id, version, status, error, reported_at
+		FROM agent_config_status
+		WHERE version = $1 AND status = $2
+		ORDER BY reported_at DESC
+		LIMIT $3
+	`)).
+		WithArgs(42, "failed", 100).
+		WillReturnError(expectedErr)
+
+	statuses, err := repo.ListByStatus(42, model.ApplyStatusFailed, 100)
+	assert.Nil(t, statuses)
+	assert.Equal(t, expectedErr, err)
+}
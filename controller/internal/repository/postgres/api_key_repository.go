@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"controller/internal/repository"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint failure.
+const uniqueViolation = "23505"
+
+type APIKeyRepository struct{ db *sql.DB }
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db}
+}
+
+func (r *APIKeyRepository) Create(key *model.APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.QueryRow(`
+		INSERT INTO api_keys (id, name, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, key.ID, key.Name, key.Hash, string(scopes), key.ExpiresAt).Scan(&key.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return repository.ErrAPIKeyNameTaken
+	}
+
+	return err
+}
+
+func (r *APIKeyRepository) GetByID(id string) (*model.APIKey, error) {
+
+	row := r.db.QueryRow(`
+		SELECT id, name, key_hash, scopes, expires_at, revoked_at, created_at
+		FROM api_keys
+		WHERE id = $1
+	`, id)
+
+	return scanAPIKey(row)
+}
+
+// List returns all keys, newest first.
+func (r *APIKeyRepository) List() ([]model.APIKey, error) {
+
+	rows, err := r.db.Query(`
+		SELECT id, name, key_hash, scopes, expires_at, revoked_at, created_at
+		FROM api_keys
+		ORDER BY created_at DESC, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]model.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Revoke(id string) error {
+	res, err := r.db.Exec(`
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var k model.APIKey
+	var scopes []byte
+	var expiresAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&k.ID,
+		&k.Name,
+		&k.Hash,
+		&scopes,
+		&expiresAt,
+		&revokedAt,
+		&k.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(scopes, &k.Scopes); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		t := expiresAt.Time
+		k.ExpiresAt = &t
+	}
+	if revokedAt.Valid {
+		t := revokedAt.Time
+		k.RevokedAt = &t
+	}
+
+	return &k, nil
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"controller/internal/repository"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyRepository_Create(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAPIKeyRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO api_keys (id, name, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`)).
+		WithArgs("k1", "ci", "hash", `["write-config"]`, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+
+	key := &model.APIKey{ID: "k1", Name: "ci", Hash: "hash", Scopes: []string{model.ScopeWriteConfig}}
+	require.NoError(t, repo.Create(key))
+	assert.Equal(t, createdAt, key.CreatedAt)
+}
+
+func TestAPIKeyRepository_Create_NameTaken(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAPIKeyRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO api_keys (id, name, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`)).
+		WithArgs("k1", "ci", "hash", `["admin"]`, nil).
+		WillReturnError(&pq.Error{Code: uniqueViolation})
+
+	err := repo.Create(&model.APIKey{ID: "k1", Name: "ci", Hash: "hash", Scopes: []string{model.ScopeAdmin}})
+	assert.True(t, errors.Is(err, repository.ErrAPIKeyNameTaken))
+}
+
+func TestAPIKeyRepository_GetByID(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAPIKeyRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	revokedAt := createdAt.Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"id", "name", "key_hash", "scopes", "expires_at", "revoked_at", "created_at"}).
+		AddRow("k1", "ci", "hash", []byte(`["read-config","register"]`), nil, revokedAt, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, name, key_hash, scopes, expires_at, revoked_at, created_at
+		FROM api_keys
+		WHERE id = $1
+	`)).
+		WithArgs("k1").
+		WillReturnRows(rows)
+
+	key, err := repo.GetByID("k1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{model.ScopeReadConfig, model.ScopeRegister}, key.Scopes)
+	assert.Nil(t, key.ExpiresAt)
+	require.NotNil(t, key.RevokedAt)
+	assert.Equal(t, revokedAt, *key.RevokedAt)
+}
+
+func TestAPIKeyRepository_Revoke_NotFound(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAPIKeyRepository(database)
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+	`)).
+		WithArgs("k9").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.Revoke("k9")
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
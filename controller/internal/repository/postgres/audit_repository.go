@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"database/sql"
+	"encoding/json"
+)
+
+type AuditRepository struct{ db *sql.DB }
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db}
+}
+
+// Save appends the entry.
+func (r *AuditRepository) Save(entry *model.AuditEntry) error {
+
+	return r.db.QueryRow(`
+		INSERT INTO audit_log (actor, action, namespace, version_before, version_after, request_id, client_ip, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`,
+		entry.Actor,
+		entry.Action,
+		entry.Namespace,
+		entry.VersionBefore,
+		entry.VersionAfter,
+		entry.RequestID,
+		entry.ClientIP,
+		nullableJSON(entry.Details),
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// List returns entries newest first.
+func (r *AuditRepository) List(filter model.AuditFilter) ([]model.AuditEntry, error) {
+
+	rows, err := r.db.Query(`
+		SELECT id, actor, action, namespace, version_before, version_after, request_id, client_ip, details, created_at
+		FROM audit_log
+		WHERE ($1 = '' OR actor = $1)
+			AND ($2::timestamptz IS NULL OR created_at >= $2)
+			AND ($3::timestamptz IS NULL OR created_at < $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5
+	`, filter.Actor, filter.Since, filter.Until, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]model.AuditEntry, 0, filter.Limit)
+	for rows.Next() {
+		var e model.AuditEntry
+		var details []byte
+		if err := rows.Scan(
+			&e.ID,
+			&e.Actor,
+			&e.Action,
+			&e.Namespace,
+			&e.VersionBefore,
+			&e.VersionAfter,
+			&e.RequestID,
+			&e.ClientIP,
+			&details,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if len(details) > 0 {
+			e.Details = json.RawMessage(details)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *AuditRepository) Count(filter model.AuditFilter) (int, error) {
+
+	var total int
+	if err := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM audit_log
+		WHERE ($1 = '' OR actor = $1)
+			AND ($2::timestamptz IS NULL OR created_at >= $2)
+			AND ($3::timestamptz IS NULL OR created_at < $3)
+	`, filter.Actor, filter.Since, filter.Until).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRepository_Save(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAuditRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO audit_log (actor, action, namespace, version_before, version_after, request_id, client_ip, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`)).
+		WithArgs("admin", model.AuditActionConfigCreate, "prod", 4, 7, "req-1", "10.0.0.1", `{"rollout":{"percentage":10}}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, createdAt))
+
+	entry := &model.AuditEntry{
+		Actor:         "admin",
+		Action:        model.AuditActionConfigCreate,
+		Namespace:     "prod",
+		VersionBefore: 4,
+		VersionAfter:  7,
+		RequestID:     "req-1",
+		ClientIP:      "10.0.0.1",
+		Details:       json.RawMessage(`{"rollout":{"percentage":10}}`),
+	}
+	require.NoError(t, repo.Save(entry))
+	assert.Equal(t, int64(3), entry.ID)
+	assert.Equal(t, 4, entry.VersionBefore)
+	assert.Equal(t, createdAt, entry.CreatedAt)
+}
+
+func TestAuditRepository_List(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAuditRepository(database)
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "actor", "action", "namespace", "version_before", "version_after", "request_id", "client_ip", "details", "created_at"}).
+		AddRow(2, "admin", model.AuditActionRolloutAbort, "prod", 6, 7, "req-2", "10.0.0.1", []byte(`{"status":"aborted"}`), createdAt).
+		AddRow(1, "admin", model.AuditActionConfigCreate, "prod", 6, 7, "req-1", "10.0.0.1", nil, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, actor, action, namespace, version_before, version_after, request_id, client_ip, details, created_at
+		FROM audit_log
+		WHERE ($1 = '' OR actor = $1)
+			AND ($2::timestamptz IS NULL OR created_at >= $2)
+			AND ($3::timestamptz IS NULL OR created_at < $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5
+	`)).
+		WithArgs("admin", &since, nil, 20, 0).
+		WillReturnRows(rows)
+
+	entries, err := repo.List(model.AuditFilter{Actor: "admin", Since: &since, Limit: 20})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.JSONEq(t, `{"status":"aborted"}`, string(entries[0].Details))
+	assert.Nil(t, entries[1].Details)
+}
+
+func TestAuditRepository_Count(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewAuditRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT COUNT(*)
+		FROM audit_log
+		WHERE ($1 = '' OR actor = $1)
+			AND ($2::timestamptz IS NULL OR created_at >= $2)
+			AND ($3::timestamptz IS NULL OR created_at < $3)
+	`)).
+		WithArgs("", nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	total, err := repo.Count(model.AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ConfigChannel is the Postgres notification channel the configurations
+// insert trigger publishes namespaces on.
+const ConfigChannel = "configurations_changed"
+
+const (
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+type ConfigNotifier struct{ databaseURL string }
+
+func NewConfigNotifier(databaseURL string) *ConfigNotifier {
+	return &ConfigNotifier{databaseURL}
+}
+
+// Listen opens a dedicated LISTEN connection that reconnects on its own. The
+// returned channel is closed once ctx is done.
+func (n *ConfigNotifier) Listen(ctx context.Context) (<-chan string, error) {
+	listener := pq.NewListener(n.databaseURL, listenerMinReconnect, listenerMaxReconnect, logListenerEvent)
+	if err := listener.Listen(ConfigChannel); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	out := make(chan string, 16)
+	go func() {
+		defer close(out)
+		defer listener.Close()
+
+		ping := time.NewTicker(listenerPingInterval)
+		defer ping.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-listener.NotificationChannel():
+				// A nil notification follows a reconnect; anything sent while
+				// disconnected is lost.
+				namespace := ""
+				if notification != nil {
+					namespace = notification.Extra
+				}
+				select {
+				case out <- namespace:
+				case <-ctx.Done():
+					return
+				}
+			case <-ping.C:
+				if err := listener.Ping(); err != nil {
+					log.Printf("event=config_listener_ping_failed err=%v", err)
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func logListenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventConnected:
+		log.Printf("event=config_listener_connected channel=%s", ConfigChannel)
+	case pq.ListenerEventDisconnected:
+		log.Printf("event=config_listener_disconnected err=%v", err)
+	case pq.ListenerEventReconnected:
+		log.Printf("event=config_listener_reconnected channel=%s", ConfigChannel)
+	case pq.ListenerEventConnectionAttemptFailed:
+		log.Printf("event=config_listener_connect_failed err=%v", err)
+	}
+}
@@ -2,7 +2,9 @@ package postgres
 
 import (
 	"controller/internal/model"
+	"controller/internal/repository"
 	"database/sql"
+	"encoding/json"
 	"errors"
 )
 
@@ -10,25 +12,345 @@ type ConfigRepository struct {
 	db *sql.DB
 }
 
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 func NewConfigRepository(db *sql.DB) *ConfigRepository {
 	return &ConfigRepository{db}
 }
 
-func (r *ConfigRepository) GetLatest() (*model.Config, error) {
+func (r *ConfigRepository) GetLatest(namespace string) (*model.Config, error) {
 
 	row := r.db.QueryRow(`
-		SELECT version, url, poll_interval_seconds
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
 		FROM configurations
+		WHERE namespace = $1
+			AND canceled_at IS NULL
+			AND (activate_at IS NULL OR activate_at <= NOW())
+			AND (approval_status IS NULL OR approval_status = 'approved')
 		ORDER BY version DESC
 		LIMIT 1
-	`)
+	`, namespace)
+
+	return scanConfig(row)
+}
+
+func (r *ConfigRepository) GetByVersion(version int) (*model.Config, error) {
+
+	row := r.db.QueryRow(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE version = $1
+	`, version)
+
+	return scanConfig(row)
+}
+
+// List returns versions newest first. An empty namespace lists all namespaces.
+func (r *ConfigRepository) List(namespace string, limit, offset int) ([]model.Config, error) {
+
+	rows, err := r.db.Query(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE ($1 = '' OR namespace = $1)
+		ORDER BY version DESC
+		LIMIT $2 OFFSET $3
+	`, namespace, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	configs := make([]model.Config, 0, limit)
+	for rows.Next() {
+		c, err := scanConfig(rows)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, *c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+func (r *ConfigRepository) Count(namespace string) (int, error) {
+
+	var total int
+	if err := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM configurations
+		WHERE ($1 = '' OR namespace = $1)
+	`, namespace).Scan(&total); err != nil {
+		return 0, err
+	}
 
+	return total, nil
+}
+
+// Create inserts a new version inside a transaction holding a per-namespace
+// advisory lock, so the expectedVersion check, the rollout check and the insert
+// cannot interleave with another writer. The webhook events commit or roll
+// back with the version.
+func (r *ConfigRepository) Create(cfg *model.Config, expectedVersion int, events []model.WebhookEvent) error {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		SELECT pg_advisory_xact_lock(hashtext($1))
+	`, cfg.Namespace); err != nil {
+		return err
+	}
+
+	var (
+		latest        int
+		pending       sql.NullBool
+		draft         sql.NullBool
+		rolloutStatus sql.NullString
+		rolloutBase   sql.NullInt64
+	)
+	err = tx.QueryRow(`
+		SELECT c.version, c.activate_at > NOW(), c.approval_status = 'pending', r.status, r.base_version
+		FROM configurations c
+		LEFT JOIN rollouts r ON r.version = c.version
+		WHERE c.namespace = $1
+			AND c.canceled_at IS NULL
+			AND c.approval_status IS DISTINCT FROM 'rejected'
+		ORDER BY c.version DESC
+		LIMIT 1
+	`, cfg.Namespace).Scan(&latest, &pending, &draft, &rolloutStatus, &rolloutBase)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// Versions are served in version order, so nothing may be created after
+	// a version that is not active yet.
+	if draft.Bool {
+		return repository.ErrApprovalPending
+	}
+	if pending.Bool {
+		return repository.ErrActivationPending
+	}
+
+	if expectedVersion > 0 && latest != expectedVersion {
+		return repository.ErrVersionConflict
+	}
+
+	switch rolloutStatus.String {
+	case model.RolloutStatusInProgress, model.RolloutStatusPaused:
+		return repository.ErrRolloutInProgress
+	case model.RolloutStatusAborted:
+		// Agents outside the aborted rollout never left its base version.
+		latest = int(rolloutBase.Int64)
+	}
+
+	if err := tx.QueryRow(`
+		INSERT INTO configurations (
+			namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, created_by, approval_status
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING version
+	`,
+		cfg.Namespace,
+		cfg.URL,
+		cfg.PollIntervalSeconds,
+		nullableJSON(cfg.Data),
+		nullableJSON(cfg.EncryptedSecrets),
+		cfg.ActivateAt,
+		cfg.CreatedBy,
+		approvalStatus(cfg.Approval),
+	).Scan(&cfg.Version); err != nil {
+		return err
+	}
+
+	if cfg.Rollout != nil {
+		agentIDs, err := marshalAgentIDs(cfg.Rollout.AgentIDs)
+		if err != nil {
+			return err
+		}
+		labels, err := marshalLabels(cfg.Rollout.Labels)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO rollouts (version, namespace, base_version, percentage, agent_ids, labels, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`,
+			cfg.Version,
+			cfg.Namespace,
+			latest,
+			cfg.Rollout.Percentage,
+			agentIDs,
+			labels,
+			model.RolloutStatusInProgress,
+		); err != nil {
+			return err
+		}
+	}
+
+	for i := range events {
+		events[i].Version = cfg.Version
+	}
+	if err := enqueueWebhookEvents(tx, events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListScheduled returns versions waiting for activation, soonest first. An
+// empty namespace lists all namespaces.
+func (r *ConfigRepository) ListScheduled(namespace string) ([]model.Config, error) {
+
+	rows, err := r.db.Query(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE ($1 = '' OR namespace = $1)
+			AND canceled_at IS NULL
+			AND approval_status IS DISTINCT FROM 'rejected'
+			AND activate_at > NOW()
+		ORDER BY activate_at, version
+	`, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	configs := make([]model.Config, 0)
+	for rows.Next() {
+		c, err := scanConfig(rows)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, *c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+// Cancel marks a version canceled if it is still waiting for activation, so
+// its activation event is never delivered.
+func (r *ConfigRepository) Cancel(version int) error {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE configurations
+		SET canceled_at = NOW()
+		WHERE version = $1
+			AND canceled_at IS NULL
+			AND approval_status IS DISTINCT FROM 'rejected'
+			AND activate_at > NOW()
+	`, version)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'failed', next_attempt_at = NULL, last_error = 'version canceled'
+		WHERE status = 'pending'
+			AND event = $2
+			AND (payload->>'version')::int = $1
+	`, version, model.WebhookEventConfigActivated); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Review approves or rejects a pending draft. The reviewer check repeats the
+// service's so two racing requests cannot both decide the draft.
+func (r *ConfigRepository) Review(version int, status, reviewer string, events []model.WebhookEvent) error {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE configurations
+		SET approval_status = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE version = $1
+			AND canceled_at IS NULL
+			AND approval_status = 'pending'
+			AND created_by <> $3
+	`, version, status, reviewer)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := enqueueWebhookEvents(tx, events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanConfig(row rowScanner) (*model.Config, error) {
 	var c model.Config
+	var data, secrets []byte
+	var activateAt, canceledAt, reviewedAt sql.NullTime
+	var approvalStatus sql.NullString
+	var reviewedBy string
 
 	err := row.Scan(
 		&c.Version,
+		&c.Namespace,
 		&c.URL,
 		&c.PollIntervalSeconds,
+		&data,
+		&secrets,
+		&activateAt,
+		&canceledAt,
+		&c.CreatedBy,
+		&approvalStatus,
+		&reviewedBy,
+		&reviewedAt,
+		&c.CreatedAt,
 	)
 
 	if err != nil {
@@ -38,18 +360,41 @@ func (r *ConfigRepository) GetLatest() (*model.Config, error) {
 		return nil, err
 	}
 
+	if len(data) > 0 {
+		c.Data = json.RawMessage(data)
+	}
+	if len(secrets) > 0 {
+		c.EncryptedSecrets = json.RawMessage(secrets)
+	}
+	if activateAt.Valid {
+		c.ActivateAt = &activateAt.Time
+	}
+	if canceledAt.Valid {
+		c.CanceledAt = &canceledAt.Time
+	}
+	if approvalStatus.Valid {
+		c.Approval = &model.Approval{Status: approvalStatus.String, ReviewedBy: reviewedBy}
+		if reviewedAt.Valid {
+			c.Approval.ReviewedAt = &reviewedAt.Time
+		}
+	}
+
 	return &c, nil
 }
 
-func (r *ConfigRepository) Create(url string, pollIntervalSeconds int) error {
-
-	_, err := r.db.Exec(`
-		INSERT INTO configurations (url, poll_interval_seconds)
-		VALUES ($1, $2)
-	`,
-		url,
-		pollIntervalSeconds,
-	)
+// nullableJSON converts a raw JSON document into a driver value for a JSONB
+// column. lib/pq sends []byte as bytea, so the document is passed as text.
+func nullableJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
 
-	return err
+// approvalStatus is NULL for versions that need no review.
+func approvalStatus(approval *model.Approval) interface{} {
+	if approval == nil {
+		return nil
+	}
+	return approval.Status
 }
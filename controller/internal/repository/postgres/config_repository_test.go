@@ -1,51 +1,406 @@
 package postgres
 
 import (
+	"controller/internal/model"
+	"controller/internal/repository"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var configColumns = []string{
+	"version", "namespace", "url", "poll_interval_seconds", "data", "encrypted_secrets", "activate_at", "canceled_at",
+	"created_by", "approval_status", "reviewed_by", "reviewed_at", "created_at",
+}
+
+func expectConfigLock(mock sqlmock.Sqlmock, namespace string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`
+		SELECT pg_advisory_xact_lock(hashtext($1))
+	`)).
+		WithArgs(namespace).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectLatestVersion(mock sqlmock.Sqlmock, namespace string, version int, rolloutStatus interface{}, baseVersion interface{}) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT c.version, c.activate_at > NOW(), c.approval_status = 'pending', r.status, r.base_version
+		FROM configurations c
+		LEFT JOIN rollouts r ON r.version = c.version
+		WHERE c.namespace = $1
+			AND c.canceled_at IS NULL
+			AND c.approval_status IS DISTINCT FROM 'rejected'
+		ORDER BY c.version DESC
+		LIMIT 1
+	`)).
+		WithArgs(namespace).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "pending", "draft", "status", "base_version"}).AddRow(version, nil, nil, rolloutStatus, baseVersion))
+}
+
+func expectPendingVersion(mock sqlmock.Sqlmock, namespace string, version int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT c.version, c.activate_at > NOW(), c.approval_status = 'pending', r.status, r.base_version
+		FROM configurations c
+		LEFT JOIN rollouts r ON r.version = c.version
+		WHERE c.namespace = $1
+			AND c.canceled_at IS NULL
+			AND c.approval_status IS DISTINCT FROM 'rejected'
+		ORDER BY c.version DESC
+		LIMIT 1
+	`)).
+		WithArgs(namespace).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "pending", "draft", "status", "base_version"}).AddRow(version, true, nil, nil, nil))
+}
+
+func expectDraftVersion(mock sqlmock.Sqlmock, namespace string, version int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT c.version, c.activate_at > NOW(), c.approval_status = 'pending', r.status, r.base_version
+		FROM configurations c
+		LEFT JOIN rollouts r ON r.version = c.version
+		WHERE c.namespace = $1
+			AND c.canceled_at IS NULL
+			AND c.approval_status IS DISTINCT FROM 'rejected'
+		ORDER BY c.version DESC
+		LIMIT 1
+	`)).
+		WithArgs(namespace).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "pending", "draft", "status", "base_version"}).AddRow(version, nil, true, nil, nil))
+}
+
+func expectEmptyNamespace(mock sqlmock.Sqlmock, namespace string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT c.version, c.activate_at > NOW(), c.approval_status = 'pending', r.status, r.base_version
+		FROM configurations c
+		LEFT JOIN rollouts r ON r.version = c.version
+		WHERE c.namespace = $1
+			AND c.canceled_at IS NULL
+			AND c.approval_status IS DISTINCT FROM 'rejected'
+		ORDER BY c.version DESC
+		LIMIT 1
+	`)).
+		WithArgs(namespace).
+		WillReturnError(sql.ErrNoRows)
+}
+
+func expectConfigInsert(mock sqlmock.Sqlmock, version int, args ...driver.Value) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO configurations (
+			namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, created_by, approval_status
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING version
+	`)).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+}
+
+const webhookEventInsert = `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+		SELECT id, $2, $3, $4
+		FROM webhooks
+		WHERE deleted_at IS NULL
+			AND (namespace = '' OR namespace = $1)
+	`
+
+// webhookPayload matches the JSON payload of event for version.
+type webhookPayload struct {
+	event   string
+	version int
+}
+
+func (p webhookPayload) Match(v driver.Value) bool {
+	raw, ok := v.(string)
+	var e model.WebhookEvent
+	return ok && json.Unmarshal([]byte(raw), &e) == nil && e.Event == p.event && e.Version == p.version
+}
+
+// expectWebhookEvent expects event of version to be queued, due at dueAt.
+func expectWebhookEvent(mock sqlmock.Sqlmock, namespace, event string, version int, dueAt time.Time) {
+	mock.ExpectExec(regexp.QuoteMeta(webhookEventInsert)).
+		WithArgs(namespace, event, webhookPayload{event: event, version: version}, dueAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
 func TestConfigRepository_Create_Success(t *testing.T) {
 	database, mock := newMockDB(t)
 	repo := NewConfigRepository(database)
 
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 6, nil, nil)
+	expectConfigInsert(mock, 7, "prod", "https://example.com/v1", 30, `{"feature":"on"}`, `{"key":"a2V5","data":"ZGF0YQ=="}`, nil, "", nil)
+	mock.ExpectCommit()
+
+	cfg := &model.Config{
+		Namespace:           "prod",
+		URL:                 "https://example.com/v1",
+		PollIntervalSeconds: 30,
+		Data:                json.RawMessage(`{"feature":"on"}`),
+		EncryptedSecrets:    json.RawMessage(`{"key":"a2V5","data":"ZGF0YQ=="}`),
+	}
+	err := repo.Create(cfg, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 7, cfg.Version)
+}
+
+func TestConfigRepository_Create_QueuesWebhookEvents(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	activateAt := now.Add(time.Hour)
+
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 6, nil, nil)
+	expectConfigInsert(mock, 7, "prod", "https://example.com/v1", 30, nil, nil, &activateAt, "", nil)
+	expectWebhookEvent(mock, "prod", model.WebhookEventConfigCreated, 7, now)
+	expectWebhookEvent(mock, "prod", model.WebhookEventConfigActivated, 7, activateAt)
+	mock.ExpectCommit()
+
+	events := []model.WebhookEvent{
+		{Event: model.WebhookEventConfigCreated, Namespace: "prod", OccurredAt: now},
+		{Event: model.WebhookEventConfigActivated, Namespace: "prod", OccurredAt: activateAt},
+	}
+	cfg := &model.Config{Namespace: "prod", URL: "https://example.com/v1", PollIntervalSeconds: 30, ActivateAt: &activateAt}
+	require.NoError(t, repo.Create(cfg, 0, events))
+	assert.Equal(t, 7, events[1].Version)
+}
+
+func TestConfigRepository_Create_WebhookEventErrorRollsBack(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectedErr := errors.New("insert failed")
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 6, nil, nil)
+	expectConfigInsert(mock, 7, "prod", "https://example.com/v1", 30, nil, nil, nil, "", nil)
+	mock.ExpectExec(regexp.QuoteMeta(webhookEventInsert)).WillReturnError(expectedErr)
+	mock.ExpectRollback()
+
+	events := []model.WebhookEvent{{Event: model.WebhookEventConfigCreated, Namespace: "prod", OccurredAt: time.Now()}}
+	err := repo.Create(&model.Config{Namespace: "prod", URL: "https://example.com/v1", PollIntervalSeconds: 30}, 0, events)
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestConfigRepository_Create_WithoutData(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "default")
+	expectEmptyNamespace(mock, "default")
+	expectConfigInsert(mock, 1, "default", "https://example.com/v1", 30, nil, nil, nil, "", nil)
+	mock.ExpectCommit()
+
+	err := repo.Create(&model.Config{Namespace: "default", URL: "https://example.com/v1", PollIntervalSeconds: 30}, 0, nil)
+	require.NoError(t, err)
+}
+
+func TestConfigRepository_Create_ExpectedVersionMatches(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 3, model.RolloutStatusCompleted, 2)
+	expectConfigInsert(mock, 4, "prod", "https://example.com/v4", 30, nil, nil, nil, "", nil)
+	mock.ExpectCommit()
+
+	err := repo.Create(&model.Config{Namespace: "prod", URL: "https://example.com/v4", PollIntervalSeconds: 30}, 3, nil)
+	require.NoError(t, err)
+}
+
+func TestConfigRepository_Create_VersionConflict(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 4, nil, nil)
+	mock.ExpectRollback()
+
+	err := repo.Create(&model.Config{Namespace: "prod", URL: "https://example.com/v4", PollIntervalSeconds: 30}, 3, nil)
+	assert.True(t, errors.Is(err, repository.ErrVersionConflict))
+}
+
+func TestConfigRepository_Create_RolloutInProgress(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 4, model.RolloutStatusPaused, 3)
+	mock.ExpectRollback()
+
+	err := repo.Create(&model.Config{Namespace: "prod", URL: "https://example.com/v5", PollIntervalSeconds: 30}, 0, nil)
+	assert.True(t, errors.Is(err, repository.ErrRolloutInProgress))
+}
+
+func TestConfigRepository_Create_ActivationPending(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectPendingVersion(mock, "prod", 4)
+	mock.ExpectRollback()
+
+	err := repo.Create(&model.Config{Namespace: "prod", URL: "https://example.com/v5", PollIntervalSeconds: 30}, 0, nil)
+	assert.True(t, errors.Is(err, repository.ErrActivationPending))
+}
+
+func TestConfigRepository_Create_ApprovalPending(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectDraftVersion(mock, "prod", 4)
+	mock.ExpectRollback()
+
+	err := repo.Create(&model.Config{Namespace: "prod", URL: "https://example.com/v5", PollIntervalSeconds: 30}, 0, nil)
+	assert.True(t, errors.Is(err, repository.ErrApprovalPending))
+}
+
+func TestConfigRepository_Create_Draft(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 4, nil, nil)
+	expectConfigInsert(mock, 5, "prod", "https://example.com/v5", 30, nil, nil, nil, "alice", model.ApprovalPending)
+	mock.ExpectCommit()
+
+	cfg := &model.Config{
+		Namespace:           "prod",
+		URL:                 "https://example.com/v5",
+		PollIntervalSeconds: 30,
+		CreatedBy:           "alice",
+		Approval:            &model.Approval{Status: model.ApprovalPending},
+	}
+	require.NoError(t, repo.Create(cfg, 0, nil))
+	assert.Equal(t, 5, cfg.Version)
+}
+
+func TestConfigRepository_Create_Scheduled(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	activateAt := time.Date(2030, 1, 2, 2, 0, 0, 0, time.UTC)
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 4, nil, nil)
+	expectConfigInsert(mock, 5, "prod", "https://example.com/v5", 30, nil, nil, activateAt, "", nil)
+	mock.ExpectCommit()
+
+	cfg := &model.Config{Namespace: "prod", URL: "https://example.com/v5", PollIntervalSeconds: 30, ActivateAt: &activateAt}
+	require.NoError(t, repo.Create(cfg, 0, nil))
+	assert.Equal(t, 5, cfg.Version)
+}
+
+func TestConfigRepository_Create_WithRollout(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 4, nil, nil)
+	expectConfigInsert(mock, 5, "prod", "https://example.com/v5", 30, nil, nil, nil, "", nil)
 	mock.ExpectExec(regexp.QuoteMeta(`
-		INSERT INTO configurations (url, poll_interval_seconds)
-		VALUES ($1, $2)
-	`)).
-		WithArgs("https://example.com/v1", 30).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+			INSERT INTO rollouts (version, namespace, base_version, percentage, agent_ids, labels, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`)).
+		WithArgs(5, "prod", 4, 10, `["agent-1"]`, `{"zone":"eu"}`, model.RolloutStatusInProgress).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	err := repo.Create("https://example.com/v1", 30)
+	err := repo.Create(&model.Config{
+		Namespace:           "prod",
+		URL:                 "https://example.com/v5",
+		PollIntervalSeconds: 30,
+		Rollout: &model.RolloutPolicy{
+			Percentage: 10,
+			AgentIDs:   []string{"agent-1"},
+			Labels:     map[string]string{"zone": "eu"},
+		},
+	}, 0, nil)
 	require.NoError(t, err)
 }
 
+func TestConfigRepository_Create_WithRollout_AfterAbortedRollout(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectLatestVersion(mock, "prod", 4, model.RolloutStatusAborted, 3)
+	expectConfigInsert(mock, 5, "prod", "https://example.com/v5", 30, nil, nil, nil, "", nil)
+	mock.ExpectExec(regexp.QuoteMeta(`
+			INSERT INTO rollouts (version, namespace, base_version, percentage, agent_ids, labels, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`)).
+		WithArgs(5, "prod", 3, 25, nil, nil, model.RolloutStatusInProgress).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.Create(&model.Config{
+		Namespace:           "prod",
+		URL:                 "https://example.com/v5",
+		PollIntervalSeconds: 30,
+		Rollout:             &model.RolloutPolicy{Percentage: 25},
+	}, 0, nil)
+	require.NoError(t, err)
+}
+
+func TestConfigRepository_Create_InsertError(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectConfigLock(mock, "prod")
+	expectEmptyNamespace(mock, "prod")
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO configurations (
+			namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, created_by, approval_status
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING version
+	`)).
+		WithArgs("prod", "https://example.com/v1", 30, nil, nil, nil, "", nil).
+		WillReturnError(errors.New("insert failed"))
+	mock.ExpectRollback()
+
+	err := repo.Create(&model.Config{Namespace: "prod", URL: "https://example.com/v1", PollIntervalSeconds: 30}, 0, nil)
+	assert.EqualError(t, err, "insert failed")
+}
+
 func TestConfigRepository_GetLatest_Success(t *testing.T) {
 	database, mock := newMockDB(t)
 	repo := NewConfigRepository(database)
 
-	rows := sqlmock.NewRows([]string{"version", "url", "poll_interval_seconds"}).
-		AddRow(2, "https://example.com/v2", 60)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := sqlmock.NewRows(configColumns).
+		AddRow(2, "default", "https://example.com/v2", 60, []byte(`{"feature":"on"}`), []byte(`{"key":"a2V5","data":"ZGF0YQ=="}`), nil, nil, "", nil, "", nil, createdAt)
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		SELECT version, url, poll_interval_seconds
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
 		FROM configurations
+		WHERE namespace = $1
+			AND canceled_at IS NULL
+			AND (activate_at IS NULL OR activate_at <= NOW())
+			AND (approval_status IS NULL OR approval_status = 'approved')
 		ORDER BY version DESC
 		LIMIT 1
 	`)).
+		WithArgs("default").
 		WillReturnRows(rows)
 
-	latest, err := repo.GetLatest()
+	latest, err := repo.GetLatest("default")
 	require.NoError(t, err)
 	assert.Equal(t, 2, latest.Version)
 	assert.Equal(t, "https://example.com/v2", latest.URL)
 	assert.Equal(t, 60, latest.PollIntervalSeconds)
+	assert.JSONEq(t, `{"feature":"on"}`, string(latest.Data))
+	assert.JSONEq(t, `{"key":"a2V5","data":"ZGF0YQ=="}`, string(latest.EncryptedSecrets))
+	assert.Equal(t, createdAt, latest.CreatedAt)
 }
 
 func TestConfigRepository_GetLatest_EmptyTable(t *testing.T) {
@@ -53,16 +408,285 @@ func TestConfigRepository_GetLatest_EmptyTable(t *testing.T) {
 	repo := NewConfigRepository(database)
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		SELECT version, url, poll_interval_seconds
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
 		FROM configurations
+		WHERE namespace = $1
+			AND canceled_at IS NULL
+			AND (activate_at IS NULL OR activate_at <= NOW())
+			AND (approval_status IS NULL OR approval_status = 'approved')
 		ORDER BY version DESC
 		LIMIT 1
 	`)).
+		WithArgs("default").
 		WillReturnError(sql.ErrNoRows)
 
-	latest, err := repo.GetLatest()
+	latest, err := repo.GetLatest("default")
 
 	assert.Nil(t, latest)
 	assert.Error(t, err)
 	assert.True(t, errors.Is(err, sql.ErrNoRows))
 }
+
+func TestConfigRepository_GetByVersion_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := sqlmock.NewRows(configColumns).
+		AddRow(1, "default", "https://example.com/v1", 30, nil, nil, nil, nil, "", nil, "", nil, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE version = $1
+	`)).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	cfg, err := repo.GetByVersion(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.Version)
+	assert.Equal(t, "https://example.com/v1", cfg.URL)
+	assert.Nil(t, cfg.Data)
+	assert.Equal(t, createdAt, cfg.CreatedAt)
+}
+
+func TestConfigRepository_GetByVersion_Reviewed(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	reviewedAt := time.Date(2024, 1, 2, 4, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(configColumns).
+		AddRow(5, "prod", "https://example.com/v5", 30, nil, nil, nil, nil, "alice", "approved", "bob", reviewedAt, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE version = $1
+	`)).
+		WithArgs(5).
+		WillReturnRows(rows)
+
+	cfg, err := repo.GetByVersion(5)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", cfg.CreatedBy)
+	assert.Equal(t, &model.Approval{Status: model.ApprovalApproved, ReviewedBy: "bob", ReviewedAt: &reviewedAt}, cfg.Approval)
+}
+
+func TestConfigRepository_GetByVersion_NotFound(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE version = $1
+	`)).
+		WithArgs(9).
+		WillReturnError(sql.ErrNoRows)
+
+	cfg, err := repo.GetByVersion(9)
+	assert.Nil(t, cfg)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestConfigRepository_List_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := sqlmock.NewRows(configColumns).
+		AddRow(2, "default", "https://example.com/v2", 60, nil, nil, nil, nil, "", nil, "", nil, createdAt).
+		AddRow(1, "default", "https://example.com/v1", 30, nil, nil, nil, nil, "", nil, "", nil, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE ($1 = '' OR namespace = $1)
+		ORDER BY version DESC
+		LIMIT $2 OFFSET $3
+	`)).
+		WithArgs("", 20, 0).
+		WillReturnRows(rows)
+
+	configs, err := repo.List("", 20, 0)
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+	assert.Equal(t, 2, configs[0].Version)
+	assert.Equal(t, 1, configs[1].Version)
+}
+
+func TestConfigRepository_List_QueryError(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	expectedErr := errors.New("query failed")
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE ($1 = '' OR namespace = $1)
+		ORDER BY version DESC
+		LIMIT $2 OFFSET $3
+	`)).
+		WithArgs("", 20, 0).
+		WillReturnError(expectedErr)
+
+	configs, err := repo.List("", 20, 0)
+	assert.Nil(t, configs)
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestConfigRepository_Count_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT COUNT(*)
+		FROM configurations
+		WHERE ($1 = '' OR namespace = $1)
+	`)).
+		WithArgs("prod").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+	total, err := repo.Count("prod")
+	require.NoError(t, err)
+	assert.Equal(t, 42, total)
+}
+
+func TestConfigRepository_ListScheduled(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewConfigRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	activateAt := time.Date(2030, 1, 2, 2, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(configColumns).
+		AddRow(5, "prod", "https://example.com/v5", 30, nil, nil, activateAt, nil, "", nil, "", nil, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT version, namespace, url, poll_interval_seconds, data, encrypted_secrets, activate_at, canceled_at,
+			created_by, approval_status, reviewed_by, reviewed_at, created_at
+		FROM configurations
+		WHERE ($1 = '' OR namespace = $1)
+			AND canceled_at IS NULL
+			AND approval_status IS DISTINCT FROM 'rejected'
+			AND activate_at > NOW()
+		ORDER BY activate_at, version
+	`)).
+		WithArgs("prod").
+		WillReturnRows(rows)
+
+	configs, err := repo.ListScheduled("prod")
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, 5, configs[0].Version)
+	require.NotNil(t, configs[0].ActivateAt)
+	assert.Equal(t, activateAt, *configs[0].ActivateAt)
+	assert.Nil(t, configs[0].CanceledAt)
+}
+
+func TestConfigRepository_Cancel(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int64
+		wantErr error
+	}{
+		{name: "scheduled", rows: 1},
+		{name: "not scheduled", rows: 0, wantErr: sql.ErrNoRows},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDB(t)
+			repo := NewConfigRepository(database)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE configurations
+		SET canceled_at = NOW()
+		WHERE version = $1
+			AND canceled_at IS NULL
+			AND approval_status IS DISTINCT FROM 'rejected'
+			AND activate_at > NOW()
+	`)).
+				WithArgs(5).
+				WillReturnResult(sqlmock.NewResult(0, tt.rows))
+			if tt.wantErr != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE webhook_deliveries
+		SET status = 'failed', next_attempt_at = NULL, last_error = 'version canceled'
+		WHERE status = 'pending'
+			AND event = $2
+			AND (payload->>'version')::int = $1
+	`)).
+					WithArgs(5, model.WebhookEventConfigActivated).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			}
+
+			err := repo.Cancel(5)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfigRepository_Review(t *testing.T) {
+	occurredAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []model.WebhookEvent{{
+		Event:      model.WebhookEventConfigApproved,
+		Namespace:  "prod",
+		Version:    5,
+		OccurredAt: occurredAt,
+	}}
+
+	tests := []struct {
+		name    string
+		rows    int64
+		wantErr error
+	}{
+		{name: "pending draft", rows: 1},
+		{name: "not pending or own draft", rows: 0, wantErr: sql.ErrNoRows},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDB(t)
+			repo := NewConfigRepository(database)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE configurations
+		SET approval_status = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE version = $1
+			AND canceled_at IS NULL
+			AND approval_status = 'pending'
+			AND created_by <> $3
+	`)).
+				WithArgs(5, model.ApprovalApproved, "bob").
+				WillReturnResult(sqlmock.NewResult(0, tt.rows))
+			if tt.wantErr != nil {
+				mock.ExpectRollback()
+			} else {
+				expectWebhookEvent(mock, "prod", model.WebhookEventConfigApproved, 5, occurredAt)
+				mock.ExpectCommit()
+			}
+
+			err := repo.Review(5, model.ApprovalApproved, "bob", events)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
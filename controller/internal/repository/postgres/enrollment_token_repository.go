@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"database/sql"
+	"errors"
+)
+
+type EnrollmentTokenRepository struct{ db *sql.DB }
+
+func NewEnrollmentTokenRepository(db *sql.DB) *EnrollmentTokenRepository {
+	return &EnrollmentTokenRepository{db}
+}
+
+func (r *EnrollmentTokenRepository) Create(token *model.EnrollmentToken) error {
+	return r.db.QueryRow(`
+		INSERT INTO enrollment_tokens (id, token_hash, max_uses, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, token.ID, token.Hash, token.MaxUses, token.ExpiresAt).Scan(&token.CreatedAt)
+}
+
+func (r *EnrollmentTokenRepository) GetByID(id string) (*model.EnrollmentToken, error) {
+
+	row := r.db.QueryRow(`
+		SELECT id, token_hash, max_uses, uses, expires_at, revoked_at, created_at
+		FROM enrollment_tokens
+		WHERE id = $1
+	`, id)
+
+	return scanEnrollmentToken(row)
+}
+
+// List returns all tokens, newest first.
+func (r *EnrollmentTokenRepository) List() ([]model.EnrollmentToken, error) {
+
+	rows, err := r.db.Query(`
+		SELECT id, token_hash, max_uses, uses, expires_at, revoked_at, created_at
+		FROM enrollment_tokens
+		ORDER BY created_at DESC, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]model.EnrollmentToken, 0)
+	for rows.Next() {
+		t, err := scanEnrollmentToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *EnrollmentTokenRepository) Revoke(id string) error {
+	res, err := r.db.Exec(`
+		UPDATE enrollment_tokens
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// consumeEnrollmentToken checks and counts a use in one statement, so
+// concurrent registrations cannot use a token more than max_uses times. It
+// returns sql.ErrNoRows when the token is unknown, revoked, expired or used up.
+func consumeEnrollmentToken(db execer, id string) error {
+	res, err := db.Exec(`
+		UPDATE enrollment_tokens
+		SET uses = uses + 1
+		WHERE id = $1
+			AND uses < max_uses
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanEnrollmentToken(row rowScanner) (*model.EnrollmentToken, error) {
+	var t model.EnrollmentToken
+	var expiresAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&t.ID,
+		&t.Hash,
+		&t.MaxUses,
+		&t.Uses,
+		&expiresAt,
+		&revokedAt,
+		&t.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		v := expiresAt.Time
+		t.ExpiresAt = &v
+	}
+	if revokedAt.Valid {
+		v := revokedAt.Time
+		t.RevokedAt = &v
+	}
+
+	return &t, nil
+}
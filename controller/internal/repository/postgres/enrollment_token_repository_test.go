@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrollmentTokenRepository_Create(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewEnrollmentTokenRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO enrollment_tokens (id, token_hash, max_uses, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`)).
+		WithArgs("t1", "hash", 5, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+
+	token := &model.EnrollmentToken{ID: "t1", Hash: "hash", MaxUses: 5}
+	require.NoError(t, repo.Create(token))
+	assert.Equal(t, createdAt, token.CreatedAt)
+}
+
+func TestEnrollmentTokenRepository_GetByID(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewEnrollmentTokenRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(24 * time.Hour)
+	rows := sqlmock.NewRows([]string{"id", "token_hash", "max_uses", "uses", "expires_at", "revoked_at", "created_at"}).
+		AddRow("t1", "hash", 5, 2, expiresAt, nil, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, token_hash, max_uses, uses, expires_at, revoked_at, created_at
+		FROM enrollment_tokens
+		WHERE id = $1
+	`)).
+		WithArgs("t1").
+		WillReturnRows(rows)
+
+	token, err := repo.GetByID("t1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, token.Uses)
+	require.NotNil(t, token.ExpiresAt)
+	assert.Equal(t, expiresAt, *token.ExpiresAt)
+	assert.Nil(t, token.RevokedAt)
+}
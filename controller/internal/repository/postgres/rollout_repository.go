@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+type RolloutRepository struct{ db *sql.DB }
+
+func NewRolloutRepository(db *sql.DB) *RolloutRepository {
+	return &RolloutRepository{db}
+}
+
+func (r *RolloutRepository) GetByVersion(version int) (*model.Rollout, error) {
+
+	row := r.db.QueryRow(`
+		SELECT version, namespace, base_version, percentage, agent_ids, labels, status, created_at, updated_at
+		FROM rollouts
+		WHERE version = $1
+	`, version)
+
+	return scanRollout(row)
+}
+
+// Update stores the rollout's policy and status. It only changes a rollout
+// that is still in progress or paused, so a concurrent abort or completion is
+// not overwritten; otherwise it returns sql.ErrNoRows.
+func (r *RolloutRepository) Update(rollout *model.Rollout) error {
+	agentIDs, err := marshalAgentIDs(rollout.AgentIDs)
+	if err != nil {
+		return err
+	}
+	labels, err := marshalLabels(rollout.Labels)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.Exec(`
+		UPDATE rollouts
+		SET percentage = $2, agent_ids = $3, labels = $4, status = $5, updated_at = NOW()
+		WHERE version = $1 AND status IN ($6, $7)
+	`,
+		rollout.Version,
+		rollout.Percentage,
+		agentIDs,
+		labels,
+		rollout.Status,
+		model.RolloutStatusInProgress,
+		model.RolloutStatusPaused,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanRollout(row rowScanner) (*model.Rollout, error) {
+	var ro model.Rollout
+	var agentIDs, labels []byte
+
+	err := row.Scan(
+		&ro.Version,
+		&ro.Namespace,
+		&ro.BaseVersion,
+		&ro.Percentage,
+		&agentIDs,
+		&labels,
+		&ro.Status,
+		&ro.CreatedAt,
+		&ro.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	if len(agentIDs) > 0 {
+		if err := json.Unmarshal(agentIDs, &ro.AgentIDs); err != nil {
+			return nil, err
+		}
+	}
+	if len(labels) > 0 {
+		if err := json.Unmarshal(labels, &ro.Labels); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ro, nil
+}
+
+func marshalAgentIDs(ids []string) (interface{}, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	return nullableJSON(raw), nil
+}
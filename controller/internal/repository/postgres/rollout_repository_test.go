@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutRepository_GetByVersion_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewRolloutRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"version", "namespace", "base_version", "percentage", "agent_ids", "labels", "status", "created_at", "updated_at"}).
+		AddRow(5, "prod", 4, 10, []byte(`["agent-1"]`), []byte(`{"zone":"eu"}`), model.RolloutStatusInProgress, createdAt, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT version, namespace, base_version, percentage, agent_ids, labels, status, created_at, updated_at
+		FROM rollouts
+		WHERE version = $1
+	`)).
+		WithArgs(5).
+		WillReturnRows(rows)
+
+	rollout, err := repo.GetByVersion(5)
+	require.NoError(t, err)
+	assert.Equal(t, 4, rollout.BaseVersion)
+	assert.Equal(t, 10, rollout.Percentage)
+	assert.Equal(t, []string{"agent-1"}, rollout.AgentIDs)
+	assert.Equal(t, map[string]string{"zone": "eu"}, rollout.Labels)
+	assert.Equal(t, model.RolloutStatusInProgress, rollout.Status)
+}
+
+func TestRolloutRepository_GetByVersion_NotFound(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewRolloutRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT version, namespace, base_version, percentage, agent_ids, labels, status, created_at, updated_at
+		FROM rollouts
+		WHERE version = $1
+	`)).
+		WithArgs(5).
+		WillReturnError(sql.ErrNoRows)
+
+	rollout, err := repo.GetByVersion(5)
+	assert.Nil(t, rollout)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestRolloutRepository_Update_Success(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewRolloutRepository(database)
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE rollouts
+		SET percentage = $2, agent_ids = $3, labels = $4, status = $5, updated_at = NOW()
+		WHERE version = $1 AND status IN ($6, $7)
+	`)).
+		WithArgs(5, 50, `["agent-1"]`, nil, model.RolloutStatusInProgress, model.RolloutStatusInProgress, model.RolloutStatusPaused).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.Update(&model.Rollout{
+		Version:       5,
+		RolloutPolicy: model.RolloutPolicy{Percentage: 50, AgentIDs: []string{"agent-1"}},
+		Status:        model.RolloutStatusInProgress,
+	})
+	require.NoError(t, err)
+}
+
+func TestRolloutRepository_Update_NotActive(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewRolloutRepository(database)
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE rollouts
+		SET percentage = $2, agent_ids = $3, labels = $4, status = $5, updated_at = NOW()
+		WHERE version = $1 AND status IN ($6, $7)
+	`)).
+		WithArgs(9, 0, nil, nil, model.RolloutStatusAborted, model.RolloutStatusInProgress, model.RolloutStatusPaused).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.Update(&model.Rollout{Version: 9, Status: model.RolloutStatusAborted})
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
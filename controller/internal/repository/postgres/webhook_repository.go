@@ -0,0 +1,315 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type WebhookRepository struct{ db *sql.DB }
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db}
+}
+
+func (r *WebhookRepository) Create(webhook *model.Webhook) error {
+	return r.db.QueryRow(`
+		INSERT INTO webhooks (id, name, url, secret, namespace)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, webhook.ID, webhook.Name, webhook.URL, webhook.Secret, webhook.Namespace).Scan(&webhook.CreatedAt)
+}
+
+func (r *WebhookRepository) GetByID(id string) (*model.Webhook, error) {
+
+	row := r.db.QueryRow(`
+		SELECT id, name, url, secret, namespace, created_at, deleted_at
+		FROM webhooks
+		WHERE id = $1
+	`, id)
+
+	return scanWebhook(row)
+}
+
+// List returns all webhooks, newest first.
+func (r *WebhookRepository) List() ([]model.Webhook, error) {
+
+	rows, err := r.db.Query(`
+		SELECT id, name, url, secret, namespace, created_at, deleted_at
+		FROM webhooks
+		ORDER BY created_at DESC, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]model.Webhook, 0)
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Delete marks the webhook deleted, keeping its delivery log. Deleting it again
+// keeps the first time.
+func (r *WebhookRepository) Delete(id string) error {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE webhooks
+		SET deleted_at = COALESCE(deleted_at, NOW())
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'failed', next_attempt_at = NULL, last_error = 'webhook deleted'
+		WHERE webhook_id = $1
+			AND status = 'pending'
+	`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// enqueueWebhookEvents queues every event for the live webhooks subscribed to
+// its namespace. Each delivery is due at the event's OccurredAt, so events of
+// scheduled versions wait for their activation time.
+func enqueueWebhookEvents(db execer, events []model.WebhookEvent) error {
+	for i := range events {
+		event := &events[i]
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		if _, err := db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+		SELECT id, $2, $3, $4
+		FROM webhooks
+		WHERE deleted_at IS NULL
+			AND (namespace = '' OR namespace = $1)
+	`, event.Namespace, event.Event, string(payload), event.OccurredAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClaimDue locks the due rows with SKIP LOCKED, so concurrent instances claim
+// disjoint batches.
+func (r *WebhookRepository) ClaimDue(limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+
+	rows, err := r.db.Query(`
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id
+			AND d.id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE status = 'pending'
+					AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at, id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+			d.response_status, d.last_error, d.redelivery_of, d.created_at, d.delivered_at, w.url, w.secret
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]model.WebhookDelivery, 0, limit)
+	for rows.Next() {
+		var d model.WebhookDelivery
+		if err := scanDelivery(rows, &d, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) RecordAttempt(d *model.WebhookDelivery) error {
+
+	_, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $2,
+			attempts = $3,
+			next_attempt_at = $4,
+			response_status = $5,
+			last_error = $6,
+			delivered_at = $7
+		WHERE id = $1
+	`, d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.ResponseStatus, d.LastError, d.DeliveredAt)
+
+	return err
+}
+
+func (r *WebhookRepository) ListDeliveries(webhookID string, limit, offset int) ([]model.WebhookDelivery, error) {
+
+	rows, err := r.db.Query(`
+		SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at,
+			response_status, last_error, redelivery_of, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`, webhookID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]model.WebhookDelivery, 0, limit)
+	for rows.Next() {
+		var d model.WebhookDelivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) GetDelivery(id int64) (*model.WebhookDelivery, error) {
+
+	row := r.db.QueryRow(`
+		SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at,
+			response_status, last_error, redelivery_of, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE id = $1
+	`, id)
+
+	var d model.WebhookDelivery
+	if err := scanDelivery(row, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Redeliver only repeats deliveries of webhooks that are not deleted.
+func (r *WebhookRepository) Redeliver(id int64) (int64, error) {
+
+	var newID int64
+	err := r.db.QueryRow(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, redelivery_of)
+		SELECT d.webhook_id, d.event, d.payload, NOW(), d.id
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1
+			AND w.deleted_at IS NULL
+		RETURNING id
+	`, id).Scan(&newID)
+
+	return newID, err
+}
+
+func scanWebhook(row rowScanner) (*model.Webhook, error) {
+	var w model.Webhook
+	var deletedAt sql.NullTime
+
+	err := row.Scan(
+		&w.ID,
+		&w.Name,
+		&w.URL,
+		&w.Secret,
+		&w.Namespace,
+		&w.CreatedAt,
+		&deletedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	if deletedAt.Valid {
+		w.DeletedAt = &deletedAt.Time
+	}
+
+	return &w, nil
+}
+
+// scanDelivery scans the delivery columns into d, followed by extra.
+func scanDelivery(row rowScanner, d *model.WebhookDelivery, extra ...interface{}) error {
+	var payload []byte
+	var nextAttemptAt, deliveredAt sql.NullTime
+
+	dest := []interface{}{
+		&d.ID,
+		&d.WebhookID,
+		&d.Event,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&nextAttemptAt,
+		&d.ResponseStatus,
+		&d.LastError,
+		&d.RedeliveryOf,
+		&d.CreatedAt,
+		&deliveredAt,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sql.ErrNoRows
+		}
+		return err
+	}
+
+	d.Payload = json.RawMessage(payload)
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"controller/internal/model"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var deliveryColumns = []string{
+	"id", "webhook_id", "event", "payload", "status", "attempts", "next_attempt_at",
+	"response_status", "last_error", "redelivery_of", "created_at", "delivered_at",
+}
+
+func TestWebhookRepository_Create(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewWebhookRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO webhooks (id, name, url, secret, namespace)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`)).
+		WithArgs("w1", "tracker", "https://hooks.example.com", "s3cret", "prod").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+
+	webhook := &model.Webhook{ID: "w1", Name: "tracker", URL: "https://hooks.example.com", Secret: "s3cret", Namespace: "prod"}
+	require.NoError(t, repo.Create(webhook))
+	assert.Equal(t, createdAt, webhook.CreatedAt)
+}
+
+func TestWebhookRepository_List(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewWebhookRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, name, url, secret, namespace, created_at, deleted_at
+		FROM webhooks
+		ORDER BY created_at DESC, id
+	`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "url", "secret", "namespace", "created_at", "deleted_at"}).
+			AddRow("w2", "chat", "https://chat.example.com", "b", "", createdAt, createdAt).
+			AddRow("w1", "tracker", "https://hooks.example.com", "a", "prod", createdAt, nil))
+
+	webhooks, err := repo.List()
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+	assert.NotNil(t, webhooks[0].DeletedAt)
+	assert.Equal(t, "prod", webhooks[1].Namespace)
+	assert.Nil(t, webhooks[1].DeletedAt)
+}
+
+func TestWebhookRepository_Delete(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		database, mock := newMockDB(t)
+		repo := NewWebhookRepository(database)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE webhooks
+		SET deleted_at = COALESCE(deleted_at, NOW())
+		WHERE id = $1
+	`)).
+			WithArgs("w1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE webhook_deliveries
+		SET status = 'failed', next_attempt_at = NULL, last_error = 'webhook deleted'
+		WHERE webhook_id = $1
+			AND status = 'pending'
+	`)).
+			WithArgs("w1").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.Delete("w1"))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		database, mock := newMockDB(t)
+		repo := NewWebhookRepository(database)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE webhooks
+		SET deleted_at = COALESCE(deleted_at, NOW())
+		WHERE id = $1
+	`)).
+			WithArgs("w1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.Delete("w1"), sql.ErrNoRows)
+	})
+}
+
+func TestWebhookRepository_ClaimDue(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewWebhookRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	lease := createdAt.Add(time.Minute)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id
+			AND d.id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE status = 'pending'
+					AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at, id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+			d.response_status, d.last_error, d.redelivery_of, d.created_at, d.delivered_at, w.url, w.secret
+	`)).
+		WithArgs(10, int64(60000)).
+		WillReturnRows(sqlmock.NewRows(append(deliveryColumns, "url", "secret")).
+			AddRow(7, "w1", "config.created", []byte(`{"version":42}`), "pending", 1, lease, 500, "unexpected status 500", 0, createdAt, nil, "https://hooks.example.com", "s3cret"))
+
+	deliveries, err := repo.ClaimDue(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, int64(7), deliveries[0].ID)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.JSONEq(t, `{"version":42}`, string(deliveries[0].Payload))
+	assert.Equal(t, "https://hooks.example.com", deliveries[0].URL)
+	assert.Equal(t, "s3cret", deliveries[0].Secret)
+	assert.Equal(t, lease, *deliveries[0].NextAttemptAt)
+}
+
+func TestWebhookRepository_RecordAttempt(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewWebhookRepository(database)
+
+	deliveredAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE webhook_deliveries
+		SET status = $2,
+			attempts = $3,
+			next_attempt_at = $4,
+			response_status = $5,
+			last_error = $6,
+			delivered_at = $7
+		WHERE id = $1
+	`)).
+		WithArgs(int64(7), model.DeliveryStatusSucceeded, 2, nil, 204, "", deliveredAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.RecordAttempt(&model.WebhookDelivery{
+		ID:             7,
+		Status:         model.DeliveryStatusSucceeded,
+		Attempts:       2,
+		ResponseStatus: 204,
+		DeliveredAt:    &deliveredAt,
+	}))
+}
+
+func TestWebhookRepository_ListDeliveries(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewWebhookRepository(database)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at,
+			response_status, last_error, redelivery_of, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`)).
+		WithArgs("w1", 20, 0).
+		WillReturnRows(sqlmock.NewRows(deliveryColumns).
+			AddRow(8, "w1", "config.created", []byte(`{}`), "succeeded", 1, nil, 200, "", 7, createdAt, createdAt))
+
+	deliveries, err := repo.ListDeliveries("w1", 20, 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, int64(7), deliveries[0].RedeliveryOf)
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+	assert.Equal(t, createdAt, *deliveries[0].DeliveredAt)
+}
+
+func TestWebhookRepository_Redeliver(t *testing.T) {
+	database, mock := newMockDB(t)
+	repo := NewWebhookRepository(database)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, redelivery_of)
+		SELECT d.webhook_id, d.event, d.payload, NOW(), d.id
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1
+			AND w.deleted_at IS NULL
+		RETURNING id
+	`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+
+	id, err := repo.Redeliver(7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), id)
+}
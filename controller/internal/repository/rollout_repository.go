@@ -0,0 +1,10 @@
+package repository
+
+import "controller/internal/model"
+
+// RolloutRepository stores rollouts. New rollouts are created together with
+// their version by ConfigRepository.Create.
+type RolloutRepository interface {
+	GetByVersion(version int) (*model.Rollout, error)
+	Update(rollout *model.Rollout) error
+}
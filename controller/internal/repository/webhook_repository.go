@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"controller/internal/model"
+	"time"
+)
+
+type WebhookRepository interface {
+	Create(webhook *model.Webhook) error
+	GetByID(id string) (*model.Webhook, error)
+	// List returns all webhooks, including deleted ones, newest first.
+	List() ([]model.Webhook, error)
+	// Delete marks the webhook deleted and fails its pending deliveries.
+	Delete(id string) error
+
+	// ClaimDue returns up to limit pending deliveries that are due, with the
+	// URL and secret of their webhook, and pushes their next attempt back by
+	// lease so no other instance claims them meanwhile.
+	ClaimDue(limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	// RecordAttempt stores the status, attempts, next attempt, response and
+	// delivery time of d.
+	RecordAttempt(d *model.WebhookDelivery) error
+
+	// ListDeliveries returns the deliveries of a webhook, newest first.
+	ListDeliveries(webhookID string, limit, offset int) ([]model.WebhookDelivery, error)
+	GetDelivery(id int64) (*model.WebhookDelivery, error)
+	// Redeliver queues a new delivery repeating the payload of delivery id and
+	// returns its ID.
+	Redeliver(id int64) (int64, error)
+}
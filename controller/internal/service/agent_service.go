@@ -1,25 +1,277 @@
 package service
 
 import (
+	"controller/internal/model"
 	"controller/internal/repository"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrInvalidAgentCredential is returned when an agent's credential does not
+// match the one issued to its ID.
+var ErrInvalidAgentCredential = errors.New("invalid agent credential")
+
 type AgentService interface {
-	Register(existingID string) (string, error)
+	// Register stores the agent's reported details and returns its ID. A new
+	// agent is enrolled under a fresh ID and also gets its credential back.
+	Register(auth model.AgentAuth, agent *model.Agent) (id, credential string, err error)
+	Authenticate(id, credential string) error
+	Get(id string) (*model.Agent, error)
+	RecordPoll(id string, configVersion int) error
+	List(namespace, status string, limit, offset int) ([]model.Agent, int, error)
+	Count(namespace, status string) (int, error)
+	ReportStatus(status *model.AgentConfigStatus) error
+	StatusSummary(namespace string, version int) (*model.ConfigStatusSummary, error)
+}
+
+// maxStatusFailures caps the failed reports returned with a status summary.
+const maxStatusFailures = 100
+
+// heartbeatsPerStale is how many times last_seen_at is refreshed within the
+// stale threshold while an agent keeps polling the same version.
+const heartbeatsPerStale = 4
+
+type agentService struct {
+	repo       repository.AgentRepository
+	statuses   repository.AgentStatusRepository
+	enrollment EnrollmentService
+	staleAfter time.Duration
+	deadAfter  time.Duration
+	now        func() time.Time
+
+	// heartbeats holds the last poll written per agent on this instance.
+	mu         sync.Mutex
+	heartbeats map[string]heartbeat
+}
+
+type heartbeat struct {
+	version int
+	at      time.Time
+}
+
+// NewAgentService returns an AgentService that reports agents as stale once
+// they have not polled for staleAfter, and as dead after deadAfter.
+func NewAgentService(
+	r repository.AgentRepository,
+	statuses repository.AgentStatusRepository,
+	enrollment EnrollmentService,
+	staleAfter, deadAfter time.Duration,
+) AgentService {
+	return &agentService{
+		repo:       r,
+		statuses:   statuses,
+		enrollment: enrollment,
+		staleAfter: staleAfter,
+		deadAfter:  deadAfter,
+		now:        time.Now,
+		heartbeats: make(map[string]heartbeat),
+	}
+}
+
+// Register keeps the agent's ID when it proves it with its credential.
+// Otherwise it redeems the enrollment token and issues a new ID and credential,
+// so callers can never pick the ID they register under. The token is used in
+// the same transaction that stores the agent, so a failed registration can be
+// retried with it.
+func (s *agentService) Register(auth model.AgentAuth, agent *model.Agent) (string, string, error) {
+	if agent.Namespace == "" {
+		agent.Namespace = model.DefaultNamespace
+	}
+
+	if auth.Credential != "" {
+		err := s.Authenticate(auth.AgentID, auth.Credential)
+		if err == nil {
+			agent.ID = auth.AgentID
+			return agent.ID, "", s.repo.Save(agent)
+		}
+		if !errors.Is(err, ErrInvalidAgentCredential) {
+			return "", "", err
+		}
+	}
+
+	tokenID, err := s.enrollment.Verify(auth.EnrollmentToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	credential, err := newSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	agent.ID = uuid.New().String()
+	err = s.repo.Enroll(agent, hashSecret(credential), tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrInvalidEnrollmentToken
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return agent.ID, credential, nil
+}
+
+// Authenticate checks credential against the one issued to the agent id.
+func (s *agentService) Authenticate(id, credential string) error {
+	if credential == "" {
+		return ErrInvalidAgentCredential
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidAgentCredential
+	}
+
+	hash, err := s.repo.GetCredentialHash(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidAgentCredential
+	}
+	if err != nil {
+		return err
+	}
+
+	if hash == "" || subtle.ConstantTimeCompare([]byte(hashSecret(credential)), []byte(hash)) != 1 {
+		return ErrInvalidAgentCredential
+	}
+
+	return nil
+}
+
+func (s *agentService) Get(id string) (*model.Agent, error) {
+	agent, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	agent.Status = s.status(agent.LastSeenAt)
+	return agent, nil
+}
+
+// RecordPoll stores a heartbeat. Polls of an unchanged version are written at
+// most heartbeatsPerStale times per stale threshold, which keeps the agent
+// healthy without a database write on every poll and stream wake-up.
+func (s *agentService) RecordPoll(id string, configVersion int) error {
+	now := s.now()
+
+	s.mu.Lock()
+	last, ok := s.heartbeats[id]
+	s.mu.Unlock()
+	if ok && last.version == configVersion && now.Sub(last.at) < s.staleAfter/heartbeatsPerStale {
+		return nil
+	}
+
+	if err := s.repo.RecordPoll(id, configVersion); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.heartbeats[id] = heartbeat{version: configVersion, at: now}
+	s.mu.Unlock()
+	return nil
 }
 
-type agentService struct{ repo repository.AgentRepository }
+// List returns one page of agents and the total matching count. An empty
+// status lists agents regardless of health.
+func (s *agentService) List(namespace, status string, limit, offset int) ([]model.Agent, int, error) {
+	filter := s.filter(status)
+	filter.Namespace = namespace
 
-func NewAgentService(r repository.AgentRepository) AgentService {
-	return &agentService{repo: r}
+	total, err := s.repo.Count(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	filter.Limit = limit
+	filter.Offset = offset
+	agents, err := s.repo.List(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range agents {
+		agents[i].Status = s.status(agents[i].LastSeenAt)
+	}
+
+	return agents, total, nil
+}
+
+// Count returns the number of agents matching namespace and status; empty
+// values match all.
+func (s *agentService) Count(namespace, status string) (int, error) {
+	filter := s.filter(status)
+	filter.Namespace = namespace
+	return s.repo.Count(filter)
 }
 
-func (s *agentService) Register(existingID string) (string, error) {
-	id := existingID
-	if _, err := uuid.Parse(existingID); existingID == "" || err != nil {
-		id = uuid.New().String()
+func (s *agentService) ReportStatus(status *model.AgentConfigStatus) error {
+	return s.statuses.Save(status)
+}
+
+// StatusSummary counts apply results for a version. Agents of the namespace
+// that have not reported for it yet are counted as pending.
+func (s *agentService) StatusSummary(namespace string, version int) (*model.ConfigStatusSummary, error) {
+	counts, err := s.statuses.CountByStatus(version)
+	if err != nil {
+		return nil, err
+	}
+
+	agents, err := s.repo.Count(model.AgentFilter{Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := s.statuses.ListByStatus(version, model.ApplyStatusFailed, maxStatusFailures)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &model.ConfigStatusSummary{
+		Version:   version,
+		Namespace: namespace,
+		Agents:    agents,
+		Applied:   counts[model.ApplyStatusApplied],
+		Failed:    counts[model.ApplyStatusFailed],
+		Failures:  failures,
+	}
+	// Agents may have left the namespace after reporting, so never go negative.
+	if pending := agents - summary.Applied - summary.Failed; pending > 0 {
+		summary.Pending = pending
+	}
+
+	return summary, nil
+}
+
+// filter translates a status into last-seen bounds matching status().
+func (s *agentService) filter(status string) model.AgentFilter {
+	now := s.now()
+	staleCutoff := now.Add(-s.staleAfter)
+	deadCutoff := now.Add(-s.deadAfter)
+
+	switch status {
+	case model.AgentStatusHealthy:
+		return model.AgentFilter{SeenSince: &staleCutoff}
+	case model.AgentStatusStale:
+		return model.AgentFilter{SeenSince: &deadCutoff, SeenBefore: &staleCutoff}
+	case model.AgentStatusDead:
+		return model.AgentFilter{SeenBefore: &deadCutoff}
+	default:
+		return model.AgentFilter{}
+	}
+}
+
+func (s *agentService) status(lastSeen *time.Time) string {
+	if lastSeen == nil {
+		return model.AgentStatusDead
+	}
+	age := s.now().Sub(*lastSeen)
+	switch {
+	case age <= s.staleAfter:
+		return model.AgentStatusHealthy
+	case age <= s.deadAfter:
+		return model.AgentStatusStale
+	default:
+		return model.AgentStatusDead
 	}
-	return id, s.repo.Save(id)
 }
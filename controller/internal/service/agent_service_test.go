@@ -2,39 +2,106 @@ package service
 
 import (
 	mocks "controller/internal/mocks/repository"
+	serviceMocks "controller/internal/mocks/service"
+	"controller/internal/model"
+	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
-func TestAgentService_Register_Success(t *testing.T) {
+// verifyEnrollment accepts the enrollment token "tok", whose id is "tok-id",
+// once.
+func verifyEnrollment() *serviceMocks.EnrollmentService {
+	m := new(serviceMocks.EnrollmentService)
+	m.On("Verify", "tok").Return("tok-id", nil).Once()
+	return m
+}
+
+func TestAgentService_Register_Enrollment(t *testing.T) {
 
 	mockRepo := new(mocks.AgentRepository)
 
+	var enrolled *model.Agent
+	var hash string
 	mockRepo.
-		On("Save", mock.MatchedBy(func(id string) bool {
-			_, err := uuid.Parse(id)
-			return err == nil
-		})).
+		On("Enroll", mock.MatchedBy(func(agent *model.Agent) bool {
+			_, err := uuid.Parse(agent.ID)
+			return err == nil && agent.Namespace == "default"
+		}), mock.Anything, "tok-id").
+		Run(func(args mock.Arguments) {
+			enrolled = args.Get(0).(*model.Agent)
+			hash = args.String(1)
+		}).
 		Return(nil).
 		Once()
 
-	service := NewAgentService(mockRepo)
-	id, err := service.Register("")
+	enrollment := verifyEnrollment()
+	service := NewAgentService(mockRepo, nil, enrollment, time.Minute, 5*time.Minute)
+	id, credential, err := service.Register(model.AgentAuth{EnrollmentToken: "tok"}, &model.Agent{})
 
 	assert.NoError(t, err)
-	assert.NotEmpty(t, id)
+	assert.NotEmpty(t, credential)
+	assert.Equal(t, hashSecret(credential), hash)
 
 	parsed, parseErr := uuid.Parse(id)
 	assert.NoError(t, parseErr)
 	assert.Equal(t, id, parsed.String())
 
+	assert.Equal(t, id, enrolled.ID)
+	mockRepo.AssertExpectations(t)
+	enrollment.AssertExpectations(t)
+}
+
+func TestAgentService_Register_EnrollmentIgnoresRequestedID(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+	requestedID := uuid.NewString()
+
+	mockRepo.
+		On("Enroll", mock.MatchedBy(func(agent *model.Agent) bool {
+			return agent.ID != requestedID
+		}), mock.Anything, "tok-id").
+		Return(nil).
+		Once()
+
+	service := NewAgentService(mockRepo, nil, verifyEnrollment(), time.Minute, 5*time.Minute)
+	id, _, err := service.Register(model.AgentAuth{AgentID: requestedID, EnrollmentToken: "tok"}, &model.Agent{})
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, requestedID, id)
 	mockRepo.AssertExpectations(t)
 }
 
+func TestAgentService_Register_InvalidEnrollmentToken(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+	enrollment := new(serviceMocks.EnrollmentService)
+	enrollment.On("Verify", "").Return("", ErrInvalidEnrollmentToken).Once()
+
+	service := NewAgentService(mockRepo, nil, enrollment, time.Minute, 5*time.Minute)
+	_, _, err := service.Register(model.AgentAuth{}, &model.Agent{})
+
+	assert.True(t, errors.Is(err, ErrInvalidEnrollmentToken))
+	mockRepo.AssertNotCalled(t, "Enroll", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAgentService_Register_EnrollmentTokenUsedUp(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+	mockRepo.On("Enroll", mock.Anything, mock.Anything, "tok-id").Return(sql.ErrNoRows).Once()
+
+	service := NewAgentService(mockRepo, nil, verifyEnrollment(), time.Minute, 5*time.Minute)
+	_, credential, err := service.Register(model.AgentAuth{EnrollmentToken: "tok"}, &model.Agent{})
+
+	assert.True(t, errors.Is(err, ErrInvalidEnrollmentToken))
+	assert.Empty(t, credential)
+}
+
 func TestAgentService_Register_Error(t *testing.T) {
 
 	mockRepo := new(mocks.AgentRepository)
@@ -42,61 +109,426 @@ func TestAgentService_Register_Error(t *testing.T) {
 	expectedErr := errors.New("database error")
 
 	mockRepo.
-		On("Save", mock.MatchedBy(func(id string) bool {
-			_, err := uuid.Parse(id)
-			return err == nil
-		})).
+		On("Enroll", mock.Anything, mock.Anything, "tok-id").
 		Return(expectedErr).
 		Once()
 
-	service := NewAgentService(mockRepo)
-	id, err := service.Register("")
+	service := NewAgentService(mockRepo, nil, verifyEnrollment(), time.Minute, 5*time.Minute)
+	_, credential, err := service.Register(model.AgentAuth{EnrollmentToken: "tok"}, &model.Agent{})
 
-	assert.Error(t, err)
 	assert.Equal(t, expectedErr, err)
+	assert.Empty(t, credential)
+}
+
+func TestAgentService_Register_ReuseIDWithCredential(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+	existingID := uuid.NewString()
+
+	mockRepo.On("GetCredentialHash", existingID).Return(hashSecret("cred"), nil).Once()
+	mockRepo.
+		On("Save", &model.Agent{ID: existingID, Namespace: "prod"}).
+		Return(nil).
+		Once()
+
+	enrollment := new(serviceMocks.EnrollmentService)
+	service := NewAgentService(mockRepo, nil, enrollment, time.Minute, 5*time.Minute)
+	id, credential, err := service.Register(
+		model.AgentAuth{AgentID: existingID, Credential: "cred", EnrollmentToken: "tok"},
+		&model.Agent{Namespace: "prod"},
+	)
 
-	assert.NotEmpty(t, id)
+	assert.NoError(t, err)
+	assert.Equal(t, existingID, id)
+	assert.Empty(t, credential)
 
 	mockRepo.AssertExpectations(t)
+	enrollment.AssertNotCalled(t, "Verify", mock.Anything)
 }
 
-func TestAgentService_Register_ReuseExistingID(t *testing.T) {
+func TestAgentService_Register_WrongCredentialFallsBackToEnrollment(t *testing.T) {
 
 	mockRepo := new(mocks.AgentRepository)
 	existingID := uuid.NewString()
 
+	mockRepo.On("GetCredentialHash", existingID).Return(hashSecret("cred"), nil).Once()
 	mockRepo.
-		On("Save", existingID).
+		On("Enroll", mock.MatchedBy(func(agent *model.Agent) bool {
+			return agent.ID != existingID
+		}), mock.Anything, "tok-id").
 		Return(nil).
 		Once()
 
-	service := NewAgentService(mockRepo)
-	id, err := service.Register(existingID)
+	service := NewAgentService(mockRepo, nil, verifyEnrollment(), time.Minute, 5*time.Minute)
+	id, credential, err := service.Register(
+		model.AgentAuth{AgentID: existingID, Credential: "stolen", EnrollmentToken: "tok"},
+		&model.Agent{},
+	)
 
 	assert.NoError(t, err)
-	assert.Equal(t, existingID, id)
+	assert.NotEqual(t, existingID, id)
+	assert.NotEmpty(t, credential)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAgentService_Authenticate(t *testing.T) {
+	id := uuid.NewString()
+
+	tests := []struct {
+		name       string
+		id         string
+		credential string
+		hash       string
+		repoErr    error
+		wantErr    error
+	}{
+		{name: "valid", id: id, credential: "cred", hash: hashSecret("cred")},
+		{name: "wrong credential", id: id, credential: "guess", hash: hashSecret("cred"), wantErr: ErrInvalidAgentCredential},
+		{name: "no credential issued", id: id, credential: "cred", hash: "", wantErr: ErrInvalidAgentCredential},
+		{name: "unknown agent", id: id, credential: "cred", repoErr: sql.ErrNoRows, wantErr: ErrInvalidAgentCredential},
+		{name: "repository error", id: id, credential: "cred", repoErr: errors.New("db down"), wantErr: errors.New("db down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.AgentRepository)
+			mockRepo.On("GetCredentialHash", tt.id).Return(tt.hash, tt.repoErr).Once()
+
+			service := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute)
+			err := service.Authenticate(tt.id, tt.credential)
+
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestAgentService_Authenticate_Malformed(t *testing.T) {
+	mockRepo := new(mocks.AgentRepository)
+	service := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute)
+
+	assert.Equal(t, ErrInvalidAgentCredential, service.Authenticate("not-a-uuid", "cred"))
+	assert.Equal(t, ErrInvalidAgentCredential, service.Authenticate(uuid.NewString(), ""))
+	mockRepo.AssertNotCalled(t, "GetCredentialHash", mock.Anything)
+}
+
+func TestAgentService_Get_Success(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+	expected := &model.Agent{ID: uuid.NewString(), Namespace: "prod"}
+
+	mockRepo.
+		On("GetByID", expected.ID).
+		Return(expected, nil).
+		Once()
+
+	service := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute)
+	agent, err := service.Get(expected.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, agent)
+	assert.Equal(t, model.AgentStatusDead, agent.Status)
 
 	mockRepo.AssertExpectations(t)
 }
 
-func TestAgentService_Register_InvalidExistingID_GeneratesNew(t *testing.T) {
+func TestAgentService_Get_NotFound(t *testing.T) {
 
 	mockRepo := new(mocks.AgentRepository)
 
 	mockRepo.
-		On("Save", mock.MatchedBy(func(id string) bool {
-			_, err := uuid.Parse(id)
-			return err == nil
+		On("GetByID", "missing").
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	service := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute)
+	agent, err := service.Get("missing")
+
+	assert.Nil(t, agent)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAgentService_Register_KeepsReportedDetails(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+
+	mockRepo.
+		On("Enroll", mock.MatchedBy(func(agent *model.Agent) bool {
+			return agent.Hostname == "host-a" &&
+				agent.Version == "1.2.0" &&
+				agent.Labels["region"] == "eu"
+		}), mock.Anything, "tok-id").
+		Return(nil).
+		Once()
+
+	service := NewAgentService(mockRepo, nil, verifyEnrollment(), time.Minute, 5*time.Minute)
+	_, _, err := service.Register(model.AgentAuth{EnrollmentToken: "tok"}, &model.Agent{
+		Hostname: "host-a",
+		Version:  "1.2.0",
+		Labels:   map[string]string{"region": "eu"},
+	})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAgentService_Get_Status(t *testing.T) {
+
+	now := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		lastSeen time.Duration
+		expected string
+	}{
+		{"healthy", 30 * time.Second, model.AgentStatusHealthy},
+		{"stale", 2 * time.Minute, model.AgentStatusStale},
+		{"dead", 10 * time.Minute, model.AgentStatusDead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.AgentRepository)
+			seen := now.Add(-tt.lastSeen)
+
+			mockRepo.
+				On("GetByID", "agent-1").
+				Return(&model.Agent{ID: "agent-1", LastSeenAt: &seen}, nil).
+				Once()
+
+			svc := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute).(*agentService)
+			svc.now = func() time.Time { return now }
+
+			agent, err := svc.Get("agent-1")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, agent.Status)
+		})
+	}
+}
+
+func TestAgentService_RecordPoll(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+
+	mockRepo.
+		On("RecordPoll", "agent-1", 3).
+		Return(nil).
+		Once()
+
+	service := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute)
+
+	assert.NoError(t, service.RecordPoll("agent-1", 3))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAgentService_RecordPoll_Throttled(t *testing.T) {
+
+	now := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	mockRepo := new(mocks.AgentRepository)
+
+	mockRepo.On("RecordPoll", "agent-1", 3).Return(nil).Times(3)
+	mockRepo.On("RecordPoll", "agent-1", 4).Return(nil).Once()
+
+	service := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute).(*agentService)
+	service.now = func() time.Time { return now }
+
+	assert.NoError(t, service.RecordPoll("agent-1", 3))
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, service.RecordPoll("agent-1", 3))
+	// A new version is written right away.
+	assert.NoError(t, service.RecordPoll("agent-1", 4))
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, service.RecordPoll("agent-1", 3))
+	now = now.Add(14 * time.Second)
+	assert.NoError(t, service.RecordPoll("agent-1", 3))
+	// A quarter of the stale threshold has passed.
+	now = now.Add(time.Second)
+	assert.NoError(t, service.RecordPoll("agent-1", 3))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAgentService_List_StaleFilter(t *testing.T) {
+
+	now := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	staleCutoff := now.Add(-time.Minute)
+	deadCutoff := now.Add(-5 * time.Minute)
+	seen := now.Add(-2 * time.Minute)
+
+	matchesBounds := func(f model.AgentFilter) bool {
+		return f.Namespace == "prod" &&
+			f.SeenSince != nil && f.SeenSince.Equal(deadCutoff) &&
+			f.SeenBefore != nil && f.SeenBefore.Equal(staleCutoff)
+	}
+
+	mockRepo := new(mocks.AgentRepository)
+
+	mockRepo.
+		On("Count", mock.MatchedBy(matchesBounds)).
+		Return(1, nil).
+		Once()
+
+	mockRepo.
+		On("List", mock.MatchedBy(func(f model.AgentFilter) bool {
+			return matchesBounds(f) && f.Limit == 10 && f.Offset == 0
+		})).
+		Return([]model.Agent{{ID: "agent-1", LastSeenAt: &seen}}, nil).
+		Once()
+
+	svc := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute).(*agentService)
+	svc.now = func() time.Time { return now }
+
+	agents, total, err := svc.List("prod", model.AgentStatusStale, 10, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Len(t, agents, 1)
+	assert.Equal(t, model.AgentStatusStale, agents[0].Status)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAgentService_List_CountError(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+	expectedErr := errors.New("database error")
+
+	mockRepo.
+		On("Count", model.AgentFilter{}).
+		Return(0, expectedErr).
+		Once()
+
+	service := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute)
+	agents, total, err := service.List("", "", 20, 0)
+
+	assert.Nil(t, agents)
+	assert.Equal(t, 0, total)
+	assert.Equal(t, expectedErr, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAgentService_Count_HealthyFilter(t *testing.T) {
+
+	now := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	staleCutoff := now.Add(-time.Minute)
+
+	mockRepo := new(mocks.AgentRepository)
+	mockRepo.
+		On("Count", mock.MatchedBy(func(f model.AgentFilter) bool {
+			return f.Namespace == "" &&
+				f.SeenSince != nil && f.SeenSince.Equal(staleCutoff) &&
+				f.SeenBefore == nil && f.Limit == 0
 		})).
+		Return(3, nil).
+		Once()
+
+	svc := NewAgentService(mockRepo, nil, nil, time.Minute, 5*time.Minute).(*agentService)
+	svc.now = func() time.Time { return now }
+
+	total, err := svc.Count("", model.AgentStatusHealthy)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAgentService_ReportStatus(t *testing.T) {
+
+	mockStatuses := new(mocks.AgentStatusRepository)
+	report := &model.AgentConfigStatus{AgentID: "agent-1", Version: 3, Status: model.ApplyStatusApplied}
+
+	mockStatuses.
+		On("Save", report).
 		Return(nil).
 		Once()
 
-	service := NewAgentService(mockRepo)
-	id, err := service.Register("invalid-id")
+	service := NewAgentService(new(mocks.AgentRepository), mockStatuses, nil, time.Minute, 5*time.Minute)
+
+	assert.NoError(t, service.ReportStatus(report))
+	mockStatuses.AssertExpectations(t)
+}
+
+func TestAgentService_StatusSummary_Success(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+	mockStatuses := new(mocks.AgentStatusRepository)
+
+	failures := []model.AgentConfigStatus{
+		{AgentID: "agent-9", Version: 42, Status: model.ApplyStatusFailed, Error: "worker unreachable"},
+	}
+
+	mockStatuses.
+		On("CountByStatus", 42).
+		Return(map[string]int{model.ApplyStatusApplied: 118, model.ApplyStatusFailed: 1}, nil).
+		Once()
+	mockRepo.
+		On("Count", model.AgentFilter{Namespace: "prod"}).
+		Return(120, nil).
+		Once()
+	mockStatuses.
+		On("ListByStatus", 42, model.ApplyStatusFailed, maxStatusFailures).
+		Return(failures, nil).
+		Once()
+
+	service := NewAgentService(mockRepo, mockStatuses, nil, time.Minute, 5*time.Minute)
+	summary, err := service.StatusSummary("prod", 42)
 
 	assert.NoError(t, err)
-	assert.NotEmpty(t, id)
-	assert.NotEqual(t, "invalid-id", id)
+	assert.Equal(t, &model.ConfigStatusSummary{
+		Version:   42,
+		Namespace: "prod",
+		Agents:    120,
+		Applied:   118,
+		Failed:    1,
+		Pending:   1,
+		Failures:  failures,
+	}, summary)
 
 	mockRepo.AssertExpectations(t)
+	mockStatuses.AssertExpectations(t)
+}
+
+func TestAgentService_StatusSummary_PendingNeverNegative(t *testing.T) {
+
+	mockRepo := new(mocks.AgentRepository)
+	mockStatuses := new(mocks.AgentStatusRepository)
+
+	mockStatuses.
+		On("CountByStatus", 5).
+		Return(map[string]int{model.ApplyStatusApplied: 3}, nil).
+		Once()
+	mockRepo.
+		On("Count", model.AgentFilter{Namespace: "default"}).
+		Return(2, nil).
+		Once()
+	mockStatuses.
+		On("ListByStatus", 5, model.ApplyStatusFailed, maxStatusFailures).
+		Return([]model.AgentConfigStatus{}, nil).
+		Once()
+
+	service := NewAgentService(mockRepo, mockStatuses, nil, time.Minute, 5*time.Minute)
+	summary, err := service.StatusSummary("default", 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, summary.Pending)
+}
+
+func TestAgentService_StatusSummary_CountError(t *testing.T) {
+
+	mockStatuses := new(mocks.AgentStatusRepository)
+	expectedErr := errors.New("database error")
+
+	mockStatuses.
+		On("CountByStatus", 5).
+		Return(nil, expectedErr).
+		Once()
+
+	service := NewAgentService(new(mocks.AgentRepository), mockStatuses, nil, time.Minute, 5*time.Minute)
+	summary, err := service.StatusSummary("default", 5)
+
+	assert.Nil(t, summary)
+	assert.Equal(t, expectedErr, err)
 }
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"controller/internal/model"
+	"controller/internal/repository"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidAPIKey is returned by Authenticate for unknown, revoked or expired
+// keys.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// ErrReservedAPIKeyName is returned by Create for the names of the bootstrap
+// keys, whether or not they are configured.
+var ErrReservedAPIKeyName = errors.New("api key name reserved")
+
+type APIKeyService interface {
+	// Create mints a key and returns it together with its plain token, which
+	// is not stored and cannot be recovered later.
+	Create(name string, scopes []string, expiresAt *time.Time) (*model.APIKey, string, error)
+	List() ([]model.APIKey, error)
+	Revoke(id string) error
+	Authenticate(token string) (*model.APIKey, error)
+}
+
+type apiKeyService struct {
+	repo      repository.APIKeyRepository
+	bootstrap map[string]*model.APIKey
+	now       func() time.Time
+}
+
+// NewAPIKeyService authenticates keys stored in r as well as the bootstrap
+// keys, which map a plain token from the environment to its identity.
+func NewAPIKeyService(r repository.APIKeyRepository, bootstrap map[string]*model.APIKey) APIKeyService {
+	return &apiKeyService{
+		repo:      r,
+		bootstrap: bootstrap,
+		now:       time.Now,
+	}
+}
+
+func (s *apiKeyService) Create(name string, scopes []string, expiresAt *time.Time) (*model.APIKey, string, error) {
+	if name == model.BootstrapAdminKeyName || name == model.BootstrapAgentKeyName {
+		return nil, "", ErrReservedAPIKeyName
+	}
+
+	plain, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &model.APIKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		Hash:      hashSecret(plain),
+	}
+	if err := s.repo.Create(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, key.ID + "." + plain, nil
+}
+
+func (s *apiKeyService) List() ([]model.APIKey, error) {
+	return s.repo.List()
+}
+
+func (s *apiKeyService) Revoke(id string) error {
+	return s.repo.Revoke(id)
+}
+
+// Authenticate resolves a token of the form "<key id>.<secret>", or one of the
+// bootstrap tokens, to its key.
+func (s *apiKeyService) Authenticate(token string) (*model.APIKey, error) {
+	if token == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	for plain, key := range s.bootstrap {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(plain)) == 1 {
+			return key, nil
+		}
+	}
+
+	id, secret, ok := splitToken(token)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if !key.Usable(s.now()) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return key, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	mocks "controller/internal/mocks/repository"
+	"controller/internal/model"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyService_Create_StoresHashOnly(t *testing.T) {
+	mockRepo := new(mocks.APIKeyRepository)
+
+	var stored *model.APIKey
+	mockRepo.On("Create", mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(*model.APIKey) }).
+		Return(nil).
+		Once()
+
+	service := NewAPIKeyService(mockRepo, nil)
+
+	key, token, err := service.Create("ci", []string{model.ScopeWriteConfig}, nil)
+	require.NoError(t, err)
+
+	id, secret, ok := strings.Cut(token, ".")
+	require.True(t, ok)
+	assert.Equal(t, key.ID, id)
+	assert.Equal(t, hashSecret(secret), stored.Hash)
+	assert.NotContains(t, stored.Hash, secret)
+	assert.Equal(t, "ci", stored.Name)
+}
+
+func TestAPIKeyService_Create_ReservedName(t *testing.T) {
+	mockRepo := new(mocks.APIKeyRepository)
+
+	// AGENT_API_KEY is not configured, yet its name stays reserved.
+	service := NewAPIKeyService(mockRepo, map[string]*model.APIKey{
+		"admin-secret": {Name: model.BootstrapAdminKeyName, Scopes: []string{model.ScopeAdmin}},
+	})
+
+	for _, name := range []string{model.BootstrapAdminKeyName, model.BootstrapAgentKeyName} {
+		_, _, err := service.Create(name, []string{model.ScopeAdmin}, nil)
+		assert.True(t, errors.Is(err, ErrReservedAPIKeyName), name)
+	}
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestAPIKeyService_Authenticate_Bootstrap(t *testing.T) {
+	mockRepo := new(mocks.APIKeyRepository)
+	admin := &model.APIKey{Name: "admin", Scopes: []string{model.ScopeAdmin}}
+
+	service := NewAPIKeyService(mockRepo, map[string]*model.APIKey{"admin-secret": admin})
+
+	key, err := service.Authenticate("admin-secret")
+	assert.NoError(t, err)
+	assert.Same(t, admin, key)
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything)
+}
+
+func TestAPIKeyService_Authenticate_StoredKey(t *testing.T) {
+	id := uuid.NewString()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		token   string
+		key     *model.APIKey
+		wantErr bool
+	}{
+		{name: "valid", token: id + ".s3cret", key: &model.APIKey{ID: id, Hash: hashSecret("s3cret")}},
+		{name: "not yet expired", token: id + ".s3cret", key: &model.APIKey{ID: id, Hash: hashSecret("s3cret"), ExpiresAt: &future}},
+		{name: "wrong secret", token: id + ".guess", key: &model.APIKey{ID: id, Hash: hashSecret("s3cret")}, wantErr: true},
+		{name: "revoked", token: id + ".s3cret", key: &model.APIKey{ID: id, Hash: hashSecret("s3cret"), RevokedAt: &past}, wantErr: true},
+		{name: "expired", token: id + ".s3cret", key: &model.APIKey{ID: id, Hash: hashSecret("s3cret"), ExpiresAt: &past}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.APIKeyRepository)
+			mockRepo.On("GetByID", id).Return(tt.key, nil).Once()
+
+			service := NewAPIKeyService(mockRepo, nil)
+
+			key, err := service.Authenticate(tt.token)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidAPIKey))
+				assert.Nil(t, key)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, id, key.ID)
+		})
+	}
+}
+
+func TestAPIKeyService_Authenticate_Malformed(t *testing.T) {
+	mockRepo := new(mocks.APIKeyRepository)
+	service := NewAPIKeyService(mockRepo, nil)
+
+	for _, token := range []string{"", "no-dot", "not-a-uuid.secret"} {
+		_, err := service.Authenticate(token)
+		assert.True(t, errors.Is(err, ErrInvalidAPIKey), token)
+	}
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything)
+}
+
+func TestAPIKeyService_Authenticate_UnknownKey(t *testing.T) {
+	mockRepo := new(mocks.APIKeyRepository)
+	id := uuid.NewString()
+	mockRepo.On("GetByID", id).Return(nil, sql.ErrNoRows).Once()
+
+	service := NewAPIKeyService(mockRepo, nil)
+
+	_, err := service.Authenticate(id + ".secret")
+	assert.True(t, errors.Is(err, ErrInvalidAPIKey))
+}
+
+func TestAPIKeyService_Authenticate_RepositoryError(t *testing.T) {
+	mockRepo := new(mocks.APIKeyRepository)
+	id := uuid.NewString()
+	mockRepo.On("GetByID", id).Return(nil, errors.New("db down")).Once()
+
+	service := NewAPIKeyService(mockRepo, nil)
+
+	_, err := service.Authenticate(id + ".secret")
+	assert.EqualError(t, err, "db down")
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"controller/internal/model"
+	"controller/internal/repository"
+)
+
+type AuditService interface {
+	Record(entry *model.AuditEntry) error
+	List(filter model.AuditFilter) ([]model.AuditEntry, int, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(r repository.AuditRepository) AuditService {
+	return &auditService{repo: r}
+}
+
+func (s *auditService) Record(entry *model.AuditEntry) error {
+	return s.repo.Save(entry)
+}
+
+func (s *auditService) List(filter model.AuditFilter) ([]model.AuditEntry, int, error) {
+	entries, err := s.repo.List(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.repo.Count(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	mocks "controller/internal/mocks/repository"
+	"controller/internal/model"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditService_List(t *testing.T) {
+	mockRepo := new(mocks.AuditRepository)
+	filter := model.AuditFilter{Actor: "admin", Limit: 20}
+
+	mockRepo.On("List", filter).Return([]model.AuditEntry{{ID: 1}}, nil).Once()
+	mockRepo.On("Count", filter).Return(5, nil).Once()
+
+	service := NewAuditService(mockRepo)
+
+	entries, total, err := service.List(filter)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, 5, total)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuditService_List_CountError(t *testing.T) {
+	mockRepo := new(mocks.AuditRepository)
+	filter := model.AuditFilter{Limit: 20}
+
+	mockRepo.On("List", filter).Return([]model.AuditEntry{}, nil).Once()
+	mockRepo.On("Count", filter).Return(0, errors.New("db down")).Once()
+
+	service := NewAuditService(mockRepo)
+
+	entries, total, err := service.List(filter)
+	assert.Error(t, err)
+	assert.Nil(t, entries)
+	assert.Zero(t, total)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"controller/internal/model"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// configDocument is the part of a version that is delivered to agents, in a
+// form that can be compared field by field.
+func configDocument(cfg *model.Config) (map[string]interface{}, error) {
+	doc := map[string]interface{}{
+		"namespace":             cfg.Namespace,
+		"url":                   cfg.URL,
+		"poll_interval_seconds": json.Number(strconv.Itoa(cfg.PollIntervalSeconds)),
+	}
+
+	if len(cfg.Data) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(cfg.Data))
+		// Keep numbers as written instead of rounding them through float64.
+		dec.UseNumber()
+
+		var data interface{}
+		if err := dec.Decode(&data); err != nil {
+			return nil, fmt.Errorf("decode data of version %d: %w", cfg.Version, err)
+		}
+		doc["data"] = data
+	}
+
+	if len(cfg.Secrets) > 0 {
+		secrets := make(map[string]interface{}, len(cfg.Secrets))
+		for name, value := range cfg.Secrets {
+			secrets[name] = value
+		}
+		doc["secrets"] = secrets
+	}
+
+	return doc, nil
+}
+
+// diffValues appends the changes turning from into to, descending into
+// objects and arrays so only the leaves that differ are reported.
+func diffValues(path string, from, to interface{}, changes []model.ConfigChange) []model.ConfigChange {
+	switch f := from.(type) {
+	case map[string]interface{}:
+		if t, ok := to.(map[string]interface{}); ok {
+			return diffObjects(path, f, t, changes)
+		}
+	case []interface{}:
+		if t, ok := to.([]interface{}); ok {
+			return diffArrays(path, f, t, changes)
+		}
+	}
+
+	if reflect.DeepEqual(from, to) {
+		return changes
+	}
+	return append(changes, model.ConfigChange{Op: model.DiffChanged, Path: path, From: from, To: to})
+}
+
+func diffObjects(path string, from, to map[string]interface{}, changes []model.ConfigChange) []model.ConfigChange {
+	keys := make([]string, 0, len(from)+len(to))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		f, inFrom := from[k]
+		t, inTo := to[k]
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+
+		switch {
+		case !inFrom:
+			changes = append(changes, model.ConfigChange{Op: model.DiffAdded, Path: child, To: t})
+		case !inTo:
+			changes = append(changes, model.ConfigChange{Op: model.DiffRemoved, Path: child, From: f})
+		default:
+			changes = diffValues(child, f, t, changes)
+		}
+	}
+	return changes
+}
+
+func diffArrays(path string, from, to []interface{}, changes []model.ConfigChange) []model.ConfigChange {
+	for i := 0; i < len(from) || i < len(to); i++ {
+		child := fmt.Sprintf("%s[%d]", path, i)
+
+		switch {
+		case i >= len(from):
+			changes = append(changes, model.ConfigChange{Op: model.DiffAdded, Path: child, To: to[i]})
+		case i >= len(to):
+			changes = append(changes, model.ConfigChange{Op: model.DiffRemoved, Path: child, From: from[i]})
+		default:
+			changes = diffValues(child, from[i], to[i], changes)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"controller/internal/model"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffObjects(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []model.ConfigChange
+	}{
+		{
+			name: "identical",
+			from: `{"a": 1, "b": {"c": [1, 2]}}`,
+			to:   `{"b": {"c": [1, 2]}, "a": 1}`,
+			want: []model.ConfigChange{},
+		},
+		{
+			name: "nested leaves",
+			from: `{"flags": {"beta": false, "old": "x"}, "retries": 3}`,
+			to:   `{"flags": {"beta": true, "new": 1}, "retries": 3}`,
+			want: []model.ConfigChange{
+				{Op: model.DiffChanged, Path: "flags.beta", From: false, To: true},
+				{Op: model.DiffAdded, Path: "flags.new", To: json.Number("1")},
+				{Op: model.DiffRemoved, Path: "flags.old", From: "x"},
+			},
+		},
+		{
+			name: "arrays by index",
+			from: `{"hosts": ["a", "b", "c"]}`,
+			to:   `{"hosts": ["a", "x"]}`,
+			want: []model.ConfigChange{
+				{Op: model.DiffChanged, Path: "hosts[1]", From: "b", To: "x"},
+				{Op: model.DiffRemoved, Path: "hosts[2]", From: "c"},
+			},
+		},
+		{
+			name: "type change",
+			from: `{"limit": {"max": 1}}`,
+			to:   `{"limit": 5}`,
+			want: []model.ConfigChange{
+				{Op: model.DiffChanged, Path: "limit", From: map[string]interface{}{"max": json.Number("1")}, To: json.Number("5")},
+			},
+		},
+		{
+			name: "numbers keep precision",
+			from: `{"id": 9007199254740993}`,
+			to:   `{"id": 9007199254740992}`,
+			want: []model.ConfigChange{
+				{Op: model.DiffChanged, Path: "id", From: json.Number("9007199254740993"), To: json.Number("9007199254740992")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, err := configDocument(&model.Config{Data: json.RawMessage(tt.from)})
+			require.NoError(t, err)
+			to, err := configDocument(&model.Config{Data: json.RawMessage(tt.to)})
+			require.NoError(t, err)
+
+			got := diffValues("", from["data"], to["data"], []model.ConfigChange{})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConfigDocument_InvalidData(t *testing.T) {
+	_, err := configDocument(&model.Config{Version: 3, Data: json.RawMessage(`{`)})
+	assert.Error(t, err)
+}
@@ -1,74 +1,676 @@
 package service
 
 import (
+	"context"
+	"controller/internal/metrics"
 	"controller/internal/model"
 	"controller/internal/repository"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
 	"sync"
+	"time"
+
+	"github.com/mrheza/distributed-config-management/shared/signing"
 )
 
+// ErrNotScheduled is returned when canceling a version that is not waiting for
+// its activation time.
+var ErrNotScheduled = errors.New("config version is not scheduled")
+
+// ErrNotPendingApproval is returned when reviewing a version that is not a
+// draft waiting for approval.
+var ErrNotPendingApproval = errors.New("config version is not pending approval")
+
+// ErrSelfApproval is returned when the creator of a draft tries to review it.
+var ErrSelfApproval = errors.New("config version must be reviewed by another identity")
+
+// activationRetry re-arms an activation the database does not consider due
+// yet, e.g. when the clocks of this instance and the database drift apart.
+const activationRetry = time.Second
+
 type ConfigService interface {
-	GetLatest() (*model.Config, error)
-	Create(url string, pollIntervalSeconds int) error
+	GetLatest(namespace string) (*model.Config, error)
+	GetByVersion(version int) (*model.Config, error)
+	List(namespace string, limit, offset int) ([]model.Config, int, error)
+	Create(cfg *model.Config, expectedVersion int) error
+	Rollback(version int, createdBy string) (*model.Config, error)
+	ListScheduled(namespace string) ([]model.Config, error)
+	Cancel(version int) (*model.Config, error)
+	Approve(version int, reviewer string) (*model.Config, error)
+	Reject(version int, reviewer string) (*model.Config, error)
+	Diff(from, to int) (*model.ConfigDiff, error)
+	Changed(namespace string) <-chan struct{}
+	Notify(namespace string)
+	Sync(ctx context.Context, changes <-chan string, resyncInterval time.Duration)
 }
 
 type configService struct {
-	repo repository.ConfigRepository
+	repo       repository.ConfigRepository
+	signingKey ed25519.PrivateKey
+	secretsKey []byte
+	// approval lists the namespaces whose new versions are drafts until
+	// another identity approves them.
+	approval map[string]bool
+	webhooks WebhookService
 
-	mu       sync.RWMutex
-	latest   *model.Config
-	hasCache bool
+	// latest caches the newest config per namespace; changed holds one channel
+	// per watched namespace, closed when a new version is created there.
+	// activations holds a timer per scheduled version that refreshes its
+	// namespace once the version becomes active.
+	mu          sync.RWMutex
+	latest      map[string]*model.Config
+	changed     map[string]chan struct{}
+	activations map[int]*time.Timer
 }
 
-func NewConfigService(r repository.ConfigRepository) ConfigService {
-	return &configService{repo: r}
+// NewConfigService signs every version it reads with signingKey; a nil key
+// leaves them unsigned. secretsKey encrypts config secrets at rest; without it
+// configs cannot carry secrets. New versions of approvalNamespaces need
+// approval before they are served. Every new, approved and activated version
+// is announced to webhooks, unless webhooks is nil.
+func NewConfigService(
+	r repository.ConfigRepository,
+	signingKey ed25519.PrivateKey,
+	secretsKey []byte,
+	approvalNamespaces []string,
+	webhooks WebhookService,
+) ConfigService {
+	approval := make(map[string]bool, len(approvalNamespaces))
+	for _, namespace := range approvalNamespaces {
+		approval[normalizeNamespace(namespace)] = true
+	}
+
+	return &configService{
+		repo:        r,
+		signingKey:  signingKey,
+		secretsKey:  secretsKey,
+		approval:    approval,
+		webhooks:    webhooks,
+		latest:      make(map[string]*model.Config),
+		changed:     make(map[string]chan struct{}),
+		activations: make(map[int]*time.Timer),
+	}
 }
 
-func (s *configService) GetLatest() (*model.Config, error) {
+func (s *configService) GetLatest(namespace string) (*model.Config, error) {
+	namespace = normalizeNamespace(namespace)
+
 	s.mu.RLock()
-	if s.hasCache && s.latest != nil {
-		cached := cloneConfig(s.latest)
+	if cached, ok := s.latest[namespace]; ok && cached != nil {
+		out := cloneConfig(cached)
 		s.mu.RUnlock()
-		return cached, nil
+		return out, nil
 	}
 	s.mu.RUnlock()
 
-	cfg, err := s.repo.GetLatest()
+	cfg, err := s.repo.GetLatest(namespace)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.load(cfg); err != nil {
+		return nil, err
+	}
 
 	s.mu.Lock()
-	s.latest = cloneConfig(cfg)
-	s.hasCache = true
+	s.latest[namespace] = cloneConfig(cfg)
 	s.mu.Unlock()
+	metrics.ConfigVersion.WithLabelValues(namespace).Set(float64(cfg.Version))
 
 	return cloneConfig(cfg), nil
 }
 
-func (s *configService) Create(url string, pollIntervalSeconds int) error {
-	if err := s.repo.Create(url, pollIntervalSeconds); err != nil {
+func (s *configService) GetByVersion(version int) (*model.Config, error) {
+	cfg, err := s.repo.GetByVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (s *configService) List(namespace string, limit, offset int) ([]model.Config, int, error) {
+	configs, err := s.repo.List(namespace, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.repo.Count(namespace)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range configs {
+		if err := s.decryptSecrets(&configs[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return configs, total, nil
+}
+
+// Create stores a new version, encrypting its secrets. A positive
+// expectedVersion fails with repository.ErrVersionConflict unless it is still
+// the namespace's latest. In namespaces requiring approval the version is
+// stored as a draft that is not served until Approve.
+func (s *configService) Create(cfg *model.Config, expectedVersion int) error {
+	return s.create(cfg, expectedVersion, 0)
+}
+
+// create stores cfg; a positive restoredVersion marks it as a rollback to that
+// version for webhooks.
+func (s *configService) create(cfg *model.Config, expectedVersion, restoredVersion int) error {
+	cfg.Namespace = normalizeNamespace(cfg.Namespace)
+	if s.approval[cfg.Namespace] {
+		cfg.Approval = &model.Approval{Status: model.ApprovalPending}
+	}
+	if len(cfg.Secrets) > 0 {
+		if s.secretsKey == nil {
+			return ErrSecretsDisabled
+		}
+		sealed, err := sealSecrets(s.secretsKey, cfg.Secrets)
+		if err != nil {
+			return err
+		}
+		cfg.EncryptedSecrets = sealed
+	}
+
+	if err := s.repo.Create(cfg, expectedVersion, s.createdEvents(cfg, restoredVersion)); err != nil {
 		return err
 	}
+	defer s.notify(cfg.Namespace)
+	s.signalWebhooks()
+
+	if cfg.Scheduled(time.Now()) {
+		s.scheduleActivation(cfg)
+	}
 
-	latest, err := s.repo.GetLatest()
+	latest, err := s.repo.GetLatest(cfg.Namespace)
+	if err == nil {
+		err = s.load(latest)
+	}
 	if err != nil {
+		// Drop the stale entry so woken waiters read the new version from the DB.
+		s.mu.Lock()
+		delete(s.latest, cfg.Namespace)
+		s.mu.Unlock()
 		return err
 	}
 
 	// Config changed, refresh cache immediately with the latest DB value.
 	s.mu.Lock()
-	s.latest = cloneConfig(latest)
-	s.hasCache = true
+	s.latest[cfg.Namespace] = cloneConfig(latest)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// ListScheduled returns the versions waiting for activation, soonest first.
+func (s *configService) ListScheduled(namespace string) ([]model.Config, error) {
+	configs, err := s.repo.ListScheduled(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range configs {
+		if err := s.decryptSecrets(&configs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return configs, nil
+}
+
+// Cancel withdraws a scheduled version before it activates. It fails with
+// ErrNotScheduled once the version is active or already canceled.
+func (s *configService) Cancel(version int) (*model.Config, error) {
+	cfg, err := s.repo.GetByVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Scheduled(time.Now()) {
+		return nil, ErrNotScheduled
+	}
+
+	if err := s.repo.Cancel(version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Activated in the meantime.
+			return nil, ErrNotScheduled
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if timer, ok := s.activations[version]; ok {
+		timer.Stop()
+		delete(s.activations, version)
+	}
+	s.mu.Unlock()
+
+	return s.GetByVersion(version)
+}
+
+// Approve makes a draft servable. reviewer must differ from the identity that
+// created it.
+func (s *configService) Approve(version int, reviewer string) (*model.Config, error) {
+	return s.review(version, model.ApprovalApproved, reviewer)
+}
+
+// Reject discards a draft for good; the namespace accepts new versions again.
+func (s *configService) Reject(version int, reviewer string) (*model.Config, error) {
+	return s.review(version, model.ApprovalRejected, reviewer)
+}
+
+func (s *configService) review(version int, status, reviewer string) (*model.Config, error) {
+	cfg, err := s.repo.GetByVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.PendingApproval() {
+		return nil, ErrNotPendingApproval
+	}
+	if reviewer == cfg.CreatedBy {
+		return nil, ErrSelfApproval
+	}
+
+	var events []model.WebhookEvent
+	if status == model.ApprovalApproved {
+		events = s.approvedEvents(cfg, reviewer)
+	}
+	if err := s.repo.Review(version, status, reviewer, events); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Reviewed or canceled in the meantime.
+			return nil, ErrNotPendingApproval
+		}
+		return nil, err
+	}
+	if len(events) > 0 {
+		s.signalWebhooks()
+	}
+
+	if status == model.ApprovalRejected {
+		s.mu.Lock()
+		if timer, ok := s.activations[version]; ok {
+			timer.Stop()
+			delete(s.activations, version)
+		}
+		s.mu.Unlock()
+	} else {
+		s.refresh(cfg.Namespace, false)
+	}
+
+	return s.GetByVersion(version)
+}
+
+// scheduleActivation refreshes the namespace of a scheduled version when it
+// becomes active, so the cached latest version and the ETag served to agents
+// flip right then and watchers are woken.
+func (s *configService) scheduleActivation(cfg *model.Config) {
+	namespace, version := cfg.Namespace, cfg.Version
+	delay := time.Until(*cfg.ActivateAt)
+	if delay <= 0 {
+		delay = activationRetry
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.activations[version]; ok {
+		return
+	}
+	s.activations[version] = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		delete(s.activations, version)
+		s.mu.Unlock()
+
+		log.Printf("event=config_activation_due namespace=%s version=%d", namespace, version)
+		s.refresh(namespace, false)
+	})
+}
+
+// scheduleActivations arms timers for the scheduled versions of namespace, or
+// of all namespaces when it is empty, including those created by other
+// controller instances.
+func (s *configService) scheduleActivations(namespace string) {
+	scheduled, err := s.repo.ListScheduled(namespace)
+	if err != nil {
+		log.Printf("event=config_schedule_load_failed namespace=%s err=%v", namespace, err)
+		return
+	}
+
+	for i := range scheduled {
+		s.scheduleActivation(&scheduled[i])
+	}
+}
+
+// Changed returns a channel that is closed once a newer version is created in
+// namespace. Callers should subscribe before reading the latest version so no
+// change can slip in between.
+func (s *configService) Changed(namespace string) <-chan struct{} {
+	namespace = normalizeNamespace(namespace)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.changed[namespace]
+	if !ok {
+		ch = make(chan struct{})
+		s.changed[namespace] = ch
+	}
+	return ch
+}
+
+// Sync keeps the cache coherent with versions created by other controller
+// instances. Every namespace received on changes is re-read, and all cached or
+// watched namespaces are re-read every resyncInterval as a safety net against
+// lost notifications. Scheduled versions are picked up the same way so each
+// instance activates them on time. It returns once ctx is done.
+func (s *configService) Sync(ctx context.Context, changes <-chan string, resyncInterval time.Duration) {
+	resync := time.NewTicker(resyncInterval)
+	defer resync.Stop()
+
+	s.scheduleActivations("")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case namespace, ok := <-changes:
+			if !ok {
+				// Notifications stopped; keep going on periodic re-reads.
+				changes = nil
+				continue
+			}
+			s.scheduleActivations(namespace)
+			if namespace == "" {
+				s.refreshAll()
+				continue
+			}
+			// Rollout changes are announced on the same channel and alter what
+			// agents are served without a new version, so always wake watchers.
+			s.refresh(namespace, true)
+		case <-resync.C:
+			s.scheduleActivations("")
+			s.refreshAll()
+		}
+	}
+}
+
+// refresh re-reads the latest version of namespace and wakes its watchers when
+// the version differs from the cached one, or unconditionally with wake.
+func (s *configService) refresh(namespace string, wake bool) {
+	latest, err := s.repo.GetLatest(namespace)
+	if err == nil {
+		err = s.load(latest)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("event=config_cache_refresh_failed namespace=%s err=%v", namespace, err)
+		// Serve from the DB until the next successful refresh.
+		s.mu.Lock()
+		delete(s.latest, namespace)
+		s.mu.Unlock()
+		return
+	}
+
+	s.mu.Lock()
+	cached := s.latest[namespace]
+	if latest == nil {
+		delete(s.latest, namespace)
+	} else {
+		s.latest[namespace] = cloneConfig(latest)
+	}
 	s.mu.Unlock()
 
+	if latest == nil {
+		metrics.ConfigVersion.DeleteLabelValues(namespace)
+	} else {
+		metrics.ConfigVersion.WithLabelValues(namespace).Set(float64(latest.Version))
+	}
+
+	if wake || (latest != nil && (cached == nil || cached.Version != latest.Version)) {
+		s.notify(namespace)
+	}
+}
+
+func (s *configService) refreshAll() {
+	s.mu.RLock()
+	namespaces := make(map[string]struct{}, len(s.latest)+len(s.changed))
+	for namespace := range s.latest {
+		namespaces[namespace] = struct{}{}
+	}
+	for namespace := range s.changed {
+		namespaces[namespace] = struct{}{}
+	}
+	s.mu.RUnlock()
+
+	for namespace := range namespaces {
+		s.refresh(namespace, false)
+	}
+}
+
+// Notify wakes the watchers of namespace so they re-read the config they are
+// served, e.g. after a rollout changed.
+func (s *configService) Notify(namespace string) {
+	s.notify(normalizeNamespace(namespace))
+}
+
+func (s *configService) notify(namespace string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ch, ok := s.changed[namespace]; ok {
+		close(ch)
+		delete(s.changed, namespace)
+	}
+}
+
+// Diff compares the stored documents of two versions: namespace, url,
+// poll_interval_seconds, data and secrets. Secret values are compared in
+// clear, so callers must redact them before showing the changes.
+func (s *configService) Diff(from, to int) (*model.ConfigDiff, error) {
+	docs := make([]map[string]interface{}, 0, 2)
+	for _, version := range []int{from, to} {
+		cfg, err := s.repo.GetByVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.decryptSecrets(cfg); err != nil {
+			return nil, err
+		}
+
+		doc, err := configDocument(cfg)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return &model.ConfigDiff{
+		From:    from,
+		To:      to,
+		Changes: diffObjects("", docs[0], docs[1], []model.ConfigChange{}),
+	}, nil
+}
+
+// Rollback creates a new version that copies the content of an older one, so
+// agents move forward to it through the regular ETag flow. Like any other new
+// version it is a draft in namespaces requiring approval.
+func (s *configService) Rollback(version int, createdBy string) (*model.Config, error) {
+	target, err := s.repo.GetByVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decryptSecrets(target); err != nil {
+		return nil, err
+	}
+
+	cfg := &model.Config{
+		Namespace:           target.Namespace,
+		URL:                 target.URL,
+		PollIntervalSeconds: target.PollIntervalSeconds,
+		Data:                target.Data,
+		Secrets:             target.Secrets,
+		CreatedBy:           createdBy,
+	}
+	if err := s.create(cfg, 0, version); err != nil {
+		return nil, err
+	}
+
+	if cfg.Approval != nil {
+		return s.GetByVersion(cfg.Version)
+	}
+	return s.GetLatest(target.Namespace)
+}
+
+// createdEvents returns the webhook events of a new version, which the
+// repository queues with the version itself. A scheduled version that needs no
+// approval also gets its activation event, delivered at its activation time.
+func (s *configService) createdEvents(cfg *model.Config, restoredVersion int) []model.WebhookEvent {
+	if s.webhooks == nil {
+		return nil
+	}
+
+	event := model.WebhookEvent{
+		Event:      model.WebhookEventConfigCreated,
+		Namespace:  cfg.Namespace,
+		CreatedBy:  cfg.CreatedBy,
+		ActivateAt: cfg.ActivateAt,
+		OccurredAt: time.Now().UTC(),
+	}
+	if restoredVersion > 0 {
+		event.Event = model.WebhookEventConfigRolledBack
+		event.RestoredVersion = restoredVersion
+	}
+	if cfg.Approval != nil {
+		event.Approval = cfg.Approval.Status
+		return []model.WebhookEvent{event}
+	}
+
+	events := []model.WebhookEvent{event}
+	if cfg.Scheduled(event.OccurredAt) {
+		events = append(events, activatedEvent(cfg))
+	}
+	return events
+}
+
+// approvedEvents returns the webhook events of approving the draft cfg. An
+// approved draft that is still scheduled also gets its activation event.
+func (s *configService) approvedEvents(cfg *model.Config, reviewer string) []model.WebhookEvent {
+	if s.webhooks == nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	events := []model.WebhookEvent{{
+		Event:      model.WebhookEventConfigApproved,
+		Namespace:  cfg.Namespace,
+		Version:    cfg.Version,
+		CreatedBy:  cfg.CreatedBy,
+		ActivateAt: cfg.ActivateAt,
+		Approval:   model.ApprovalApproved,
+		ReviewedBy: reviewer,
+		OccurredAt: now,
+	}}
+	if cfg.Scheduled(now) {
+		events = append(events, activatedEvent(cfg))
+	}
+	return events
+}
+
+func activatedEvent(cfg *model.Config) model.WebhookEvent {
+	return model.WebhookEvent{
+		Event:      model.WebhookEventConfigActivated,
+		Namespace:  cfg.Namespace,
+		Version:    cfg.Version,
+		CreatedBy:  cfg.CreatedBy,
+		ActivateAt: cfg.ActivateAt,
+		OccurredAt: cfg.ActivateAt.UTC(),
+	}
+}
+
+// signalWebhooks sends the events just queued without waiting for the next
+// delivery round.
+func (s *configService) signalWebhooks() {
+	if s.webhooks != nil {
+		s.webhooks.Signal()
+	}
+}
+
+// load prepares a version read from the repository for serving.
+func (s *configService) load(cfg *model.Config) error {
+	if err := s.decryptSecrets(cfg); err != nil {
+		return err
+	}
+	return s.sign(cfg)
+}
+
+// decryptSecrets sets cfg.Secrets from its at-rest envelope.
+func (s *configService) decryptSecrets(cfg *model.Config) error {
+	if len(cfg.EncryptedSecrets) == 0 {
+		return nil
+	}
+	if s.secretsKey == nil {
+		return ErrSecretsDisabled
+	}
+
+	secrets, err := openSecrets(s.secretsKey, cfg.EncryptedSecrets)
+	if err != nil {
+		return err
+	}
+	cfg.Secrets = secrets
+	return nil
+}
+
+// sign sets the signature agents and workers verify before applying cfg.
+func (s *configService) sign(cfg *model.Config) error {
+	if s.signingKey == nil {
+		return nil
+	}
+
+	signature, err := signing.Sign(s.signingKey, signing.Payload{
+		Version:             cfg.Version,
+		Namespace:           cfg.Namespace,
+		URL:                 cfg.URL,
+		PollIntervalSeconds: cfg.PollIntervalSeconds,
+		Data:                cfg.Data,
+		Secrets:             cfg.Secrets,
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.Signature = signature
 	return nil
 }
 
+func normalizeNamespace(namespace string) string {
+	if namespace == "" {
+		return model.DefaultNamespace
+	}
+	return namespace
+}
+
 func cloneConfig(c *model.Config) *model.Config {
 	if c == nil {
 		return nil
 	}
 
 	cp := *c
+	if c.Data != nil {
+		cp.Data = append(json.RawMessage(nil), c.Data...)
+	}
+	if c.Secrets != nil {
+		cp.Secrets = make(map[string]string, len(c.Secrets))
+		for k, v := range c.Secrets {
+			cp.Secrets[k] = v
+		}
+	}
+	if c.Approval != nil {
+		approval := *c.Approval
+		cp.Approval = &approval
+	}
 	return &cp
 }
@@ -1,13 +1,22 @@
 package service
 
 import (
+	"context"
 	mocks "controller/internal/mocks/repository"
+	serviceMocks "controller/internal/mocks/service"
 	"controller/internal/model"
+	"controller/internal/repository"
+	"crypto/ed25519"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/mrheza/distributed-config-management/shared/signing"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfigService_GetLatest_Success(t *testing.T) {
@@ -19,12 +28,12 @@ func TestConfigService_GetLatest_Success(t *testing.T) {
 		URL:     "https://example.com",
 	}
 
-	mockRepo.On("GetLatest").
+	mockRepo.On("GetLatest", "default").
 		Return(expected, nil).
 		Once()
 
-	service := NewConfigService(mockRepo)
-	result, err := service.GetLatest()
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	result, err := service.GetLatest("default")
 
 	assert.NoError(t, err)
 	assert.Equal(t, expected, result)
@@ -32,6 +41,45 @@ func TestConfigService_GetLatest_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestConfigService_SignsVersions(t *testing.T) {
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	mockRepo := new(mocks.ConfigRepository)
+	stored := &model.Config{
+		Version:             3,
+		Namespace:           "prod",
+		URL:                 "https://example.com",
+		PollIntervalSeconds: 30,
+		Data:                json.RawMessage(`{"mode": "fast"}`),
+	}
+	mockRepo.On("GetLatest", "prod").Return(cloneConfig(stored), nil).Once()
+	mockRepo.On("GetByVersion", 3).Return(cloneConfig(stored), nil).Once()
+
+	service := NewConfigService(mockRepo, priv, nil, nil, nil)
+
+	payload := signing.Payload{
+		Version:             3,
+		Namespace:           "prod",
+		URL:                 "https://example.com",
+		PollIntervalSeconds: 30,
+		// Verification must not depend on the whitespace of data.
+		Data: json.RawMessage(`{"mode":"fast"}`),
+	}
+
+	latest, err := service.GetLatest("prod")
+	require.NoError(t, err)
+	assert.NoError(t, signing.Verify(pub, payload, latest.Signature))
+
+	byVersion, err := service.GetByVersion(3)
+	require.NoError(t, err)
+	assert.Equal(t, latest.Signature, byVersion.Signature)
+
+	payload.URL = "https://attacker.example"
+	assert.ErrorIs(t, signing.Verify(pub, payload, latest.Signature), signing.ErrInvalidSignature)
+}
+
 func TestConfigService_GetLatest_UsesCacheAfterFirstFetch(t *testing.T) {
 	mockRepo := new(mocks.ConfigRepository)
 
@@ -41,14 +89,14 @@ func TestConfigService_GetLatest_UsesCacheAfterFirstFetch(t *testing.T) {
 		PollIntervalSeconds: 30,
 	}
 
-	mockRepo.On("GetLatest").
+	mockRepo.On("GetLatest", "default").
 		Return(expected, nil).
 		Once()
 
-	service := NewConfigService(mockRepo)
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
 
-	first, err1 := service.GetLatest()
-	second, err2 := service.GetLatest()
+	first, err1 := service.GetLatest("default")
+	second, err2 := service.GetLatest("default")
 
 	assert.NoError(t, err1)
 	assert.NoError(t, err2)
@@ -64,13 +112,13 @@ func TestConfigService_GetLatest_Error(t *testing.T) {
 
 	expectedErr := errors.New("database error")
 
-	mockRepo.On("GetLatest").
+	mockRepo.On("GetLatest", "default").
 		Return(nil, expectedErr).
 		Once()
 
-	service := NewConfigService(mockRepo)
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
 
-	result, err := service.GetLatest()
+	result, err := service.GetLatest("default")
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -83,13 +131,13 @@ func TestConfigService_GetLatest_NotFound(t *testing.T) {
 
 	mockRepo := new(mocks.ConfigRepository)
 
-	mockRepo.On("GetLatest").
+	mockRepo.On("GetLatest", "default").
 		Return(nil, sql.ErrNoRows).
 		Once()
 
-	service := NewConfigService(mockRepo)
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
 
-	result, err := service.GetLatest()
+	result, err := service.GetLatest("default")
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -102,23 +150,27 @@ func TestConfigService_Create_Success(t *testing.T) {
 
 	mockRepo := new(mocks.ConfigRepository)
 
-	url := "https://example.com"
-	pollIntervalSeconds := 30
+	input := &model.Config{
+		URL:                 "https://example.com",
+		PollIntervalSeconds: 30,
+		Data:                json.RawMessage(`{"feature":"on"}`),
+	}
 	latest := &model.Config{
 		Version:             1,
-		URL:                 url,
-		PollIntervalSeconds: pollIntervalSeconds,
+		URL:                 input.URL,
+		PollIntervalSeconds: input.PollIntervalSeconds,
+		Data:                input.Data,
 	}
 
-	mockRepo.On("Create", url, pollIntervalSeconds).
+	mockRepo.On("Create", input, 0, mock.Anything).
 		Return(nil).
 		Once()
-	mockRepo.On("GetLatest").
+	mockRepo.On("GetLatest", "default").
 		Return(latest, nil).
 		Once()
 
-	service := NewConfigService(mockRepo)
-	err := service.Create(url, pollIntervalSeconds)
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	err := service.Create(input, 0)
 
 	assert.NoError(t, err)
 
@@ -129,16 +181,15 @@ func TestConfigService_Create_Error(t *testing.T) {
 
 	mockRepo := new(mocks.ConfigRepository)
 
-	url := "https://example.com"
-	pollIntervalSeconds := 30
+	input := &model.Config{URL: "https://example.com", PollIntervalSeconds: 30}
 	expectedErr := errors.New("insert failed")
 
-	mockRepo.On("Create", url, pollIntervalSeconds).
+	mockRepo.On("Create", input, 0, mock.Anything).
 		Return(expectedErr).
 		Once()
 
-	service := NewConfigService(mockRepo)
-	err := service.Create(url, pollIntervalSeconds)
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	err := service.Create(input, 0)
 
 	assert.Error(t, err)
 	assert.Equal(t, expectedErr, err)
@@ -149,19 +200,18 @@ func TestConfigService_Create_Error(t *testing.T) {
 func TestConfigService_Create_GetLatestError(t *testing.T) {
 	mockRepo := new(mocks.ConfigRepository)
 
-	url := "https://example.com"
-	pollIntervalSeconds := 30
+	input := &model.Config{URL: "https://example.com", PollIntervalSeconds: 30}
 	expectedErr := errors.New("get latest failed")
 
-	mockRepo.On("Create", url, pollIntervalSeconds).
+	mockRepo.On("Create", input, 0, mock.Anything).
 		Return(nil).
 		Once()
-	mockRepo.On("GetLatest").
+	mockRepo.On("GetLatest", "default").
 		Return(nil, expectedErr).
 		Once()
 
-	service := NewConfigService(mockRepo)
-	err := service.Create(url, pollIntervalSeconds)
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	err := service.Create(input, 0)
 
 	assert.Error(t, err)
 	assert.Equal(t, expectedErr, err)
@@ -183,27 +233,968 @@ func TestConfigService_Create_InvalidatesCache(t *testing.T) {
 		PollIntervalSeconds: 60,
 	}
 
-	mockRepo.On("GetLatest").
+	mockRepo.On("GetLatest", "default").
 		Return(initial, nil).
 		Once()
-	mockRepo.On("Create", "https://example.com/v2", 60).
+	input := &model.Config{URL: "https://example.com/v2", PollIntervalSeconds: 60}
+	mockRepo.On("Create", input, 0, mock.Anything).
 		Return(nil).
 		Once()
-	mockRepo.On("GetLatest").
+	mockRepo.On("GetLatest", "default").
 		Return(latest, nil).
 		Once()
 
-	service := NewConfigService(mockRepo)
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
 
-	_, err := service.GetLatest()
+	_, err := service.GetLatest("default")
 	assert.NoError(t, err)
 
-	err = service.Create("https://example.com/v2", 60)
+	err = service.Create(input, 0)
 	assert.NoError(t, err)
 
-	cfg, err := service.GetLatest()
+	cfg, err := service.GetLatest("default")
 	assert.NoError(t, err)
 	assert.Equal(t, latest, cfg)
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestConfigService_List_Success(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	configs := []model.Config{
+		{Version: 2, URL: "https://example.com/v2", PollIntervalSeconds: 60},
+		{Version: 1, URL: "https://example.com/v1", PollIntervalSeconds: 30},
+	}
+
+	mockRepo.On("List", "", 20, 0).
+		Return(configs, nil).
+		Once()
+	mockRepo.On("Count", "").
+		Return(2, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	result, total, err := service.List("", 20, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, configs, result)
+	assert.Equal(t, 2, total)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_List_CountError(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	expectedErr := errors.New("count failed")
+
+	mockRepo.On("List", "", 20, 0).
+		Return([]model.Config{}, nil).
+		Once()
+	mockRepo.On("Count", "").
+		Return(0, expectedErr).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	result, total, err := service.List("", 20, 0)
+
+	assert.Equal(t, expectedErr, err)
+	assert.Nil(t, result)
+	assert.Equal(t, 0, total)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Rollback_Success(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	target := &model.Config{
+		Version:             1,
+		Namespace:           "default",
+		URL:                 "https://example.com/v1",
+		PollIntervalSeconds: 30,
+		Data:                json.RawMessage(`{"feature":"off"}`),
+	}
+	restored := &model.Config{
+		Version:             3,
+		URL:                 "https://example.com/v1",
+		PollIntervalSeconds: 30,
+		Data:                json.RawMessage(`{"feature":"off"}`),
+	}
+
+	mockRepo.On("GetByVersion", 1).
+		Return(target, nil).
+		Once()
+	mockRepo.On("Create", &model.Config{
+		Namespace:           "default",
+		URL:                 "https://example.com/v1",
+		PollIntervalSeconds: 30,
+		Data:                json.RawMessage(`{"feature":"off"}`),
+		CreatedBy:           "ops",
+	}, 0, mock.Anything).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "default").
+		Return(restored, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	cfg, err := service.Rollback(1, "ops")
+
+	assert.NoError(t, err)
+	assert.Equal(t, restored, cfg)
+
+	// cache is refreshed by the rollback, so no further repository reads happen
+	latest, err := service.GetLatest("default")
+	assert.NoError(t, err)
+	assert.Equal(t, restored, latest)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Rollback_VersionNotFound(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	mockRepo.On("GetByVersion", 99).
+		Return(nil, sql.ErrNoRows).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	cfg, err := service.Rollback(99, "ops")
+
+	assert.Nil(t, cfg)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_GetLatest_ReturnsIndependentCopy(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	mockRepo.On("GetLatest", "default").
+		Return(&model.Config{Version: 1, Data: json.RawMessage(`{"a":1}`)}, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+
+	first, err := service.GetLatest("default")
+	assert.NoError(t, err)
+	first.Data[2] = 'b'
+
+	second, err := service.GetLatest("default")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":1}`, string(second.Data))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_GetLatest_CachesPerNamespace(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	prod := &model.Config{Version: 3, Namespace: "prod", URL: "https://prod.example.com"}
+	staging := &model.Config{Version: 4, Namespace: "staging", URL: "https://staging.example.com"}
+
+	mockRepo.On("GetLatest", "prod").
+		Return(prod, nil).
+		Once()
+	mockRepo.On("GetLatest", "staging").
+		Return(staging, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		gotProd, err := service.GetLatest("prod")
+		assert.NoError(t, err)
+		assert.Equal(t, prod, gotProd)
+
+		gotStaging, err := service.GetLatest("staging")
+		assert.NoError(t, err)
+		assert.Equal(t, staging, gotStaging)
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Create_OnlyRefreshesOwnNamespace(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	prod := &model.Config{Version: 1, Namespace: "prod"}
+	staging := &model.Config{Version: 2, Namespace: "staging"}
+	input := &model.Config{Namespace: "staging", URL: "https://staging.example.com", PollIntervalSeconds: 30}
+
+	mockRepo.On("GetLatest", "prod").
+		Return(prod, nil).
+		Once()
+	mockRepo.On("Create", input, 0, mock.Anything).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "staging").
+		Return(staging, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+
+	_, err := service.GetLatest("prod")
+	assert.NoError(t, err)
+
+	err = service.Create(input, 0)
+	assert.NoError(t, err)
+
+	gotProd, err := service.GetLatest("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, prod, gotProd)
+
+	gotStaging, err := service.GetLatest("staging")
+	assert.NoError(t, err)
+	assert.Equal(t, staging, gotStaging)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_GetLatest_EmptyNamespaceUsesDefault(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	expected := &model.Config{Version: 1, Namespace: "default"}
+	mockRepo.On("GetLatest", "default").
+		Return(expected, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	result, err := service.GetLatest("")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Create_WakesWatchers(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	input := &model.Config{Namespace: "prod", URL: "https://example.com", PollIntervalSeconds: 30}
+	mockRepo.On("Create", input, 0, mock.Anything).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 2, Namespace: "prod"}, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	prod := service.Changed("prod")
+	staging := service.Changed("staging")
+
+	err := service.Create(input, 0)
+	assert.NoError(t, err)
+
+	select {
+	case <-prod:
+	default:
+		t.Fatal("expected prod watchers to be woken")
+	}
+
+	select {
+	case <-staging:
+		t.Fatal("expected staging watchers to keep waiting")
+	default:
+	}
+
+	// A fresh subscription waits for the next change.
+	select {
+	case <-service.Changed("prod"):
+		t.Fatal("expected a new channel after notification")
+	default:
+	}
+}
+
+func TestConfigService_Create_GetLatestError_StillWakesWatchers(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	input := &model.Config{URL: "https://example.com", PollIntervalSeconds: 30}
+	mockRepo.On("Create", input, 0, mock.Anything).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "default").
+		Return(nil, errors.New("get latest failed")).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	changed := service.Changed("")
+
+	assert.Error(t, service.Create(input, 0))
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("expected watchers to be woken")
+	}
+}
+
+func TestConfigService_Create_Error_DoesNotWakeWatchers(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	input := &model.Config{URL: "https://example.com", PollIntervalSeconds: 30}
+	mockRepo.On("Create", input, 0, mock.Anything).
+		Return(errors.New("insert failed")).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	changed := service.Changed("default")
+
+	assert.Error(t, service.Create(input, 0))
+
+	select {
+	case <-changed:
+		t.Fatal("expected watchers to keep waiting")
+	default:
+	}
+}
+
+func TestConfigService_Sync_RefreshesNotifiedNamespace(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+	mockRepo.On("ListScheduled", mock.Anything).Return([]model.Config{}, nil).Maybe()
+
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod"}, nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 2, Namespace: "prod"}, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	_, err := service.GetLatest("prod")
+	assert.NoError(t, err)
+	changed := service.Changed("prod")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string)
+	go service.Sync(ctx, changes, time.Hour)
+	changes <- "prod"
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected watchers to be woken")
+	}
+
+	cfg, err := service.GetLatest("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cfg.Version)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Sync_SameVersionDoesNotWake(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod"}, nil).
+		Twice()
+
+	svc := NewConfigService(mockRepo, nil, nil, nil, nil).(*configService)
+	_, err := svc.GetLatest("prod")
+	assert.NoError(t, err)
+	changed := svc.Changed("prod")
+
+	svc.refresh("prod", false)
+
+	select {
+	case <-changed:
+		t.Fatal("expected watchers to keep waiting")
+	default:
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Sync_RefreshErrorDropsCache(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod"}, nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(nil, errors.New("db down")).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 3, Namespace: "prod"}, nil).
+		Once()
+
+	svc := NewConfigService(mockRepo, nil, nil, nil, nil).(*configService)
+	_, err := svc.GetLatest("prod")
+	assert.NoError(t, err)
+
+	svc.refresh("prod", false)
+
+	cfg, err := svc.GetLatest("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cfg.Version)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Sync_ResyncCoversCachedAndWatchedNamespaces(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+	mockRepo.On("ListScheduled", mock.Anything).Return([]model.Config{}, nil).Maybe()
+
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod"}, nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod"}, nil)
+	mockRepo.On("GetLatest", "staging").
+		Return(&model.Config{Version: 4, Namespace: "staging"}, nil)
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	_, err := service.GetLatest("prod")
+	assert.NoError(t, err)
+	staging := service.Changed("staging")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.Sync(ctx, nil, 5*time.Millisecond)
+
+	select {
+	case <-staging:
+	case <-time.After(time.Second):
+		t.Fatal("expected periodic resync to wake staging watchers")
+	}
+}
+
+func TestConfigService_Create_VersionConflict(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	input := &model.Config{Namespace: "prod", URL: "https://example.com", PollIntervalSeconds: 30}
+	mockRepo.On("Create", input, 3, mock.Anything).
+		Return(repository.ErrVersionConflict).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+
+	err := service.Create(input, 3)
+	assert.True(t, errors.Is(err, repository.ErrVersionConflict))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Sync_NotificationWakesOnSameVersion(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+	mockRepo.On("ListScheduled", mock.Anything).Return([]model.Config{}, nil).Maybe()
+
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod"}, nil).
+		Twice()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	_, err := service.GetLatest("prod")
+	assert.NoError(t, err)
+	changed := service.Changed("prod")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string)
+	go service.Sync(ctx, changes, time.Hour)
+	// e.g. a rollout of the latest version advanced on another instance
+	changes <- "prod"
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected watchers to be woken")
+	}
+}
+
+func TestConfigService_EncryptsSecrets(t *testing.T) {
+	key := make([]byte, secretsKeySize)
+	mockRepo := new(mocks.ConfigRepository)
+
+	var stored *model.Config
+	mockRepo.On("Create", mock.AnythingOfType("*model.Config"), 0, mock.Anything).
+		Run(func(args mock.Arguments) {
+			cfg := args.Get(0).(*model.Config)
+			cfg.Version = 1
+			stored = cloneConfig(cfg)
+			stored.Secrets = nil
+		}).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(func(string) *model.Config { return cloneConfig(stored) }, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, key, nil, nil)
+	err := service.Create(&model.Config{
+		Namespace: "prod",
+		URL:       "https://example.com",
+		Secrets:   map[string]string{"db_password": "hunter2"},
+	}, 0)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, stored.EncryptedSecrets)
+	assert.NotContains(t, string(stored.EncryptedSecrets), "hunter2")
+
+	latest, err := service.GetLatest("prod")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"db_password": "hunter2"}, latest.Secrets)
+}
+
+func TestConfigService_Create_SecretsWithoutKey(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+
+	err := service.Create(&model.Config{
+		URL:     "https://example.com",
+		Secrets: map[string]string{"db_password": "hunter2"},
+	}, 0)
+
+	assert.ErrorIs(t, err, ErrSecretsDisabled)
+	mockRepo.AssertNotCalled(t, "Create")
+}
+
+func TestConfigService_ScheduledVersionActivatesOnTime(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	activateAt := time.Now().Add(50 * time.Millisecond)
+	input := &model.Config{Namespace: "prod", URL: "https://example.com/v2", ActivateAt: &activateAt}
+	mockRepo.On("Create", input, 0, mock.Anything).
+		Run(func(args mock.Arguments) { args.Get(0).(*model.Config).Version = 2 }).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod"}, nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 2, Namespace: "prod"}, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	require.NoError(t, service.Create(input, 0))
+
+	changed := service.Changed("prod")
+	cfg, err := service.GetLatest("prod")
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.Version)
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected watchers to be woken at activation")
+	}
+	assert.False(t, time.Now().Before(activateAt))
+
+	cfg, err = service.GetLatest("prod")
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.Version)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Sync_SchedulesStoredVersions(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	activateAt := time.Now().Add(20 * time.Millisecond)
+	mockRepo.On("ListScheduled", "").
+		Return([]model.Config{{Version: 7, Namespace: "prod", ActivateAt: &activateAt}}, nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 7, Namespace: "prod"}, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, nil)
+	changed := service.Changed("prod")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.Sync(ctx, nil, time.Hour)
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the stored scheduled version to activate")
+	}
+}
+
+func TestConfigService_Cancel(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	t.Run("scheduled", func(t *testing.T) {
+		mockRepo := new(mocks.ConfigRepository)
+		mockRepo.On("GetByVersion", 5).
+			Return(&model.Config{Version: 5, Namespace: "prod", ActivateAt: &future}, nil).
+			Once()
+		mockRepo.On("Cancel", 5).Return(nil).Once()
+		mockRepo.On("GetByVersion", 5).
+			Return(&model.Config{Version: 5, Namespace: "prod", ActivateAt: &future, CanceledAt: &past}, nil).
+			Once()
+
+		cfg, err := NewConfigService(mockRepo, nil, nil, nil, nil).Cancel(5)
+		require.NoError(t, err)
+		assert.NotNil(t, cfg.CanceledAt)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("already active", func(t *testing.T) {
+		mockRepo := new(mocks.ConfigRepository)
+		mockRepo.On("GetByVersion", 5).
+			Return(&model.Config{Version: 5, Namespace: "prod", ActivateAt: &past}, nil).
+			Once()
+
+		_, err := NewConfigService(mockRepo, nil, nil, nil, nil).Cancel(5)
+		assert.ErrorIs(t, err, ErrNotScheduled)
+		mockRepo.AssertNotCalled(t, "Cancel", 5)
+	})
+
+	t.Run("activated meanwhile", func(t *testing.T) {
+		mockRepo := new(mocks.ConfigRepository)
+		mockRepo.On("GetByVersion", 5).
+			Return(&model.Config{Version: 5, Namespace: "prod", ActivateAt: &future}, nil).
+			Once()
+		mockRepo.On("Cancel", 5).Return(sql.ErrNoRows).Once()
+
+		_, err := NewConfigService(mockRepo, nil, nil, nil, nil).Cancel(5)
+		assert.ErrorIs(t, err, ErrNotScheduled)
+	})
+}
+
+func TestConfigService_Create_DraftInApprovalNamespace(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	mockRepo.On("Create", mock.MatchedBy(func(cfg *model.Config) bool {
+		return cfg.Approval != nil && cfg.Approval.Status == model.ApprovalPending
+	}), 0, mock.Anything).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 1, Namespace: "prod"}, nil).
+		Once()
+	mockRepo.On("Create", mock.MatchedBy(func(cfg *model.Config) bool {
+		return cfg.Approval == nil
+	}), 0, mock.Anything).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "dev").
+		Return(&model.Config{Version: 2, Namespace: "dev"}, nil).
+		Once()
+
+	service := NewConfigService(mockRepo, nil, nil, []string{"prod"}, nil)
+	require.NoError(t, service.Create(&model.Config{Namespace: "prod", CreatedBy: "alice"}, 0))
+	require.NoError(t, service.Create(&model.Config{Namespace: "dev", CreatedBy: "alice"}, 0))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Approve(t *testing.T) {
+	draft := func() *model.Config {
+		return &model.Config{
+			Version:   5,
+			Namespace: "prod",
+			CreatedBy: "alice",
+			Approval:  &model.Approval{Status: model.ApprovalPending},
+		}
+	}
+
+	t.Run("approved", func(t *testing.T) {
+		mockRepo := new(mocks.ConfigRepository)
+		approved := draft()
+		approved.Approval = &model.Approval{Status: model.ApprovalApproved, ReviewedBy: "bob"}
+
+		mockRepo.On("GetByVersion", 5).Return(draft(), nil).Once()
+		mockRepo.On("Review", 5, model.ApprovalApproved, "bob", mock.Anything).Return(nil).Once()
+		mockRepo.On("GetLatest", "prod").Return(approved, nil).Once()
+		mockRepo.On("GetByVersion", 5).Return(approved, nil).Once()
+
+		service := NewConfigService(mockRepo, nil, nil, []string{"prod"}, nil)
+		changed := service.Changed("prod")
+
+		cfg, err := service.Approve(5, "bob")
+		require.NoError(t, err)
+		assert.Equal(t, model.ApprovalApproved, cfg.Approval.Status)
+
+		select {
+		case <-changed:
+		default:
+			t.Fatal("expected watchers to be woken by the approval")
+		}
+
+		latest, err := service.GetLatest("prod")
+		require.NoError(t, err)
+		assert.Equal(t, 5, latest.Version)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("own draft", func(t *testing.T) {
+		mockRepo := new(mocks.ConfigRepository)
+		mockRepo.On("GetByVersion", 5).Return(draft(), nil).Once()
+
+		_, err := NewConfigService(mockRepo, nil, nil, nil, nil).Approve(5, "alice")
+		assert.ErrorIs(t, err, ErrSelfApproval)
+		mockRepo.AssertNotCalled(t, "Review", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not a draft", func(t *testing.T) {
+		mockRepo := new(mocks.ConfigRepository)
+		mockRepo.On("GetByVersion", 5).Return(&model.Config{Version: 5, Namespace: "prod"}, nil).Once()
+
+		_, err := NewConfigService(mockRepo, nil, nil, nil, nil).Approve(5, "bob")
+		assert.ErrorIs(t, err, ErrNotPendingApproval)
+	})
+
+	t.Run("reviewed meanwhile", func(t *testing.T) {
+		mockRepo := new(mocks.ConfigRepository)
+		mockRepo.On("GetByVersion", 5).Return(draft(), nil).Once()
+		mockRepo.On("Review", 5, model.ApprovalApproved, "bob", mock.Anything).Return(sql.ErrNoRows).Once()
+
+		_, err := NewConfigService(mockRepo, nil, nil, nil, nil).Approve(5, "bob")
+		assert.ErrorIs(t, err, ErrNotPendingApproval)
+	})
+}
+
+func TestConfigService_Reject(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	mockRepo.On("GetByVersion", 5).
+		Return(&model.Config{Version: 5, Namespace: "prod", CreatedBy: "alice", Approval: &model.Approval{Status: model.ApprovalPending}}, nil).
+		Once()
+	mockRepo.On("Review", 5, model.ApprovalRejected, "bob", mock.Anything).Return(nil).Once()
+	mockRepo.On("GetByVersion", 5).
+		Return(&model.Config{Version: 5, Namespace: "prod", CreatedBy: "alice", Approval: &model.Approval{Status: model.ApprovalRejected}}, nil).
+		Once()
+
+	cfg, err := NewConfigService(mockRepo, nil, nil, nil, nil).Reject(5, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, model.ApprovalRejected, cfg.Approval.Status)
+
+	// A rejected draft never becomes the latest version.
+	mockRepo.AssertNotCalled(t, "GetLatest", "prod")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Rollback_DraftInApprovalNamespace(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	mockRepo.On("GetByVersion", 1).
+		Return(&model.Config{Version: 1, Namespace: "prod", URL: "https://example.com/v1"}, nil).
+		Once()
+	mockRepo.On("Create", mock.MatchedBy(func(cfg *model.Config) bool {
+		return cfg.CreatedBy == "alice" && cfg.Approval != nil
+	}), 0, mock.Anything).
+		Run(func(args mock.Arguments) { args.Get(0).(*model.Config).Version = 6 }).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 5, Namespace: "prod"}, nil).
+		Once()
+	mockRepo.On("GetByVersion", 6).
+		Return(&model.Config{Version: 6, Namespace: "prod", Approval: &model.Approval{Status: model.ApprovalPending}}, nil).
+		Once()
+
+	cfg, err := NewConfigService(mockRepo, nil, nil, []string{"prod"}, nil).Rollback(1, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 6, cfg.Version)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Diff(t *testing.T) {
+	key := make([]byte, secretsKeySize)
+	mockRepo := new(mocks.ConfigRepository)
+
+	oldSecrets, err := sealSecrets(key, map[string]string{"token": "a", "old": "x"})
+	require.NoError(t, err)
+	newSecrets, err := sealSecrets(key, map[string]string{"token": "b", "old": "x"})
+	require.NoError(t, err)
+
+	mockRepo.On("GetByVersion", 41).
+		Return(&model.Config{
+			Version:             41,
+			Namespace:           "prod",
+			URL:                 "https://example.com/v1",
+			PollIntervalSeconds: 30,
+			Data:                json.RawMessage(`{"feature":"off","retries":3}`),
+			EncryptedSecrets:    oldSecrets,
+		}, nil).
+		Once()
+	mockRepo.On("GetByVersion", 42).
+		Return(&model.Config{
+			Version:             42,
+			Namespace:           "prod",
+			URL:                 "https://example.com/v2",
+			PollIntervalSeconds: 30,
+			Data:                json.RawMessage(`{"feature":"on"}`),
+			EncryptedSecrets:    newSecrets,
+		}, nil).
+		Once()
+
+	diff, err := NewConfigService(mockRepo, nil, key, nil, nil).Diff(41, 42)
+	require.NoError(t, err)
+	assert.Equal(t, 41, diff.From)
+	assert.Equal(t, 42, diff.To)
+	assert.Equal(t, []model.ConfigChange{
+		{Op: model.DiffChanged, Path: "data.feature", From: "off", To: "on"},
+		{Op: model.DiffRemoved, Path: "data.retries", From: json.Number("3")},
+		{Op: model.DiffChanged, Path: "secrets.token", From: "a", To: "b"},
+		{Op: model.DiffChanged, Path: "url", From: "https://example.com/v1", To: "https://example.com/v2"},
+	}, diff.Changes)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfigService_Diff_VersionNotFound(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+
+	mockRepo.On("GetByVersion", 41).Return(&model.Config{Version: 41}, nil).Once()
+	mockRepo.On("GetByVersion", 99).Return(nil, sql.ErrNoRows).Once()
+
+	_, err := NewConfigService(mockRepo, nil, nil, nil, nil).Diff(41, 99)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+// eventNames returns the Event of every event in events.
+func eventNames(events []model.WebhookEvent) []string {
+	names := make([]string, 0, len(events))
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	return names
+}
+
+func TestConfigService_QueuesWebhookEventsWithVersion(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+	mockWebhooks := new(serviceMocks.WebhookService)
+
+	mockRepo.On("Create", mock.AnythingOfType("*model.Config"), 0, mock.MatchedBy(func(events []model.WebhookEvent) bool {
+		return len(events) == 1 &&
+			events[0].Event == model.WebhookEventConfigCreated &&
+			events[0].Namespace == "prod" &&
+			events[0].CreatedBy == "alice" &&
+			!events[0].OccurredAt.IsZero()
+	})).
+		Run(func(args mock.Arguments) { args.Get(0).(*model.Config).Version = 42 }).
+		Return(nil).
+		Once()
+	mockRepo.On("Create", mock.AnythingOfType("*model.Config"), 0, mock.MatchedBy(func(events []model.WebhookEvent) bool {
+		return len(events) == 1 && events[0].Event == model.WebhookEventConfigRolledBack && events[0].RestoredVersion == 40
+	})).
+		Run(func(args mock.Arguments) { args.Get(0).(*model.Config).Version = 43 }).
+		Return(nil).
+		Once()
+	mockRepo.On("GetLatest", "prod").
+		Return(&model.Config{Version: 42, Namespace: "prod"}, nil)
+	mockRepo.On("GetByVersion", 40).
+		Return(&model.Config{Version: 40, Namespace: "prod", URL: "https://example.com/v40"}, nil).
+		Once()
+	mockWebhooks.On("Signal").Return().Twice()
+
+	service := NewConfigService(mockRepo, nil, nil, nil, mockWebhooks)
+	require.NoError(t, service.Create(&model.Config{Namespace: "prod", CreatedBy: "alice"}, 0))
+
+	_, err := service.Rollback(40, "bob")
+	require.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+	mockWebhooks.AssertExpectations(t)
+}
+
+func TestConfigService_WebhookEventsOfScheduledAndDraftVersions(t *testing.T) {
+	activateAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		namespace string
+		approval  []string
+		want      []string
+	}{
+		{
+			name:      "scheduled",
+			namespace: "prod",
+			want:      []string{model.WebhookEventConfigCreated, model.WebhookEventConfigActivated},
+		},
+		{
+			name:      "scheduled draft waits for approval",
+			namespace: "prod",
+			approval:  []string{"prod"},
+			want:      []string{model.WebhookEventConfigCreated},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.ConfigRepository)
+			mockWebhooks := new(serviceMocks.WebhookService)
+
+			var events []model.WebhookEvent
+			mockRepo.On("Create", mock.AnythingOfType("*model.Config"), 0, mock.Anything).
+				Run(func(args mock.Arguments) {
+					args.Get(0).(*model.Config).Version = 7
+					events = args.Get(2).([]model.WebhookEvent)
+				}).
+				Return(nil).
+				Once()
+			mockRepo.On("GetLatest", tt.namespace).Return(&model.Config{Version: 6, Namespace: tt.namespace}, nil).Once()
+			mockWebhooks.On("Signal").Return().Once()
+
+			service := NewConfigService(mockRepo, nil, nil, tt.approval, mockWebhooks)
+			require.NoError(t, service.Create(&model.Config{Namespace: tt.namespace, ActivateAt: &activateAt}, 0))
+
+			assert.Equal(t, tt.want, eventNames(events))
+			if len(events) == 2 {
+				assert.True(t, events[1].OccurredAt.Equal(activateAt), "activation is delivered at the activation time")
+			}
+		})
+	}
+}
+
+func TestConfigService_Approve_QueuesWebhookEvents(t *testing.T) {
+	activateAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		activateAt *time.Time
+		want       []string
+	}{
+		{name: "draft", want: []string{model.WebhookEventConfigApproved}},
+		{name: "scheduled draft", activateAt: &activateAt, want: []string{model.WebhookEventConfigApproved, model.WebhookEventConfigActivated}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.ConfigRepository)
+			mockWebhooks := new(serviceMocks.WebhookService)
+
+			draft := &model.Config{
+				Version:    5,
+				Namespace:  "prod",
+				CreatedBy:  "alice",
+				ActivateAt: tt.activateAt,
+				Approval:   &model.Approval{Status: model.ApprovalPending},
+			}
+			var events []model.WebhookEvent
+			mockRepo.On("GetByVersion", 5).Return(draft, nil).Once()
+			mockRepo.On("Review", 5, model.ApprovalApproved, "bob", mock.Anything).
+				Run(func(args mock.Arguments) { events = args.Get(3).([]model.WebhookEvent) }).
+				Return(nil).
+				Once()
+			mockRepo.On("GetLatest", "prod").Return(&model.Config{Version: 4, Namespace: "prod"}, nil).Once()
+			mockRepo.On("GetByVersion", 5).Return(draft, nil).Once()
+			mockWebhooks.On("Signal").Return().Once()
+
+			service := NewConfigService(mockRepo, nil, nil, []string{"prod"}, mockWebhooks)
+			_, err := service.Approve(5, "bob")
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, eventNames(events))
+			assert.Equal(t, 5, events[0].Version)
+			assert.Equal(t, "bob", events[0].ReviewedBy)
+			mockWebhooks.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConfigService_Reject_QueuesNoWebhookEvents(t *testing.T) {
+	mockRepo := new(mocks.ConfigRepository)
+	mockWebhooks := new(serviceMocks.WebhookService)
+
+	draft := &model.Config{Version: 5, Namespace: "prod", CreatedBy: "alice", Approval: &model.Approval{Status: model.ApprovalPending}}
+	mockRepo.On("GetByVersion", 5).Return(draft, nil).Twice()
+	mockRepo.On("Review", 5, model.ApprovalRejected, "bob", ([]model.WebhookEvent)(nil)).Return(nil).Once()
+
+	_, err := NewConfigService(mockRepo, nil, nil, []string{"prod"}, mockWebhooks).Reject(5, "bob")
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockWebhooks.AssertNotCalled(t, "Signal")
+}
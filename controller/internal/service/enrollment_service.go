@@ -0,0 +1,85 @@
+package service
+
+import (
+	"controller/internal/model"
+	"controller/internal/repository"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidEnrollmentToken is returned for unknown, revoked, expired or used
+// up enrollment tokens.
+var ErrInvalidEnrollmentToken = errors.New("invalid enrollment token")
+
+type EnrollmentService interface {
+	// Create mints a token valid for maxUses registrations and returns it
+	// together with its plain value, which is not stored.
+	Create(maxUses int, expiresAt *time.Time) (*model.EnrollmentToken, string, error)
+	List() ([]model.EnrollmentToken, error)
+	Revoke(id string) error
+	// Verify checks the secret of a token of the form "<token id>.<secret>"
+	// and returns the token id. The token is used when the agent is enrolled.
+	Verify(token string) (string, error)
+}
+
+type enrollmentService struct {
+	repo repository.EnrollmentTokenRepository
+}
+
+func NewEnrollmentService(r repository.EnrollmentTokenRepository) EnrollmentService {
+	return &enrollmentService{repo: r}
+}
+
+func (s *enrollmentService) Create(maxUses int, expiresAt *time.Time) (*model.EnrollmentToken, string, error) {
+	plain, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &model.EnrollmentToken{
+		ID:        uuid.New().String(),
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+		Hash:      hashSecret(plain),
+	}
+	if err := s.repo.Create(token); err != nil {
+		return nil, "", err
+	}
+
+	return token, token.ID + "." + plain, nil
+}
+
+func (s *enrollmentService) List() ([]model.EnrollmentToken, error) {
+	return s.repo.List()
+}
+
+func (s *enrollmentService) Revoke(id string) error {
+	return s.repo.Revoke(id)
+}
+
+// Verify only checks the secret; revocation, expiry and uses are checked
+// atomically when AgentRepository.Enroll uses the token.
+func (s *enrollmentService) Verify(token string) (string, error) {
+	id, secret, ok := splitToken(token)
+	if !ok {
+		return "", ErrInvalidEnrollmentToken
+	}
+
+	stored, err := s.repo.GetByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidEnrollmentToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(stored.Hash)) != 1 {
+		return "", ErrInvalidEnrollmentToken
+	}
+
+	return id, nil
+}
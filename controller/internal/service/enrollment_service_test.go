@@ -0,0 +1,87 @@
+package service
+
+import (
+	mocks "controller/internal/mocks/repository"
+	"controller/internal/model"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrollmentService_Create_StoresHashOnly(t *testing.T) {
+	mockRepo := new(mocks.EnrollmentTokenRepository)
+
+	var stored *model.EnrollmentToken
+	mockRepo.On("Create", mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(*model.EnrollmentToken) }).
+		Return(nil).
+		Once()
+
+	service := NewEnrollmentService(mockRepo)
+
+	token, plain, err := service.Create(3, nil)
+	require.NoError(t, err)
+
+	id, secret, ok := strings.Cut(plain, ".")
+	require.True(t, ok)
+	assert.Equal(t, token.ID, id)
+	assert.Equal(t, 3, stored.MaxUses)
+	assert.Equal(t, hashSecret(secret), stored.Hash)
+}
+
+func TestEnrollmentService_Verify(t *testing.T) {
+	id := uuid.NewString()
+	stored := &model.EnrollmentToken{ID: id, MaxUses: 1, Hash: hashSecret("s3cret")}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantID  string
+		wantErr error
+	}{
+		{name: "valid", token: id + ".s3cret", wantID: id},
+		{name: "wrong secret", token: id + ".guess", wantErr: ErrInvalidEnrollmentToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.EnrollmentTokenRepository)
+			mockRepo.On("GetByID", id).Return(stored, nil).Once()
+
+			gotID, err := NewEnrollmentService(mockRepo).Verify(tt.token)
+
+			assert.Equal(t, tt.wantID, gotID)
+			assert.Equal(t, tt.wantErr, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEnrollmentService_Verify_LookupError(t *testing.T) {
+	mockRepo := new(mocks.EnrollmentTokenRepository)
+	id := uuid.NewString()
+	mockRepo.On("GetByID", id).Return(nil, errors.New("db down")).Once()
+
+	_, err := NewEnrollmentService(mockRepo).Verify(id + ".s3cret")
+
+	assert.EqualError(t, err, "db down")
+}
+
+func TestEnrollmentService_Verify_UnknownOrMalformed(t *testing.T) {
+	mockRepo := new(mocks.EnrollmentTokenRepository)
+	id := uuid.NewString()
+	mockRepo.On("GetByID", id).Return(nil, sql.ErrNoRows).Once()
+
+	service := NewEnrollmentService(mockRepo)
+
+	for _, token := range []string{"", "no-dot", "not-a-uuid.secret", id + ".secret"} {
+		_, err := service.Verify(token)
+		assert.True(t, errors.Is(err, ErrInvalidEnrollmentToken), token)
+	}
+}
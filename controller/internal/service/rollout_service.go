@@ -0,0 +1,256 @@
+package service
+
+import (
+	"controller/internal/model"
+	"controller/internal/repository"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// ErrInvalidRolloutState is returned when a rollout cannot make the requested
+// transition, e.g. advancing an aborted rollout.
+var ErrInvalidRolloutState = errors.New("invalid rollout state")
+
+// ErrRolloutNarrowed is returned when an advance lowers the percentage, which
+// would take the new version away from agents that already run it.
+var ErrRolloutNarrowed = fmt.Errorf("%w: percentage cannot decrease", ErrInvalidRolloutState)
+
+type RolloutService interface {
+	Get(version int) (*model.Rollout, error)
+	Advance(version, percentage int, agentIDs []string) (*model.Rollout, error)
+	Pause(version int) (*model.Rollout, error)
+	Abort(version int) (*model.Rollout, error)
+	Resolve(agent *model.Agent, latest *model.Config) (*model.Config, error)
+}
+
+// rolloutCacheTTL bounds how long a cached rollout is served when a change
+// notification from another controller instance was lost.
+const rolloutCacheTTL = 30 * time.Second
+
+type rolloutService struct {
+	repo    repository.RolloutRepository
+	configs ConfigService
+
+	// cache holds the rollout of the latest version per namespace.
+	mu    sync.Mutex
+	cache map[string]*rolloutEntry
+	now   func() time.Time
+}
+
+// rolloutEntry is the rollout of version, nil when it has none, and the base
+// config served outside it once loaded. It is valid until changed is closed,
+// i.e. until a version is created in the namespace or a rollout there changes.
+type rolloutEntry struct {
+	version  int
+	rollout  *model.Rollout
+	base     *model.Config
+	changed  <-chan struct{}
+	loadedAt time.Time
+}
+
+func NewRolloutService(r repository.RolloutRepository, configs ConfigService) RolloutService {
+	return &rolloutService{
+		repo:    r,
+		configs: configs,
+		cache:   make(map[string]*rolloutEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *rolloutService) Get(version int) (*model.Rollout, error) {
+	return s.repo.GetByVersion(version)
+}
+
+// Advance widens the rollout to percentage and the extra agentIDs, resuming it
+// when paused. Reaching 100 percent completes it. The percentage never
+// decreases, so selected agents keep the new version.
+func (s *rolloutService) Advance(version, percentage int, agentIDs []string) (*model.Rollout, error) {
+	return s.transition(version, func(r *model.Rollout) error {
+		if percentage < r.Percentage {
+			return ErrRolloutNarrowed
+		}
+		r.Percentage = percentage
+		r.AgentIDs = appendMissing(r.AgentIDs, agentIDs)
+		r.Status = model.RolloutStatusInProgress
+		if percentage >= 100 {
+			r.Status = model.RolloutStatusCompleted
+		}
+		return nil
+	})
+}
+
+// Pause holds the rollout: selected agents keep the new version and the
+// selection no longer changes until it is advanced.
+func (s *rolloutService) Pause(version int) (*model.Rollout, error) {
+	return s.transition(version, func(r *model.Rollout) error {
+		r.Status = model.RolloutStatusPaused
+		return nil
+	})
+}
+
+// Abort sends every agent back to the base version.
+func (s *rolloutService) Abort(version int) (*model.Rollout, error) {
+	return s.transition(version, func(r *model.Rollout) error {
+		r.Status = model.RolloutStatusAborted
+		return nil
+	})
+}
+
+func (s *rolloutService) transition(version int, apply func(r *model.Rollout) error) (*model.Rollout, error) {
+	rollout, err := s.repo.GetByVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if !rollout.Active() {
+		return nil, ErrInvalidRolloutState
+	}
+
+	if err := apply(rollout); err != nil {
+		return nil, err
+	}
+	err = s.repo.Update(rollout)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The rollout was aborted or completed since it was read.
+		return nil, ErrInvalidRolloutState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.configs.Notify(rollout.Namespace)
+	return rollout, nil
+}
+
+// Resolve returns the config agent should run given the latest version of its
+// namespace: latest itself, or the rollout's base version when the agent is
+// not selected for it.
+func (s *rolloutService) Resolve(agent *model.Agent, latest *model.Config) (*model.Config, error) {
+	entry, err := s.cached(latest)
+	if err != nil {
+		return nil, err
+	}
+
+	rollout := entry.rollout
+	if rollout == nil {
+		return latest, nil
+	}
+
+	switch rollout.Status {
+	case model.RolloutStatusCompleted:
+		return latest, nil
+	case model.RolloutStatusInProgress, model.RolloutStatusPaused:
+		if selected(rollout, agent) {
+			return latest, nil
+		}
+	}
+
+	if rollout.BaseVersion == 0 {
+		// The namespace had nothing before this version.
+		return nil, sql.ErrNoRows
+	}
+	return s.base(entry)
+}
+
+// cached returns the rollout entry of latest, reading the rollout when the
+// cached one is for another version or was invalidated.
+func (s *rolloutService) cached(latest *model.Config) (*rolloutEntry, error) {
+	s.mu.Lock()
+	entry, ok := s.cache[latest.Namespace]
+	s.mu.Unlock()
+	if ok && entry.version == latest.Version && s.now().Sub(entry.loadedAt) < rolloutCacheTTL && !closed(entry.changed) {
+		return entry, nil
+	}
+
+	// Subscribe before reading so a change in between invalidates the entry.
+	changed := s.configs.Changed(latest.Namespace)
+	loadedAt := s.now()
+
+	rollout, err := s.repo.GetByVersion(latest.Version)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	entry = &rolloutEntry{version: latest.Version, rollout: rollout, changed: changed, loadedAt: loadedAt}
+	s.mu.Lock()
+	s.cache[latest.Namespace] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
+
+// base returns a copy of the entry's base config, loading it on first use.
+func (s *rolloutService) base(entry *rolloutEntry) (*model.Config, error) {
+	s.mu.Lock()
+	base := entry.base
+	s.mu.Unlock()
+
+	if base == nil {
+		var err error
+		base, err = s.configs.GetByVersion(entry.rollout.BaseVersion)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		entry.base = base
+		s.mu.Unlock()
+	}
+
+	return cloneConfig(base), nil
+}
+
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func selected(rollout *model.Rollout, agent *model.Agent) bool {
+	for _, id := range rollout.AgentIDs {
+		if id == agent.ID {
+			return true
+		}
+	}
+
+	if len(rollout.Labels) > 0 && hasLabels(agent.Labels, rollout.Labels) {
+		return true
+	}
+
+	return rolloutBucket(agent.ID) < rollout.Percentage
+}
+
+// rolloutBucket maps an agent to a stable bucket in [0, 100), so raising the
+// percentage only ever adds agents.
+func rolloutBucket(agentID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(agentID))
+	return int(h.Sum32() % 100)
+}
+
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func appendMissing(ids, extra []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		seen[id] = struct{}{}
+	}
+	for _, id := range extra {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
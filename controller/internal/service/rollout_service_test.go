@@ -0,0 +1,371 @@
+package service
+
+import (
+	mocks "controller/internal/mocks/repository"
+	serviceMocks "controller/internal/mocks/service"
+	"controller/internal/model"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// watchNamespace lets Resolve subscribe to namespace and returns the channel
+// that invalidates its cached rollout when closed.
+func watchNamespace(configs *serviceMocks.ConfigService, namespace string) chan struct{} {
+	ch := make(chan struct{})
+	configs.On("Changed", namespace).Return((<-chan struct{})(ch)).Once()
+	return ch
+}
+
+func TestRolloutService_Resolve_NoRollout(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 5).Return(nil, sql.ErrNoRows).Once()
+
+	mockConfigs := new(serviceMocks.ConfigService)
+	watchNamespace(mockConfigs, "prod")
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	cfg, err := service.Resolve(&model.Agent{ID: "a"}, latest)
+	assert.NoError(t, err)
+	assert.Same(t, latest, cfg)
+}
+
+func TestRolloutService_Resolve_SelectedAgentGetsLatest(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		BaseVersion:   4,
+		RolloutPolicy: model.RolloutPolicy{AgentIDs: []string{"canary"}},
+		Status:        model.RolloutStatusInProgress,
+	}, nil).Once()
+
+	mockConfigs := new(serviceMocks.ConfigService)
+	watchNamespace(mockConfigs, "prod")
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	cfg, err := service.Resolve(&model.Agent{ID: "canary"}, latest)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, cfg.Version)
+}
+
+func TestRolloutService_Resolve_LabelSelector(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		BaseVersion:   4,
+		RolloutPolicy: model.RolloutPolicy{Labels: map[string]string{"zone": "eu"}},
+		Status:        model.RolloutStatusPaused,
+	}, nil).Once()
+
+	mockConfigs := new(serviceMocks.ConfigService)
+	watchNamespace(mockConfigs, "prod")
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	cfg, err := service.Resolve(&model.Agent{ID: "a", Labels: map[string]string{"zone": "eu", "tier": "web"}}, latest)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, cfg.Version)
+}
+
+func TestRolloutService_Resolve_UnselectedAgentGetsBase(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+	watchNamespace(mockConfigs, "prod")
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		BaseVersion:   4,
+		RolloutPolicy: model.RolloutPolicy{Labels: map[string]string{"zone": "eu"}},
+		Status:        model.RolloutStatusInProgress,
+	}, nil).Once()
+	mockConfigs.On("GetByVersion", 4).Return(&model.Config{Version: 4, Namespace: "prod"}, nil).Once()
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	cfg, err := service.Resolve(&model.Agent{ID: "a", Labels: map[string]string{"zone": "us"}}, latest)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cfg.Version)
+	mockConfigs.AssertExpectations(t)
+}
+
+func TestRolloutService_Resolve_AbortedServesBase(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+	watchNamespace(mockConfigs, "prod")
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		BaseVersion:   4,
+		RolloutPolicy: model.RolloutPolicy{AgentIDs: []string{"canary"}},
+		Status:        model.RolloutStatusAborted,
+	}, nil).Once()
+	mockConfigs.On("GetByVersion", 4).Return(&model.Config{Version: 4, Namespace: "prod"}, nil).Once()
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	cfg, err := service.Resolve(&model.Agent{ID: "canary"}, latest)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cfg.Version)
+}
+
+func TestRolloutService_Resolve_NoBaseVersion(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	latest := &model.Config{Version: 1, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 1).Return(&model.Rollout{
+		Version: 1,
+		Status:  model.RolloutStatusInProgress,
+	}, nil).Once()
+
+	mockConfigs := new(serviceMocks.ConfigService)
+	watchNamespace(mockConfigs, "prod")
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	cfg, err := service.Resolve(&model.Agent{ID: "a"}, latest)
+	assert.Nil(t, cfg)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestRolloutService_Resolve_CachesRollout(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+	watchNamespace(mockConfigs, "prod")
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		BaseVersion:   4,
+		RolloutPolicy: model.RolloutPolicy{AgentIDs: []string{"canary"}},
+		Status:        model.RolloutStatusInProgress,
+	}, nil).Once()
+	mockConfigs.On("GetByVersion", 4).Return(&model.Config{Version: 4, Namespace: "prod"}, nil).Once()
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	for _, agentID := range []string{"canary", "a", "b", "canary"} {
+		_, err := service.Resolve(&model.Agent{ID: agentID}, latest)
+		assert.NoError(t, err)
+	}
+	mockRepo.AssertExpectations(t)
+	mockConfigs.AssertExpectations(t)
+}
+
+func TestRolloutService_Resolve_ReloadsAfterChange(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+	changed := watchNamespace(mockConfigs, "prod")
+	watchNamespace(mockConfigs, "prod")
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		BaseVersion:   4,
+		RolloutPolicy: model.RolloutPolicy{AgentIDs: []string{"canary"}},
+		Status:        model.RolloutStatusInProgress,
+	}, nil).Once()
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		BaseVersion:   4,
+		RolloutPolicy: model.RolloutPolicy{AgentIDs: []string{"canary"}},
+		Status:        model.RolloutStatusAborted,
+	}, nil).Once()
+	mockConfigs.On("GetByVersion", 4).Return(&model.Config{Version: 4, Namespace: "prod"}, nil).Once()
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	cfg, err := service.Resolve(&model.Agent{ID: "canary"}, latest)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, cfg.Version)
+
+	close(changed)
+
+	cfg, err = service.Resolve(&model.Agent{ID: "canary"}, latest)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cfg.Version)
+	mockRepo.AssertExpectations(t)
+	mockConfigs.AssertExpectations(t)
+}
+
+func TestRolloutService_Resolve_ReloadsAfterTTL(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+	watchNamespace(mockConfigs, "prod")
+	watchNamespace(mockConfigs, "prod")
+	latest := &model.Config{Version: 5, Namespace: "prod"}
+
+	mockRepo.On("GetByVersion", 5).Return(nil, sql.ErrNoRows).Twice()
+
+	service := NewRolloutService(mockRepo, mockConfigs).(*rolloutService)
+	service.now = func() time.Time { return now }
+
+	_, err := service.Resolve(&model.Agent{ID: "a"}, latest)
+	assert.NoError(t, err)
+	now = now.Add(rolloutCacheTTL)
+	_, err = service.Resolve(&model.Agent{ID: "a"}, latest)
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRolloutService_Selected_PercentageOnlyAddsAgents(t *testing.T) {
+	rollout := &model.Rollout{Status: model.RolloutStatusInProgress}
+
+	agents := make([]*model.Agent, 500)
+	for i := range agents {
+		agents[i] = &model.Agent{ID: uuid.New().String()}
+	}
+
+	previous := map[string]bool{}
+	for _, percentage := range []int{0, 10, 50, 100} {
+		rollout.Percentage = percentage
+		count := 0
+		for _, agent := range agents {
+			in := selected(rollout, agent)
+			if previous[agent.ID] {
+				assert.True(t, in, "agent dropped out when widening to %d%%", percentage)
+			}
+			previous[agent.ID] = in
+			if in {
+				count++
+			}
+		}
+
+		switch percentage {
+		case 0:
+			assert.Zero(t, count)
+		case 100:
+			assert.Equal(t, len(agents), count)
+		}
+	}
+}
+
+func TestRolloutService_Advance_CompletesAndNotifies(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		Namespace:     "prod",
+		RolloutPolicy: model.RolloutPolicy{Percentage: 10, AgentIDs: []string{"a"}},
+		Status:        model.RolloutStatusPaused,
+	}, nil).Once()
+	mockRepo.On("Update", mock.MatchedBy(func(r *model.Rollout) bool {
+		return r.Percentage == 100 &&
+			r.Status == model.RolloutStatusCompleted &&
+			assert.ObjectsAreEqual([]string{"a", "b"}, r.AgentIDs)
+	})).Return(nil).Once()
+	mockConfigs.On("Notify", "prod").Once()
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	rollout, err := service.Advance(5, 100, []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.RolloutStatusCompleted, rollout.Status)
+	mockRepo.AssertExpectations(t)
+	mockConfigs.AssertExpectations(t)
+}
+
+func TestRolloutService_Pause(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:   5,
+		Namespace: "prod",
+		Status:    model.RolloutStatusInProgress,
+	}, nil).Once()
+	mockRepo.On("Update", mock.MatchedBy(func(r *model.Rollout) bool {
+		return r.Status == model.RolloutStatusPaused
+	})).Return(nil).Once()
+	mockConfigs.On("Notify", "prod").Once()
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	rollout, err := service.Pause(5)
+	assert.NoError(t, err)
+	assert.Equal(t, model.RolloutStatusPaused, rollout.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRolloutService_Abort_FinishedRollout(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version: 5,
+		Status:  model.RolloutStatusCompleted,
+	}, nil).Once()
+
+	service := NewRolloutService(mockRepo, new(serviceMocks.ConfigService))
+
+	rollout, err := service.Abort(5)
+	assert.Nil(t, rollout)
+	assert.True(t, errors.Is(err, ErrInvalidRolloutState))
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestRolloutService_Advance_LowerPercentage(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		Namespace:     "prod",
+		RolloutPolicy: model.RolloutPolicy{Percentage: 50},
+		Status:        model.RolloutStatusPaused,
+	}, nil).Once()
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	rollout, err := service.Advance(5, 10, nil)
+	assert.Nil(t, rollout)
+	assert.True(t, errors.Is(err, ErrInvalidRolloutState))
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+	mockConfigs.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func TestRolloutService_Advance_AbortedConcurrently(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+	mockConfigs := new(serviceMocks.ConfigService)
+
+	mockRepo.On("GetByVersion", 5).Return(&model.Rollout{
+		Version:       5,
+		Namespace:     "prod",
+		RolloutPolicy: model.RolloutPolicy{Percentage: 10},
+		Status:        model.RolloutStatusInProgress,
+	}, nil).Once()
+	mockRepo.On("Update", mock.AnythingOfType("*model.Rollout")).Return(sql.ErrNoRows).Once()
+
+	service := NewRolloutService(mockRepo, mockConfigs)
+
+	rollout, err := service.Advance(5, 50, nil)
+	assert.Nil(t, rollout)
+	assert.True(t, errors.Is(err, ErrInvalidRolloutState))
+	mockConfigs.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func TestRolloutService_Abort_NotFound(t *testing.T) {
+	mockRepo := new(mocks.RolloutRepository)
+
+	mockRepo.On("GetByVersion", 9).Return(nil, sql.ErrNoRows).Once()
+
+	service := NewRolloutService(mockRepo, new(serviceMocks.ConfigService))
+
+	_, err := service.Abort(9)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// newSecret returns 32 random bytes, hex encoded.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitToken splits a token of the form "<uuid>.<secret>".
+func splitToken(token string) (id, secret string, ok bool) {
+	id, secret, ok = strings.Cut(token, ".")
+	if !ok {
+		return "", "", false
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrSecretsDisabled is returned for configs with secrets while the controller
+// has no secrets key.
+var ErrSecretsDisabled = errors.New("config secrets require a secrets key")
+
+// secretsKeySize is the AES-256 key size of both the key encryption key and
+// the per-version data keys.
+const secretsKeySize = 32
+
+// sealedSecretPrefix marks a value sealed by sealSecret, so values stored
+// before a secrets key was configured are still read as is.
+const sealedSecretPrefix = "sealed:"
+
+// secretEnvelope is the at-rest form of a config's secrets: the secrets sealed
+// with a fresh data key, and the data key sealed with the controller's key
+// encryption key. Both are AES-256-GCM with the nonce prepended.
+type secretEnvelope struct {
+	Key  []byte `json:"key"`
+	Data []byte `json:"data"`
+}
+
+// LoadSecretsKey reads the key encryption key from path: 32 random bytes,
+// base64 encoded, e.g. from "openssl rand -base64 32".
+func LoadSecretsKey(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read secrets key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("decode secrets key: %w", err)
+	}
+	if len(key) != secretsKeySize {
+		return nil, fmt.Errorf("secrets key must be %d bytes, got %d", secretsKeySize, len(key))
+	}
+	return key, nil
+}
+
+// sealSecrets encrypts secrets under a new data key wrapped with kek.
+func sealSecrets(kek []byte, secrets map[string]string) (json.RawMessage, error) {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, secretsKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	var env secretEnvelope
+	if env.Data, err = seal(dataKey, plaintext); err != nil {
+		return nil, err
+	}
+	if env.Key, err = seal(kek, dataKey); err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// openSecrets reverses sealSecrets.
+func openSecrets(kek []byte, sealed json.RawMessage) (map[string]string, error) {
+	var env secretEnvelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		return nil, fmt.Errorf("decode secrets envelope: %w", err)
+	}
+
+	dataKey, err := open(kek, env.Key)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap secrets data key: %w", err)
+	}
+	plaintext, err := open(dataKey, env.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt secrets: %w", err)
+	}
+
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("decode secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// sealSecret encrypts a single secret with kek for a text column. Without a
+// kek the secret is returned as is.
+func sealSecret(kek []byte, secret string) (string, error) {
+	if kek == nil {
+		return secret, nil
+	}
+
+	sealed, err := seal(kek, []byte(secret))
+	if err != nil {
+		return "", err
+	}
+	return sealedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret reverses sealSecret.
+func openSecret(kek []byte, stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedSecretPrefix)
+	if !ok {
+		return stored, nil
+	}
+	if kek == nil {
+		return "", errors.New("secret is sealed but no secrets key is configured")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decode sealed secret: %w", err)
+	}
+	secret, err := open(kek, sealed)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
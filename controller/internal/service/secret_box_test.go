@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealSecrets_RoundTrip(t *testing.T) {
+	kek := make([]byte, secretsKeySize)
+	secrets := map[string]string{"db_password": "hunter2", "token": "abc"}
+
+	sealed, err := sealSecrets(kek, secrets)
+	require.NoError(t, err)
+
+	opened, err := openSecrets(kek, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, secrets, opened)
+
+	otherKEK := make([]byte, secretsKeySize)
+	otherKEK[0] = 1
+	_, err = openSecrets(otherKEK, sealed)
+	assert.Error(t, err)
+}
+
+func TestSealSecret(t *testing.T) {
+	kek := make([]byte, secretsKeySize)
+
+	sealed, err := sealSecret(kek, "s3cret")
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "s3cret")
+
+	opened, err := openSecret(kek, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", opened)
+
+	_, err = openSecret(nil, sealed)
+	assert.Error(t, err)
+
+	// Without a key, and for values stored before one was set, the secret is
+	// kept as is.
+	plain, err := sealSecret(nil, "s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plain)
+	opened, err = openSecret(kek, plain)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", opened)
+}
+
+func TestLoadSecretsKey(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.key")
+	require.NoError(t, os.WriteFile(valid, []byte(base64.StdEncoding.EncodeToString(make([]byte, 32))+"\n"), 0o600))
+	key, err := LoadSecretsKey(valid)
+	require.NoError(t, err)
+	assert.Len(t, key, secretsKeySize)
+
+	short := filepath.Join(dir, "short.key")
+	require.NoError(t, os.WriteFile(short, []byte(base64.StdEncoding.EncodeToString(make([]byte, 16))), 0o600))
+	_, err = LoadSecretsKey(short)
+	assert.Error(t, err)
+
+	_, err = LoadSecretsKey(filepath.Join(dir, "missing.key"))
+	assert.Error(t, err)
+}
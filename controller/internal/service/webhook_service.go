@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"controller/internal/model"
+	"controller/internal/repository"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrheza/distributed-config-management/shared/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// webhookMaxAttempts is how often a delivery is tried before it fails.
+	webhookMaxAttempts = 8
+	// webhookBaseBackoff is the wait after the first failed attempt; it
+	// doubles with every further failure up to webhookMaxBackoff.
+	webhookBaseBackoff = 10 * time.Second
+	webhookMaxBackoff  = time.Hour
+	// webhookTimeout bounds a single attempt. The claim lease outlasts it, so
+	// no other instance picks the delivery up while it is being sent.
+	webhookTimeout   = 10 * time.Second
+	webhookLease     = time.Minute
+	webhookBatchSize = 20
+)
+
+type WebhookService interface {
+	// Create registers an endpoint and returns it together with its signing
+	// secret, which is only returned here.
+	Create(name, url, namespace string) (*model.Webhook, string, error)
+	List() ([]model.Webhook, error)
+	Delete(id string) error
+	// Signal wakes Run after deliveries were queued, so they are sent without
+	// waiting for the next interval.
+	Signal()
+	ListDeliveries(webhookID string, limit, offset int) ([]model.WebhookDelivery, error)
+	// Redeliver queues the payload of a delivery of webhookID once more.
+	Redeliver(webhookID string, deliveryID int64) (*model.WebhookDelivery, error)
+	// Run sends due deliveries until ctx is done, checking every interval and
+	// right after Signal.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type webhookService struct {
+	repo       repository.WebhookRepository
+	secretsKey []byte
+	client     *http.Client
+	now        func() time.Time
+	wake       chan struct{}
+}
+
+// NewWebhookService sends deliveries with client; nil uses a client with a
+// ten second timeout. secretsKey encrypts the signing secrets at rest; without
+// it they are stored as is.
+func NewWebhookService(r repository.WebhookRepository, secretsKey []byte, client *http.Client) WebhookService {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout, Transport: tracing.Transport(nil)}
+	}
+
+	return &webhookService{
+		repo:       r,
+		secretsKey: secretsKey,
+		client:     client,
+		now:        time.Now,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+func (s *webhookService) Create(name, url, namespace string) (*model.Webhook, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	sealed, err := sealSecret(s.secretsKey, secret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	webhook := &model.Webhook{
+		ID:        uuid.New().String(),
+		Name:      name,
+		URL:       url,
+		Namespace: namespace,
+		Secret:    sealed,
+	}
+	if err := s.repo.Create(webhook); err != nil {
+		return nil, "", err
+	}
+
+	return webhook, secret, nil
+}
+
+func (s *webhookService) List() ([]model.Webhook, error) {
+	return s.repo.List()
+}
+
+func (s *webhookService) Delete(id string) error {
+	return s.repo.Delete(id)
+}
+
+func (s *webhookService) ListDeliveries(webhookID string, limit, offset int) ([]model.WebhookDelivery, error) {
+	if _, err := s.repo.GetByID(webhookID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(webhookID, limit, offset)
+}
+
+func (s *webhookService) Redeliver(webhookID string, deliveryID int64) (*model.WebhookDelivery, error) {
+	original, err := s.repo.GetDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if original.WebhookID != webhookID {
+		return nil, sql.ErrNoRows
+	}
+
+	id, err := s.repo.Redeliver(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	s.Signal()
+
+	return s.repo.GetDelivery(id)
+}
+
+func (s *webhookService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// signal wakes Run without blocking; one pending wake-up is enough.
+func (s *webhookService) Signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue sends claimed batches in parallel until nothing is due.
+func (s *webhookService) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := s.repo.ClaimDue(webhookBatchSize, webhookLease)
+		if err != nil {
+			log.Printf("event=webhook_claim_failed err=%v", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		for i := range due {
+			wg.Add(1)
+			go func(d *model.WebhookDelivery) {
+				defer wg.Done()
+				s.deliver(ctx, d)
+			}(&due[i])
+		}
+		wg.Wait()
+
+		if len(due) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt and records its outcome, scheduling a retry with
+// exponential backoff after a failure.
+func (s *webhookService) deliver(ctx context.Context, d *model.WebhookDelivery) {
+	ctx, span := otel.Tracer("controller/internal/service").Start(ctx, "webhook.deliver")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("webhook.id", d.WebhookID),
+		attribute.Int64("webhook.delivery_id", d.ID),
+		attribute.String("webhook.event", d.Event),
+	)
+
+	var status int
+	secret, err := openSecret(s.secretsKey, d.Secret)
+	if err == nil {
+		status, err = s.send(ctx, d, secret)
+	}
+	tracing.RecordError(span, err)
+
+	now := s.now()
+	d.Attempts++
+	d.ResponseStatus = status
+	d.LastError = ""
+	d.NextAttemptAt = nil
+
+	switch {
+	case err == nil:
+		d.Status = model.DeliveryStatusSucceeded
+		d.DeliveredAt = &now
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = model.DeliveryStatusFailed
+		d.LastError = err.Error()
+	default:
+		next := now.Add(webhookBackoff(d.Attempts))
+		d.Status = model.DeliveryStatusPending
+		d.NextAttemptAt = &next
+		d.LastError = err.Error()
+	}
+
+	if err != nil {
+		log.Printf(
+			"event=webhook_delivery_failed delivery_id=%d webhook_id=%s attempt=%d status=%s err=%v",
+			d.ID, d.WebhookID, d.Attempts, d.Status, err,
+		)
+	}
+
+	if err := s.repo.RecordAttempt(d); err != nil {
+		// The lease expires and the attempt is repeated.
+		log.Printf("event=webhook_record_failed delivery_id=%d err=%v", d.ID, err)
+	}
+}
+
+// send POSTs the payload signed with secret and returns the response status.
+// Anything but 2xx is an error.
+func (s *webhookService) send(ctx context.Context, d *model.WebhookDelivery, secret string) (int, error) {
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "config-controller-webhook")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a bounded part of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhook is the hex HMAC-SHA256 of "<timestamp>.<body>" under secret.
+// Including the timestamp lets receivers reject replayed deliveries.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the wait after the given number of failed attempts.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	mocks "controller/internal/mocks/repository"
+	"controller/internal/model"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookService_Create_ReturnsSecretOnce(t *testing.T) {
+	mockRepo := new(mocks.WebhookRepository)
+
+	var stored *model.Webhook
+	mockRepo.On("Create", mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(*model.Webhook) }).
+		Return(nil).
+		Once()
+
+	webhook, secret, err := NewWebhookService(mockRepo, nil, nil).Create("tracker", "https://hooks.example.com", "prod")
+	require.NoError(t, err)
+	assert.NotEmpty(t, webhook.ID)
+	assert.Len(t, secret, 64)
+	assert.Equal(t, secret, stored.Secret)
+	assert.Equal(t, "prod", stored.Namespace)
+
+	raw, err := json.Marshal(webhook)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), secret)
+}
+
+func TestWebhookService_Create_SealsSecret(t *testing.T) {
+	kek := make([]byte, secretsKeySize)
+	mockRepo := new(mocks.WebhookRepository)
+
+	var stored *model.Webhook
+	mockRepo.On("Create", mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(*model.Webhook) }).
+		Return(nil).
+		Once()
+
+	_, secret, err := NewWebhookService(mockRepo, kek, nil).Create("tracker", "https://hooks.example.com", "prod")
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Secret, secret)
+
+	opened, err := openSecret(kek, stored.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, secret, opened)
+}
+
+func TestWebhookService_Deliver_Success(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	now := time.Unix(1700000000, 0)
+	mockRepo := new(mocks.WebhookRepository)
+	mockRepo.On("RecordAttempt", mock.MatchedBy(func(d *model.WebhookDelivery) bool {
+		return d.Status == model.DeliveryStatusSucceeded &&
+			d.Attempts == 1 &&
+			d.ResponseStatus == http.StatusNoContent &&
+			d.NextAttemptAt == nil &&
+			d.DeliveredAt != nil && d.DeliveredAt.Equal(now)
+	})).
+		Return(nil).
+		Once()
+
+	service := NewWebhookService(mockRepo, nil, server.Client()).(*webhookService)
+	service.now = func() time.Time { return now }
+
+	service.deliver(context.Background(), &model.WebhookDelivery{
+		ID:      7,
+		Event:   model.WebhookEventConfigCreated,
+		Payload: json.RawMessage(`{"version":42}`),
+		Status:  model.DeliveryStatusPending,
+		URL:     server.URL,
+		Secret:  "s3cret",
+	})
+
+	require.NotNil(t, got)
+	assert.Equal(t, `{"version":42}`, string(body))
+	assert.Equal(t, "config.created", got.Header.Get("X-Webhook-Event"))
+	assert.Equal(t, "7", got.Header.Get("X-Webhook-Delivery"))
+	assert.Equal(t, "1700000000", got.Header.Get("X-Webhook-Timestamp"))
+	assert.Equal(t, "sha256="+signWebhook("s3cret", "1700000000", body), got.Header.Get("X-Webhook-Signature"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWebhookService_Deliver_SealedSecret(t *testing.T) {
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Webhook-Signature")
+	}))
+	defer server.Close()
+
+	kek := make([]byte, secretsKeySize)
+	sealed, err := sealSecret(kek, "s3cret")
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	mockRepo := new(mocks.WebhookRepository)
+	mockRepo.On("RecordAttempt", mock.MatchedBy(func(d *model.WebhookDelivery) bool {
+		return d.Status == model.DeliveryStatusSucceeded
+	})).
+		Return(nil).
+		Once()
+
+	service := NewWebhookService(mockRepo, kek, server.Client()).(*webhookService)
+	service.now = func() time.Time { return now }
+
+	service.deliver(context.Background(), &model.WebhookDelivery{
+		ID:      7,
+		Payload: json.RawMessage(`{}`),
+		Status:  model.DeliveryStatusPending,
+		URL:     server.URL,
+		Secret:  sealed,
+	})
+
+	assert.Equal(t, "sha256="+signWebhook("s3cret", "1700000000", []byte(`{}`)), signature)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWebhookService_Deliver_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	now := time.Unix(1700000000, 0)
+
+	t.Run("retried with backoff", func(t *testing.T) {
+		mockRepo := new(mocks.WebhookRepository)
+		mockRepo.On("RecordAttempt", mock.MatchedBy(func(d *model.WebhookDelivery) bool {
+			return d.Status == model.DeliveryStatusPending &&
+				d.Attempts == 3 &&
+				d.ResponseStatus == http.StatusBadGateway &&
+				d.LastError == "unexpected status 502" &&
+				d.NextAttemptAt != nil && d.NextAttemptAt.Equal(now.Add(40*time.Second))
+		})).
+			Return(nil).
+			Once()
+
+		service := NewWebhookService(mockRepo, nil, server.Client()).(*webhookService)
+		service.now = func() time.Time { return now }
+		service.deliver(context.Background(), &model.WebhookDelivery{
+			ID: 7, Attempts: 2, Status: model.DeliveryStatusPending, URL: server.URL, Payload: json.RawMessage(`{}`),
+		})
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("out of attempts", func(t *testing.T) {
+		mockRepo := new(mocks.WebhookRepository)
+		mockRepo.On("RecordAttempt", mock.MatchedBy(func(d *model.WebhookDelivery) bool {
+			return d.Status == model.DeliveryStatusFailed &&
+				d.Attempts == webhookMaxAttempts &&
+				d.NextAttemptAt == nil
+		})).
+			Return(nil).
+			Once()
+
+		service := NewWebhookService(mockRepo, nil, server.Client()).(*webhookService)
+		service.now = func() time.Time { return now }
+		service.deliver(context.Background(), &model.WebhookDelivery{
+			ID: 7, Attempts: webhookMaxAttempts - 1, Status: model.DeliveryStatusPending, URL: server.URL, Payload: json.RawMessage(`{}`),
+		})
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestWebhookService_Run_DeliversSignaledEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	started := make(chan struct{})
+	recorded := make(chan *model.WebhookDelivery, 1)
+
+	mockRepo := new(mocks.WebhookRepository)
+	mockRepo.On("ClaimDue", webhookBatchSize, webhookLease).
+		Run(func(mock.Arguments) { close(started) }).
+		Return([]model.WebhookDelivery{}, nil).
+		Once()
+	mockRepo.On("ClaimDue", webhookBatchSize, webhookLease).
+		Return([]model.WebhookDelivery{{ID: 1, Status: model.DeliveryStatusPending, URL: server.URL, Payload: json.RawMessage(`{}`)}}, nil).
+		Once()
+	mockRepo.On("RecordAttempt", mock.Anything).
+		Run(func(args mock.Arguments) { recorded <- args.Get(0).(*model.WebhookDelivery) }).
+		Return(nil).
+		Once()
+	mockRepo.On("ClaimDue", webhookBatchSize, webhookLease).Return([]model.WebhookDelivery{}, nil)
+
+	service := NewWebhookService(mockRepo, nil, server.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.Run(ctx, time.Hour)
+
+	<-started
+	service.Signal()
+
+	select {
+	case d := <-recorded:
+		assert.Equal(t, model.DeliveryStatusSucceeded, d.Status)
+	case <-time.After(time.Second):
+		t.Fatal("expected the signaled event to be delivered without waiting for the interval")
+	}
+}
+
+func TestWebhookService_Redeliver(t *testing.T) {
+	t.Run("queues a copy", func(t *testing.T) {
+		mockRepo := new(mocks.WebhookRepository)
+		mockRepo.On("GetDelivery", int64(7)).Return(&model.WebhookDelivery{ID: 7, WebhookID: "w1"}, nil).Once()
+		mockRepo.On("Redeliver", int64(7)).Return(int64(9), nil).Once()
+		mockRepo.On("GetDelivery", int64(9)).Return(&model.WebhookDelivery{ID: 9, WebhookID: "w1", RedeliveryOf: 7}, nil).Once()
+
+		d, err := NewWebhookService(mockRepo, nil, nil).Redeliver("w1", 7)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), d.RedeliveryOf)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("delivery of another webhook", func(t *testing.T) {
+		mockRepo := new(mocks.WebhookRepository)
+		mockRepo.On("GetDelivery", int64(7)).Return(&model.WebhookDelivery{ID: 7, WebhookID: "w2"}, nil).Once()
+
+		_, err := NewWebhookService(mockRepo, nil, nil).Redeliver("w1", 7)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		mockRepo.AssertNotCalled(t, "Redeliver", mock.Anything)
+	})
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{7, 640 * time.Second},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, webhookBackoff(tt.attempts), "attempts=%d", tt.attempts)
+	}
+}
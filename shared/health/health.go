@@ -0,0 +1,87 @@
+// Package health serves the liveness (/healthz) and readiness (/readyz)
+// probes of the services.
+package health
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Probe and check states.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// checkTimeout bounds each readiness check, so a hanging dependency fails the
+// probe instead of stalling it.
+const checkTimeout = 2 * time.Second
+
+// Check is one readiness condition. Run returns nil when it holds.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Name      string  `json:"name" example:"database"`
+	Status    string  `json:"status" example:"ok"`
+	LatencyMS float64 `json:"latency_ms" example:"1.25"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string        `json:"status" example:"ok"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// Liveness answers 200 as long as the process serves HTTP.
+func Liveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, Report{Status: StatusOK})
+	}
+}
+
+// Readiness runs checks and answers 200 when all of them pass, 503
+// otherwise. The body lists every check with its status and latency.
+func Readiness(checks ...Check) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := Run(c.Request.Context(), checks)
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
+
+// Run executes checks one after another.
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: StatusOK, Checks: make([]CheckResult, 0, len(checks))}
+
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		start := time.Now()
+		err := check.Run(checkCtx)
+		latency := time.Since(start)
+		cancel()
+
+		result := CheckResult{
+			Name:      check.Name,
+			Status:    StatusOK,
+			LatencyMS: float64(latency.Microseconds()) / 1000,
+		}
+		if err != nil {
+			result.Status = StatusFail
+			result.Error = err.Error()
+			report.Status = StatusFail
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
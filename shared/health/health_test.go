@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func passing(name string) Check {
+	return Check{Name: name, Run: func(context.Context) error { return nil }}
+}
+
+func failing(name string, err error) Check {
+	return Check{Name: name, Run: func(context.Context) error { return err }}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantStatus string
+		wantChecks []string
+	}{
+		{name: "no checks", wantStatus: StatusOK},
+		{
+			name:       "all pass",
+			checks:     []Check{passing("database"), passing("upstream")},
+			wantStatus: StatusOK,
+			wantChecks: []string{StatusOK, StatusOK},
+		},
+		{
+			name:       "one fails",
+			checks:     []Check{failing("database", errors.New("connection refused")), passing("upstream")},
+			wantStatus: StatusFail,
+			wantChecks: []string{StatusFail, StatusOK},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(context.Background(), tt.checks)
+
+			assert.Equal(t, tt.wantStatus, report.Status)
+			require.Len(t, report.Checks, len(tt.wantChecks))
+			for i, want := range tt.wantChecks {
+				assert.Equal(t, tt.checks[i].Name, report.Checks[i].Name)
+				assert.Equal(t, want, report.Checks[i].Status)
+			}
+		})
+	}
+}
+
+func TestRun_ReportsError(t *testing.T) {
+	report := Run(context.Background(), []Check{failing("database", errors.New("connection refused"))})
+
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "connection refused", report.Checks[0].Error)
+	assert.GreaterOrEqual(t, report.Checks[0].LatencyMS, 0.0)
+}
+
+func TestRun_PerCheckTimeout(t *testing.T) {
+	var deadlines []time.Time
+	check := Check{Name: "slow", Run: func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok, "each check gets a deadline")
+		deadlines = append(deadlines, deadline)
+		return nil
+	}}
+
+	start := time.Now()
+	Run(context.Background(), []Check{check, check})
+
+	require.Len(t, deadlines, 2)
+	for _, deadline := range deadlines {
+		assert.WithinDuration(t, start.Add(checkTimeout), deadline, time.Second)
+	}
+}
+
+func TestRun_ParentCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := Run(ctx, []Check{{Name: "database", Run: func(ctx context.Context) error { return ctx.Err() }}})
+
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, context.Canceled.Error(), report.Checks[0].Error)
+}
+
+func serve(handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/probe", handler)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/probe", nil))
+	return resp
+}
+
+func TestLiveness(t *testing.T) {
+	resp := serve(Liveness())
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, resp.Body.String())
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name     string
+		checks   []Check
+		wantCode int
+	}{
+		{name: "ready", checks: []Check{passing("database")}, wantCode: http.StatusOK},
+		{name: "not ready", checks: []Check{passing("database"), failing("upstream", errors.New("timeout"))}, wantCode: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(Readiness(tt.checks...))
+
+			assert.Equal(t, tt.wantCode, resp.Code)
+
+			var report Report
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &report))
+			assert.Len(t, report.Checks, len(tt.checks))
+		})
+	}
+}
@@ -58,6 +58,13 @@ func Unauthorized(c *gin.Context) {
 	Error(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
 }
 
+func Forbidden(c *gin.Context, message string) {
+	if message == "" {
+		message = "forbidden"
+	}
+	Error(c, http.StatusForbidden, "FORBIDDEN", message)
+}
+
 func NotFound(c *gin.Context, message string) {
 	if message == "" {
 		message = "resource not found"
@@ -83,6 +90,20 @@ func ValidationError(c *gin.Context, err error, requestStruct interface{}) {
 	})
 }
 
+func FieldValidationError(c *gin.Context, field, code, message string) {
+	c.JSON(http.StatusBadRequest, ValidationErrorResponse{
+		Error: ValidationErrorDetail{
+			Code:    "VALIDATION_ERROR",
+			Message: "validation failed",
+			Fields: []ValidationFieldError{{
+				Field:   field,
+				Code:    code,
+				Message: message,
+			}},
+		},
+	})
+}
+
 func FromError(c *gin.Context, err error) {
 	if errors.Is(err, sql.ErrNoRows) {
 		NotFound(c, "")
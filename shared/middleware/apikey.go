@@ -1,18 +1,37 @@
 package middleware
 
 import (
+	"crypto/subtle"
+
 	sharedhttpresponse "github.com/mrheza/distributed-config-management/shared/httpresponse"
 
 	"github.com/gin-gonic/gin"
 )
 
+// IdentityKey is the gin context key holding the name of the authenticated
+// caller.
+const IdentityKey = "identity"
+
 func APIKeyAuth(key string) gin.HandlerFunc {
+	return APIKeyAuthAs(key, "")
+}
+
+// APIKeyAuthAs behaves like APIKeyAuth and records identity as the caller.
+func APIKeyAuthAs(key, identity string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.GetHeader("X-API-Key") != key {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-API-Key")), []byte(key)) != 1 {
 			sharedhttpresponse.Unauthorized(c)
 			c.Abort()
 			return
 		}
+		if identity != "" {
+			c.Set(IdentityKey, identity)
+		}
 		c.Next()
 	}
 }
+
+// Identity returns the authenticated caller, or "" when unknown.
+func Identity(c *gin.Context) string {
+	return c.GetString(IdentityKey)
+}
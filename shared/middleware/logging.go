@@ -10,10 +10,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// RequestIDKey is the gin context key holding the request ID.
+const RequestIDKey = "request_id"
+
 type requestLog struct {
 	Timestamp string `json:"timestamp"`
 	RequestID string `json:"request_id"`
+	TraceID   string `json:"trace_id,omitempty"`
 	AgentID   string `json:"agent_id,omitempty"`
+	Identity  string `json:"identity,omitempty"`
 	Method    string `json:"method"`
 	Path      string `json:"path"`
 	Query     string `json:"query,omitempty"`
@@ -33,13 +38,16 @@ func RequestLogger() gin.HandlerFunc {
 			requestID = newRequestID()
 		}
 		c.Writer.Header().Set("X-Request-ID", requestID)
+		c.Set(RequestIDKey, requestID)
 
 		c.Next()
 
 		entry := requestLog{
 			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
 			RequestID: requestID,
+			TraceID:   TraceID(c),
 			AgentID:   c.GetHeader("X-Agent-ID"),
+			Identity:  c.GetString(IdentityKey),
 			Method:    c.Request.Method,
 			Path:      c.Request.URL.Path,
 			Query:     c.Request.URL.RawQuery,
@@ -63,6 +71,11 @@ func RequestLogger() gin.HandlerFunc {
 	}
 }
 
+// RequestID returns the ID RequestLogger assigned to the request.
+func RequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
 func newRequestID() string {
 	b := make([]byte, 12)
 	if _, err := rand.Read(b); err != nil {
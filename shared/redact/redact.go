@@ -0,0 +1,21 @@
+// Package redact hides config secret values in responses and state dumps.
+package redact
+
+// Value replaces each secret value.
+const Value = "[REDACTED]"
+
+// Secrets returns a copy of secrets with every value replaced by Value, so the
+// names stay visible. A nil map stays nil. Callers also drop the config
+// signature next to redacted secrets, as it would let a reader confirm
+// guesses of the values.
+func Secrets(secrets map[string]string) map[string]string {
+	if secrets == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(secrets))
+	for name := range secrets {
+		out[name] = Value
+	}
+	return out
+}
@@ -0,0 +1,15 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecrets(t *testing.T) {
+	secrets := map[string]string{"db_password": "hunter2", "token": "abc"}
+
+	assert.Equal(t, map[string]string{"db_password": Value, "token": Value}, Secrets(secrets))
+	assert.Equal(t, "hunter2", secrets["db_password"], "the input is not modified")
+	assert.Nil(t, Secrets(nil))
+}
@@ -0,0 +1,117 @@
+// Package signing signs config versions on the controller and verifies them on
+// agents and workers.
+//
+// A signature covers the fields every hop forwards: version, namespace, url,
+// poll_interval_seconds, data and secrets. The signed message is their JSON
+// encoding in that order with data compacted and secrets sorted by key, so
+// intermediaries re-encoding the document do not invalidate it.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInvalidSignature is returned by Verify for missing, malformed or
+// mismatching signatures.
+var ErrInvalidSignature = errors.New("invalid config signature")
+
+// Payload holds the signed fields of a config version.
+type Payload struct {
+	Version             int               `json:"version"`
+	Namespace           string            `json:"namespace"`
+	URL                 string            `json:"url"`
+	PollIntervalSeconds int               `json:"poll_interval_seconds"`
+	Data                json.RawMessage   `json:"data,omitempty"`
+	Secrets             map[string]string `json:"secrets,omitempty"`
+}
+
+// Message returns the canonical bytes signed for p.
+func Message(p Payload) ([]byte, error) {
+	// encoding/json compacts RawMessage values and sorts map keys, which makes
+	// data and secrets canonical.
+	return json.Marshal(p)
+}
+
+// Sign returns the base64 encoded Ed25519 signature of p.
+func Sign(key ed25519.PrivateKey, p Payload) (string, error) {
+	msg, err := Message(p)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, msg)), nil
+}
+
+// Verify checks a signature produced by Sign.
+func Verify(key ed25519.PublicKey, p Payload, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrInvalidSignature
+	}
+
+	msg, err := Message(p)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !ed25519.Verify(key, msg, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// LoadPrivateKey reads a PEM encoded PKCS #8 Ed25519 private key, as written by
+// `openssl genpkey -algorithm ed25519`.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 private key", path)
+	}
+	return priv, nil
+}
+
+// LoadPublicKey reads a PEM encoded PKIX Ed25519 public key, as written by
+// `openssl pkey -pubout`.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 public key", path)
+	}
+	return pub, nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: no %q PEM block", path, blockType)
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,154 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return pub, priv
+}
+
+func testPayload() Payload {
+	return Payload{
+		Version:             7,
+		Namespace:           "prod",
+		URL:                 "https://example.com",
+		PollIntervalSeconds: 30,
+		Data:                json.RawMessage(`{"region":"eu"}`),
+		Secrets:             map[string]string{"token": "s3cret", "api_key": "k"},
+	}
+}
+
+func TestMessage_Canonical(t *testing.T) {
+	msg, err := Message(testPayload())
+	require.NoError(t, err)
+	assert.Equal(t,
+		`{"version":7,"namespace":"prod","url":"https://example.com","poll_interval_seconds":30,`+
+			`"data":{"region":"eu"},"secrets":{"api_key":"k","token":"s3cret"}}`,
+		string(msg),
+	)
+
+	// Re-encoded data keeps the signature valid.
+	reformatted := testPayload()
+	reformatted.Data = json.RawMessage("{\n  \"region\": \"eu\"\n}")
+	again, err := Message(reformatted)
+	require.NoError(t, err)
+	assert.Equal(t, msg, again)
+}
+
+func TestMessage_OmitsEmptyDataAndSecrets(t *testing.T) {
+	msg, err := Message(Payload{Version: 1, Namespace: "default", URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"version":1,"namespace":"default","url":"https://example.com","poll_interval_seconds":0}`, string(msg))
+}
+
+func TestSignVerify(t *testing.T) {
+	pub, priv := newKey(t)
+
+	signature, err := Sign(priv, testPayload())
+	require.NoError(t, err)
+	assert.NoError(t, Verify(pub, testPayload(), signature))
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	pub, priv := newKey(t)
+
+	signature, err := Sign(priv, testPayload())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		tamper func(p *Payload)
+	}{
+		{name: "version", tamper: func(p *Payload) { p.Version = 8 }},
+		{name: "namespace", tamper: func(p *Payload) { p.Namespace = "dev" }},
+		{name: "url", tamper: func(p *Payload) { p.URL = "https://evil.example.com" }},
+		{name: "poll interval", tamper: func(p *Payload) { p.PollIntervalSeconds = 1 }},
+		{name: "data", tamper: func(p *Payload) { p.Data = json.RawMessage(`{"region":"us"}`) }},
+		{name: "data removed", tamper: func(p *Payload) { p.Data = nil }},
+		{name: "secret", tamper: func(p *Payload) { p.Secrets["token"] = "other" }},
+		{name: "secrets removed", tamper: func(p *Payload) { p.Secrets = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPayload()
+			tt.tamper(&p)
+			assert.ErrorIs(t, Verify(pub, p, signature), ErrInvalidSignature)
+		})
+	}
+}
+
+func TestVerify_InvalidSignature(t *testing.T) {
+	pub, priv := newKey(t)
+	otherPub, _ := newKey(t)
+
+	signature, err := Sign(priv, testPayload())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		key       ed25519.PublicKey
+		signature string
+	}{
+		{name: "missing", key: pub, signature: ""},
+		{name: "not base64", key: pub, signature: "not base64!"},
+		{name: "wrong length", key: pub, signature: "c2hvcnQ="},
+		{name: "other key", key: otherPub, signature: signature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, Verify(tt.key, testPayload(), tt.signature), ErrInvalidSignature)
+		})
+	}
+}
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func TestLoadKeys(t *testing.T) {
+	pub, priv := newKey(t)
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	loadedPriv, err := LoadPrivateKey(writePEM(t, "PRIVATE KEY", privDER))
+	require.NoError(t, err)
+	loadedPub, err := LoadPublicKey(writePEM(t, "PUBLIC KEY", pubDER))
+	require.NoError(t, err)
+
+	signature, err := Sign(loadedPriv, testPayload())
+	require.NoError(t, err)
+	assert.NoError(t, Verify(loadedPub, testPayload(), signature))
+}
+
+func TestLoadKeys_WrongBlockType(t *testing.T) {
+	pub, _ := newKey(t)
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	path := writePEM(t, "PUBLIC KEY", pubDER)
+
+	_, err = LoadPrivateKey(path)
+	assert.ErrorContains(t, err, `no "PRIVATE KEY" PEM block`)
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestValidExporter(t *testing.T) {
+	tests := []struct {
+		exporter string
+		want     bool
+	}{
+		{exporter: ExporterNone, want: true},
+		{exporter: ExporterStdout, want: true},
+		{exporter: ExporterOTLP, want: true},
+		{exporter: "", want: false},
+		{exporter: "jaeger", want: false},
+		{exporter: "OTLP", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ValidExporter(tt.exporter), tt.exporter)
+	}
+}
+
+func TestInit_None(t *testing.T) {
+	shutdown, err := Init(context.Background(), "test", ExporterNone)
+	require.NoError(t, err)
+	require.NotNil(t, shutdown)
+	assert.NoError(t, shutdown(context.Background()))
+
+	// Trace context is still propagated without an exporter.
+	assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+}
+
+func TestInit_UnknownExporter(t *testing.T) {
+	_, err := Init(context.Background(), "test", "jaeger")
+	assert.ErrorContains(t, err, `unknown trace exporter "jaeger"`)
+}
+
+// recordSpans installs a tracer provider recording ended spans for the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	_, err := Init(context.Background(), "test", ExporterNone)
+	require.NoError(t, err)
+	return recorder
+}
+
+func TestTransport_InjectsTraceContext(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/config", nil)
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.End()
+
+	assert.Contains(t, traceparent, parent.SpanContext().TraceID().String())
+	assert.Empty(t, req.Header.Get("traceparent"), "the caller's request is not modified")
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	client := spans[0]
+	assert.Equal(t, "HTTP GET", client.Name())
+	assert.Equal(t, trace.SpanKindClient, client.SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), client.Parent().SpanID())
+	assert.Equal(t, codes.Error, client.Status().Code, "5xx answers mark the span failed")
+}
+
+type failingTransport struct{ err error }
+
+func (f failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, f.err
+}
+
+func TestTransport_RecordsError(t *testing.T) {
+	recorder := recordSpans(t)
+
+	req := httptest.NewRequest(http.MethodGet, "http://upstream.invalid/", nil)
+	_, err := Transport(failingTransport{err: errors.New("dial failed")}).RoundTrip(req)
+	assert.EqualError(t, err, "dial failed")
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "dial failed", spans[0].Status().Description)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"database/sql"
 	"errors"
 	"log"
 	"net/http"
@@ -17,6 +19,9 @@ import (
 	"worker/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mrheza/distributed-config-management/shared/health"
+	"github.com/mrheza/distributed-config-management/shared/signing"
+	"github.com/mrheza/distributed-config-management/shared/tracing"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -34,27 +39,55 @@ func main() {
 		log.Fatal(err)
 	}
 	log.Printf(
-		"event=worker_config_loaded port=%s gin_mode=%s timeout_secs=%d",
+		"event=worker_config_loaded port=%s gin_mode=%s timeout_secs=%d verify_signatures=%t config_store=%s trace_exporter=%s",
 		cfg.Port,
 		cfg.GinMode,
 		cfg.RequestTimeoutSeconds,
+		cfg.PublicKeyFile != "",
+		cfg.ConfigStore,
+		cfg.TraceExporter,
 	)
 	gin.SetMode(cfg.GinMode)
 
-	repo := repository.NewMemoryConfigRepository()
+	shutdownTracing, err := tracing.Init(context.Background(), "worker", cfg.TraceExporter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var verifyKey ed25519.PublicKey
+	if cfg.PublicKeyFile != "" {
+		key, err := signing.LoadPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		verifyKey = key
+	}
+
+	repo, err := newConfigRepository(cfg, verifyKey)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fetch := client.NewFetchClient(cfg.RequestTimeoutSeconds)
 	workerSvc := service.NewWorkerService(repo, fetch)
-	h := handler.New(workerSvc)
+
+	h := handler.New(workerSvc, verifyKey)
 
 	r := gin.New()
 	r.Use(middleware.RequestLogger())
+	r.Use(middleware.Metrics())
+	r.Use(middleware.Tracing("worker"))
 	r.Use(gin.Recovery())
 	r.Use(middleware.CORSMiddleware())
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.GET("/metrics", middleware.MetricsHandler())
 	agent := r.Group("/", middleware.APIKeyAuth(cfg.AgentAPIKey))
 	agent.POST("/config", h.SetConfig)
 	r.GET("/hit", h.Hit)
 	r.GET("/state", h.GetState)
+	r.GET("/healthz", health.Liveness())
+	r.GET("/readyz", health.Readiness(
+		health.Check{Name: "config", Run: workerSvc.CheckConfig},
+	))
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -74,4 +107,43 @@ func main() {
 	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("tracing shutdown error: %v", err)
+	}
+}
+
+// newConfigRepository returns the store selected by CONFIG_STORE. A stored
+// config that no longer verifies, e.g. one saved before signing was enabled,
+// is dropped so the worker waits for the agent instead of serving it.
+func newConfigRepository(cfg *config.Config, verifyKey ed25519.PublicKey) (repository.ConfigRepository, error) {
+	if cfg.ConfigStore != config.ConfigStoreFile {
+		return repository.NewMemoryConfigRepository(), nil
+	}
+
+	repo, err := repository.NewFileConfigRepository(cfg.ConfigStorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := repo.Get()
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("event=worker_config_store_empty path=%s", cfg.ConfigStorePath)
+		return repo, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if verifyKey != nil {
+		if err := signing.Verify(verifyKey, stored.SigningPayload(), stored.Signature); err != nil {
+			log.Printf("event=worker_stored_config_signature_invalid version=%d err=%q", stored.Version, err)
+			return repo, repo.Set(nil)
+		}
+	}
+
+	log.Printf("event=worker_config_loaded_from_store path=%s version=%d url=%s", cfg.ConfigStorePath, stored.Version, stored.URL)
+	return repo, nil
 }
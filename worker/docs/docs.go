@@ -22,7 +22,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Called by Agent to update worker configuration",
+                "description": "Called by Agent to update worker configuration. Configs without a valid controller signature are rejected when CONFIG_PUBLIC_KEY_FILE is set, versions older than the applied one unless force is set, and data.request that cannot be built",
                 "consumes": [
                     "application/json"
                 ],
@@ -41,6 +41,12 @@ const docTemplate = `{
                         "in": "header",
                         "required": true
                     },
+                    {
+                        "type": "boolean",
+                        "description": "apply even if the version is older than the applied one",
+                        "name": "force",
+                        "in": "query"
+                    },
                     {
                         "description": "Worker config",
                         "name": "request",
@@ -70,6 +76,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/httpresponse.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpresponse.VersionConflictResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -81,7 +93,7 @@ const docTemplate = `{
         },
         "/hit": {
             "get": {
-                "description": "Executes HTTP GET to configured URL and returns raw response body",
+                "description": "Sends the request described by data.request of the applied config (a GET to its URL by default) and returns the raw upstream response. An upstream status outside data.request.expected_status is answered with 502",
                 "produces": [
                     "text/plain"
                 ],
@@ -107,13 +119,19 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httpresponse.ErrorResponse"
                         }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/httpresponse.ErrorResponse"
+                        }
                     }
                 }
             }
         },
         "/state": {
             "get": {
-                "description": "Returns current configuration used by worker",
+                "description": "Returns current configuration used by worker, with secret values redacted",
                 "produces": [
                     "application/json"
                 ],
@@ -164,15 +182,42 @@ const docTemplate = `{
                 }
             }
         },
+        "httpresponse.VersionConflictResponse": {
+            "type": "object",
+            "properties": {
+                "current_version": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "error": {
+                    "$ref": "#/definitions/httpresponse.ErrorDetail"
+                }
+            }
+        },
         "model.Config": {
             "type": "object",
             "required": [
                 "url"
             ],
             "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "namespace": {
+                    "type": "string"
+                },
                 "poll_interval_seconds": {
                     "type": "integer"
                 },
+                "secrets": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "signature": {
+                    "type": "string"
+                },
                 "url": {
                     "type": "string"
                 },
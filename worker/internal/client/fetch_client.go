@@ -1,14 +1,20 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
+	"worker/internal/metrics"
+	"worker/internal/model"
+
+	"github.com/mrheza/distributed-config-management/shared/tracing"
 )
 
 type FetchClient interface {
-	Get(ctx context.Context, url string) (status int, contentType string, body []byte, err error)
+	Do(ctx context.Context, req *model.UpstreamRequest) (status int, contentType string, body []byte, err error)
 }
 
 type fetchClient struct {
@@ -20,20 +26,31 @@ func NewFetchClient(timeoutSeconds int) FetchClient {
 	if t <= 0 {
 		t = 10 * time.Second
 	}
-	return &fetchClient{http: &http.Client{Timeout: t}}
+	return &fetchClient{http: &http.Client{Timeout: t, Transport: tracing.Transport(nil)}}
 }
 
-func (c *fetchClient) Get(ctx context.Context, url string) (int, string, []byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (c *fetchClient) Do(ctx context.Context, upstream *model.UpstreamRequest) (int, string, []byte, error) {
+	var reqBody io.Reader
+	if len(upstream.Body) > 0 {
+		reqBody = bytes.NewReader(upstream.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, upstream.Method, upstream.URL, reqBody)
 	if err != nil {
 		return 0, "", nil, err
 	}
+	for name, value := range upstream.Headers {
+		req.Header.Set(name, value)
+	}
 
+	start := time.Now()
 	resp, err := c.http.Do(req)
+	metrics.UpstreamDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.UpstreamResponses.WithLabelValues(metrics.UpstreamError).Inc()
 		return 0, "", nil, err
 	}
 	defer resp.Body.Close()
+	metrics.UpstreamResponses.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -2,11 +2,15 @@ package client
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
+	"worker/internal/metrics"
+	"worker/internal/model"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,7 +30,7 @@ func TestNewFetchClient_CustomTimeout(t *testing.T) {
 	}
 }
 
-func TestFetchClient_Get_Success(t *testing.T) {
+func TestFetchClient_Do_Success(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusCreated)
@@ -34,22 +38,48 @@ func TestFetchClient_Get_Success(t *testing.T) {
 	}))
 	defer srv.Close()
 
+	before := testutil.ToFloat64(metrics.UpstreamResponses.WithLabelValues("201"))
+
 	c := NewFetchClient(5)
-	status, contentType, body, err := c.Get(context.Background(), srv.URL)
+	status, contentType, body, err := c.Do(context.Background(), &model.UpstreamRequest{Method: http.MethodGet, URL: srv.URL})
 
 	assert.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.UpstreamResponses.WithLabelValues("201")))
 	assert.Equal(t, http.StatusCreated, status)
 	assert.Contains(t, contentType, "text/plain")
 	assert.Equal(t, []byte("ok"), body)
 }
 
-func TestFetchClient_Get_InvalidURL(t *testing.T) {
+func TestFetchClient_Do_SendsMethodHeadersAndBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer s3cret", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"a":1}`, string(body))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	c := NewFetchClient(5)
+	status, _, _, err := c.Do(context.Background(), &model.UpstreamRequest{
+		Method:  http.MethodPost,
+		URL:     srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer s3cret"},
+		Body:    []byte(`{"a":1}`),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, status)
+}
+
+func TestFetchClient_Do_InvalidURL(t *testing.T) {
 	c := NewFetchClient(5)
-	_, _, _, err := c.Get(context.Background(), "://bad-url")
+	_, _, _, err := c.Do(context.Background(), &model.UpstreamRequest{Method: http.MethodGet, URL: "://bad-url"})
 	assert.Error(t, err)
 }
 
-func TestFetchClient_Get_ContextCanceled(t *testing.T) {
+func TestFetchClient_Do_ContextCanceled(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(200 * time.Millisecond)
 		w.WriteHeader(http.StatusOK)
@@ -60,6 +90,6 @@ func TestFetchClient_Get_ContextCanceled(t *testing.T) {
 	defer cancel()
 
 	c := NewFetchClient(5)
-	_, _, _, err := c.Get(ctx, srv.URL)
+	_, _, _, err := c.Do(ctx, &model.UpstreamRequest{Method: http.MethodGet, URL: srv.URL})
 	assert.Error(t, err)
 }
@@ -7,13 +7,24 @@ import (
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/mrheza/distributed-config-management/shared/tracing"
+)
+
+// Config stores.
+const (
+	ConfigStoreMemory = "memory"
+	ConfigStoreFile   = "file"
 )
 
 type Config struct {
 	RequestTimeoutSeconds int
 	AgentAPIKey           string
+	PublicKeyFile         string
 	GinMode               string
 	Port                  string
+	TraceExporter         string
+	ConfigStore           string
+	ConfigStorePath       string
 }
 
 func Load() *Config {
@@ -22,8 +33,12 @@ func Load() *Config {
 	return &Config{
 		RequestTimeoutSeconds: getEnvInt("REQUEST_TIMEOUT_SECONDS"),
 		AgentAPIKey:           os.Getenv("AGENT_API_KEY"),
+		PublicKeyFile:         os.Getenv("CONFIG_PUBLIC_KEY_FILE"),
 		GinMode:               os.Getenv("GIN_MODE"),
 		Port:                  os.Getenv("PORT"),
+		TraceExporter:         getEnvDefault("OTEL_TRACES_EXPORTER", tracing.ExporterNone),
+		ConfigStore:           getEnvDefault("CONFIG_STORE", ConfigStoreMemory),
+		ConfigStorePath:       getEnvDefault("CONFIG_STORE_PATH", "data/worker_config.json"),
 	}
 }
 
@@ -45,6 +60,12 @@ func (c *Config) Validate() error {
 	if c.RequestTimeoutSeconds <= 0 {
 		return fmt.Errorf("invalid REQUEST_TIMEOUT_SECONDS: must be > 0")
 	}
+	if c.ConfigStore != ConfigStoreMemory && c.ConfigStore != ConfigStoreFile {
+		return fmt.Errorf("invalid CONFIG_STORE: must be %s or %s", ConfigStoreMemory, ConfigStoreFile)
+	}
+	if !tracing.ValidExporter(c.TraceExporter) {
+		return fmt.Errorf("invalid OTEL_TRACES_EXPORTER: must be none, stdout or otlp")
+	}
 
 	return nil
 }
@@ -60,3 +81,10 @@ func getEnvInt(k string) int {
 	}
 	return v
 }
+
+func getEnvDefault(k, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+		return v
+	}
+	return fallback
+}
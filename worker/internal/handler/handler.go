@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"log"
 	"net/http"
 	"worker/internal/httpresponse"
@@ -8,19 +10,28 @@ import (
 	"worker/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mrheza/distributed-config-management/shared/redact"
+	"github.com/mrheza/distributed-config-management/shared/signing"
 )
 
+type SetConfigQuery struct {
+	Force bool `form:"force"`
+}
+
 type Handler struct {
 	workerService service.WorkerService
+	verifyKey     ed25519.PublicKey
 }
 
-func New(ws service.WorkerService) *Handler {
-	return &Handler{workerService: ws}
+// New returns a Handler that rejects configs not signed by verifyKey; a nil
+// key accepts unsigned configs.
+func New(ws service.WorkerService, verifyKey ed25519.PublicKey) *Handler {
+	return &Handler{workerService: ws, verifyKey: verifyKey}
 }
 
 // GetState godoc
 // @Summary Worker state
-// @Description Returns current configuration used by worker
+// @Description Returns current configuration used by worker, with secret values redacted
 // @Tags worker
 // @Produce json
 // @Success 200 {object} model.Config
@@ -34,50 +45,90 @@ func (h *Handler) GetState(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, cfg)
+	c.JSON(http.StatusOK, redactSecrets(cfg))
 }
 
 // SetConfig godoc
 // @Summary Apply worker config
-// @Description Called by Agent to update worker configuration
+// @Description Called by Agent to update worker configuration. Configs without a valid controller signature are rejected when CONFIG_PUBLIC_KEY_FILE is set, versions older than the applied one unless force is set, and data.request that cannot be built
 // @Tags worker
 // @Accept json
 // @Produce json
 // @Param X-API-Key header string true "API key"
+// @Param force query bool false "apply even if the version is older than the applied one"
 // @Param request body model.Config true "Worker config"
 // @Success 200 {object} model.ConfigUpdateResponse
 // @Failure 400 {object} httpresponse.ErrorResponse
 // @Failure 401 {object} httpresponse.ErrorResponse
+// @Failure 409 {object} httpresponse.VersionConflictResponse
 // @Failure 500 {object} httpresponse.ErrorResponse
 // @Security ApiKeyAuth
 // @Router /config [post]
 func (h *Handler) SetConfig(c *gin.Context) {
+	var query SetConfigQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		httpresponse.ValidationError(c, err, query)
+		return
+	}
+
 	var req model.Config
 	if err := c.ShouldBindJSON(&req); err != nil {
 		httpresponse.ValidationError(c, err, req)
 		return
 	}
 
-	if err := h.workerService.ApplyConfig(&req); err != nil {
+	if h.verifyKey != nil {
+		if err := signing.Verify(h.verifyKey, req.SigningPayload(), req.Signature); err != nil {
+			log.Printf("event=worker_config_signature_invalid version=%d", req.Version)
+			httpresponse.Error(c, http.StatusBadRequest, "INVALID_SIGNATURE", err.Error())
+			return
+		}
+	}
+
+	// A request that cannot be built would fail every hit, so it is rejected
+	// before it replaces the applied config.
+	if _, _, err := req.UpstreamRequest(); err != nil {
+		log.Printf("event=worker_config_request_invalid version=%d err=%q", req.Version, err)
+		httpresponse.Error(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		return
+	}
+
+	err := h.workerService.ApplyConfig(&req, query.Force)
+	var stale *service.StaleVersionError
+	if errors.As(err, &stale) {
+		log.Printf("event=worker_config_stale version=%d current_version=%d", stale.Received, stale.Current)
+		c.JSON(http.StatusConflict, httpresponse.VersionConflictResponse{
+			Error:          httpresponse.ErrorDetail{Code: "STALE_VERSION", Message: err.Error()},
+			CurrentVersion: stale.Current,
+		})
+		return
+	}
+	if err != nil {
 		httpresponse.FromError(c, err)
 		return
 	}
 
-	log.Printf("event=worker_config_updated version=%d url=%s poll_interval_secs=%d", req.Version, req.URL, req.PollIntervalSeconds)
+	log.Printf("event=worker_config_updated version=%d url=%s poll_interval_secs=%d data_bytes=%d", req.Version, req.URL, req.PollIntervalSeconds, len(req.Data))
 	c.JSON(http.StatusOK, model.ConfigUpdateResponse{Message: "config updated"})
 }
 
 // Hit godoc
 // @Summary Execute hit task
-// @Description Executes HTTP GET to configured URL and returns raw response body
+// @Description Sends the request described by data.request of the applied config (a GET to its URL by default) and returns the raw upstream response. An upstream status outside data.request.expected_status is answered with 502
 // @Tags worker
 // @Produce plain
 // @Success 200 {string} string
 // @Failure 404 {object} httpresponse.ErrorResponse
 // @Failure 500 {object} httpresponse.ErrorResponse
+// @Failure 502 {object} httpresponse.ErrorResponse
 // @Router /hit [get]
 func (h *Handler) Hit(c *gin.Context) {
 	status, contentType, body, err := h.workerService.Hit(c.Request.Context())
+	var unexpected *service.UnexpectedStatusError
+	if errors.As(err, &unexpected) {
+		httpresponse.Error(c, http.StatusBadGateway, "UNEXPECTED_UPSTREAM_STATUS", err.Error())
+		return
+	}
 	if err != nil {
 		httpresponse.FromError(c, err)
 		return
@@ -92,3 +143,14 @@ func (h *Handler) Hit(c *gin.Context) {
 
 	c.Data(status, contentType, body)
 }
+
+// redactSecrets returns a copy of cfg for GET /state whose secret values are
+// hidden.
+func redactSecrets(cfg *model.Config) *model.Config {
+	out := *cfg
+	if cfg.Secrets != nil {
+		out.Secrets = redact.Secrets(cfg.Secrets)
+		out.Signature = ""
+	}
+	return &out
+}
@@ -2,19 +2,24 @@ package handler
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"worker/internal/httpresponse"
 	"worker/internal/middleware"
 	serviceMocks "worker/internal/mocks/service"
 	"worker/internal/model"
+	"worker/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mrheza/distributed-config-management/shared/signing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func setupRouter(h *Handler) *gin.Engine {
@@ -29,11 +34,11 @@ func setupRouter(h *Handler) *gin.Engine {
 
 func TestSetConfig_Success(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	body := `{"version":1,"url":"https://example.com","poll_interval_seconds":30}`
-	mockSvc.On("ApplyConfig", mock.AnythingOfType("*model.Config")).Return(nil).Once()
+	mockSvc.On("ApplyConfig", mock.AnythingOfType("*model.Config"), false).Return(nil).Once()
 
 	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
@@ -50,7 +55,7 @@ func TestSetConfig_Success(t *testing.T) {
 
 func TestSetConfig_ValidationError(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"url":"invalid"}`))
@@ -64,11 +69,11 @@ func TestSetConfig_ValidationError(t *testing.T) {
 
 func TestSetConfig_ServiceError(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	body := `{"version":1,"url":"https://example.com"}`
-	mockSvc.On("ApplyConfig", mock.AnythingOfType("*model.Config")).Return(errors.New("save failed")).Once()
+	mockSvc.On("ApplyConfig", mock.AnythingOfType("*model.Config"), false).Return(errors.New("save failed")).Once()
 
 	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
@@ -79,9 +84,123 @@ func TestSetConfig_ServiceError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, resp.Code)
 }
 
+func TestSetConfig_InvalidRequest(t *testing.T) {
+	mockSvc := new(serviceMocks.WorkerService)
+	h := New(mockSvc, nil)
+	r := setupRouter(h)
+
+	body := `{"version":1,"url":"https://example.com","data":{"request":{"method":"POST","headers":{"Authorization":"{{ .Secrets.token }}"}}}}`
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "worker-secret")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "INVALID_REQUEST")
+	mockSvc.AssertNotCalled(t, "ApplyConfig", mock.Anything, mock.Anything)
+}
+
+func TestSetConfig_StaleVersion(t *testing.T) {
+	mockSvc := new(serviceMocks.WorkerService)
+	h := New(mockSvc, nil)
+	r := setupRouter(h)
+
+	body := `{"version":1,"url":"https://example.com"}`
+	mockSvc.On("ApplyConfig", mock.AnythingOfType("*model.Config"), false).
+		Return(&service.StaleVersionError{Current: 3, Received: 1}).Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "worker-secret")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	var out httpresponse.VersionConflictResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &out))
+	assert.Equal(t, "STALE_VERSION", out.Error.Code)
+	assert.Equal(t, 3, out.CurrentVersion)
+}
+
+func TestSetConfig_Force(t *testing.T) {
+	mockSvc := new(serviceMocks.WorkerService)
+	h := New(mockSvc, nil)
+	r := setupRouter(h)
+
+	body := `{"version":1,"url":"https://example.com"}`
+	mockSvc.On("ApplyConfig", mock.AnythingOfType("*model.Config"), true).Return(nil).Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/config?force=true", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "worker-secret")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestSetConfig_Signature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sig, err := signing.Sign(priv, signing.Payload{
+		Version:             2,
+		Namespace:           "prod",
+		URL:                 "https://example.com",
+		PollIntervalSeconds: 30,
+		Data:                json.RawMessage(`{"retries":3}`),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "valid",
+			body:       `{"version":2,"namespace":"prod","url":"https://example.com","poll_interval_seconds":30,"data":{"retries": 3},"signature":"` + sig + `"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing",
+			body:       `{"version":2,"namespace":"prod","url":"https://example.com","poll_interval_seconds":30,"data":{"retries":3}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "tampered",
+			body:       `{"version":2,"namespace":"prod","url":"https://evil.example.com","poll_interval_seconds":30,"data":{"retries":3},"signature":"` + sig + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(serviceMocks.WorkerService)
+			r := setupRouter(New(mockSvc, pub))
+			if tt.wantStatus == http.StatusOK {
+				mockSvc.On("ApplyConfig", mock.AnythingOfType("*model.Config"), false).Return(nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", "worker-secret")
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.wantStatus, resp.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.Contains(t, resp.Body.String(), "INVALID_SIGNATURE")
+				mockSvc.AssertNotCalled(t, "ApplyConfig", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
 func TestSetConfig_Unauthorized(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(`{"version":1,"url":"https://example.com"}`))
@@ -90,12 +209,12 @@ func TestSetConfig_Unauthorized(t *testing.T) {
 	r.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusUnauthorized, resp.Code)
-	mockSvc.AssertNotCalled(t, "ApplyConfig", mock.Anything)
+	mockSvc.AssertNotCalled(t, "ApplyConfig", mock.Anything, mock.Anything)
 }
 
 func TestHit_Success(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	mockSvc.On("Hit", mock.Anything).Return(200, "text/plain", []byte("1.2.3.4"), nil).Once()
@@ -110,7 +229,7 @@ func TestHit_Success(t *testing.T) {
 
 func TestHit_Error(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	mockSvc.On("Hit", mock.Anything).Return(0, "", nil, errors.New("upstream error")).Once()
@@ -122,9 +241,24 @@ func TestHit_Error(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, resp.Code)
 }
 
+func TestHit_UnexpectedUpstreamStatus(t *testing.T) {
+	mockSvc := new(serviceMocks.WorkerService)
+	h := New(mockSvc, nil)
+	r := setupRouter(h)
+
+	mockSvc.On("Hit", mock.Anything).Return(0, "", nil, &service.UnexpectedStatusError{Status: 500, Expected: []int{200}}).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/hit", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadGateway, resp.Code)
+	assert.Contains(t, resp.Body.String(), "UNEXPECTED_UPSTREAM_STATUS")
+}
+
 func TestHit_DefaultStatusAndContentType(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	mockSvc.On("Hit", mock.Anything).Return(0, "", []byte("raw"), nil).Once()
@@ -140,7 +274,7 @@ func TestHit_DefaultStatusAndContentType(t *testing.T) {
 
 func TestGetState_Success(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	expected := &model.Config{Version: 2, URL: "https://example.com", PollIntervalSeconds: 30}
@@ -157,9 +291,71 @@ func TestGetState_Success(t *testing.T) {
 	assert.Equal(t, *expected, out)
 }
 
+func TestGetState_RedactsSecrets(t *testing.T) {
+	mockSvc := new(serviceMocks.WorkerService)
+	h := New(mockSvc, nil)
+	r := setupRouter(h)
+
+	stored := &model.Config{Version: 2, URL: "https://example.com", Secrets: map[string]string{"db_password": "hunter2"}, Signature: "sig"}
+	mockSvc.On("GetCurrentConfig").Return(stored, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/state", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotContains(t, resp.Body.String(), "hunter2")
+	var out model.Config
+	err := json.Unmarshal(resp.Body.Bytes(), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"db_password": "[REDACTED]"}, out.Secrets)
+	assert.Empty(t, out.Signature)
+	assert.Equal(t, "hunter2", stored.Secrets["db_password"])
+}
+
+func TestSetConfig_KeepsData(t *testing.T) {
+	mockSvc := new(serviceMocks.WorkerService)
+	h := New(mockSvc, nil)
+	r := setupRouter(h)
+
+	body := `{"version":1,"url":"https://example.com","poll_interval_seconds":30,"data":{"region":"eu","weights":[1,2]}}`
+	mockSvc.On("ApplyConfig", mock.MatchedBy(func(cfg *model.Config) bool {
+		var data map[string]interface{}
+		return json.Unmarshal(cfg.Data, &data) == nil && data["region"] == "eu"
+	}), false).Return(nil).Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "worker-secret")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestGetState_ExposesData(t *testing.T) {
+	mockSvc := new(serviceMocks.WorkerService)
+	h := New(mockSvc, nil)
+	r := setupRouter(h)
+
+	expected := &model.Config{Version: 2, URL: "https://example.com", Data: json.RawMessage(`{"region":"eu"}`)}
+	mockSvc.On("GetCurrentConfig").Return(expected, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/state", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var out map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"region": "eu"}, out["data"])
+}
+
 func TestGetState_NotFound(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	mockSvc.On("GetCurrentConfig").Return((*model.Config)(nil), sql.ErrNoRows).Once()
@@ -173,7 +369,7 @@ func TestGetState_NotFound(t *testing.T) {
 
 func TestGetState_InternalError(t *testing.T) {
 	mockSvc := new(serviceMocks.WorkerService)
-	h := New(mockSvc)
+	h := New(mockSvc, nil)
 	r := setupRouter(h)
 
 	mockSvc.On("GetCurrentConfig").Return((*model.Config)(nil), errors.New("db down")).Once()
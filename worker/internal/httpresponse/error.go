@@ -30,6 +30,13 @@ type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
 }
 
+// VersionConflictResponse reports the version the worker has applied when an
+// older one is rejected.
+type VersionConflictResponse struct {
+	Error          ErrorDetail `json:"error"`
+	CurrentVersion int         `json:"current_version" example:"42"`
+}
+
 type ValidationErrorResponse struct {
 	Error ValidationErrorDetail `json:"error"`
 }
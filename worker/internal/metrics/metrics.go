@@ -0,0 +1,37 @@
+// Package metrics holds the worker's Prometheus metrics. They are registered
+// with the default registry served on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Hit results.
+const (
+	HitOK               = "ok"
+	HitNoConfig         = "no_config"
+	HitUnexpectedStatus = "unexpected_status"
+	HitError            = "error"
+)
+
+// UpstreamError labels upstream requests that got no response at all.
+const UpstreamError = "error"
+
+var (
+	Hits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_hits_total",
+		Help: "Hit requests by result.",
+	}, []string{"result"})
+
+	UpstreamDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "worker_upstream_request_duration_seconds",
+		Help:    "Latency of requests to the configured upstream URL.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	UpstreamResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_upstream_responses_total",
+		Help: "Upstream responses by status code, or error when the request failed.",
+	}, []string{"code"})
+)
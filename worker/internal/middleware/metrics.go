@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	sharedmiddleware "github.com/mrheza/distributed-config-management/shared/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Metrics() gin.HandlerFunc {
+	return sharedmiddleware.Metrics()
+}
+
+func MetricsHandler() gin.HandlerFunc {
+	return sharedmiddleware.MetricsHandler()
+}
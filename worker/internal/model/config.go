@@ -1,7 +1,32 @@
 package model
 
+import (
+	"encoding/json"
+
+	"github.com/mrheza/distributed-config-management/shared/signing"
+)
+
+// Config holds the fields the worker acts on. Data carries the rest of the
+// controller document untouched so it can be inspected via /state; Secrets
+// are kept for the worker's own use and redacted there.
 type Config struct {
-	Version             int    `json:"version"`
-	URL                 string `json:"url" binding:"required,url"`
-	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+	Version             int               `json:"version"`
+	Namespace           string            `json:"namespace,omitempty"`
+	URL                 string            `json:"url" binding:"required,url"`
+	PollIntervalSeconds int               `json:"poll_interval_seconds"`
+	Data                json.RawMessage   `json:"data,omitempty" swaggertype:"object"`
+	Secrets             map[string]string `json:"secrets,omitempty"`
+	Signature           string            `json:"signature,omitempty"`
+}
+
+// SigningPayload returns the fields covered by the controller's signature.
+func (c *Config) SigningPayload() signing.Payload {
+	return signing.Payload{
+		Version:             c.Version,
+		Namespace:           c.Namespace,
+		URL:                 c.URL,
+		PollIntervalSeconds: c.PollIntervalSeconds,
+		Data:                c.Data,
+		Secrets:             c.Secrets,
+	}
 }
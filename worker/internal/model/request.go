@@ -0,0 +1,169 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+)
+
+// RequestSpec describes the upstream request of /hit. It is read from the
+// "request" object of the config data, so it is signed and forwarded like the
+// rest of the document. Header values, query values and the body are
+// text/template templates over RequestTemplateData.
+type RequestSpec struct {
+	Method  string            `json:"method,omitempty" example:"POST"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	// ExpectedStatus lists the upstream status codes /hit accepts; empty
+	// accepts any.
+	ExpectedStatus []int `json:"expected_status,omitempty"`
+}
+
+// RequestTemplateData is the data the request templates are executed with,
+// e.g. {{ .Secrets.api_token }} or {{ json .Data.region }}.
+type RequestTemplateData struct {
+	Version   int
+	Namespace string
+	Data      map[string]interface{}
+	Secrets   map[string]string
+}
+
+// UpstreamRequest is a rendered RequestSpec, ready to be sent.
+type UpstreamRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    []byte
+}
+
+var requestMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// templateFuncs are available in request templates; json encodes a value, so
+// secrets and data can be placed in JSON bodies safely.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	},
+}
+
+// RequestSpec returns the request described by the config data. Without a
+// "request" object it is a plain GET accepting any status.
+func (c *Config) RequestSpec() (*RequestSpec, error) {
+	var doc struct {
+		Request *RequestSpec `json:"request"`
+	}
+	if len(c.Data) > 0 {
+		if err := json.Unmarshal(c.Data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+	}
+
+	spec := doc.Request
+	if spec == nil {
+		spec = &RequestSpec{}
+	}
+	if spec.Method == "" {
+		spec.Method = http.MethodGet
+	}
+	if !requestMethods[spec.Method] {
+		return nil, fmt.Errorf("invalid request method %q: must be one of GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS", spec.Method)
+	}
+	for _, status := range spec.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid expected status %d: must be between 100 and 599", status)
+		}
+	}
+	return spec, nil
+}
+
+// Expects reports whether the upstream answering status completes the task.
+func (s *RequestSpec) Expects(status int) bool {
+	if len(s.ExpectedStatus) == 0 {
+		return true
+	}
+	for _, expected := range s.ExpectedStatus {
+		if status == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// UpstreamRequest renders the request of the config. Templates referring to
+// missing data or secrets are an error rather than an empty value.
+func (c *Config) UpstreamRequest() (*UpstreamRequest, *RequestSpec, error) {
+	spec, err := c.RequestSpec()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := RequestTemplateData{Version: c.Version, Namespace: c.Namespace, Secrets: c.Secrets}
+	if len(c.Data) > 0 {
+		if err := json.Unmarshal(c.Data, &data.Data); err != nil {
+			return nil, nil, fmt.Errorf("invalid request: %w", err)
+		}
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(spec.Query) > 0 {
+		query := u.Query()
+		for name, value := range spec.Query {
+			rendered, err := render("query "+name, value, data)
+			if err != nil {
+				return nil, nil, err
+			}
+			query.Set(name, rendered)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	req := &UpstreamRequest{Method: spec.Method, URL: u.String()}
+	if len(spec.Headers) > 0 {
+		req.Headers = make(map[string]string, len(spec.Headers))
+		for name, value := range spec.Headers {
+			rendered, err := render("header "+name, value, data)
+			if err != nil {
+				return nil, nil, err
+			}
+			req.Headers[name] = rendered
+		}
+	}
+	if spec.Body != "" {
+		body, err := render("body", spec.Body, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Body = []byte(body)
+	}
+
+	return req, spec, nil
+}
+
+func render(name, text string, data RequestTemplateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid request %s: %w", name, err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("render request %s: %w", name, err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_UpstreamRequest_Default(t *testing.T) {
+	cfg := &Config{URL: "https://example.com/ip", Data: json.RawMessage(`{"region":"eu"}`)}
+
+	req, spec, err := cfg.UpstreamRequest()
+	require.NoError(t, err)
+	assert.Equal(t, &UpstreamRequest{Method: http.MethodGet, URL: "https://example.com/ip"}, req)
+	assert.True(t, spec.Expects(http.StatusInternalServerError))
+}
+
+func TestConfig_UpstreamRequest_Renders(t *testing.T) {
+	cfg := &Config{
+		Version:   4,
+		Namespace: "prod",
+		URL:       "https://example.com/jobs?source=worker",
+		Data: json.RawMessage(`{
+			"region": "eu",
+			"request": {
+				"method": "POST",
+				"headers": {"Authorization": "Bearer {{ .Secrets.api_token }}", "Content-Type": "application/json"},
+				"query": {"region": "{{ .Data.region }}"},
+				"body": "{\"version\": {{ .Version }}, \"namespace\": {{ json .Namespace }}}",
+				"expected_status": [200, 202]
+			}
+		}`),
+		Secrets: map[string]string{"api_token": "s3cret"},
+	}
+
+	req, spec, err := cfg.UpstreamRequest()
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "https://example.com/jobs?region=eu&source=worker", req.URL)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer s3cret", "Content-Type": "application/json"}, req.Headers)
+	assert.JSONEq(t, `{"version":4,"namespace":"prod"}`, string(req.Body))
+	assert.True(t, spec.Expects(http.StatusAccepted))
+	assert.False(t, spec.Expects(http.StatusCreated))
+}
+
+func TestConfig_UpstreamRequest_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "method", data: `{"request":{"method":"FETCH"}}`, wantErr: "invalid request method"},
+		{name: "expected status", data: `{"request":{"expected_status":[700]}}`, wantErr: "invalid expected status 700"},
+		{name: "template syntax", data: `{"request":{"body":"{{ .Version"}}`, wantErr: "invalid request body"},
+		{name: "missing secret", data: `{"request":{"headers":{"Authorization":"{{ .Secrets.token }}"}}}`, wantErr: "render request header Authorization"},
+		{name: "not an object", data: `{"request":"POST"}`, wantErr: "invalid request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{URL: "https://example.com", Data: json.RawMessage(tt.data)}
+
+			_, _, err := cfg.UpstreamRequest()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
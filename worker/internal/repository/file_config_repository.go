@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"worker/internal/model"
+
+	"github.com/mrheza/distributed-config-management/shared/fileutil"
+)
+
+// FileConfigRepository keeps the config in memory and persists every change to
+// a JSON file, so a restarted worker serves the last applied config right
+// away. The file holds secrets in clear and is only readable by its owner.
+type FileConfigRepository struct {
+	mu     sync.Mutex
+	path   string
+	memory *MemoryConfigRepository
+}
+
+// NewFileConfigRepository loads the config stored at path, if any. A file that
+// cannot be parsed is an error rather than an empty store, so a corrupt file
+// is noticed instead of silently overwritten.
+func NewFileConfigRepository(path string) (*FileConfigRepository, error) {
+	r := &FileConfigRepository{path: path, memory: NewMemoryConfigRepository()}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return r, nil
+		}
+		return nil, err
+	}
+
+	var cfg model.Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config store %s: %w", path, err)
+	}
+	if err := r.memory.Set(&cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *FileConfigRepository) Get() (*model.Config, error) {
+	return r.memory.Get()
+}
+
+// Set writes cfg to disk before serving it; a nil cfg removes the file.
+func (r *FileConfigRepository) Set(cfg *model.Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cfg == nil {
+		if err := os.Remove(r.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return r.memory.Set(nil)
+	}
+
+	raw, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteAtomic(r.path, raw); err != nil {
+		return err
+	}
+	return r.memory.Set(cfg)
+}
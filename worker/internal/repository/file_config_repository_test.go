@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"worker/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileConfigRepository_MissingFile(t *testing.T) {
+	repo, err := NewFileConfigRepository(filepath.Join(t.TempDir(), "config.json"))
+	require.NoError(t, err)
+
+	cfg, err := repo.Get()
+	assert.Nil(t, cfg)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestFileConfigRepository_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "config.json")
+
+	repo, err := NewFileConfigRepository(path)
+	require.NoError(t, err)
+	require.NoError(t, repo.Set(&model.Config{
+		Version: 3,
+		URL:     "https://example.com",
+		Data:    json.RawMessage(`{"retries":3}`),
+		Secrets: map[string]string{"token": "s3cret"},
+	}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	reopened, err := NewFileConfigRepository(path)
+	require.NoError(t, err)
+
+	cfg, err := reopened.Get()
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.Version)
+	assert.Equal(t, "https://example.com", cfg.URL)
+	assert.JSONEq(t, `{"retries":3}`, string(cfg.Data))
+	assert.Equal(t, "s3cret", cfg.Secrets["token"])
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must not be left behind")
+}
+
+func TestFileConfigRepository_SetNilRemovesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	repo, err := NewFileConfigRepository(path)
+	require.NoError(t, err)
+	require.NoError(t, repo.Set(&model.Config{Version: 1, URL: "https://example.com"}))
+	require.NoError(t, repo.Set(nil))
+
+	_, err = os.Stat(path)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = repo.Get()
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestFileConfigRepository_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	repo, err := NewFileConfigRepository(path)
+	assert.Nil(t, repo)
+	assert.Error(t, err)
+}
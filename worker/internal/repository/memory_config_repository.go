@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"sync"
 	"worker/internal/model"
 )
@@ -23,8 +24,7 @@ func (r *MemoryConfigRepository) Get() (*model.Config, error) {
 		return nil, sql.ErrNoRows
 	}
 
-	c := *r.config
-	return &c, nil
+	return cloneConfig(r.config), nil
 }
 
 func (r *MemoryConfigRepository) Set(cfg *model.Config) error {
@@ -36,7 +36,20 @@ func (r *MemoryConfigRepository) Set(cfg *model.Config) error {
 		return nil
 	}
 
-	c := *cfg
-	r.config = &c
+	r.config = cloneConfig(cfg)
 	return nil
 }
+
+func cloneConfig(cfg *model.Config) *model.Config {
+	c := *cfg
+	if cfg.Data != nil {
+		c.Data = append(json.RawMessage(nil), cfg.Data...)
+	}
+	if cfg.Secrets != nil {
+		c.Secrets = make(map[string]string, len(cfg.Secrets))
+		for name, value := range cfg.Secrets {
+			c.Secrets[name] = value
+		}
+	}
+	return &c
+}
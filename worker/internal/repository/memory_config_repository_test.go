@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"testing"
 	"worker/internal/model"
@@ -41,3 +42,32 @@ func TestMemoryConfigRepository_Get_ReturnsClone(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "https://example.com", cfg2.URL)
 }
+
+func TestMemoryConfigRepository_Get_ClonesData(t *testing.T) {
+	repo := NewMemoryConfigRepository()
+	_ = repo.Set(&model.Config{Version: 1, URL: "https://example.com", Data: json.RawMessage(`{"a":1}`)})
+
+	cfg, err := repo.Get()
+	assert.NoError(t, err)
+	cfg.Data[2] = 'b'
+
+	cfg2, err := repo.Get()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":1}`, string(cfg2.Data))
+}
+
+func TestMemoryConfigRepository_ClonesSecrets(t *testing.T) {
+	repo := NewMemoryConfigRepository()
+	secrets := map[string]string{"token": "s3cret"}
+	_ = repo.Set(&model.Config{Version: 1, URL: "https://example.com", Secrets: secrets})
+	secrets["token"] = "changed-by-caller"
+
+	cfg, err := repo.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.Secrets["token"])
+	cfg.Secrets["token"] = "changed-by-reader"
+
+	cfg2, err := repo.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg2.Secrets["token"])
+}
@@ -2,18 +2,60 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
 	"worker/internal/client"
+	"worker/internal/metrics"
 	"worker/internal/model"
 	"worker/internal/repository"
+
+	"github.com/mrheza/distributed-config-management/shared/tracing"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
+var tracer = otel.Tracer("worker/internal/service")
+
 type WorkerService interface {
-	ApplyConfig(cfg *model.Config) error
+	// ApplyConfig stores cfg unless it is older than the applied config; force
+	// allows an intentional downgrade.
+	ApplyConfig(cfg *model.Config, force bool) error
 	Hit(ctx context.Context) (status int, contentType string, body []byte, err error)
 	GetCurrentConfig() (*model.Config, error)
+	// CheckConfig fails until a config has been applied.
+	CheckConfig(ctx context.Context) error
+}
+
+// StaleVersionError is returned by ApplyConfig when cfg is older than the
+// applied config, e.g. for a delayed retry.
+type StaleVersionError struct {
+	Current  int
+	Received int
 }
 
+func (e *StaleVersionError) Error() string {
+	return fmt.Sprintf("config version %d is older than applied version %d", e.Received, e.Current)
+}
+
+// UnexpectedStatusError is returned by Hit when the upstream answers with a
+// status the config does not expect.
+type UnexpectedStatusError struct {
+	Status   int
+	Expected []int
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("upstream answered with status %d, expected one of %v", e.Status, e.Expected)
+}
+
+// ErrNoConfig is returned by CheckConfig before the agent applied a config.
+var ErrNoConfig = errors.New("no config applied yet")
+
 type workerService struct {
+	// mu makes the version check and the write of ApplyConfig atomic.
+	mu    sync.Mutex
 	repo  repository.ConfigRepository
 	fetch client.FetchClient
 }
@@ -22,19 +64,70 @@ func NewWorkerService(repo repository.ConfigRepository, fetch client.FetchClient
 	return &workerService{repo: repo, fetch: fetch}
 }
 
-func (s *workerService) ApplyConfig(cfg *model.Config) error {
+func (s *workerService) ApplyConfig(cfg *model.Config, force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !force {
+		current, err := s.repo.Get()
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		// The same version is accepted again, as the agent re-sends it after
+		// restarts.
+		if current != nil && cfg.Version < current.Version {
+			return &StaleVersionError{Current: current.Version, Received: cfg.Version}
+		}
+	}
+
 	return s.repo.Set(cfg)
 }
 
-func (s *workerService) Hit(ctx context.Context) (int, string, []byte, error) {
+func (s *workerService) Hit(ctx context.Context) (status int, contentType string, body []byte, err error) {
+	ctx, span := tracer.Start(ctx, "worker.hit")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	cfg, err := s.repo.Get()
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			metrics.Hits.WithLabelValues(metrics.HitNoConfig).Inc()
+		} else {
+			metrics.Hits.WithLabelValues(metrics.HitError).Inc()
+		}
 		return 0, "", nil, err
 	}
 
-	return s.fetch.Get(ctx, cfg.URL)
+	req, spec, err := cfg.UpstreamRequest()
+	if err != nil {
+		metrics.Hits.WithLabelValues(metrics.HitError).Inc()
+		return 0, "", nil, err
+	}
+	span.SetAttributes(semconv.HTTPRequestMethodKey.String(req.Method))
+
+	status, contentType, body, err = s.fetch.Do(ctx, req)
+	if err != nil {
+		metrics.Hits.WithLabelValues(metrics.HitError).Inc()
+		return 0, "", nil, err
+	}
+	if !spec.Expects(status) {
+		metrics.Hits.WithLabelValues(metrics.HitUnexpectedStatus).Inc()
+		return 0, "", nil, &UnexpectedStatusError{Status: status, Expected: spec.ExpectedStatus}
+	}
+	metrics.Hits.WithLabelValues(metrics.HitOK).Inc()
+	return status, contentType, body, nil
 }
 
 func (s *workerService) GetCurrentConfig() (*model.Config, error) {
 	return s.repo.Get()
 }
+
+func (s *workerService) CheckConfig(ctx context.Context) error {
+	_, err := s.repo.Get()
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoConfig
+	}
+	return err
+}
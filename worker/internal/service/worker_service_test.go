@@ -3,13 +3,19 @@ package service
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"testing"
+	"worker/internal/metrics"
 	clientMocks "worker/internal/mocks/client"
 	repositoryMocks "worker/internal/mocks/repository"
 	"worker/internal/model"
+	"worker/internal/repository"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWorkerService_ApplyConfig(t *testing.T) {
@@ -18,13 +24,50 @@ func TestWorkerService_ApplyConfig(t *testing.T) {
 	svc := NewWorkerService(repo, fetch)
 
 	cfg := &model.Config{Version: 1, URL: "https://example.com"}
+	repo.On("Get").Return((*model.Config)(nil), sql.ErrNoRows).Once()
 	repo.On("Set", cfg).Return(nil).Once()
 
-	err := svc.ApplyConfig(cfg)
+	err := svc.ApplyConfig(cfg, false)
 	assert.NoError(t, err)
 	repo.AssertExpectations(t)
 }
 
+func TestWorkerService_ApplyConfig_Versions(t *testing.T) {
+	tests := []struct {
+		name     string
+		received int
+		force    bool
+		wantSet  bool
+	}{
+		{name: "newer", received: 4, wantSet: true},
+		{name: "same", received: 3, wantSet: true},
+		{name: "older", received: 2},
+		{name: "older forced", received: 2, force: true, wantSet: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewMemoryConfigRepository()
+			require.NoError(t, repo.Set(&model.Config{Version: 3, URL: "https://example.com/v3"}))
+			svc := NewWorkerService(repo, nil)
+
+			err := svc.ApplyConfig(&model.Config{Version: tt.received, URL: "https://example.com"}, tt.force)
+
+			stored, getErr := repo.Get()
+			require.NoError(t, getErr)
+			if tt.wantSet {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.received, stored.Version)
+				return
+			}
+			var stale *StaleVersionError
+			require.ErrorAs(t, err, &stale)
+			assert.Equal(t, 3, stale.Current)
+			assert.Equal(t, tt.received, stale.Received)
+			assert.Equal(t, 3, stored.Version)
+		})
+	}
+}
+
 func TestWorkerService_Hit_NoConfig(t *testing.T) {
 	repo := new(repositoryMocks.ConfigRepository)
 	fetch := new(clientMocks.FetchClient)
@@ -44,7 +87,7 @@ func TestWorkerService_Hit_Success(t *testing.T) {
 
 	cfg := &model.Config{Version: 1, URL: "https://example.com"}
 	repo.On("Get").Return(cfg, nil).Once()
-	fetch.On("Get", context.Background(), cfg.URL).Return(200, "text/plain", []byte("ok"), nil).Once()
+	fetch.On("Do", mock.Anything, &model.UpstreamRequest{Method: "GET", URL: cfg.URL}).Return(200, "text/plain", []byte("ok"), nil).Once()
 
 	status, ct, body, err := svc.Hit(context.Background())
 	assert.NoError(t, err)
@@ -53,6 +96,40 @@ func TestWorkerService_Hit_Success(t *testing.T) {
 	assert.Equal(t, []byte("ok"), body)
 }
 
+func TestWorkerService_Hit_ConfiguredRequest(t *testing.T) {
+	repo := new(repositoryMocks.ConfigRepository)
+	fetch := new(clientMocks.FetchClient)
+	svc := NewWorkerService(repo, fetch)
+
+	cfg := &model.Config{
+		Version: 1,
+		URL:     "https://example.com/jobs",
+		Data:    json.RawMessage(`{"request":{"method":"POST","headers":{"X-Token":"{{ .Secrets.token }}"},"body":"run","expected_status":[202]}}`),
+		Secrets: map[string]string{"token": "s3cret"},
+	}
+	repo.On("Get").Return(cfg, nil)
+	fetch.On("Do", mock.Anything, &model.UpstreamRequest{
+		Method:  "POST",
+		URL:     cfg.URL,
+		Headers: map[string]string{"X-Token": "s3cret"},
+		Body:    []byte("run"),
+	}).Return(202, "text/plain", []byte("queued"), nil).Once()
+
+	status, _, body, err := svc.Hit(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 202, status)
+	assert.Equal(t, []byte("queued"), body)
+
+	fetch.On("Do", mock.Anything, mock.Anything).Return(500, "text/plain", []byte("boom"), nil).Once()
+	before := testutil.ToFloat64(metrics.Hits.WithLabelValues(metrics.HitUnexpectedStatus))
+
+	_, _, _, err = svc.Hit(context.Background())
+	var unexpected *UnexpectedStatusError
+	require.ErrorAs(t, err, &unexpected)
+	assert.Equal(t, 500, unexpected.Status)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.Hits.WithLabelValues(metrics.HitUnexpectedStatus)))
+}
+
 func TestWorkerService_GetCurrentConfig(t *testing.T) {
 	repo := new(repositoryMocks.ConfigRepository)
 	fetch := new(clientMocks.FetchClient)
@@ -65,3 +142,14 @@ func TestWorkerService_GetCurrentConfig(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, cfg, result)
 }
+
+func TestWorkerService_CheckConfig(t *testing.T) {
+	repo := new(repositoryMocks.ConfigRepository)
+	svc := NewWorkerService(repo, nil)
+
+	repo.On("Get").Return((*model.Config)(nil), sql.ErrNoRows).Once()
+	assert.ErrorIs(t, svc.CheckConfig(context.Background()), ErrNoConfig)
+
+	repo.On("Get").Return(&model.Config{Version: 1}, nil).Once()
+	assert.NoError(t, svc.CheckConfig(context.Background()))
+}